@@ -4,10 +4,16 @@ package errors
 import "github.com/heroiclabs/nakama-common/runtime"
 
 // gRPC status codes.
+// Clients branch on the code: not-found, already-owned/claimed and insufficient funds each get
+// their own code so they never need to match on the message.
 const (
-	CodeInternal   = 13 // codes.Internal
-	CodeInvalidArg = 3  // codes.InvalidArgument
-	CodeForbidden  = 7  // codes.PermissionDenied
+	CodeInternal           = 13 // codes.Internal
+	CodeInvalidArg         = 3  // codes.InvalidArgument
+	CodeNotFound           = 5  // codes.NotFound
+	CodeAlreadyExists      = 6  // codes.AlreadyExists
+	CodeForbidden          = 7  // codes.PermissionDenied
+	CodeResourceExhausted  = 8  // codes.ResourceExhausted
+	CodeFailedPrecondition = 9  // codes.FailedPrecondition
 )
 
 // Unified error definitions
@@ -29,6 +35,7 @@ var (
 	ErrCouldNotWriteStorage   = runtime.NewError("could not write storage", CodeInternal)
 	ErrCouldNotUnmarshal      = runtime.NewError("could not unmarshal storage data", CodeInternal)
 	ErrCouldNotUpdateWallet   = runtime.NewError("could not update wallet", CodeInternal)
+	ErrCouldNotUpdateAccount  = runtime.NewError("could not update user account", CodeInternal)
 
 	ErrEquipmentUnavailable   = runtime.NewError("equipment system unavailable", CodeInternal)
 	ErrInventoryUnavailable   = runtime.NewError("inventory system unavailable", CodeInternal)
@@ -40,14 +47,11 @@ var (
 	ErrInvalidInput            = runtime.NewError("invalid request", CodeInvalidArg)
 	ErrNotOwned                = runtime.NewError("item not owned", CodeInvalidArg)
 	ErrInvalidItemID           = runtime.NewError("invalid item ID", CodeInvalidArg)
-	ErrItemNotFound            = runtime.NewError("item not found", CodeInvalidArg)
 	ErrInvalidAbility          = runtime.NewError("invalid ability for item", CodeInvalidArg)
 	ErrInvalidAbilityPet       = runtime.NewError("invalid ability for pet", CodeInvalidArg)
 	ErrInvalidAbilityClass     = runtime.NewError("invalid ability for class", CodeInvalidArg)
 	ErrNoAbilitiesAvailable    = runtime.NewError("no abilities available", CodeInvalidArg)
-	ErrAbilityNotFound         = runtime.NewError("ability not found", CodeInvalidArg)
 	ErrAbilityNotUnlocked      = runtime.NewError("ability not unlocked", CodeInvalidArg)
-	ErrInsufficientPetTreats   = runtime.NewError("insufficient pet treats", CodeInvalidArg)
 	ErrInvalidExperience       = runtime.NewError("invalid experience amount", CodeInvalidArg)
 	ErrInvalidItemType         = runtime.NewError("invalid item type for experience", CodeInvalidArg)
 	ErrCouldNotEquipAbility    = runtime.NewError("couldn't equip ability", CodeInvalidArg)
@@ -55,26 +59,80 @@ var (
 	ErrCouldNotEquipClass      = runtime.NewError("couldn't equip class", CodeInvalidArg)
 	ErrCouldNotEquipBackground = runtime.NewError("couldn't equip background", CodeInvalidArg)
 	ErrCouldNotEquipStyle      = runtime.NewError("couldn't equip style", CodeInvalidArg)
+	ErrCouldNotEquipSprite     = runtime.NewError("couldn't equip sprite", CodeInvalidArg)
+	ErrInvalidSprite           = runtime.NewError("invalid sprite for item", CodeInvalidArg)
+	ErrSpriteNotUnlocked       = runtime.NewError("sprite not unlocked", CodeInvalidArg)
 	ErrInvalidPetID            = runtime.NewError("invalid pet ID", CodeInvalidArg)
 	ErrInvalidLevelThresholds  = runtime.NewError("invalid level thresholds", CodeInvalidArg)
-	ErrLootboxAlreadyOpened    = runtime.NewError("lootbox already opened", CodeInvalidArg)
-	ErrRewardAlreadyClaimed    = runtime.NewError("reward already claimed or unavailable", CodeInvalidArg)
+	ErrQuestIncomplete         = runtime.NewError("quest not complete", CodeInvalidArg)
+	ErrProofUnavailable        = runtime.NewError("no fairness proof for this lootbox", CodeInvalidArg)
+	ErrNotMaxLevel             = runtime.NewError("item is not at max level", CodeInvalidArg)
+	ErrScoreOutOfBounds        = runtime.NewError("score out of bounds", CodeInvalidArg)
 
 	// Social errors (code 3 → HTTP 400 → non-retryable)
 	ErrInvalidInviteTarget = runtime.NewError("invite target user not found", CodeInvalidArg)
 	ErrInviteMissingMatch  = runtime.NewError("match_id required for game invite", CodeInvalidArg)
 
+	// Tournament errors (code 3)
+	ErrTournamentNotConfigured = runtime.NewError("no tournament configured", CodeInvalidArg)
+	ErrTournamentFull          = runtime.NewError("tournament is full", CodeInvalidArg)
+	ErrTournamentClosed        = runtime.NewError("tournament is not open", CodeInvalidArg)
+
+	ErrTournamentNoCompletedSession = runtime.NewError("no completed tournament session", CodeInvalidArg)
+
 	// Match validation errors (code 3 → HTTP 400 → client does NOT retry)
 	// Using CodeInvalidArg instead of fmt.Errorf so the SDK treats these as non-retryable.
 	ErrMatchTooShort     = runtime.NewError("match duration too short", CodeInvalidArg)
-	ErrNoActiveMatch     = runtime.NewError("no active match found", CodeInvalidArg)
 	ErrMatchIDMismatch   = runtime.NewError("match ID mismatch", CodeInvalidArg)
 	ErrStaleMatchExpired = runtime.NewError("stale active match expired", CodeInvalidArg)
+	ErrIllegalLoadout    = runtime.NewError("equipped abilities break loadout rules", CodeInvalidArg)
+	ErrMatchRefereed     = runtime.NewError("match is settled by the server", CodeInvalidArg)
+
+	// Referral errors (code 3)
+	ErrSelfReferral        = runtime.NewError("cannot redeem your own referral code", CodeInvalidArg)
+	ErrReferralNotEligible = runtime.NewError("account not eligible to redeem a referral", CodeInvalidArg)
+
+	// Not found errors (code 5 → HTTP 404 → non-retryable)
+	ErrItemNotFound        = runtime.NewError("item not found", CodeNotFound)
+	ErrAbilityNotFound     = runtime.NewError("ability not found", CodeNotFound)
+	ErrQuestNotFound       = runtime.NewError("quest not found", CodeNotFound)
+	ErrInvalidShopItem     = runtime.NewError("invalid shop item", CodeNotFound)
+	ErrInvalidReferralCode = runtime.NewError("invalid referral code", CodeNotFound)
+	ErrNoActiveMatch       = runtime.NewError("no active match found", CodeNotFound)
+	ErrLoadoutNotFound     = runtime.NewError("loadout preset not found", CodeNotFound)
+	ErrReportMatchNotFound = runtime.NewError("no result for both players in this match", CodeNotFound)
+
+	// Already exists errors (code 6 → HTTP 409 → non-retryable)
+	ErrItemAlreadyOwned        = runtime.NewError("item already owned", CodeAlreadyExists)
+	ErrOfferAlreadyPurchased   = runtime.NewError("offer already purchased", CodeAlreadyExists)
+	ErrLootboxAlreadyOpened    = runtime.NewError("lootbox already opened", CodeAlreadyExists)
+	ErrRewardAlreadyClaimed    = runtime.NewError("reward already claimed or unavailable", CodeAlreadyExists)
+	ErrAlreadyReported         = runtime.NewError("player already reported for this match", CodeAlreadyExists)
+	ErrReferralAlreadyRedeemed = runtime.NewError("referral code already redeemed", CodeAlreadyExists)
+
+	// Insufficient funds errors (code 9 → HTTP 400 → non-retryable)
+	ErrInsufficientGems      = runtime.NewError("insufficient gems", CodeFailedPrecondition)
+	ErrInsufficientGold      = runtime.NewError("insufficient gold", CodeFailedPrecondition)
+	ErrInsufficientPetTreats = runtime.NewError("insufficient pet treats", CodeFailedPrecondition)
+	ErrNoXpBoost             = runtime.NewError("no xp boost available", CodeFailedPrecondition)
+	ErrOnboardingStepNotMet  = runtime.NewError("onboarding step not reached yet", CodeFailedPrecondition)
+	ErrInsufficientFunds     = runtime.NewError("insufficient funds", CodeFailedPrecondition)
+	ErrReferralsDisabled     = runtime.NewError("referrals not configured", CodeFailedPrecondition)
+
+	// Limit errors (code 8 → HTTP 429)
+	ErrReportLimitReached   = runtime.NewError("daily report limit reached", CodeResourceExhausted)
+	ErrRematchLimit         = runtime.NewError("too many recent matches against this opponent", CodeResourceExhausted)
+	ErrExportRateLimited    = runtime.NewError("data export already requested recently", CodeResourceExhausted)
+	ErrTooManyActiveMatches = runtime.NewError("too many active matches", CodeResourceExhausted)
+	ErrFavoritesFull        = runtime.NewError("favorites limit reached", CodeResourceExhausted)
+	ErrLoadoutPresetsFull   = runtime.NewError("loadout preset limit reached", CodeResourceExhausted)
+	ErrLootboxCooldown      = runtime.NewError("lootbox open cooldown active", CodeResourceExhausted)
 
 	// Forbidden errors (code 7)
 	ErrItemNotOwnedForbidden = runtime.NewError("item not owned", CodeForbidden)
 	ErrPetNotOwned           = runtime.NewError("pet not owned", CodeForbidden)
 	ErrClassNotOwned         = runtime.NewError("class not owned", CodeForbidden)
+	ErrAdminUnauthorized     = runtime.NewError("admin authorization failed", CodeForbidden)
 
 	// Transaction / commit errors (code 13)
 	ErrTransactionFailed = runtime.NewError("transaction failed", CodeInternal)
@@ -90,10 +148,9 @@ var (
 	// Shop validation errors (code 3)
 	ErrInvalidLootboxTier = runtime.NewError("invalid lootbox tier", CodeInvalidArg)
 	ErrTierNotPurchasable = runtime.NewError("tier cannot be purchased", CodeInvalidArg)
-	ErrInsufficientGems   = runtime.NewError("insufficient gems", CodeInvalidArg)
-	ErrInsufficientGold   = runtime.NewError("insufficient gold", CodeInvalidArg)
-	ErrItemAlreadyOwned   = runtime.NewError("item already owned", CodeInvalidArg)
 	ErrItemNotAvailable   = runtime.NewError("item not currently available", CodeInvalidArg)
-	ErrInvalidShopItem    = runtime.NewError("invalid shop item", CodeInvalidArg)
 	ErrWrongItemType      = runtime.NewError("wrong item type for RPC", CodeInvalidArg)
+	ErrOfferExpired       = runtime.NewError("offer no longer available", CodeInvalidArg)
+	ErrItemNotSellable    = runtime.NewError("item cannot be sold", CodeInvalidArg)
+	ErrItemEquipped       = runtime.NewError("item is equipped", CodeInvalidArg)
 )
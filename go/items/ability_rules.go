@@ -0,0 +1,96 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// AbilityRulesConfig is ability_rules in items.json: restrictions on the abilities a loadout may
+// carry into a match. Checked at match start, so a client can't enter with an illegal combination.
+type AbilityRulesConfig struct {
+	// Types assigns ability IDs to a type, e.g. "heal" or "shield".
+	Types map[uint32]string `json:"types"`
+	// UniqueTypes allows at most one equipped ability of each listed type.
+	UniqueTypes []string `json:"unique_types"`
+	// ForbiddenPairs lists ability IDs that can't be equipped together.
+	ForbiddenPairs [][2]uint32 `json:"forbidden_pairs"`
+}
+
+// Enabled reports whether any rule is configured.
+func (c *AbilityRulesConfig) Enabled() bool {
+	return len(c.UniqueTypes) > 0 || len(c.ForbiddenPairs) > 0
+}
+
+var abilityRules *AbilityRulesConfig
+
+func GetAbilityRulesConfig() *AbilityRulesConfig {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
+	if abilityRules == nil {
+		return &AbilityRulesConfig{}
+	}
+	return abilityRules
+}
+
+// Validate returns a description of the first rule the equipped abilities break, or "" if legal.
+func (c *AbilityRulesConfig) Validate(abilityIDs []uint32) string {
+	seenTypes := map[string]uint32{}
+	for _, id := range abilityIDs {
+		abilityType, ok := c.Types[id]
+		if !ok || !containsString(c.UniqueTypes, abilityType) {
+			continue
+		}
+		if other, dup := seenTypes[abilityType]; dup {
+			return fmt.Sprintf("abilities %d and %d are both %q", other, id, abilityType)
+		}
+		seenTypes[abilityType] = id
+	}
+
+	equipped := make(map[uint32]bool, len(abilityIDs))
+	for _, id := range abilityIDs {
+		equipped[id] = true
+	}
+	for _, pair := range c.ForbiddenPairs {
+		if equipped[pair[0]] && equipped[pair[1]] {
+			return fmt.Sprintf("abilities %d and %d can't be equipped together", pair[0], pair[1])
+		}
+	}
+	return ""
+}
+
+// AbilityLoadoutRulesResponse is the get_ability_loadout_rules payload, derived from ability_rules.
+type AbilityLoadoutRulesResponse struct {
+	Types             map[uint32]string `json:"types"`
+	MaxPerType        map[string]int    `json:"max_per_type"`
+	IncompatiblePairs [][2]uint32       `json:"incompatible_pairs"`
+}
+
+// RpcGetAbilityLoadoutRules returns the loadout restrictions enforced at match start,
+// so the client can block illegal selections up front.
+func RpcGetAbilityLoadoutRules(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	rules := GetAbilityRulesConfig()
+	resp := AbilityLoadoutRulesResponse{
+		Types:             map[uint32]string{},
+		MaxPerType:        map[string]int{},
+		IncompatiblePairs: [][2]uint32{},
+	}
+	for id, abilityType := range rules.Types {
+		resp.Types[id] = abilityType
+	}
+	for _, abilityType := range rules.UniqueTypes {
+		resp.MaxPerType[abilityType] = 1
+	}
+	resp.IncompatiblePairs = append(resp.IncompatiblePairs, rules.ForbiddenPairs...)
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,97 @@
+package items
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"block-server/errors"
+)
+
+func withAbilityRules(t *testing.T, rules AbilityRulesConfig) {
+	t.Helper()
+	gameDataMu.Lock()
+	original := abilityRules
+	abilityRules = &rules
+	gameDataMu.Unlock()
+	t.Cleanup(func() {
+		gameDataMu.Lock()
+		abilityRules = original
+		gameDataMu.Unlock()
+	})
+}
+
+func TestAbilityRulesValidate(t *testing.T) {
+	rules := AbilityRulesConfig{
+		Types:          map[uint32]string{1: "heal", 2: "heal", 3: "shield"},
+		UniqueTypes:    []string{"heal"},
+		ForbiddenPairs: [][2]uint32{{3, 4}},
+	}
+	cases := []struct {
+		ids   []uint32
+		legal bool
+	}{
+		{[]uint32{1, 3}, true},
+		{[]uint32{1, 2}, false},
+		{[]uint32{3, 4}, false},
+		{[]uint32{4, 3}, false},
+		{[]uint32{2, 5}, true},
+	}
+	for _, c := range cases {
+		if reason := rules.Validate(c.ids); (reason == "") != c.legal {
+			t.Errorf("Validate(%v) = %q, want legal %v", c.ids, reason, c.legal)
+		}
+	}
+}
+
+func TestMatchStartRejectsIllegalAbilities(t *testing.T) {
+	pet, _ := GetPet(DefaultPetID)
+	class, _ := GetClass(DefaultClassID)
+	if pet == nil || class == nil || len(pet.AbilityIDs) == 0 || len(class.AbilityIDs) == 0 {
+		t.Skip("default pet and class need abilities")
+	}
+	petAbility, classAbility := pet.AbilityIDs[0], class.AbilityIDs[0]
+	nk := newFakeNakama()
+	ownDefaultLoadout(t, nk, "u1")
+	start := func(matchID string) error {
+		_, err := RpcNotifyMatchStart(testContext("u1"), testLogger{}, nil, nk, `{"match_id":"`+matchID+`"}`)
+		return err
+	}
+
+	withAbilityRules(t, AbilityRulesConfig{ForbiddenPairs: [][2]uint32{{petAbility, classAbility}}})
+	if err := start("m1"); err != errors.ErrIllegalLoadout {
+		t.Errorf("forbidden pair: err = %v, want ErrIllegalLoadout", err)
+	}
+
+	withAbilityRules(t, AbilityRulesConfig{
+		Types:       map[uint32]string{petAbility: "heal", classAbility: "shield"},
+		UniqueTypes: []string{"heal"},
+	})
+	if err := start("m2"); err != nil {
+		t.Errorf("legal loadout: err = %v", err)
+	}
+}
+
+func TestAbilityLoadoutRulesMatchConfig(t *testing.T) {
+	withAbilityRules(t, AbilityRulesConfig{
+		Types:          map[uint32]string{1: "heal", 2: "heal", 3: "shield"},
+		UniqueTypes:    []string{"heal"},
+		ForbiddenPairs: [][2]uint32{{3, 4}},
+	})
+	resp, err := RpcGetAbilityLoadoutRules(testContext("u1"), testLogger{}, nil, newFakeNakama(), "")
+	if err != nil {
+		t.Fatalf("RpcGetAbilityLoadoutRules: %v", err)
+	}
+	var rules AbilityLoadoutRulesResponse
+	if err := json.Unmarshal([]byte(resp), &rules); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want := AbilityLoadoutRulesResponse{
+		Types:             map[uint32]string{1: "heal", 2: "heal", 3: "shield"},
+		MaxPerType:        map[string]int{"heal": 1},
+		IncompatiblePairs: [][2]uint32{{3, 4}},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+}
@@ -0,0 +1,201 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	storageCollectionAchievements = "achievements"
+	storageKeyAchievementProgress = "progress"
+
+	// Achievement stat keys. Definitions in items.json reference these by name.
+	AchievementStatMatchesPlayed   = "matches_played"
+	AchievementStatMatchesWon      = "matches_won"
+	AchievementStatLootboxesOpened = "lootboxes_opened"
+	AchievementStatPetXP           = "pet_xp"
+	AchievementStatPetsMaxed       = "pets_maxed" // pets that reached their tree's max level
+)
+
+// AchievementDefinition is one entry of the "achievements" list in items.json.
+// Completes when Stats[Stat] reaches Target; the reward is granted in the same commit.
+type AchievementDefinition struct {
+	ID     string `json:"id"`
+	Stat   string `json:"stat"`
+	Target int    `json:"target"`
+	Reward struct {
+		Gold   int `json:"gold,omitempty"`
+		Gems   int `json:"gems,omitempty"`
+		Treats int `json:"treats,omitempty"`
+	} `json:"reward"`
+}
+
+// AchievementState is the per-user achievement document.
+// Collection: achievements, Key: "progress". OCC-protected via Version.
+type AchievementState struct {
+	Stats     map[string]int   `json:"stats"`
+	Completed map[string]int64 `json:"completed"` // achievement ID -> completion time (ms)
+	Version   string           `json:"-"`
+}
+
+// AchievementStatus is one row of the get_achievements response.
+type AchievementStatus struct {
+	AchievementDefinition
+	Progress    int   `json:"progress"`
+	CompletedAt int64 `json:"completed_at,omitempty"`
+}
+
+var achievementDefs []AchievementDefinition
+
+// GetAchievementDefinitions returns the configured achievements (empty if none configured).
+func GetAchievementDefinitions() []AchievementDefinition {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
+	return achievementDefs
+}
+
+func readAchievementState(ctx context.Context, nk runtime.NakamaModule, userID string) (*AchievementState, error) {
+	state := &AchievementState{
+		Stats:     make(map[string]int),
+		Completed: make(map[string]int64),
+	}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionAchievements,
+		Key:        storageKeyAchievementProgress,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return state, nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), state); err != nil {
+		return nil, err
+	}
+	if state.Stats == nil {
+		state.Stats = make(map[string]int)
+	}
+	if state.Completed == nil {
+		state.Completed = make(map[string]int64)
+	}
+	state.Version = objects[0].Version
+	return state, nil
+}
+
+// PrepareAchievementProgress applies stat increments and returns deferred writes for the
+// updated document plus the currency rewards of any newly completed achievements.
+// Returns nil when no achievements are configured. Caller commits via MultiUpdate.
+func PrepareAchievementProgress(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, increments map[string]int) (*PendingWrites, error) {
+	defs := GetAchievementDefinitions()
+	if len(defs) == 0 || len(increments) == 0 {
+		return nil, nil
+	}
+
+	state, err := readAchievementState(ctx, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+	for stat, delta := range increments {
+		if delta > 0 {
+			state.Stats[stat] += delta
+		}
+	}
+
+	pending := NewPendingWrites()
+	walletChanges := make(map[string]int64)
+	var completed []string
+	now := time.Now().UnixMilli()
+	for _, def := range defs {
+		if _, done := state.Completed[def.ID]; done {
+			continue
+		}
+		if def.Target <= 0 || state.Stats[def.Stat] < def.Target {
+			continue
+		}
+		state.Completed[def.ID] = now
+		completed = append(completed, def.ID)
+		if def.Reward.Gold > 0 {
+			walletChanges["gold"] += int64(def.Reward.Gold)
+		}
+		if def.Reward.Gems > 0 {
+			walletChanges["gems"] += int64(def.Reward.Gems)
+		}
+		if def.Reward.Treats > 0 {
+			walletChanges["treats"] += int64(def.Reward.Treats)
+		}
+		logger.Info("[achievements] User %s completed %s", userID, def.ID)
+	}
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return nil, errors.ErrMarshal
+	}
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionAchievements,
+		Key:             storageKeyAchievementProgress,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         state.Version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	})
+
+	if len(completed) > 0 {
+		payload := notify.NewRewardPayload("achievement")
+		payload.Achievements = completed
+		if len(walletChanges) > 0 {
+			pending.AddWalletUpdate(userID, walletChanges)
+			payload.Wallet = &notify.WalletDelta{
+				Gold:   int(walletChanges["gold"]),
+				Gems:   int(walletChanges["gems"]),
+				Treats: int(walletChanges["treats"]),
+			}
+			payload.SetWalletReasonArgs()
+		}
+		pending.Payload = payload
+	}
+
+	return pending, nil
+}
+
+// RpcGetAchievements returns every configured achievement with the caller's progress.
+func RpcGetAchievements(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	state, err := readAchievementState(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read achievements for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	defs := GetAchievementDefinitions()
+	resp := make([]AchievementStatus, 0, len(defs))
+	for _, def := range defs {
+		progress := state.Stats[def.Stat]
+		if progress > def.Target {
+			progress = def.Target
+		}
+		resp = append(resp, AchievementStatus{
+			AchievementDefinition: def,
+			Progress:              progress,
+			CompletedAt:           state.Completed[def.ID],
+		})
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,46 @@
+package items
+
+import "testing"
+
+func TestPetXPFeedsAchievementsWhereApplied(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+	petID := firstPetID(t)
+	tree, ok := GetPetLevelTree(petID)
+	if !ok {
+		t.Fatalf("pet %d has no level tree", petID)
+	}
+	maxExp := tree.LevelThresholds[tree.MaxLevel]
+
+	_, pending, err := PrepareExperience(ctx, nk, testLogger{}, "u1", storageKeyPet, petID, uint32(maxExp))
+	if err != nil {
+		t.Fatalf("PrepareExperience: %v", err)
+	}
+	if err := CommitPendingWrites(ctx, nk, testLogger{}, pending); err != nil {
+		t.Fatalf("CommitPendingWrites: %v", err)
+	}
+
+	var state AchievementState
+	if !nk.get(t, storageCollectionAchievements, storageKeyAchievementProgress, "u1", &state) {
+		t.Fatal("no achievement progress written for pet XP")
+	}
+	if state.Stats[AchievementStatPetXP] != maxExp {
+		t.Errorf("pet_xp = %d, want %d", state.Stats[AchievementStatPetXP], maxExp)
+	}
+	if state.Stats[AchievementStatPetsMaxed] != 1 {
+		t.Errorf("pets_maxed = %d, want 1", state.Stats[AchievementStatPetsMaxed])
+	}
+
+	// More XP on a maxed pet neither adds XP progress nor counts the pet twice.
+	_, pending, err = PrepareExperience(ctx, nk, testLogger{}, "u1", storageKeyPet, petID, 100)
+	if err != nil {
+		t.Fatalf("PrepareExperience (maxed): %v", err)
+	}
+	if err := CommitPendingWrites(ctx, nk, testLogger{}, pending); err != nil {
+		t.Fatalf("CommitPendingWrites (maxed): %v", err)
+	}
+	nk.get(t, storageCollectionAchievements, storageKeyAchievementProgress, "u1", &state)
+	if state.Stats[AchievementStatPetsMaxed] != 1 || state.Stats[AchievementStatPetXP] != maxExp {
+		t.Errorf("maxed pet changed stats: %v", state.Stats)
+	}
+}
@@ -0,0 +1,74 @@
+package items
+
+import (
+	"testing"
+	"time"
+)
+
+func seedActiveMatch(t *testing.T, nk *fakeNakama, userID string, m ActiveMatch) {
+	t.Helper()
+	nk.put(t, storageCollectionActiveMatch, activeMatchKey(m.MatchID), userID, m)
+}
+
+func TestSessionEndClearsStaleActiveMatch(t *testing.T) {
+	nk := newFakeNakama()
+	started := time.Now().Add(-2 * time.Duration(maxMatchDurationMs) * time.Millisecond)
+	seedActiveMatch(t, nk, "u1", ActiveMatch{MatchID: "m1", OpponentID: "u2", StartTime: started.UnixMilli()})
+	// A claim on file does not protect a lock that has outlived the match ceiling.
+	nk.put(t, storageCollectionResults, "m1_u2", "u2", MatchResultRecord{UserID: "u2"})
+
+	cleared, err := ClearAbandonedActiveMatch(testContext("u1"), nk, testLogger{}, "u1")
+	if err != nil {
+		t.Fatalf("ClearAbandonedActiveMatch: %v", err)
+	}
+	if !cleared || nk.count(storageCollectionActiveMatch, "u1") != 0 {
+		t.Errorf("stale lock not cleared (cleared=%v)", cleared)
+	}
+}
+
+func TestSessionEndKeepsMatchMidConsensus(t *testing.T) {
+	nk := newFakeNakama()
+	seedActiveMatch(t, nk, "u1", ActiveMatch{MatchID: "m1", OpponentID: "u2", StartTime: time.Now().UnixMilli()})
+	nk.put(t, storageCollectionResults, "m1_u2", "u2", MatchResultRecord{UserID: "u2", ClaimedWin: true})
+
+	cleared, err := ClearAbandonedActiveMatch(testContext("u1"), nk, testLogger{}, "u1")
+	if err != nil {
+		t.Fatalf("ClearAbandonedActiveMatch: %v", err)
+	}
+	if cleared || nk.count(storageCollectionActiveMatch, "u1") != 1 {
+		t.Errorf("lock with an opponent claim on file was cleared (cleared=%v)", cleared)
+	}
+}
+
+func TestSessionEndOnlyClearsAbandonedLocks(t *testing.T) {
+	nk := newFakeNakama()
+	now := time.Now().UnixMilli()
+	seedActiveMatch(t, nk, "u1", ActiveMatch{MatchID: "m1", OpponentID: "u2", StartTime: now})
+	seedActiveMatch(t, nk, "u1", ActiveMatch{MatchID: "m2", OpponentID: "u3", StartTime: now})
+	nk.put(t, storageCollectionResults, "m2_u1", "u1", MatchResultRecord{UserID: "u1"})
+
+	if _, err := ClearAbandonedActiveMatch(testContext("u1"), nk, testLogger{}, "u1"); err != nil {
+		t.Fatalf("ClearAbandonedActiveMatch: %v", err)
+	}
+	if nk.get(t, storageCollectionActiveMatch, activeMatchKey("m1"), "u1", nil) {
+		t.Error("unsubmitted lock m1 survived session end")
+	}
+	if !nk.get(t, storageCollectionActiveMatch, activeMatchKey("m2"), "u1", nil) {
+		t.Error("submitted lock m2 was cleared")
+	}
+}
+
+func TestSessionEndClearsFreshUnresolvedMatch(t *testing.T) {
+	for _, opponent := range []string{"", "u2"} {
+		nk := newFakeNakama()
+		seedActiveMatch(t, nk, "u1", ActiveMatch{MatchID: "m1", OpponentID: opponent, StartTime: time.Now().UnixMilli()})
+
+		cleared, err := ClearAbandonedActiveMatch(testContext("u1"), nk, testLogger{}, "u1")
+		if err != nil {
+			t.Fatalf("opponent %q: ClearAbandonedActiveMatch: %v", opponent, err)
+		}
+		if !cleared || nk.count(storageCollectionActiveMatch, "u1") != 0 {
+			t.Errorf("opponent %q: fresh unresolved lock not cleared (cleared=%v)", opponent, cleared)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package items
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// adminSecretEnvKey is the Nakama runtime env var (runtime.env in config) holding the
+// shared secret for admin RPCs. Unset means admin RPCs are disabled.
+const adminSecretEnvKey = "ADMIN_SECRET"
+
+// checkAdminSecret compares secret against the configured admin secret in constant time.
+// Fails closed when no secret is configured.
+func checkAdminSecret(ctx context.Context, secret string) bool {
+	env, ok := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	if !ok {
+		return false
+	}
+	expected := env[adminSecretEnvKey]
+	if expected == "" || secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(secret)) == 1
+}
+
+// ReloadGameDataRequest optionally carries a full items.json document; empty reloads the embedded file.
+type ReloadGameDataRequest struct {
+	Secret   string          `json:"secret"`
+	Operator string          `json:"operator"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// RpcReloadGameData re-parses game data and swaps it in without a restart. Admin only.
+func RpcReloadGameData(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req ReloadGameDataRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !checkAdminSecret(ctx, req.Secret) {
+		logger.Warn("[admin] Rejected reload_game_data (operator=%q)", req.Operator)
+		return "", errors.ErrAdminUnauthorized
+	}
+
+	if err := ReloadGameData(req.Data); err != nil {
+		logger.Error("[admin] Game data reload by %q failed: %v", req.Operator, err)
+		return "", errors.ErrInvalidConfig
+	}
+
+	for _, warning := range LevelTreeWarnings() {
+		logger.Warn("Level tree validation: %s", warning)
+	}
+
+	data := currentGameData()
+	logger.Info("[admin] Game data reloaded by %q: config_version=%s, %d pets, %d classes, %d backgrounds, %d styles",
+		req.Operator, GetConfigVersion(), len(data.Pets), len(data.Classes), len(data.Backgrounds), len(data.PieceStyles))
+
+	respBytes, err := json.Marshal(map[string]interface{}{
+		"success":        true,
+		"config_version": GetConfigVersion(),
+	})
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// AdminItemGrant is one item in an admin grant. Type is pet, class, background or piece_style.
+type AdminItemGrant struct {
+	Type string `json:"type"`
+	ID   uint32 `json:"id"`
+}
+
+// AdminGrantRequest compensates a player with items and/or currency in one atomic commit.
+type AdminGrantRequest struct {
+	Secret      string           `json:"secret"`
+	Operator    string           `json:"operator"`
+	UserID      string           `json:"user_id"`
+	Reason      string           `json:"reason,omitempty"`
+	Items       []AdminItemGrant `json:"items,omitempty"`
+	Wallet      map[string]int64 `json:"wallet,omitempty"`
+	AllowDeduct bool             `json:"allow_deduct,omitempty"`
+	Notify      bool             `json:"notify,omitempty"`
+}
+
+var adminWalletCurrencies = map[string]bool{"gold": true, "gems": true, "treats": true, "xp_boost": true}
+
+// RpcAdminGrant grants items and wallet deltas to a target user. Admin only.
+// Every item is validated against game data; negative deltas require allow_deduct.
+func RpcAdminGrant(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req AdminGrantRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !checkAdminSecret(ctx, req.Secret) {
+		logger.Warn("[admin] Rejected admin_grant (operator=%q, target=%s)", req.Operator, req.UserID)
+		return "", errors.ErrAdminUnauthorized
+	}
+	if req.UserID == "" || req.Operator == "" || (len(req.Items) == 0 && len(req.Wallet) == 0) {
+		return "", errors.ErrInvalidInput
+	}
+	if _, err := nk.AccountGetId(ctx, req.UserID); err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+
+	mutator := NewInventoryMutator()
+	result := notify.NewRewardPayload("admin")
+	result.ReasonKey = "reward.admin.grant"
+	for _, item := range req.Items {
+		storageKey, ok := lootboxTypeToStorageKey[item.Type]
+		if !ok || !ValidateItemExists(storageKey, item.ID) {
+			logger.Warn("[admin] Invalid item %s/%d in grant by %q", item.Type, item.ID, req.Operator)
+			return "", errors.ErrInvalidItemID
+		}
+		mutator.AddItem(storageKey, item.ID)
+		if result.Inventory == nil {
+			result.Inventory = &notify.InventoryDelta{Items: []notify.ItemGrant{}}
+		}
+		result.Inventory.Items = append(result.Inventory.Items, notify.ItemGrant{ID: item.ID, Type: item.Type})
+	}
+
+	for currency, delta := range req.Wallet {
+		if !adminWalletCurrencies[currency] {
+			return "", errors.ErrInvalidInput
+		}
+		if delta < 0 && !req.AllowDeduct {
+			return "", errors.ErrInvalidInput
+		}
+	}
+
+	pending, err := mutator.CompileWrites(ctx, nk, logger, req.UserID)
+	if err != nil {
+		logger.Error("[admin] Failed to prepare item grant for %s: %v", req.UserID, err)
+		return "", errors.ErrPrepareFailed
+	}
+	if len(req.Wallet) > 0 {
+		pending.AddWalletUpdate(req.UserID, req.Wallet)
+		result.Wallet = &notify.WalletDelta{
+			Gold:   int(req.Wallet["gold"]),
+			Gems:   int(req.Wallet["gems"]),
+			Treats: int(req.Wallet["treats"]),
+		}
+		result.SetWalletReasonArgs()
+	}
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("[admin] Grant by %q to %s failed: %v", req.Operator, req.UserID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"operator": req.Operator,
+		"target":   req.UserID,
+		"reason":   req.Reason,
+		"items":    req.Items,
+		"wallet":   req.Wallet,
+		"deduct":   req.AllowDeduct,
+	}).Info("[admin] Grant applied")
+	EmitServerTelemetry(logger, req.UserID, "admin_grant", map[string]interface{}{
+		"operator": req.Operator,
+		"reason":   req.Reason,
+		"items":    len(req.Items),
+	})
+
+	if req.Notify {
+		SendRewardOrStore(ctx, nk, logger, req.UserID, result)
+	}
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
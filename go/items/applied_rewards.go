@@ -0,0 +1,159 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	storageCollectionAppliedRewards = "applied_rewards"
+
+	// appliedRewardTTL bounds how long an applied RewardID is remembered. Re-applies only
+	// happen within a delivery retry window, so a week is generous.
+	appliedRewardTTL = 7 * 24 * time.Hour
+
+	// ProgressionKeyAppliedRewardsPruned records the UTC day applied rewards were last pruned.
+	ProgressionKeyAppliedRewardsPruned = "applied_rewards_pruned"
+)
+
+type appliedRewardsPruneState struct {
+	Day int64 `json:"day"` // UTC midnight, Unix seconds
+}
+
+// AppliedReward marks a RewardID as committed. Collection: applied_rewards, Key: RewardID.
+type AppliedReward struct {
+	AppliedAt int64  `json:"applied_at"`
+	Source    string `json:"source,omitempty"`
+}
+
+// IsRewardApplied reports whether rewardID has already been committed for userID.
+func IsRewardApplied(ctx context.Context, nk runtime.NakamaModule, userID, rewardID string) (bool, error) {
+	if rewardID == "" {
+		return false, nil
+	}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionAppliedRewards,
+		Key:        rewardID,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return false, err
+	}
+	return len(objects) > 0, nil
+}
+
+// CommitRewardOnce commits pending with a create-only marker for pending.Payload.RewardID in
+// the same MultiUpdate. Re-applying a known RewardID is a no-op and returns applied=false.
+// Pendings without a payload fall through to CommitPendingWrites.
+func CommitRewardOnce(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, pending *PendingWrites) (bool, error) {
+	if pending == nil || pending.Payload == nil || pending.Payload.RewardID == "" {
+		return true, CommitPendingWrites(ctx, nk, logger, pending)
+	}
+	rewardID := pending.Payload.RewardID
+
+	applied, err := IsRewardApplied(ctx, nk, userID, rewardID)
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		logger.Info("Reward %s already applied for user %s, skipping", rewardID, userID)
+		return false, nil
+	}
+
+	value, _ := json.Marshal(AppliedReward{
+		AppliedAt: time.Now().Unix(),
+		Source:    pending.Payload.Source,
+	})
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionAppliedRewards,
+		Key:             rewardID,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         "*", // create-only: a concurrent apply of the same ID fails the whole batch
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	})
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		// Lost a race with a concurrent apply of the same reward: treat as already applied.
+		if applied, readErr := IsRewardApplied(ctx, nk, userID, rewardID); readErr == nil && applied {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneAppliedRewards deletes markers older than appliedRewardTTL. Runs at most once per UTC
+// day per user; later calls that day return after a single progression read.
+func PruneAppliedRewards(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) {
+	if !claimAppliedRewardsPrune(ctx, nk, logger, userID) {
+		return
+	}
+	objects, err := listAllStorage(ctx, nk, logger, userID, storageCollectionAppliedRewards)
+	if err != nil {
+		logger.Warn("Failed to list applied rewards for user %s: %v", userID, err)
+		return
+	}
+
+	cutoff := time.Now().Add(-appliedRewardTTL).Unix()
+	var deletes []*runtime.StorageDelete
+	for _, obj := range objects {
+		var marker AppliedReward
+		if err := json.Unmarshal([]byte(obj.Value), &marker); err != nil || marker.AppliedAt < cutoff {
+			deletes = append(deletes, &runtime.StorageDelete{
+				Collection: storageCollectionAppliedRewards,
+				Key:        obj.Key,
+				UserID:     userID,
+				Version:    obj.Version,
+			})
+		}
+	}
+	if len(deletes) == 0 {
+		return
+	}
+	if err := nk.StorageDelete(ctx, deletes); err != nil {
+		logger.Warn("Failed to prune applied rewards for user %s: %v", userID, err)
+	}
+}
+
+// claimAppliedRewardsPrune records today as the prune day and reports whether the caller should
+// prune. The version-guarded write means concurrent session ends prune once between them.
+func claimAppliedRewardsPrune(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) bool {
+	today := utcMidnight(time.Now()).Unix()
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyAppliedRewardsPruned,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Warn("Failed to read applied rewards prune state for user %s: %v", userID, err)
+		return false
+	}
+	version := "*"
+	if len(objects) > 0 {
+		var state appliedRewardsPruneState
+		if err := json.Unmarshal([]byte(objects[0].Value), &state); err == nil && state.Day >= today {
+			return false
+		}
+		version = objects[0].Version
+	}
+
+	value, _ := json.Marshal(appliedRewardsPruneState{Day: today})
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyAppliedRewardsPruned,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Debug("Skipping applied rewards prune for user %s: %v", userID, err)
+		return false
+	}
+	return true
+}
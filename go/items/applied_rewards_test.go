@@ -0,0 +1,84 @@
+package items
+
+import (
+	"testing"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+)
+
+func goldReward(rewardID string, gold int64) *PendingWrites {
+	pending := NewPendingWrites()
+	pending.AddWalletUpdate("u1", map[string]int64{"gold": gold})
+	pending.Payload = notify.NewRewardPayload("match")
+	pending.Payload.RewardID = rewardID
+	return pending
+}
+
+func TestCommitRewardOnceIgnoresSeenID(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	applied, err := CommitRewardOnce(ctx, nk, testLogger{}, "u1", goldReward("r1", 50))
+	if err != nil || !applied {
+		t.Fatalf("first apply = %v, %v; want applied", applied, err)
+	}
+	applied, err = CommitRewardOnce(ctx, nk, testLogger{}, "u1", goldReward("r1", 50))
+	if err != nil || applied {
+		t.Fatalf("re-apply = %v, %v; want a no-op", applied, err)
+	}
+	if gold := nk.wallet("u1")["gold"]; gold != 50 {
+		t.Errorf("gold = %d after re-applying r1, want 50", gold)
+	}
+
+	if applied, err := CommitRewardOnce(ctx, nk, testLogger{}, "u1", goldReward("r2", 5)); err != nil || !applied {
+		t.Fatalf("new id apply = %v, %v; want applied", applied, err)
+	}
+	if gold := nk.wallet("u1")["gold"]; gold != 55 {
+		t.Errorf("gold = %d after r2, want 55", gold)
+	}
+}
+
+func TestPruneAppliedRewardsOncePerDay(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+	old := time.Now().Add(-appliedRewardTTL - time.Hour).Unix()
+	nk.put(t, storageCollectionAppliedRewards, "old", "u1", AppliedReward{AppliedAt: old})
+	nk.put(t, storageCollectionAppliedRewards, "fresh", "u1", AppliedReward{AppliedAt: time.Now().Unix()})
+
+	PruneAppliedRewards(ctx, nk, testLogger{}, "u1")
+	if nk.get(t, storageCollectionAppliedRewards, "old", "u1", nil) {
+		t.Error("expired marker survived the prune")
+	}
+	if !nk.get(t, storageCollectionAppliedRewards, "fresh", "u1", nil) {
+		t.Error("fresh marker was pruned")
+	}
+
+	nk.put(t, storageCollectionAppliedRewards, "old2", "u1", AppliedReward{AppliedAt: old})
+	lists := nk.storageListCalls
+	PruneAppliedRewards(ctx, nk, testLogger{}, "u1")
+	if nk.storageListCalls != lists {
+		t.Error("second prune on the same day listed the collection")
+	}
+	if !nk.get(t, storageCollectionAppliedRewards, "old2", "u1", nil) {
+		t.Error("second prune on the same day deleted markers")
+	}
+}
+
+func TestAlreadyAppliedMatchRewardReleasesLock(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000001" // lootbox IDs embed a UUID prefix
+	nk := newFakeNakama()
+	ctx := testContext(userID)
+	match := ActiveMatch{Key: activeMatchKey("m1"), MatchID: "m1", StartTime: time.Now().UnixMilli()}
+	seedActiveMatch(t, nk, userID, match)
+	nk.put(t, storageCollectionAppliedRewards, "match_m1", userID, AppliedReward{AppliedAt: time.Now().Unix()})
+
+	req := &MatchResultRequest{MatchID: "m1", FinalScore: 100, EquippedPetID: firstPetID(t)}
+	if _, err := processMatchRewards(ctx, nk, testLogger{}, userID, req, true, &match, streakUnresolved); err != errors.ErrRewardAlreadyClaimed {
+		t.Fatalf("err = %v, want ErrRewardAlreadyClaimed", err)
+	}
+	if nk.count(storageCollectionActiveMatch, userID) != 0 {
+		t.Error("active match lock kept after an already-applied reward")
+	}
+}
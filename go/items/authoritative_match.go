@@ -0,0 +1,669 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// AuthoritativeMatchModule is the RegisterMatch name of the server-refereed match.
+//
+// Board simulation stays on the client; the server referees instead. A round's outcome is
+// taken from each player's report of their own survival, and only a reported defeat is
+// trusted — nobody can claim a win the opponent didn't concede. The outcome is settled once
+// here, so these matches skip the consensus/conflict path of submit_match_result; their locks
+// are flagged Authoritative and the client submit and forfeit RPCs refuse them.
+const AuthoritativeMatchModule = "block_match"
+
+const (
+	opCodeRoundEnd      int64 = 1 // client → server: RoundEndMessage
+	opCodeRoundResolved int64 = 2 // server → all: RoundResolvedMessage
+	opCodeMatchOver     int64 = 3 // server → each player: their notify.RewardPayload
+
+	authMatchTickRate = 5
+	// authMatchRoundTimeoutTicks resolves a round with one report missing after 20 seconds.
+	authMatchRoundTimeoutTicks = 20 * authMatchTickRate
+	// authMatchStartTimeoutTicks abandons a 1v1 whose opponent never joins.
+	authMatchStartTimeoutTicks = 60 * authMatchTickRate
+	// authMatchIdleTimeoutTicks settles a started match once a round has gone 5 minutes without a report.
+	authMatchIdleTimeoutTicks = 300 * authMatchTickRate
+	// authMatchPayoutTimeoutTicks stops waiting on payouts after 30 seconds; late ones go to the inbox.
+	authMatchPayoutTimeoutTicks = 30 * authMatchTickRate
+	// authMatchJobQueue holds a match's queued storage work: one job per round, payout and delivery.
+	authMatchJobQueue       = 32
+	defaultRoundsToWin      = 2 // Best of three
+	authMatchMaxRoundsToWin = 5 // A 1v1 runs at most 2*authMatchMaxRoundsToWin+1 rounds
+)
+
+// maxAuthMatchDurationMs is the stale ceiling for refereed locks: the longest series with every
+// round running to the idle and round timeouts, plus the payout wait.
+const maxAuthMatchDurationMs = ((2*authMatchMaxRoundsToWin+1)*(authMatchIdleTimeoutTicks+authMatchRoundTimeoutTicks) + authMatchPayoutTimeoutTicks) * 1000 / authMatchTickRate
+
+type CreateAuthoritativeMatchRequest struct {
+	Solo        bool `json:"solo"`
+	RoundsToWin int  `json:"rounds_to_win,omitempty"` // 1v1 only; defaults to best of three
+}
+
+type CreateAuthoritativeMatchResponse struct {
+	MatchID string `json:"match_id"`
+}
+
+// RoundEndMessage is a player's own report at the end of a round.
+type RoundEndMessage struct {
+	RoundNumber  int   `json:"round_number"`
+	Survived     bool  `json:"survived"`
+	DurationMs   int64 `json:"duration_ms"`
+	Score        int   `json:"score"`
+	PiecesPlaced int   `json:"pieces_placed"`
+}
+
+// RoundResolvedMessage is the server's verdict on a round. WinnerID is empty when nobody won it.
+type RoundResolvedMessage struct {
+	RoundNumber int            `json:"round_number"`
+	WinnerID    string         `json:"winner_id,omitempty"`
+	RoundsWon   map[string]int `json:"rounds_won"`
+}
+
+type authMatchPlayer struct {
+	presence  runtime.Presence
+	left      bool
+	roundsWon int
+	score     int
+	pieces    int
+	durMs     int64
+	rounds    []RoundResult
+}
+
+type authMatchState struct {
+	matchID     string
+	solo        bool
+	roundsToWin int
+	started     bool
+	players     map[string]*authMatchPlayer
+	order       []string // Join order; fixes the opponent pairing
+	round       int
+	reports     map[string]RoundEndMessage // Current round's reports by user
+	roundTick   int64                      // Tick of the first report for the current round
+	roundStart  int64                      // Tick the current round started
+
+	// Storage and wallet I/O runs in order on a worker goroutine so MatchLoop never blocks on it.
+	jobs       chan func()
+	startErr   chan error // Result of taking the players' locks; the worker starts once the match is full
+	payouts    chan authMatchPayout
+	finishing  bool  // The verdict is in; the loop only delivers payouts now
+	finishTick int64 // Tick the verdict was reached
+	pending    int   // Payouts not yet delivered
+	ended      bool
+}
+
+// authMatchPayout is a finished payout handed back to the loop for delivery. result is nil
+// when the player earned nothing.
+type authMatchPayout struct {
+	userID    string
+	result    *notify.RewardPayload
+	respBytes []byte
+}
+
+type authoritativeMatch struct{}
+
+// NewAuthoritativeMatch is the RegisterMatch factory for AuthoritativeMatchModule.
+func NewAuthoritativeMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule) (runtime.Match, error) {
+	return &authoritativeMatch{}, nil
+}
+
+// RpcCreateAuthoritativeMatch starts a server-refereed match and returns its ID for the players to join.
+func RpcCreateAuthoritativeMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	if _, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string); !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req CreateAuthoritativeMatchRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+	}
+	if req.RoundsToWin < 0 || req.RoundsToWin > authMatchMaxRoundsToWin {
+		return "", errors.ErrInvalidInput
+	}
+
+	matchID, err := nk.MatchCreate(ctx, AuthoritativeMatchModule, map[string]interface{}{
+		"solo":          req.Solo,
+		"rounds_to_win": req.RoundsToWin,
+	})
+	if err != nil {
+		logger.Error("Failed to create authoritative match: %v", err)
+		return "", errors.ErrInternalError
+	}
+
+	respBytes, err := json.Marshal(CreateAuthoritativeMatchResponse{MatchID: matchID})
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+func (m *authoritativeMatch) MatchInit(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, params map[string]interface{}) (interface{}, int, string) {
+	state := &authMatchState{
+		roundsToWin: defaultRoundsToWin,
+		players:     map[string]*authMatchPlayer{},
+		reports:     map[string]RoundEndMessage{},
+		round:       1,
+	}
+	state.matchID, _ = ctx.Value(runtime.RUNTIME_CTX_MATCH_ID).(string)
+	if solo, ok := params["solo"].(bool); ok {
+		state.solo = solo
+	}
+	switch n := params["rounds_to_win"].(type) {
+	case int:
+		if n > 0 {
+			state.roundsToWin = n
+		}
+	case float64:
+		if n > 0 {
+			state.roundsToWin = int(n)
+		}
+	}
+	mode := "1v1"
+	if state.solo {
+		mode = "solo"
+	}
+	return state, authMatchTickRate, fmt.Sprintf(`{"mode":%q}`, mode)
+}
+
+func (m *authoritativeMatch) capacity(state *authMatchState) int {
+	if state.solo {
+		return 1
+	}
+	return 2
+}
+
+func (m *authoritativeMatch) MatchJoinAttempt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presence runtime.Presence, metadata map[string]string) (interface{}, bool, string) {
+	s := state.(*authMatchState)
+	if _, ok := s.players[presence.GetUserId()]; ok {
+		// Leaving forfeits, so there is no rejoin; this also blocks a second session.
+		return s, false, "already joined"
+	}
+	if s.jobs != nil || len(s.players) >= m.capacity(s) {
+		return s, false, "match full"
+	}
+	return s, true, ""
+}
+
+func (m *authoritativeMatch) MatchJoin(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
+	s := state.(*authMatchState)
+	for _, presence := range presences {
+		userID := presence.GetUserId()
+		s.players[userID] = &authMatchPlayer{presence: presence}
+		s.order = append(s.order, userID)
+	}
+	if s.jobs != nil || len(s.players) < m.capacity(s) {
+		return s
+	}
+
+	// Full: start the worker and have it take the same active match lock the RPC path uses, so
+	// loadout rules, the rematch policy and the concurrent-match cap all apply. MatchLoop starts
+	// the match once the locks are held.
+	s.jobs = make(chan func(), authMatchJobQueue)
+	s.startErr = make(chan error, 1)
+	s.payouts = make(chan authMatchPayout, len(s.order))
+	go func(jobs <-chan func()) {
+		for job := range jobs {
+			job()
+		}
+	}(s.jobs)
+	matchID, order, startErr := s.matchID, append([]string(nil), s.order...), s.startErr
+	s.jobs <- func() {
+		startErr <- takeAuthMatchLocks(logger, nk, matchID, order)
+	}
+	return s
+}
+
+// takeAuthMatchLocks writes each player's refereed lock, releasing those already taken if any
+// player is refused. It runs on the match's worker, never in MatchLoop.
+func takeAuthMatchLocks(logger runtime.Logger, nk runtime.NakamaModule, matchID string, order []string) error {
+	for i, userID := range order {
+		opponentID := ""
+		for _, id := range order {
+			if id != userID {
+				opponentID = id
+			}
+		}
+		ctx := asUser(context.Background(), userID)
+		if err := startActiveMatch(ctx, nk, logger, userID, NotifyMatchStartRequest{MatchID: matchID, OpponentID: opponentID}, true); err != nil {
+			logger.Warn("Authoritative match %s: start rejected for user %s: %v", matchID, userID, err)
+			for _, id := range order[:i] {
+				clearActiveMatch(asUser(context.Background(), id), nk, logger, id, &ActiveMatch{MatchID: matchID})
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *authoritativeMatch) MatchLeave(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, presences []runtime.Presence) interface{} {
+	s := state.(*authMatchState)
+	for _, presence := range presences {
+		userID := presence.GetUserId()
+		p, ok := s.players[userID]
+		if !ok {
+			continue
+		}
+		if s.jobs != nil {
+			// The locks are being or have been taken; leaving now forfeits.
+			p.left = true
+			continue
+		}
+		// Before the match fills nothing is at stake; free the slot.
+		delete(s.players, userID)
+		for i, id := range s.order {
+			if id == userID {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	return s
+}
+
+func (m *authoritativeMatch) MatchLoop(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, messages []runtime.MatchData) interface{} {
+	s := state.(*authMatchState)
+
+	if !s.started {
+		if s.jobs == nil {
+			if (len(s.players) > 0 && s.allLeft()) || tick > authMatchStartTimeoutTicks {
+				return nil
+			}
+			return s
+		}
+		select {
+		case err := <-s.startErr:
+			if err != nil {
+				// Nobody holds a lock and nothing was earned.
+				s.end(logger, nk)
+				return nil
+			}
+		default:
+			return s // Still taking the locks
+		}
+		s.started = true
+		s.roundStart = tick
+		logger.Info("Authoritative match %s started (%d players)", s.matchID, len(s.order))
+	}
+
+	if s.finishing {
+		return m.deliver(logger, nk, dispatcher, tick, s)
+	}
+
+	for _, msg := range messages {
+		if msg.GetOpCode() != opCodeRoundEnd {
+			continue
+		}
+		var report RoundEndMessage
+		if err := json.Unmarshal(msg.GetData(), &report); err != nil || report.RoundNumber != s.round {
+			continue // Malformed, late or early reports are dropped
+		}
+		if _, dup := s.reports[msg.GetUserId()]; dup {
+			continue
+		}
+		// Scores feed the payout as reported, so hold them to the same bounds as a submitted result.
+		if err := validateRoundReport(s.players[msg.GetUserId()], report); err != nil {
+			logger.Warn("Authoritative match %s: dropped round %d report from user %s: %v", s.matchID, s.round, msg.GetUserId(), err)
+			continue
+		}
+		if len(s.reports) == 0 {
+			s.roundTick = tick
+		}
+		s.reports[msg.GetUserId()] = report
+	}
+
+	// Leaving ends a solo run; in a 1v1 the leaver forfeits.
+	if leaver := s.leaver(); leaver != "" {
+		if s.solo {
+			m.finish(logger, nk, s, tick, "", false)
+		} else {
+			m.finish(logger, nk, s, tick, s.opponentOf(leaver), true)
+		}
+		return s
+	}
+
+	if len(s.reports) == len(s.order) || (len(s.reports) > 0 && tick-s.roundTick >= authMatchRoundTimeoutTicks) {
+		if done, winnerID := m.resolveRound(logger, db, nk, dispatcher, s); done {
+			m.finish(logger, nk, s, tick, winnerID, false)
+			return s
+		}
+		s.roundStart = tick
+	} else if len(s.reports) == 0 && tick-s.roundStart >= authMatchIdleTimeoutTicks {
+		// Nobody is playing; settle on the rounds already won rather than run forever.
+		logger.Warn("Authoritative match %s: no reports for round %d, settling", s.matchID, s.round)
+		winnerID := ""
+		if !s.solo {
+			winnerID = s.leader()
+		}
+		m.finish(logger, nk, s, tick, winnerID, false)
+	}
+	return s
+}
+
+func (m *authoritativeMatch) MatchTerminate(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, graceSeconds int) interface{} {
+	s := state.(*authMatchState)
+	if s.jobs != nil {
+		s.end(logger, nk)
+	}
+	return state
+}
+
+func (m *authoritativeMatch) MatchSignal(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, state interface{}, data string) (interface{}, string) {
+	return state, ""
+}
+
+// resolveRound settles the current round from the reports received, queues banking it through
+// the round_result path and reports whether the match is over (and who won it).
+func (m *authoritativeMatch) resolveRound(logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, s *authMatchState) (bool, string) {
+	winnerID := ""
+	if !s.solo {
+		// A round goes to the player whose opponent reported their own defeat while they didn't.
+		a, b := s.order[0], s.order[1]
+		aReport, aOK := s.reports[a]
+		bReport, bOK := s.reports[b]
+		aLost := aOK && !aReport.Survived
+		bLost := bOK && !bReport.Survived
+		switch {
+		case bLost && !aLost:
+			winnerID = a
+		case aLost && !bLost:
+			winnerID = b
+		}
+	}
+
+	for _, userID := range s.order {
+		p := s.players[userID]
+		report, ok := s.reports[userID]
+		won := userID == winnerID
+		if won {
+			p.roundsWon++
+		}
+		if !ok {
+			continue
+		}
+		p.score += report.Score
+		p.pieces += report.PiecesPlaced
+		p.durMs += report.DurationMs
+		p.rounds = append(p.rounds, RoundResult{
+			RoundNumber: s.round,
+			PlayerWon:   won,
+			DurationMs:  report.DurationMs,
+			Score:       report.Score,
+		})
+
+		roundReq, _ := json.Marshal(RoundResultRequest{
+			MatchID:      s.matchID,
+			RoundNumber:  s.round,
+			PlayerWon:    won,
+			Survived:     report.Survived,
+			DurationMs:   report.DurationMs,
+			Score:        report.Score,
+			PiecesPlaced: report.PiecesPlaced,
+		})
+		matchID, round := s.matchID, s.round
+		s.jobs <- func() {
+			if _, err := RpcReportRoundResult(asUser(context.Background(), userID), logger, db, nk, string(roundReq)); err != nil {
+				logger.Warn("Authoritative match %s: failed to bank round %d for user %s: %v", matchID, round, userID, err)
+			}
+		}
+	}
+
+	resolved := RoundResolvedMessage{RoundNumber: s.round, WinnerID: winnerID, RoundsWon: map[string]int{}}
+	for userID, p := range s.players {
+		resolved.RoundsWon[userID] = p.roundsWon
+	}
+	if data, err := json.Marshal(resolved); err == nil {
+		dispatcher.BroadcastMessage(opCodeRoundResolved, data, nil, nil, true)
+	}
+
+	soloReport, soloReported := s.reports[s.order[0]]
+	s.round++
+	s.reports = map[string]RoundEndMessage{}
+
+	if s.solo {
+		// A solo run ends the first round the player doesn't survive.
+		return soloReported && !soloReport.Survived, ""
+	}
+	if winnerID != "" && s.players[winnerID].roundsWon >= s.roundsToWin {
+		return true, winnerID
+	}
+	// Unwon rounds can't stretch a match forever: after the longest possible series, most rounds wins.
+	if s.round > 2*s.roundsToWin+1 {
+		return true, s.leader()
+	}
+	return false, ""
+}
+
+// finish queues a payout for every player straight through processMatchRewards with the server's
+// verdict; MatchLoop delivers them as they land. winnerID is empty for a draw (1v1) or a finished
+// solo run.
+func (m *authoritativeMatch) finish(logger runtime.Logger, nk runtime.NakamaModule, s *authMatchState, tick int64, winnerID string, forfeit bool) {
+	s.finishing = true
+	s.finishTick = tick
+	for _, userID := range s.order {
+		p := s.players[userID]
+		if forfeit && userID != winnerID {
+			// The leaver's lock is left for ClearAbandonedActiveMatch; they earn nothing.
+			continue
+		}
+
+		req := &MatchResultRequest{
+			MatchID:           s.matchID,
+			Won:               !s.solo && userID == winnerID,
+			Draw:              !s.solo && winnerID == "",
+			FinalScore:        p.score,
+			MatchDurationSec:  int(p.durMs / 1000),
+			RoundsWon:         p.roundsWon,
+			RoundsLost:        len(p.rounds) - p.roundsWon,
+			Rounds:            p.rounds,
+			OpponentForfeited: forfeit,
+			PiecesPlaced:      p.pieces,
+		}
+		solo, payouts := s.solo, s.payouts
+		s.pending++
+		s.jobs <- func() {
+			payouts <- payAuthMatchPlayer(logger, nk, userID, req, solo, forfeit)
+		}
+	}
+	logger.Info("Authoritative match %s finished (winner=%q, forfeit=%v)", s.matchID, winnerID, forfeit)
+}
+
+// payAuthMatchPlayer commits one player's match rewards. It runs on the match's worker, never in MatchLoop.
+func payAuthMatchPlayer(logger runtime.Logger, nk runtime.NakamaModule, userID string, req *MatchResultRequest, solo, forfeit bool) authMatchPayout {
+	po := authMatchPayout{userID: userID}
+	ctx := asUser(context.Background(), po.userID)
+	activeMatch, err := validateActiveMatch(ctx, nk, logger, po.userID, req.MatchID)
+	if err != nil && !(err == errors.ErrMatchTooShort && activeMatch != nil && len(req.Rounds) > 0) {
+		logger.Warn("Authoritative match %s: no payout for user %s: %v", req.MatchID, po.userID, err)
+		return po
+	}
+
+	req.ServerDurationMs = activeMatch.elapsedMs()
+	if l := activeMatch.Loadout; l != nil {
+		req.EquippedPetID, req.EquippedClassID = l.PetID, l.ClassID
+	} else if req.EquippedPetID, req.EquippedClassID, err = equippedLoadoutIDs(ctx, nk, po.userID); err != nil {
+		logger.Warn("Authoritative match %s: failed to read loadout for user %s: %v", req.MatchID, po.userID, err)
+	}
+	consensus := "ok"
+	if forfeit {
+		consensus = "forfeit_win"
+	}
+
+	result, err := processMatchRewards(ctx, nk, logger, po.userID, req, solo, activeMatch, streakOutcomeFor(consensus, req.Won, solo))
+	if err != nil {
+		logger.Error("Authoritative match %s: reward commit failed for user %s: %v", req.MatchID, po.userID, err)
+		return po
+	}
+	// These matches never reach submit_match_result, which writes stats and history otherwise.
+	// Non-fatal; failures are logged inside.
+	_ = UpdatePlayerStatsAndHistory(ctx, nk, logger, po.userID, req, solo, req.Won, activeMatch.OpponentID)
+	rank, delta, boardID, competitive := writeLeaderboardRecords(ctx, nk, logger, po.userID, req, solo, req.Won, activeMatch.ShadowBanned)
+	if rank > 0 {
+		result.LeaderboardRank = rank
+		result.LeaderboardRankDelta = delta
+		result.BoardId = boardID
+	}
+	result.Competitive = competitive
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		return po
+	}
+	// A late submit_match_result for this match returns the payload instead of re-processing.
+	cacheMatchResult(ctx, nk, logger, po.userID, req.MatchID, "", respBytes)
+	po.result, po.respBytes = result, respBytes
+	return po
+}
+
+// deliver hands finished payouts to their players and ends the match once all have landed or
+// authMatchPayoutTimeoutTicks has passed.
+func (m *authoritativeMatch) deliver(logger runtime.Logger, nk runtime.NakamaModule, dispatcher runtime.MatchDispatcher, tick int64, s *authMatchState) interface{} {
+drain:
+	for s.pending > 0 {
+		select {
+		case po := <-s.payouts:
+			s.pending--
+			if po.result == nil {
+				continue
+			}
+			if p := s.players[po.userID]; p.left {
+				s.jobs <- func() { SendRewardOrStore(context.Background(), nk, logger, po.userID, po.result) }
+			} else {
+				dispatcher.BroadcastMessage(opCodeMatchOver, po.respBytes, []runtime.Presence{p.presence}, nil, true)
+			}
+		default:
+			break drain
+		}
+	}
+	if s.pending > 0 && tick-s.finishTick < authMatchPayoutTimeoutTicks {
+		return s
+	}
+	if s.pending > 0 {
+		logger.Warn("Authoritative match %s: %d payouts still running at close, sending them to the inbox", s.matchID, s.pending)
+	}
+	s.end(logger, nk)
+	return nil
+}
+
+// end stops the worker once its queue drains. Payouts that land after the loop stops reading
+// are sent through SendRewardOrStore; the worker runs jobs in order, so none is missed.
+func (s *authMatchState) end(logger runtime.Logger, nk runtime.NakamaModule) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	payouts := s.payouts
+	s.jobs <- func() {
+		for {
+			select {
+			case po := <-payouts:
+				if po.result != nil {
+					SendRewardOrStore(context.Background(), nk, logger, po.userID, po.result)
+				}
+			default:
+				return
+			}
+		}
+	}
+	close(s.jobs)
+}
+
+func (s *authMatchState) opponentOf(userID string) string {
+	for _, id := range s.order {
+		if id != userID {
+			return id
+		}
+	}
+	return ""
+}
+
+// leader returns the 1v1 player with more rounds won, or "" when they are level.
+func (s *authMatchState) leader() string {
+	a, b := s.players[s.order[0]], s.players[s.order[1]]
+	switch {
+	case a.roundsWon > b.roundsWon:
+		return s.order[0]
+	case b.roundsWon > a.roundsWon:
+		return s.order[1]
+	}
+	return ""
+}
+
+// leaver returns the first player who has left a started match, if any.
+func (s *authMatchState) leaver() string {
+	for _, id := range s.order {
+		if s.players[id].left {
+			return id
+		}
+	}
+	return ""
+}
+
+func (s *authMatchState) allLeft() bool {
+	for _, p := range s.players {
+		if !p.left {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRoundReport holds a round report to ScoreBounds: its own score, and the match total it
+// would bring p to. The match minimum only applies to the final total, so it isn't checked here.
+func validateRoundReport(p *authMatchPlayer, report RoundEndMessage) error {
+	bounds := GetEconomyConfig().ScoreBounds
+	if err := bounds.validateRound(report.RoundNumber, report.Score); err != nil {
+		return err
+	}
+	total := report.Score
+	if p != nil {
+		total += p.score
+	}
+	if total > bounds.maxMatch() {
+		return fmt.Errorf("match score %d above %d", total, bounds.maxMatch())
+	}
+	return nil
+}
+
+// asUser scopes ctx to userID so the per-user RPC handlers can run on a player's behalf.
+func asUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, runtime.RUNTIME_CTX_USER_ID, userID)
+}
+
+// equippedLoadoutIDs returns the user's equipped pet and class, defaulting when unset.
+func equippedLoadoutIDs(ctx context.Context, nk runtime.NakamaModule, userID string) (uint32, uint32, error) {
+	petID, classID := uint32(DefaultPetID), uint32(DefaultClassID)
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{
+		{Collection: storageCollectionEquipment, Key: storageKeyPet, UserID: userID},
+		{Collection: storageCollectionEquipment, Key: storageKeyClass, UserID: userID},
+	})
+	if err != nil {
+		return petID, classID, err
+	}
+	for _, obj := range objects {
+		var data EquipmentData
+		if err := json.Unmarshal([]byte(obj.Value), &data); err != nil {
+			continue
+		}
+		switch obj.Key {
+		case storageKeyPet:
+			petID = data.ID
+		case storageKeyClass:
+			classID = data.ID
+		}
+	}
+	return petID, classID, nil
+}
+
+var _ runtime.Match = (*authoritativeMatch)(nil)
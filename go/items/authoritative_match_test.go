@@ -0,0 +1,372 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+type testPresence struct {
+	runtime.Presence
+	userID string
+}
+
+func (p testPresence) GetUserId() string { return p.userID }
+
+type testMatchData struct {
+	runtime.MatchData
+	userID string
+	opCode int64
+	data   []byte
+}
+
+func (d testMatchData) GetUserId() string { return d.userID }
+func (d testMatchData) GetOpCode() int64  { return d.opCode }
+func (d testMatchData) GetData() []byte   { return d.data }
+
+type testDispatcher struct {
+	runtime.MatchDispatcher
+	mu   sync.Mutex
+	sent map[int64][]string // Recipients by op code
+}
+
+func (d *testDispatcher) BroadcastMessage(opCode int64, data []byte, presences []runtime.Presence, sender runtime.Presence, reliable bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.sent == nil {
+		d.sent = map[int64][]string{}
+	}
+	if presences == nil {
+		d.sent[opCode] = append(d.sent[opCode], "*")
+	}
+	for _, p := range presences {
+		d.sent[opCode] = append(d.sent[opCode], p.GetUserId())
+	}
+	return nil
+}
+
+func (d *testDispatcher) recipients(opCode int64) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.sent[opCode]...)
+}
+
+type authMatchHarness struct {
+	t          *testing.T
+	nk         *fakeNakama
+	m          *authoritativeMatch
+	s          *authMatchState
+	dispatcher *testDispatcher
+}
+
+// startAuthMatch creates and fills an authoritative match, ticking 0 until the locks are taken.
+func startAuthMatch(t *testing.T, nk *fakeNakama, solo bool, userIDs ...string) *authMatchHarness {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_MATCH_ID, "auth-match")
+	h := &authMatchHarness{t: t, nk: nk, m: &authoritativeMatch{}, dispatcher: &testDispatcher{}}
+	state, _, _ := h.m.MatchInit(ctx, testLogger{}, nil, nk, map[string]interface{}{"solo": solo})
+	var presences []runtime.Presence
+	for _, userID := range userIDs {
+		ownDefaultLoadout(t, nk, userID)
+		presences = append(presences, testPresence{userID: userID})
+	}
+	h.s = h.m.MatchJoin(ctx, testLogger{}, nil, nk, h.dispatcher, 0, state, presences).(*authMatchState)
+	deadline := time.Now().Add(time.Second)
+	for !h.s.started {
+		if !h.loop(0) || time.Now().After(deadline) {
+			t.Fatal("match did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return h
+}
+
+// loop runs one MatchLoop tick, failing if it blocks, and reports whether the match is still running.
+func (h *authMatchHarness) loop(tick int64, messages ...runtime.MatchData) bool {
+	h.t.Helper()
+	done := make(chan interface{}, 1)
+	go func() {
+		done <- h.m.MatchLoop(context.Background(), testLogger{}, nil, h.nk, h.dispatcher, tick, h.s, messages)
+	}()
+	select {
+	case next := <-done:
+		return next != nil
+	case <-time.After(time.Second):
+		h.t.Fatalf("MatchLoop blocked at tick %d", tick)
+		return false
+	}
+}
+
+// runUntilEnd ticks the match from tick until it ends, failing if that takes over a second.
+func (h *authMatchHarness) runUntilEnd(tick int64) {
+	h.t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for ; h.loop(tick); tick++ {
+		if time.Now().After(deadline) {
+			h.t.Fatal("match did not end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Payouts can grant lootboxes, whose IDs embed a UUID prefix.
+const (
+	authUserA = "00000000-0000-0000-0000-000000000081"
+	authUserB = "00000000-0000-0000-0000-000000000082"
+)
+
+func roundEnd(userID string, round int, survived bool) runtime.MatchData {
+	data, _ := json.Marshal(RoundEndMessage{RoundNumber: round, Survived: survived, DurationMs: 60000, Score: 100, PiecesPlaced: 20})
+	return testMatchData{userID: userID, opCode: opCodeRoundEnd, data: data}
+}
+
+func TestAuthMatchSettlesWhenNobodyReports(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, false, authUserA, authUserB)
+
+	// A takes round 1, then neither player reports again.
+	if !h.loop(1, roundEnd(authUserA, 1, true), roundEnd(authUserB, 1, false)) {
+		t.Fatal("match ended after one round")
+	}
+	if !h.loop(authMatchIdleTimeoutTicks) || h.s.finishing {
+		t.Fatal("match settled before the idle timeout")
+	}
+	if !h.loop(1 + authMatchIdleTimeoutTicks) {
+		t.Fatal("settling must wait for the payouts")
+	}
+	if !h.s.finishing {
+		t.Fatal("an idle match was not settled")
+	}
+	h.runUntilEnd(2 + authMatchIdleTimeoutTicks)
+
+	if got := h.dispatcher.recipients(opCodeMatchOver); len(got) != 2 {
+		t.Fatalf("match over sent to %v, want both players", got)
+	}
+	for _, userID := range []string{authUserA, authUserB} {
+		if nk.count(storageCollectionActiveMatch, userID) != 0 {
+			t.Fatalf("%s still holds the match lock", userID)
+		}
+	}
+}
+
+func TestAuthMatchIdleSoloRunEndsWithoutPayout(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, true, authUserA)
+
+	if !h.loop(authMatchIdleTimeoutTicks) {
+		t.Fatal("payouts are delivered on a later tick")
+	}
+	h.runUntilEnd(1 + authMatchIdleTimeoutTicks)
+	if got := h.dispatcher.recipients(opCodeMatchOver); len(got) != 0 {
+		t.Fatalf("a run with no rounds paid out to %v", got)
+	}
+}
+
+func TestAuthMatchPayoutRunsOffTheLoop(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, true, authUserA)
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	nk.multiUpdateHook = func() {
+		once.Do(func() { close(entered) })
+		<-release
+	}
+
+	if !h.loop(1, roundEnd(authUserA, 1, false)) {
+		t.Fatal("match ended before its payout was delivered")
+	}
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("payout never reached storage")
+	}
+	// Storage is stuck, yet the loop keeps ticking.
+	if !h.loop(2) {
+		t.Fatal("match ended while its payout was running")
+	}
+	close(release)
+	h.runUntilEnd(3)
+
+	if got := h.dispatcher.recipients(opCodeMatchOver); len(got) != 1 || got[0] != authUserA {
+		t.Fatalf("match over sent to %v, want the player", got)
+	}
+	if nk.count(storageCollectionActiveMatch, authUserA) != 0 {
+		t.Fatal("payout did not release the match lock")
+	}
+}
+
+func TestAuthMatchStuckPayoutGoesToInbox(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, true, authUserA)
+	release := make(chan struct{})
+	nk.multiUpdateHook = func() { <-release }
+
+	if !h.loop(1, roundEnd(authUserA, 1, false)) {
+		t.Fatal("match ended before its payout was delivered")
+	}
+	if h.loop(1 + authMatchPayoutTimeoutTicks) {
+		t.Fatal("match kept waiting on a stuck payout")
+	}
+	close(release)
+
+	if sent := waitForRewardNotifications(t, nk, 1); len(sent) != 1 {
+		t.Fatalf("late payout sent %d notifications, want 1", len(sent))
+	}
+	if got := h.dispatcher.recipients(opCodeMatchOver); len(got) != 0 {
+		t.Fatalf("late payout was broadcast to %v after the match ended", got)
+	}
+}
+
+// backdateAuthLock moves userID's lock for the match back by age.
+func (h *authMatchHarness) backdateAuthLock(userID string, age time.Duration) {
+	h.t.Helper()
+	var lock ActiveMatch
+	key := activeMatchKey(h.s.matchID)
+	if !h.nk.get(h.t, storageCollectionActiveMatch, key, userID, &lock) {
+		h.t.Fatal("match start wrote no lock")
+	}
+	lock.StartTime = time.Now().Add(-age).UnixMilli()
+	h.nk.put(h.t, storageCollectionActiveMatch, key, userID, lock)
+}
+
+func TestAuthMatchRecordsServerDuration(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, true, authUserA)
+	h.backdateAuthLock(authUserA, 90*time.Second)
+
+	if !h.loop(1, roundEnd(authUserA, 1, false)) {
+		t.Fatal("match ended before its payout was delivered")
+	}
+	h.runUntilEnd(2)
+	if ms := serverDurationOf(t, nk, authUserA, h.s.matchID); ms < 90000 || ms > 100000 {
+		t.Fatalf("server_duration_ms = %d, want about 90000", ms)
+	}
+}
+
+func TestAuthMatchDropsReportsOutOfBounds(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.ScoreBounds = ScoreBoundsConfig{MaxMatch: 150, MaxRound: 100}
+	})
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, false, authUserA, authUserB)
+	report := func(userID string, round, score int) runtime.MatchData {
+		data, _ := json.Marshal(RoundEndMessage{RoundNumber: round, Survived: true, DurationMs: 60000, Score: score})
+		return testMatchData{userID: userID, opCode: opCodeRoundEnd, data: data}
+	}
+
+	h.loop(1, report(authUserA, 1, 101), report(authUserB, 1, 100))
+	if _, ok := h.s.reports[authUserA]; ok {
+		t.Fatal("a round score above the bound was accepted")
+	}
+	if _, ok := h.s.reports[authUserB]; !ok {
+		t.Fatal("a round score at the bound was dropped")
+	}
+
+	// A resends within bounds; the round resolves and both bank their scores.
+	h.loop(2, report(authUserA, 1, 100))
+	if h.s.round != 2 {
+		t.Fatalf("round = %d, want 2", h.s.round)
+	}
+
+	// B's total would pass the match bound; A's lands on it.
+	h.loop(3, report(authUserA, 2, 50), report(authUserB, 2, 51))
+	if _, ok := h.s.reports[authUserB]; ok {
+		t.Fatal("a report taking the match total above the bound was accepted")
+	}
+	if _, ok := h.s.reports[authUserA]; !ok {
+		t.Fatal("a report taking the match total to the bound was dropped")
+	}
+}
+
+func TestAuthMatchSettlesPastTheClientMatchCeiling(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, false, authUserA, authUserB)
+	age := 15 * time.Minute
+	if time.Duration(maxMatchDurationMs)*time.Millisecond >= age {
+		t.Fatal("test match is not older than the 1v1 ceiling")
+	}
+	for _, userID := range []string{authUserA, authUserB} {
+		h.backdateAuthLock(userID, age)
+	}
+
+	h.loop(1, roundEnd(authUserA, 1, true), roundEnd(authUserB, 1, false))
+	if !h.loop(2, roundEnd(authUserA, 2, true), roundEnd(authUserB, 2, false)) || !h.s.finishing {
+		t.Fatal("a won series did not settle")
+	}
+	h.runUntilEnd(3)
+
+	if got := h.dispatcher.recipients(opCodeMatchOver); len(got) != 2 {
+		t.Fatalf("match over sent to %v, want both players", got)
+	}
+	if ms := serverDurationOf(t, nk, authUserA, h.s.matchID); ms < age.Milliseconds() {
+		t.Fatalf("server_duration_ms = %d, want at least %d", ms, age.Milliseconds())
+	}
+}
+
+func TestAuthMatchRefusesClientSettlement(t *testing.T) {
+	nk := newFakeNakama()
+	h := startAuthMatch(t, nk, false, authUserA, authUserB)
+	h.backdateAuthLock(authUserA, time.Minute)
+
+	submit, _ := json.Marshal(MatchResultRequest{
+		MatchID: h.s.matchID, Won: true, RoundsWon: 2,
+		EquippedPetID: DefaultPetID, EquippedClassID: DefaultClassID,
+	})
+	if _, err := RpcSubmitMatchResult(testContext(authUserA), testLogger{}, nil, nk, string(submit)); err != errors.ErrMatchRefereed {
+		t.Fatalf("submit err = %v, want ErrMatchRefereed", err)
+	}
+	forfeit, _ := json.Marshal(ForfeitMatchRequest{MatchID: h.s.matchID})
+	if _, err := RpcForfeitMatch(testContext(authUserB), testLogger{}, nil, nk, string(forfeit)); err != errors.ErrMatchRefereed {
+		t.Fatalf("forfeit err = %v, want ErrMatchRefereed", err)
+	}
+	for _, userID := range []string{authUserA, authUserB} {
+		if nk.count(storageCollectionActiveMatch, userID) != 1 {
+			t.Fatalf("%s lost the refereed lock", userID)
+		}
+	}
+	if nk.count(storageCollectionResults, authUserA) != 0 {
+		t.Fatal("a client claim was recorded for a refereed match")
+	}
+}
+
+func TestAuthMatchTakesLocksOffTheLoop(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.WithValue(context.Background(), runtime.RUNTIME_CTX_MATCH_ID, "auth-match")
+	h := &authMatchHarness{t: t, nk: nk, m: &authoritativeMatch{}, dispatcher: &testDispatcher{}}
+	state, _, _ := h.m.MatchInit(ctx, testLogger{}, nil, nk, map[string]interface{}{"solo": true})
+	ownDefaultLoadout(t, nk, authUserA)
+	release := make(chan struct{})
+	nk.storageWriteHook = func() { <-release }
+
+	done := make(chan interface{}, 1)
+	go func() {
+		done <- h.m.MatchJoin(ctx, testLogger{}, nil, nk, h.dispatcher, 0, state, []runtime.Presence{testPresence{userID: authUserA}})
+	}()
+	select {
+	case next := <-done:
+		h.s = next.(*authMatchState)
+	case <-time.After(time.Second):
+		t.Fatal("MatchJoin blocked on storage")
+	}
+	if !h.loop(1) || h.s.started {
+		t.Fatal("match started before its lock was written")
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for tick := int64(2); !h.s.started; tick++ {
+		if !h.loop(tick) || time.Now().After(deadline) {
+			t.Fatal("match did not start once its lock was written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if nk.count(storageCollectionActiveMatch, authUserA) != 1 {
+		t.Fatal("match started without its lock")
+	}
+}
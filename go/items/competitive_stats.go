@@ -69,6 +69,8 @@ func PreparePlayerStatsUpdate(ctx context.Context, nk runtime.NakamaModule, user
 	stats.MatchesPlayed++
 	if won {
 		stats.Wins++
+	} else if req.Draw {
+		stats.Draws++
 	} else {
 		stats.Losses++
 	}
@@ -100,25 +102,27 @@ func PrepareMatchHistoryWrite(ctx context.Context, nk runtime.NakamaModule, user
 	}
 
 	entry := MatchHistoryEntry{
-		Schema:          MatchHistoryEntrySchema,
-		MatchID:         req.MatchID,
-		Mode:            mode,
-		Score:           req.FinalScore,
-		OpponentID:      opponentID,
-		OpponentName:    req.OpponentName,
-		Won:             won,
-		MyPetID:         req.EquippedPetID,
-		MyClassID:       req.EquippedClassID,
-		OpponentPetID:   req.OpponentPetID,
-		OpponentClassID: req.OpponentClassID,
-		AbilitiesCast:   req.AbilitiesCast,
-		APM:             req.APM,
-		RoundsWon:       req.RoundsWon,
-		RoundsLost:      req.RoundsLost,
-		DurationSec:     req.MatchDurationSec,
-		PiecesPlaced:    req.PiecesPlaced,
-		TowerHeight:     req.TowerHeight,
-		PlayedAt:        time.Now().UnixMilli(),
+		Schema:           MatchHistoryEntrySchema,
+		MatchID:          req.MatchID,
+		Mode:             mode,
+		Score:            req.FinalScore,
+		OpponentID:       opponentID,
+		OpponentName:     req.OpponentName,
+		Won:              won,
+		Draw:             req.Draw,
+		MyPetID:          req.EquippedPetID,
+		MyClassID:        req.EquippedClassID,
+		OpponentPetID:    req.OpponentPetID,
+		OpponentClassID:  req.OpponentClassID,
+		AbilitiesCast:    req.AbilitiesCast,
+		APM:              req.APM,
+		RoundsWon:        req.RoundsWon,
+		RoundsLost:       req.RoundsLost,
+		DurationSec:      req.MatchDurationSec,
+		ServerDurationMs: req.ServerDurationMs,
+		PiecesPlaced:     req.PiecesPlaced,
+		TowerHeight:      req.TowerHeight,
+		PlayedAt:         time.Now().UnixMilli(),
 	}
 
 	var doc MatchHistoryDocument
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 
+	"block-server/errors"
+
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
@@ -40,7 +42,7 @@ func RpcGetServerMeta(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 
 	out, err := json.Marshal(resp)
 	if err != nil {
-		return "", err
+		return "", errors.ErrMarshal
 	}
 
 	return string(out), nil
@@ -52,3 +54,82 @@ func RpcGetGameConfig(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 	// gamedata (items.json) is now exported as a complete UnifiedConfig containing both items and economy.
 	return string(gamedata), nil
 }
+
+type ItemDetailsRequest struct {
+	Type string `json:"type"` // pet, class, background or piece_style
+	ID   uint32 `json:"id"`
+}
+
+// ItemDetailsResponse carries one item's static metadata. Backgrounds and piece styles only have a name.
+type ItemDetailsResponse struct {
+	Type          string   `json:"type"`
+	ID            uint32   `json:"id"`
+	Name          string   `json:"name"`
+	SpriteCount   int      `json:"sprite_count,omitempty"`
+	AbilityIDs    []uint32 `json:"ability_ids,omitempty"`
+	BackgroundIDs []uint32 `json:"background_ids,omitempty"`
+	StyleIDs      []uint32 `json:"style_ids,omitempty"`
+	LevelTreeName string   `json:"level_tree_name,omitempty"`
+}
+
+// RpcGetItemDetails returns a single item's metadata from GameData, for clients that need a
+// targeted lookup rather than the full config from RpcGetGameConfig.
+func RpcGetItemDetails(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req ItemDetailsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	storageKey, ok := lootboxTypeToStorageKey[req.Type]
+	if !ok {
+		return "", errors.ErrWrongItemType
+	}
+	if !ValidateItemExists(storageKey, req.ID) {
+		return "", errors.ErrItemNotFound
+	}
+
+	// Read from one snapshot; a reload between the existence check and here removes the item.
+	data := currentGameData()
+	resp := ItemDetailsResponse{Type: req.Type, ID: req.ID}
+	switch storageKey {
+	case storageKeyPet:
+		pet, exists := data.Pets[req.ID]
+		if !exists {
+			return "", errors.ErrItemNotFound
+		}
+		resp.Name = pet.Name
+		resp.SpriteCount = pet.SpriteCount
+		resp.AbilityIDs = pet.AbilityIDs
+		resp.BackgroundIDs = pet.BackgroundIDs
+		resp.StyleIDs = pet.StyleIDs
+		resp.LevelTreeName = pet.LevelTreeName
+	case storageKeyClass:
+		class, exists := data.Classes[req.ID]
+		if !exists {
+			return "", errors.ErrItemNotFound
+		}
+		resp.Name = class.Name
+		resp.SpriteCount = class.SpriteCount
+		resp.AbilityIDs = class.AbilityIDs
+		resp.BackgroundIDs = class.BackgroundIDs
+		resp.StyleIDs = class.StyleIDs
+		resp.LevelTreeName = class.LevelTreeName
+	case storageKeyBackground:
+		background, exists := data.Backgrounds[req.ID]
+		if !exists {
+			return "", errors.ErrItemNotFound
+		}
+		resp.Name = background.Name
+	case storageKeyPieceStyle:
+		style, exists := data.PieceStyles[req.ID]
+		if !exists {
+			return "", errors.ErrItemNotFound
+		}
+		resp.Name = style.Name
+	}
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(out), nil
+}
@@ -0,0 +1,250 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ProgressionKeyDailyEarned tracks wallet currency earned today from capped sources (progression collection).
+const ProgressionKeyDailyEarned = "daily_earned"
+
+// DailyEarnCapConfig is economy.daily_earn_cap in items.json: the most of each currency a player can
+// earn per UTC day from match rewards, lootboxes and quests combined. 0 leaves a currency uncapped.
+// Purchases, admin grants and inbox claims are never capped.
+type DailyEarnCapConfig struct {
+	Gold   int64 `json:"gold"`
+	Gems   int64 `json:"gems"`
+	Treats int64 `json:"treats"`
+}
+
+func (c DailyEarnCapConfig) limit(currency string) int64 {
+	switch currency {
+	case "gold":
+		return c.Gold
+	case "gems":
+		return c.Gems
+	case "treats":
+		return c.Treats
+	}
+	return 0
+}
+
+// Enabled reports whether any currency is capped.
+func (c DailyEarnCapConfig) Enabled() bool {
+	return c.Gold > 0 || c.Gems > 0 || c.Treats > 0
+}
+
+// DailyEarned is the per-user running total; Day is the UTC midnight the totals apply to.
+type DailyEarned struct {
+	Day    int64            `json:"day"`
+	Earned map[string]int64 `json:"earned"`
+}
+
+// readDailyEarned returns the user's totals for the UTC day containing now; yesterday's totals read
+// as empty. The version is "*" when no tracker exists yet, for a create-only write.
+func readDailyEarned(ctx context.Context, nk runtime.NakamaModule, userID string, now time.Time) (*DailyEarned, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyDailyEarned,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	today := utcMidnight(now).Unix()
+	earned := &DailyEarned{Day: today, Earned: map[string]int64{}}
+	if len(objects) == 0 {
+		return earned, "*", nil
+	}
+	var stored DailyEarned
+	if err := json.Unmarshal([]byte(objects[0].Value), &stored); err == nil && stored.Day == today && stored.Earned != nil {
+		earned = &stored
+	}
+	return earned, objects[0].Version, nil
+}
+
+// CapDailyEarnings opts this batch into the daily earn cap. Checked by CommitPendingWrites.
+func (pw *PendingWrites) CapDailyEarnings() {
+	pw.capDailyEarnings = true
+}
+
+// applyDailyEarnCap clamps capped wallet grants in pending before commit and stages the updated
+// daily_earned totals in the same MultiUpdate, so the cap and the grant land together.
+// Withheld amounts are recorded on pending.Withheld and reflected in pending.Payload.
+func applyDailyEarnCap(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, pending *PendingWrites) error {
+	caps := GetEconomyConfig().DailyEarnCap
+	if !pending.capDailyEarnings || !caps.Enabled() {
+		return nil
+	}
+
+	// Group by user: a batch normally touches one wallet.
+	byUser := map[string][]*runtime.WalletUpdate{}
+	for _, u := range pending.WalletUpdates {
+		for currency, amount := range u.Changeset {
+			if amount > 0 && caps.limit(currency) > 0 {
+				byUser[u.UserID] = append(byUser[u.UserID], u)
+				break
+			}
+		}
+	}
+	if len(byUser) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for userID, updates := range byUser {
+		earned, version, err := readDailyEarned(ctx, nk, userID, now)
+		if err != nil {
+			return err
+		}
+
+		for _, u := range updates {
+			for currency, amount := range u.Changeset {
+				limit := caps.limit(currency)
+				if amount <= 0 || limit <= 0 {
+					continue
+				}
+				remaining := limit - earned.Earned[currency]
+				if remaining < 0 {
+					remaining = 0
+				}
+				if amount > remaining {
+					if pending.Withheld == nil {
+						pending.Withheld = map[string]int64{}
+					}
+					pending.Withheld[currency] += amount - remaining
+					amount = remaining
+					u.Changeset[currency] = amount
+				}
+				earned.Earned[currency] += amount
+			}
+		}
+
+		value, err := json.Marshal(earned)
+		if err != nil {
+			return err
+		}
+		pending.AddStorageWrite(&runtime.StorageWrite{
+			Collection:      storageCollectionProgression,
+			Key:             ProgressionKeyDailyEarned,
+			UserID:          userID,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		})
+	}
+
+	if len(pending.Withheld) > 0 {
+		pending.rebuildWalletTelemetry()
+		applyWithheldToPayload(pending.Payload, pending.Withheld)
+		logger.Info("Daily earn cap withheld %v", pending.Withheld)
+	}
+	return nil
+}
+
+// rebuildWalletTelemetry re-derives telemetry after wallet changesets were clamped.
+func (pw *PendingWrites) rebuildWalletTelemetry() {
+	pw.Telemetry = pw.Telemetry[:0]
+	for _, u := range pw.WalletUpdates {
+		for currency, amount := range u.Changeset {
+			if amount > 0 {
+				pw.Telemetry = append(pw.Telemetry, PendingTelemetry{UserID: u.UserID, Currency: currency, Amount: amount, Source: "system", Sink: "wallet"})
+			} else if amount < 0 {
+				pw.Telemetry = append(pw.Telemetry, PendingTelemetry{UserID: u.UserID, Currency: currency, Amount: -amount, Source: "wallet", Sink: "system"})
+			}
+		}
+	}
+}
+
+// applyWithheldToPayload lowers the displayed wallet grant by what the cap withheld and flags
+// the capped currencies in the reward meta. Safe to call with a nil payload or empty withheld.
+func applyWithheldToPayload(payload *notify.RewardPayload, withheld map[string]int64) {
+	if payload == nil || len(withheld) == 0 {
+		return
+	}
+	if payload.Wallet != nil {
+		payload.Wallet.Gold = clampNonNegative(payload.Wallet.Gold - int(withheld["gold"]))
+		payload.Wallet.Gems = clampNonNegative(payload.Wallet.Gems - int(withheld["gems"]))
+		payload.Wallet.Treats = clampNonNegative(payload.Wallet.Treats - int(withheld["treats"]))
+		payload.SetWalletReasonArgs()
+	}
+	if payload.Meta == nil {
+		payload.Meta = &notify.RewardMeta{}
+	}
+	for _, currency := range []string{"gold", "gems", "treats"} {
+		if withheld[currency] > 0 && !containsString(payload.Meta.DailyCapReached, currency) {
+			payload.Meta.DailyCapReached = append(payload.Meta.DailyCapReached, currency)
+		}
+	}
+}
+
+func clampNonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// DailyEarningsResponse is the get_daily_earnings payload. Earned and Caps only hold capped
+// currencies; earnings are not tracked for the ones listed in Uncapped.
+type DailyEarningsResponse struct {
+	Earned   map[string]int64 `json:"earned"`
+	Caps     map[string]int64 `json:"caps"`
+	Uncapped []string         `json:"uncapped"`
+	ResetsAt int64            `json:"resets_at"`
+}
+
+// RpcGetDailyEarnings returns what the caller earned today from capped sources against the caps.
+func RpcGetDailyEarnings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	now := time.Now()
+	earned, _, err := readDailyEarned(ctx, nk, userID, now)
+	if err != nil {
+		logger.Error("Failed to read daily earnings for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	caps := GetEconomyConfig().DailyEarnCap
+	resp := DailyEarningsResponse{
+		Earned:   map[string]int64{},
+		Caps:     map[string]int64{},
+		Uncapped: []string{},
+		ResetsAt: utcMidnight(now).AddDate(0, 0, 1).Unix(),
+	}
+	for _, currency := range []string{"gold", "gems", "treats"} {
+		limit := caps.limit(currency)
+		if limit <= 0 {
+			resp.Uncapped = append(resp.Uncapped, currency)
+			continue
+		}
+		resp.Earned[currency] = earned.Earned[currency]
+		resp.Caps[currency] = limit
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,105 @@
+package items
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"block-server/notify"
+)
+
+func withDailyEarnCap(t *testing.T, caps DailyEarnCapConfig) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.DailyEarnCap = caps })
+}
+
+// grantCapped commits a capped wallet grant of gold for userID and returns the pending batch.
+func grantCapped(t *testing.T, nk *fakeNakama, userID string, gold int64) *PendingWrites {
+	t.Helper()
+	pending := NewPendingWrites()
+	pending.AddWalletUpdate(userID, map[string]int64{"gold": gold})
+	pending.Payload = notify.NewRewardPayload("match")
+	pending.Payload.Wallet = &notify.WalletDelta{Gold: int(gold)}
+	pending.CapDailyEarnings()
+	if err := CommitPendingWrites(testContext(userID), nk, testLogger{}, pending); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return pending
+}
+
+func TestDailyEarnCapClampsGrants(t *testing.T) {
+	withDailyEarnCap(t, DailyEarnCapConfig{Gold: 100})
+	nk := newFakeNakama()
+
+	if p := grantCapped(t, nk, "u1", 70); len(p.Withheld) != 0 {
+		t.Fatalf("grant under the cap withheld %v", p.Withheld)
+	}
+	p := grantCapped(t, nk, "u1", 50)
+	if p.Withheld["gold"] != 20 {
+		t.Errorf("withheld = %v, want 20 gold over the cap", p.Withheld)
+	}
+	if p.Payload.Wallet.Gold != 30 || p.Payload.Meta == nil || !containsString(p.Payload.Meta.DailyCapReached, "gold") {
+		t.Errorf("payload wallet %+v meta %+v, want 30 gold shown and gold flagged capped", p.Payload.Wallet, p.Payload.Meta)
+	}
+	grantCapped(t, nk, "u1", 10)
+	if got := nk.wallet("u1")["gold"]; got != 100 {
+		t.Errorf("gold = %d, want clamped at the cap of 100", got)
+	}
+
+	// Batches that don't opt in, like purchases and admin grants, aren't capped.
+	pending := NewPendingWrites()
+	pending.AddWalletUpdate("u1", map[string]int64{"gold": 25})
+	if err := CommitPendingWrites(testContext("u1"), nk, testLogger{}, pending); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if got := nk.wallet("u1")["gold"]; got != 125 {
+		t.Errorf("gold = %d after an uncapped grant, want 125", got)
+	}
+}
+
+func TestDailyEarnCapResetsAtUTCMidnight(t *testing.T) {
+	withDailyEarnCap(t, DailyEarnCapConfig{Gold: 100})
+	nk := newFakeNakama()
+	yesterday := utcMidnight(time.Now()).AddDate(0, 0, -1).Unix()
+	nk.put(t, storageCollectionProgression, ProgressionKeyDailyEarned, "u1", DailyEarned{Day: yesterday, Earned: map[string]int64{"gold": 100}})
+
+	if p := grantCapped(t, nk, "u1", 60); len(p.Withheld) != 0 {
+		t.Errorf("yesterday's earnings withheld %v today", p.Withheld)
+	}
+}
+
+func dailyEarnings(t *testing.T, nk *fakeNakama, userID string) DailyEarningsResponse {
+	t.Helper()
+	out, err := RpcGetDailyEarnings(testContext(userID), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetDailyEarnings: %v", err)
+	}
+	var resp DailyEarningsResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return resp
+}
+
+func TestDailyEarningsSummary(t *testing.T) {
+	withDailyEarnCap(t, DailyEarnCapConfig{Gold: 100, Gems: 10})
+	nk := newFakeNakama()
+	grantCapped(t, nk, "u1", 40)
+
+	resp := dailyEarnings(t, nk, "u1")
+	if resp.Earned["gold"] != 40 || resp.Caps["gold"] != 100 || resp.Caps["gems"] != 10 {
+		t.Errorf("summary = %+v, want 40 of 100 gold and a 10 gem cap", resp)
+	}
+	if _, ok := resp.Earned["treats"]; ok || !reflect.DeepEqual(resp.Uncapped, []string{"treats"}) {
+		t.Errorf("summary = %+v, want treats listed as uncapped, not as earned 0", resp)
+	}
+	if want := utcMidnight(time.Now()).AddDate(0, 0, 1).Unix(); resp.ResetsAt != want {
+		t.Errorf("resets_at = %d, want next UTC midnight %d", resp.ResetsAt, want)
+	}
+
+	yesterday := utcMidnight(time.Now()).AddDate(0, 0, -1).Unix()
+	nk.put(t, storageCollectionProgression, ProgressionKeyDailyEarned, "u1", DailyEarned{Day: yesterday, Earned: map[string]int64{"gold": 90}})
+	if resp := dailyEarnings(t, nk, "u1"); resp.Earned["gold"] != 0 {
+		t.Errorf("earned gold = %d from yesterday's tracker, want 0", resp.Earned["gold"])
+	}
+}
@@ -0,0 +1,226 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// deleteUserDataConfirmation must be echoed back by a self-service deletion request.
+const deleteUserDataConfirmation = "DELETE"
+
+// userDataCollections are the user-owned collections erased by delete_user_data.
+// The account survives deletion, so records that stop rewards being claimed twice stay:
+// applied_rewards and iap_purchases guard replays, and reports are moderation records
+// backing bans. Anti-abuse markers inside erased collections are listed in retainedUserDataKeys.
+var userDataCollections = []string{
+	storageCollectionInventory,
+	storageCollectionEquipment,
+	storageCollectionProgression,
+	storageCollectionLootboxes,
+	storageCollectionLootboxSecrets,
+	storageCollectionActiveMatch,
+	storageCollectionResults,
+	"match_results_cache",
+	storageCollectionMatchHistory,
+	storageCollectionCompetitiveStats,
+	storageCollectionShopHistory,
+	storageCollectionAchievements,
+	storageCollectionQuests,
+	storageCollectionReferrals,
+	storageCollectionPendingRewards,
+	storageCollectionProfile,
+	storageCollectionWalletLedger,
+	storageCollectionLoadouts,
+}
+
+// retainedUserDataKeys are per-collection keys delete_user_data leaves in place. Wiping them
+// would let the same account re-redeem a referral, re-claim one-time onboarding and first-box
+// rewards, reset daily caps and rate limits, or buy a lifetime-limited offer again.
+var retainedUserDataKeys = map[string]map[string]bool{
+	storageCollectionReferrals: {
+		storageKeyReferralRedeemed: true,
+		storageKeyReferrerStats:    true,
+	},
+	storageCollectionProgression: {
+		ProgressionKeyOnboarding:       true,
+		ProgressionKeyFirstBoxOpened:   true,
+		ProgressionKeyReportQuota:      true,
+		ProgressionKeyDailyJourney:     true,
+		ProgressionKeyDailyEarned:      true,
+		ProgressionKeyLootboxCooldowns: true,
+		ProgressionKeyDataExport:       true,
+		ProgressionKeyRecentOpponents:  true,
+	},
+}
+
+// retainUserDataKey reports whether delete_user_data must keep collection/key.
+func retainUserDataKey(collection, key string) bool {
+	if collection == storageCollectionShopHistory && strings.HasPrefix(key, purchaseLimitKey("")) {
+		return true
+	}
+	return retainedUserDataKeys[collection][key]
+}
+
+// DeleteUserDataRequest is either self-service (Confirm must be "DELETE") or admin
+// (Secret, Operator and UserID set).
+type DeleteUserDataRequest struct {
+	Confirm  string `json:"confirm,omitempty"`
+	Secret   string `json:"secret,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+type DeleteUserDataResponse struct {
+	Success      bool           `json:"success"`
+	UserID       string         `json:"user_id"`
+	Deleted      map[string]int `json:"deleted"` // Objects removed per collection
+	WalletZeroed bool           `json:"wallet_zeroed"`
+}
+
+// deleteUserCollection removes the objects the user owns in collection, except retained
+// anti-abuse markers, returning how many were deleted.
+func deleteUserCollection(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, collection string) (int, error) {
+	objects, err := listAllStorage(ctx, nk, logger, userID, collection)
+	if err != nil {
+		return 0, err
+	}
+	deletes := make([]*runtime.StorageDelete, 0, len(objects))
+	for _, obj := range objects {
+		if retainUserDataKey(collection, obj.Key) {
+			continue
+		}
+		deletes = append(deletes, &runtime.StorageDelete{
+			Collection: collection,
+			Key:        obj.Key,
+			UserID:     userID,
+		})
+	}
+	if len(deletes) == 0 {
+		return 0, nil
+	}
+	if err := nk.StorageDelete(ctx, deletes); err != nil {
+		return 0, err
+	}
+	return len(deletes), nil
+}
+
+// deleteReferralCodeIndex removes the system-owned code index entries that point at userID.
+func deleteReferralCodeIndex(ctx context.Context, nk runtime.NakamaModule, userID string) error {
+	secret := referralSecret(ctx)
+	if secret == "" {
+		return nil // Codes can't have been issued
+	}
+	var deletes []*runtime.StorageDelete
+	for _, length := range referralCodeLengths {
+		code := referralCodeCandidate(secret, userID, length)
+		owner, err := lookupReferralCode(ctx, nk, code)
+		if err != nil {
+			return err
+		}
+		if owner == userID {
+			deletes = append(deletes, &runtime.StorageDelete{
+				Collection: storageCollectionReferrals,
+				Key:        referralCodePrefix + code,
+			})
+		}
+	}
+	if len(deletes) == 0 {
+		return nil
+	}
+	return nk.StorageDelete(ctx, deletes)
+}
+
+// RpcDeleteUserData erases a user's game data for account-deletion requests: user-owned
+// gameplay storage is deleted (anti-abuse markers are kept) and the wallet is zeroed. Unlike initialization it grants nothing back,
+// leaving the account empty. Callers delete their own data with confirm "DELETE"; admins pass a user_id.
+func RpcDeleteUserData(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req DeleteUserDataRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	var userID string
+	if req.Secret != "" {
+		if !checkAdminSecret(ctx, req.Secret) {
+			logger.Warn("[admin] Rejected delete_user_data (operator=%q, target=%s)", req.Operator, req.UserID)
+			return "", errors.ErrAdminUnauthorized
+		}
+		if req.UserID == "" || req.Operator == "" {
+			return "", errors.ErrInvalidInput
+		}
+		userID = req.UserID
+	} else {
+		callerID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+		if !ok || callerID == "" {
+			return "", errors.ErrNoUserIdFound
+		}
+		if req.Confirm != deleteUserDataConfirmation {
+			return "", errors.ErrInvalidInput
+		}
+		userID = callerID
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+
+	resp := DeleteUserDataResponse{UserID: userID, Deleted: map[string]int{}}
+
+	// The referral index is resolved from the user's own entries, so clear it first.
+	if err := deleteReferralCodeIndex(ctx, nk, userID); err != nil {
+		logger.Error("Failed to delete referral code index for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	for _, collection := range userDataCollections {
+		count, err := deleteUserCollection(ctx, nk, logger, userID, collection)
+		if err != nil {
+			logger.Error("Failed to delete %s for user %s: %v", collection, userID, err)
+			return "", errors.ErrCouldNotWriteStorage
+		}
+		if count > 0 {
+			resp.Deleted[collection] = count
+		}
+	}
+
+	var wallet map[string]int64
+	if err := json.Unmarshal([]byte(account.Wallet), &wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	changeset := map[string]int64{}
+	for currency, balance := range wallet {
+		if balance != 0 {
+			changeset[currency] = -balance
+		}
+	}
+	if len(changeset) > 0 {
+		if _, _, err := nk.WalletUpdate(ctx, userID, changeset, map[string]interface{}{"reason": "delete_user_data"}, false); err != nil {
+			logger.Error("Failed to zero wallet for user %s: %v", userID, err)
+			return "", errors.ErrTransactionFailed
+		}
+		// Recorded after the collections were erased, so the ledger keeps only this entry.
+		recordWalletDelta(ctx, nk, logger, userID, WalletLedgerEntry{Delta: changeset, Reason: "delete_user_data"})
+	}
+	resp.WalletZeroed = true
+	resp.Success = true
+
+	logger.WithFields(map[string]interface{}{
+		"user":     userID,
+		"operator": req.Operator,
+		"deleted":  resp.Deleted,
+		"action":   "delete_user_data",
+	}).Info("User data deleted")
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
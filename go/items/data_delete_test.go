@@ -0,0 +1,76 @@
+package items
+
+import (
+	"fmt"
+	"testing"
+
+	"block-server/errors"
+)
+
+func TestDeleteUserDataKeepsAntiAbuseMarkers(t *testing.T) {
+	withReferralConfig(t, 0)
+	nk := newFakeNakama()
+	user := "00000000-0000-0000-0000-000000000051"
+
+	code := referralCode(t, nk, referrerUser)
+	if err := redeemReferral(nk, user, code); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	nk.put(t, storageCollectionProgression, ProgressionKeyFirstBoxOpened, user, map[string]bool{"opened": true})
+	nk.put(t, storageCollectionProgression, ProgressionKeyPlayer+"0", user, map[string]int{"level": 4})
+	nk.put(t, storageCollectionShopHistory, purchaseLimitKey("starter"), user, map[string]int{"count": 1})
+	nk.put(t, storageCollectionAppliedRewards, "receipt-1", user, map[string]bool{"applied": true})
+	nk.put(t, storageCollectionReports, "report-1", user, map[string]string{"target": referrerUser})
+	nk.put(t, storageCollectionMatchHistory, "history", user, MatchHistoryDocument{Matches: []MatchHistoryEntry{{MatchID: "m1"}}})
+
+	if _, err := RpcDeleteUserData(testContext(user), testLogger{}, nil, nk, `{"confirm":"DELETE"}`); err != nil {
+		t.Fatalf("RpcDeleteUserData: %v", err)
+	}
+
+	if gold := nk.wallet(user)["gold"]; gold != 0 {
+		t.Errorf("gold = %d after deletion, want 0", gold)
+	}
+	var v map[string]interface{}
+	for _, gone := range [][2]string{
+		{storageCollectionProgression, ProgressionKeyPlayer + "0"},
+		{storageCollectionMatchHistory, "history"},
+	} {
+		if nk.get(t, gone[0], gone[1], user, &v) {
+			t.Errorf("%s/%s survived deletion", gone[0], gone[1])
+		}
+	}
+	for _, kept := range [][2]string{
+		{storageCollectionReferrals, storageKeyReferralRedeemed},
+		{storageCollectionProgression, ProgressionKeyFirstBoxOpened},
+		{storageCollectionShopHistory, purchaseLimitKey("starter")},
+		{storageCollectionAppliedRewards, "receipt-1"},
+		{storageCollectionReports, "report-1"},
+	} {
+		if !nk.get(t, kept[0], kept[1], user, &v) {
+			t.Errorf("%s/%s was deleted", kept[0], kept[1])
+		}
+	}
+
+	if err := redeemReferral(nk, user, code); err != errors.ErrReferralAlreadyRedeemed {
+		t.Errorf("redeem after deletion: err = %v, want ErrReferralAlreadyRedeemed", err)
+	}
+	if gems := nk.wallet(referrerUser)["gems"]; gems != 50 {
+		t.Errorf("referrer gems = %d, want 50 from the single redemption", gems)
+	}
+}
+
+func TestDeleteUserDataEmptiesLargeCollections(t *testing.T) {
+	nk := newFakeNakama()
+	user := "00000000-0000-0000-0000-000000000052"
+	total := listAllStorageMaxPages*100 + 250
+	for i := 0; i < total; i++ {
+		nk.put(t, storageCollectionLootboxes, fmt.Sprintf("box-%05d", i), user, map[string]int{"n": i})
+	}
+
+	if _, err := RpcDeleteUserData(testContext(user), testLogger{}, nil, nk, `{"confirm":"DELETE"}`); err != nil {
+		t.Fatalf("RpcDeleteUserData: %v", err)
+	}
+	if left := nk.count(storageCollectionLootboxes, user); left != 0 {
+		t.Fatalf("%d of %d lootboxes survived deletion", left, total)
+	}
+}
@@ -0,0 +1,177 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// ProgressionKeyDataExport records the caller's last export for rate limiting (progression collection).
+	ProgressionKeyDataExport = "data_export"
+	// dataExportCooldown bounds how often a user can export: an export lists every collection they own.
+	dataExportCooldown  = 24 * time.Hour
+	dataExportSchemaVer = 1
+)
+
+// UserDataExport is the export_user_data payload (schema_version 1). Each section holds the raw
+// storage objects of the listed collections; values keep their stored JSON shape.
+// Server-private data (lootbox seeds, moderation records) is deliberately left out.
+type UserDataExport struct {
+	SchemaVersion    int              `json:"schema_version"`
+	UserID           string           `json:"user_id"`
+	ExportedAt       int64            `json:"exported_at"` // Unix seconds
+	Account          ExportedAccount  `json:"account"`
+	Wallet           map[string]int64 `json:"wallet"`
+	Inventory        []ExportedObject `json:"inventory"`         // inventory
+	Equipment        []ExportedObject `json:"equipment"`         // equipment + loadouts
+	Progression      []ExportedObject `json:"progression"`       // progression
+	Lootboxes        []ExportedObject `json:"lootboxes"`         // lootboxes
+	MatchHistory     []ExportedObject `json:"match_history"`     // match_history
+	CompetitiveStats []ExportedObject `json:"competitive_stats"` // competitive_stats
+	Purchases        []ExportedObject `json:"purchases"`         // shop_history + iap_purchases
+	Achievements     []ExportedObject `json:"achievements"`      // achievements
+	Quests           []ExportedObject `json:"quests"`            // quests
+	Referrals        []ExportedObject `json:"referrals"`         // referrals
+	WalletLedger     []ExportedObject `json:"wallet_ledger"`     // wallet_ledger
+}
+
+type ExportedAccount struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   int64  `json:"created_at"` // Unix seconds
+}
+
+type ExportedObject struct {
+	Collection string          `json:"collection"`
+	Key        string          `json:"key"`
+	Value      json.RawMessage `json:"value"`
+	UpdatedAt  int64           `json:"updated_at"` // Unix seconds
+}
+
+type dataExportState struct {
+	LastExportAt int64 `json:"last_export_at"`
+}
+
+// exportCollections lists every object the user owns in the given collections.
+func exportCollections(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, collections ...string) ([]ExportedObject, error) {
+	out := make([]ExportedObject, 0)
+	for _, collection := range collections {
+		objects, err := listAllStorage(ctx, nk, logger, userID, collection)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			entry := ExportedObject{
+				Collection: obj.Collection,
+				Key:        obj.Key,
+				Value:      json.RawMessage(obj.Value),
+			}
+			if obj.UpdateTime != nil {
+				entry.UpdatedAt = obj.UpdateTime.AsTime().Unix()
+			}
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+// RpcExportUserData returns everything the server stores for the caller, for data-portability requests.
+// Callers may only export their own data, at most once per dataExportCooldown.
+func RpcExportUserData(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	now := time.Now()
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyDataExport,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+	if len(objects) > 0 {
+		var state dataExportState
+		if err := json.Unmarshal([]byte(objects[0].Value), &state); err == nil &&
+			now.Sub(time.Unix(state.LastExportAt, 0)) < dataExportCooldown {
+			notifyRateLimited(ctx, nk, logger, userID, "You can export your data once a day.")
+			return "", errors.ErrExportRateLimited
+		}
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+
+	export := UserDataExport{
+		SchemaVersion: dataExportSchemaVer,
+		UserID:        userID,
+		ExportedAt:    now.Unix(),
+		Wallet:        map[string]int64{},
+	}
+	if account.User != nil {
+		export.Account.Username = account.User.Username
+		export.Account.DisplayName = account.User.DisplayName
+		if account.User.CreateTime != nil {
+			export.Account.CreatedAt = account.User.CreateTime.AsTime().Unix()
+		}
+	}
+	if err := json.Unmarshal([]byte(account.Wallet), &export.Wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	sections := []struct {
+		dst         *[]ExportedObject
+		collections []string
+	}{
+		{&export.Inventory, []string{storageCollectionInventory}},
+		{&export.Equipment, []string{storageCollectionEquipment, storageCollectionLoadouts}},
+		{&export.Progression, []string{storageCollectionProgression}},
+		{&export.Lootboxes, []string{storageCollectionLootboxes}},
+		{&export.MatchHistory, []string{storageCollectionMatchHistory}},
+		{&export.CompetitiveStats, []string{storageCollectionCompetitiveStats}},
+		{&export.Purchases, []string{storageCollectionShopHistory, StorageCollectionIAPPurchases}},
+		{&export.Achievements, []string{storageCollectionAchievements}},
+		{&export.Quests, []string{storageCollectionQuests}},
+		{&export.Referrals, []string{storageCollectionReferrals}},
+		{&export.WalletLedger, []string{storageCollectionWalletLedger}},
+	}
+	for _, section := range sections {
+		entries, err := exportCollections(ctx, nk, logger, userID, section.collections...)
+		if err != nil {
+			logger.Error("Data export failed listing %v for user %s: %v", section.collections, userID, err)
+			return "", errors.ErrCouldNotReadStorage
+		}
+		*section.dst = entries
+	}
+
+	respBytes, err := json.Marshal(export)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+
+	stateBytes, _ := json.Marshal(dataExportState{LastExportAt: now.Unix()})
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyDataExport,
+		UserID:          userID,
+		Value:           string(stateBytes),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to record data export for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	logger.Info("User %s exported their data (%d bytes)", userID, len(respBytes))
+	return string(respBytes), nil
+}
@@ -0,0 +1,76 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+)
+
+func TestExportRateLimitSendsWarningToast(t *testing.T) {
+	nk := newFakeNakama()
+	nk.put(t, storageCollectionProgression, ProgressionKeyDataExport, "u1", dataExportState{LastExportAt: time.Now().Unix()})
+
+	_, err := RpcExportUserData(testContext("u1"), testLogger{}, nil, nk, "")
+	if err != errors.ErrExportRateLimited {
+		t.Fatalf("err = %v, want ErrExportRateLimited", err)
+	}
+	toasts := nk.sent("")
+	if len(toasts) != 1 || toasts[0].Code != notify.CodeToast || toasts[0].Content["severity"] != string(notify.ToastWarning) {
+		t.Errorf("rate-limited export sent %v, want one warning toast", toasts)
+	}
+}
+
+func TestExportIncludesEverySection(t *testing.T) {
+	nk := newFakeNakama()
+	nk.setWallet("u1", map[string]int64{"gold": 120, "gems": 4})
+	seeded := []string{
+		storageCollectionInventory, storageCollectionEquipment, storageCollectionLoadouts,
+		storageCollectionProgression, storageCollectionLootboxes, storageCollectionMatchHistory,
+		storageCollectionCompetitiveStats, storageCollectionShopHistory, StorageCollectionIAPPurchases,
+		storageCollectionAchievements, storageCollectionQuests, storageCollectionReferrals,
+		storageCollectionWalletLedger, storageCollectionLootboxSecrets,
+	}
+	for _, collection := range seeded {
+		nk.put(t, collection, "k_"+collection, "u1", map[string]string{"collection": collection})
+	}
+
+	resp, err := RpcExportUserData(testContext("u1"), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcExportUserData: %v", err)
+	}
+	var export UserDataExport
+	if err := json.Unmarshal([]byte(resp), &export); err != nil {
+		t.Fatalf("unmarshal export: %v", err)
+	}
+	if export.UserID != "u1" || export.SchemaVersion != dataExportSchemaVer {
+		t.Errorf("export header = %q v%d", export.UserID, export.SchemaVersion)
+	}
+	if export.Wallet["gold"] != 120 || export.Wallet["gems"] != 4 {
+		t.Errorf("wallet = %v, want gold=120 gems=4", export.Wallet)
+	}
+
+	sections := map[string][]ExportedObject{
+		"inventory": export.Inventory, "equipment": export.Equipment, "progression": export.Progression,
+		"lootboxes": export.Lootboxes, "match_history": export.MatchHistory,
+		"competitive_stats": export.CompetitiveStats, "purchases": export.Purchases,
+		"achievements": export.Achievements, "quests": export.Quests, "referrals": export.Referrals,
+		"wallet_ledger": export.WalletLedger,
+	}
+	exported := map[string]bool{}
+	for name, entries := range sections {
+		if len(entries) == 0 {
+			t.Errorf("section %s is empty", name)
+		}
+		for _, entry := range entries {
+			exported[entry.Collection] = true
+		}
+	}
+	for _, collection := range seeded {
+		if want := collection != storageCollectionLootboxSecrets; exported[collection] != want {
+			t.Errorf("collection %s exported = %v, want %v", collection, exported[collection], want)
+		}
+	}
+}
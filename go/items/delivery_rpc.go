@@ -0,0 +1,73 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// AcknowledgeRewardRequest confirms the client finished processing a reward notification.
+type AcknowledgeRewardRequest struct {
+	RewardID string `json:"reward_id"`
+}
+
+// RpcAcknowledgeReward clears the delivery record for a reward so it is not re-sent.
+// Idempotent: acking an unknown or already-acked reward succeeds.
+func RpcAcknowledgeReward(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req AcknowledgeRewardRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if req.RewardID == "" {
+		return "", errors.ErrInvalidInput
+	}
+
+	if err := notify.AcknowledgeReward(ctx, nk, userID, req.RewardID); err != nil {
+		logger.Error("Failed to acknowledge reward %s for user %s: %v", req.RewardID, userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+	return `{"success": true}`, nil
+}
+
+// maxAckNotifications bounds one ack call; the client pages through larger inboxes.
+const maxAckNotifications = 100
+
+// AckNotificationsRequest lists persistent notification IDs the client has processed.
+type AckNotificationsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// RpcAckNotifications deletes processed notifications from the caller's inbox and acks the
+// rewards they carried. Only the caller's own notifications are touched.
+func RpcAckNotifications(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req AckNotificationsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxAckNotifications {
+		return "", errors.ErrInvalidInput
+	}
+
+	deleted, err := notify.AcknowledgeNotifications(ctx, nk, userID, req.IDs)
+	if err != nil {
+		logger.Error("Failed to acknowledge notifications for user %s: %v", userID, err)
+		return "", errors.ErrInternalError
+	}
+	return fmt.Sprintf(`{"success": true, "deleted": %d}`, deleted), nil
+}
@@ -0,0 +1,254 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionEconomySnapshots holds one system-owned snapshot per UTC day, keyed YYYY-MM-DD.
+	storageCollectionEconomySnapshots = "economy_snapshots"
+	economySnapshotFlushInterval      = 5 * time.Minute
+	economySnapshotMaxDays            = 30
+	economySnapshotWriteAttempts      = 3
+)
+
+// EconomySnapshot aggregates one UTC day of economy activity across all nodes.
+type EconomySnapshot struct {
+	Day               string             `json:"day"`                      // YYYY-MM-DD, UTC
+	Granted           map[string]int64   `json:"granted"`                  // Currency credited, per currency
+	Spent             map[string]int64   `json:"spent"`                    // Currency debited, per currency (positive)
+	LootboxesOpened   map[string]int64   `json:"lootboxes_opened"`         // Per tier
+	ItemsDropped      map[string]int64   `json:"items_dropped"`            // New items from opens, per tier
+	DuplicatesDropped map[string]int64   `json:"duplicates_dropped"`       // Duplicate fallbacks, per tier
+	ItemDropRate      map[string]float64 `json:"item_drop_rate,omitempty"` // Items per open, per tier; computed on read
+	UpdatedAt         int64              `json:"updated_at"`
+}
+
+func newEconomySnapshot(day string) *EconomySnapshot {
+	return &EconomySnapshot{
+		Day:               day,
+		Granted:           map[string]int64{},
+		Spent:             map[string]int64{},
+		LootboxesOpened:   map[string]int64{},
+		ItemsDropped:      map[string]int64{},
+		DuplicatesDropped: map[string]int64{},
+	}
+}
+
+func (s *EconomySnapshot) add(other *EconomySnapshot) {
+	addCounts := func(dst, src map[string]int64) {
+		for k, v := range src {
+			dst[k] += v
+		}
+	}
+	addCounts(s.Granted, other.Granted)
+	addCounts(s.Spent, other.Spent)
+	addCounts(s.LootboxesOpened, other.LootboxesOpened)
+	addCounts(s.ItemsDropped, other.ItemsDropped)
+	addCounts(s.DuplicatesDropped, other.DuplicatesDropped)
+}
+
+// economyCounters buffers this node's activity per day until the next flush.
+var economyCounters = struct {
+	sync.Mutex
+	days map[string]*EconomySnapshot
+}{days: map[string]*EconomySnapshot{}}
+
+func economyCountersFor(now time.Time) *EconomySnapshot {
+	day := now.UTC().Format("2006-01-02")
+	snap, ok := economyCounters.days[day]
+	if !ok {
+		snap = newEconomySnapshot(day)
+		economyCounters.days[day] = snap
+	}
+	return snap
+}
+
+// recordCurrencyFlow counts a committed wallet change; negative amounts are spends.
+func recordCurrencyFlow(nk runtime.NakamaModule, currency string, amount int64) {
+	if amount == 0 {
+		return
+	}
+	economyCounters.Lock()
+	snap := economyCountersFor(time.Now())
+	if amount > 0 {
+		snap.Granted[currency] += amount
+	} else {
+		snap.Spent[currency] -= amount
+	}
+	economyCounters.Unlock()
+
+	if amount > 0 {
+		nk.MetricsCounterAdd("economy_currency_granted", map[string]string{"currency": currency}, amount)
+	} else {
+		nk.MetricsCounterAdd("economy_currency_spent", map[string]string{"currency": currency}, -amount)
+	}
+}
+
+// recordLootboxOpen counts a committed lootbox open and what it dropped.
+func recordLootboxOpen(nk runtime.NakamaModule, tier string, items, duplicates int) {
+	economyCounters.Lock()
+	snap := economyCountersFor(time.Now())
+	snap.LootboxesOpened[tier]++
+	snap.ItemsDropped[tier] += int64(items)
+	snap.DuplicatesDropped[tier] += int64(duplicates)
+	economyCounters.Unlock()
+
+	nk.MetricsCounterAdd("economy_lootboxes_opened", map[string]string{"tier": tier}, 1)
+}
+
+// flushEconomySnapshots merges the buffered counters into the stored daily snapshots.
+// Days that fail to write stay buffered for the next flush.
+func flushEconomySnapshots(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule) {
+	economyCounters.Lock()
+	days := economyCounters.days
+	economyCounters.days = map[string]*EconomySnapshot{}
+	economyCounters.Unlock()
+
+	for day, delta := range days {
+		if err := mergeEconomySnapshot(ctx, nk, delta); err != nil {
+			logger.Warn("Failed to flush economy snapshot for %s: %v", day, err)
+			economyCounters.Lock()
+			if pending, ok := economyCounters.days[day]; ok {
+				pending.add(delta)
+			} else {
+				economyCounters.days[day] = delta
+			}
+			economyCounters.Unlock()
+		}
+	}
+}
+
+// mergeEconomySnapshot adds delta to the stored snapshot for its day with an OCC retry,
+// so nodes flushing concurrently don't overwrite each other.
+func mergeEconomySnapshot(ctx context.Context, nk runtime.NakamaModule, delta *EconomySnapshot) error {
+	var lastErr error
+	for attempt := 0; attempt < economySnapshotWriteAttempts; attempt++ {
+		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+			Collection: storageCollectionEconomySnapshots,
+			Key:        delta.Day,
+		}})
+		if err != nil {
+			return err
+		}
+		snap := newEconomySnapshot(delta.Day)
+		version := "*"
+		if len(objects) > 0 {
+			if err := json.Unmarshal([]byte(objects[0].Value), snap); err != nil {
+				return err
+			}
+			version = objects[0].Version
+		}
+		snap.add(delta)
+		snap.UpdatedAt = time.Now().Unix()
+
+		value, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+			Collection:      storageCollectionEconomySnapshots,
+			Key:             delta.Day,
+			Value:           string(value),
+			Version:         version,
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		}}); err != nil {
+			lastErr = err
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// StartEconomySnapshots flushes economy counters to daily snapshots every economySnapshotFlushInterval.
+func StartEconomySnapshots(logger runtime.Logger, nk runtime.NakamaModule) {
+	go func() {
+		ticker := time.NewTicker(economySnapshotFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			flushEconomySnapshots(context.Background(), logger, nk)
+		}
+	}()
+}
+
+type EconomySnapshotRequest struct {
+	Secret   string `json:"secret"`
+	Operator string `json:"operator"`
+	Days     int    `json:"days,omitempty"` // Most recent N days including today; default 7, max 30
+}
+
+type EconomySnapshotResponse struct {
+	Snapshots []*EconomySnapshot `json:"snapshots"` // Newest first; days without activity are omitted
+}
+
+// RpcGetEconomySnapshot returns recent daily economy snapshots for live-ops tuning. Admin only.
+// Activity from the last few minutes may still be buffered on a node and not yet included.
+func RpcGetEconomySnapshot(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req EconomySnapshotRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !checkAdminSecret(ctx, req.Secret) {
+		logger.Warn("[admin] Rejected get_economy_snapshot (operator=%q)", req.Operator)
+		return "", errors.ErrAdminUnauthorized
+	}
+	days := req.Days
+	if days <= 0 {
+		days = 7
+	}
+	if days > economySnapshotMaxDays {
+		days = economySnapshotMaxDays
+	}
+
+	today := utcMidnight(time.Now())
+	reads := make([]*runtime.StorageRead, 0, days)
+	for i := 0; i < days; i++ {
+		reads = append(reads, &runtime.StorageRead{
+			Collection: storageCollectionEconomySnapshots,
+			Key:        today.AddDate(0, 0, -i).Format("2006-01-02"),
+		})
+	}
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	byDay := make(map[string]*EconomySnapshot, len(objects))
+	for _, obj := range objects {
+		snap := newEconomySnapshot(obj.Key)
+		if err := json.Unmarshal([]byte(obj.Value), snap); err != nil {
+			logger.Warn("Failed to unmarshal economy snapshot %s: %v", obj.Key, err)
+			continue
+		}
+		snap.ItemDropRate = map[string]float64{}
+		for tier, opened := range snap.LootboxesOpened {
+			if opened > 0 {
+				snap.ItemDropRate[tier] = float64(snap.ItemsDropped[tier]) / float64(opened)
+			}
+		}
+		byDay[obj.Key] = snap
+	}
+
+	resp := EconomySnapshotResponse{Snapshots: make([]*EconomySnapshot, 0, len(byDay))}
+	for _, read := range reads {
+		if snap, ok := byDay[read.Key]; ok {
+			resp.Snapshots = append(resp.Snapshots, snap)
+		}
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
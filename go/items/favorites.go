@@ -0,0 +1,158 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageKeyFavorites lives in the profile collection but, unlike the showcase, is owner-read only.
+	storageKeyFavorites = "favorites"
+	maxFavorites        = 50
+)
+
+// Favorites is the set of items the player starred, each stored as "type:id" (e.g. "pet:3").
+type Favorites struct {
+	Items     []string `json:"items"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+type ToggleFavoriteRequest struct {
+	Type string `json:"type"` // pet, class, background or piece_style
+	ID   uint32 `json:"id"`
+}
+
+type ToggleFavoriteResponse struct {
+	Favorited bool     `json:"favorited"` // State of the toggled item after the call
+	Items     []string `json:"items"`
+}
+
+func favoriteEntry(itemType string, itemID uint32) string {
+	return fmt.Sprintf("%s:%d", itemType, itemID)
+}
+
+// readFavorites returns the caller's favorites and the storage version for an OCC write.
+func readFavorites(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) (*Favorites, string, error) {
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
+		Collection: storageCollectionProfile,
+		Key:        storageKeyFavorites,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return &Favorites{Items: []string{}}, "*", nil
+	}
+	favorites, err := UnmarshalJSON[Favorites](objects[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	if favorites.Items == nil {
+		favorites.Items = []string{}
+	}
+	return favorites, objects[0].Version, nil
+}
+
+// RpcToggleFavorite stars an owned item, or unstars it if it is already a favorite.
+// Unstarring never checks ownership, so sold items can still be cleaned up.
+func RpcToggleFavorite(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req ToggleFavoriteRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	storageKey, ok := lootboxTypeToStorageKey[req.Type]
+	if !ok {
+		return "", errors.ErrWrongItemType
+	}
+	if !ValidateItemExists(storageKey, req.ID) {
+		return "", errors.ErrInvalidItemID
+	}
+
+	favorites, version, err := readFavorites(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read favorites for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	entry := favoriteEntry(req.Type, req.ID)
+	resp := ToggleFavoriteResponse{}
+	kept := make([]string, 0, len(favorites.Items)+1)
+	for _, existing := range favorites.Items {
+		if existing != entry {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == len(favorites.Items) {
+		if len(kept) >= maxFavorites {
+			return "", errors.ErrFavoritesFull
+		}
+		owned, err := IsItemOwned(ctx, nk, userID, req.ID, storageKey)
+		if err != nil {
+			return "", errors.ErrFailedCheckOwnership
+		}
+		if !owned {
+			return "", errors.ErrNotOwned
+		}
+		kept = append(kept, entry)
+		resp.Favorited = true
+	}
+	favorites.Items = kept
+	favorites.UpdatedAt = time.Now().Unix()
+
+	value, err := json.Marshal(favorites)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionProfile,
+		Key:             storageKeyFavorites,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version, // OCC: a concurrent toggle fails rather than being lost
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to write favorites for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	resp.Items = favorites.Items
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// RpcGetFavorites returns the caller's favorites as "type:id" strings.
+func RpcGetFavorites(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	favorites, _, err := readFavorites(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read favorites for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	respBytes, err := json.Marshal(favorites)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -16,148 +16,338 @@ var gamedata []byte
 var (
 	GameData         *GameDataStruct
 	GameDataOnce     sync.Once
+	gameDataMu       sync.RWMutex // guards GameData and the config pointers below across reloads
 	starterPack      *StarterPack
+	leaderboards     *LeaderboardsConfig
 	configVersion    string
 	minClientVersion string
+
+	// levelTreeWarnings holds non-fatal level tree issues found at load time; logged by InitModule.
+	levelTreeWarnings []string
 )
 
-// LoadGameData loads and parses game data from embedded JSON
+// LoadGameData loads and parses game data from embedded JSON. Runs once at module init;
+// use ReloadGameData to swap in new data afterwards.
 func LoadGameData() error {
 	var initErr error
-	var parseErrors []error
 	GameDataOnce.Do(func() {
-		var raw struct {
-			Items struct {
-				Pets        map[string]Pet        `json:"pets"`
-				Classes     map[string]Class      `json:"classes"`
-				Backgrounds map[string]Background `json:"backgrounds"`
-				PieceStyles map[string]PieceStyle `json:"piece_styles"`
-				LevelTrees  map[string]LevelTree  `json:"level_trees"`
-				StatCurves  map[string][]uint32   `json:"stat_curves"`
-			} `json:"items"`
-			Economy             EconomyConfig `json:"economy"`
-			StarterPack         StarterPack   `json:"starter_pack"`
-			ConfigVersion       string        `json:"config_version"`
-			VersionRequirements struct {
-				MinClientVersion string `json:"min_client_version"`
-			} `json:"version_requirements"`
+		initErr = loadGameDataFrom(gamedata)
+	})
+	return initErr
+}
+
+// ReloadGameData re-parses game data from data (or the embedded items.json if empty) and
+// swaps it in atomically. On any parse error the current data stays in place.
+func ReloadGameData(data []byte) error {
+	if len(data) == 0 {
+		data = gamedata
+	}
+	return loadGameDataFrom(data)
+}
+
+func loadGameDataFrom(data []byte) error {
+	var parseErrors []error
+	var raw struct {
+		Items struct {
+			Pets        map[string]Pet        `json:"pets"`
+			Classes     map[string]Class      `json:"classes"`
+			Backgrounds map[string]Background `json:"backgrounds"`
+			PieceStyles map[string]PieceStyle `json:"piece_styles"`
+			LevelTrees  map[string]LevelTree  `json:"level_trees"`
+			StatCurves  map[string][]uint32   `json:"stat_curves"`
+			// DefaultLevelTrees is used by any pet or class whose levelTreeName is empty.
+			DefaultLevelTrees struct {
+				Pets    string `json:"pets"`
+				Classes string `json:"classes"`
+			} `json:"default_level_trees"`
+		} `json:"items"`
+		Economy             EconomyConfig           `json:"economy"`
+		StarterPack         StarterPack             `json:"starter_pack"`
+		Leaderboards        LeaderboardsConfig      `json:"leaderboards"`
+		Achievements        []AchievementDefinition `json:"achievements"`
+		Quests              QuestsConfig            `json:"quests"`
+		Tournament          TournamentConfig        `json:"tournament"`
+		AbilityRules        AbilityRulesConfig      `json:"ability_rules"`
+		ConfigVersion       string                  `json:"config_version"`
+		VersionRequirements struct {
+			MinClientVersion string `json:"min_client_version"`
+		} `json:"version_requirements"`
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	next := &GameDataStruct{
+		Pets:        make(map[uint32]*Pet, len(raw.Items.Pets)),
+		Classes:     make(map[uint32]*Class, len(raw.Items.Classes)),
+		Backgrounds: make(map[uint32]Background, len(raw.Items.Backgrounds)),
+		PieceStyles: make(map[uint32]PieceStyle, len(raw.Items.PieceStyles)),
+		LevelTrees:  make(map[string]LevelTree, len(raw.Items.LevelTrees)),
+		StatCurves:  make(map[string][]uint32, len(raw.Items.StatCurves)),
+	}
+
+	for name, tree := range raw.Items.LevelTrees {
+		t := tree
+		
+		// Validate level_thresholds array
+		if len(t.LevelThresholds) < t.MaxLevel+1 {
+			parseErrors = append(parseErrors, fmt.Errorf("level tree %q has invalid level_thresholds length (got %d, expected at least %d)", name, len(t.LevelThresholds), t.MaxLevel+1))
+		} else {
+			// Ensure strictly ascending order
+			for i := 1; i <= t.MaxLevel; i++ {
+				if t.LevelThresholds[i] < t.LevelThresholds[i-1] {
+					parseErrors = append(parseErrors, fmt.Errorf("level tree %q has non-ascending level_thresholds at index %d", name, i))
+					break
+				}
+			}
 		}
+		
+		next.LevelTrees[name] = t
+	}
 
-		if err := json.Unmarshal(gamedata, &raw); err != nil {
-			initErr = err
-			return
+	defaults := raw.Items.DefaultLevelTrees
+	for kind, name := range map[string]string{"pets": defaults.Pets, "classes": defaults.Classes} {
+		if _, ok := next.LevelTrees[name]; name != "" && !ok {
+			parseErrors = append(parseErrors, fmt.Errorf("default_level_trees.%s references unknown level tree %q", kind, name))
 		}
+	}
 
-		economyConfig = &raw.Economy
-		starterPack = &raw.StarterPack
-		configVersion = raw.ConfigVersion
-		minClientVersion = raw.VersionRequirements.MinClientVersion
-		GameData = &GameDataStruct{
-			Pets:        make(map[uint32]*Pet, len(raw.Items.Pets)),
-			Classes:     make(map[uint32]*Class, len(raw.Items.Classes)),
-			Backgrounds: make(map[uint32]Background, len(raw.Items.Backgrounds)),
-			PieceStyles: make(map[uint32]PieceStyle, len(raw.Items.PieceStyles)),
-			LevelTrees:  make(map[string]LevelTree, len(raw.Items.LevelTrees)),
-			StatCurves:  make(map[string][]uint32, len(raw.Items.StatCurves)),
+	for k, v := range raw.Items.Pets {
+		if v.LevelTreeName == "" {
+			v.LevelTreeName = defaults.Pets
 		}
+		id, err := strconv.ParseUint(k, 10, 32)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid pet ID %q: %w", k, err))
+			continue
+		}
+		next.Pets[uint32(id)] = &Pet{
+			Name:               v.Name,
+			SpriteCount:        v.SpriteCount,
+			AbilityIDs:         v.AbilityIDs,
+			AbilitySet:         createAbilitySet(v.AbilityIDs),
+			BackgroundIDs:      v.BackgroundIDs,
+			StyleIDs:           v.StyleIDs,
+			LevelTreeName:      v.LevelTreeName,
+			HealthCurveID:      v.HealthCurveID,
+			AttackCurveID:      v.AttackCurveID,
+		}
+	}
 
-		for name, tree := range raw.Items.LevelTrees {
-			t := tree
-			
-			// Validate level_thresholds array
-			if len(t.LevelThresholds) < t.MaxLevel+1 {
-				parseErrors = append(parseErrors, fmt.Errorf("level tree %q has invalid level_thresholds length (got %d, expected at least %d)", name, len(t.LevelThresholds), t.MaxLevel+1))
-			} else {
-				// Ensure strictly ascending order
-				for i := 1; i <= t.MaxLevel; i++ {
-					if t.LevelThresholds[i] < t.LevelThresholds[i-1] {
-						parseErrors = append(parseErrors, fmt.Errorf("level tree %q has non-ascending level_thresholds at index %d", name, i))
-						break
-					}
-				}
-			}
-			
-			GameData.LevelTrees[name] = t
+	for k, v := range raw.Items.Classes {
+		if v.LevelTreeName == "" {
+			v.LevelTreeName = defaults.Classes
+		}
+		id, err := strconv.ParseUint(k, 10, 32)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid class ID %q: %w", k, err))
+			continue
 		}
+		next.Classes[uint32(id)] = &Class{
+			Name:               v.Name,
+			SpriteCount:        v.SpriteCount,
+			AbilityIDs:         v.AbilityIDs,
+			AbilitySet:         createAbilitySet(v.AbilityIDs),
+			BackgroundIDs:      v.BackgroundIDs,
+			StyleIDs:           v.StyleIDs,
+			LevelTreeName:      v.LevelTreeName,
+			HealthCurveID:      v.HealthCurveID,
+			AttackCurveID:      v.AttackCurveID,
+		}
+	}
 
-		for k, v := range raw.Items.Pets {
-			id, err := strconv.ParseUint(k, 10, 32)
-			if err != nil {
-				parseErrors = append(parseErrors, fmt.Errorf("invalid pet ID %q: %w", k, err))
-				continue
-			}
-			GameData.Pets[uint32(id)] = &Pet{
-				Name:               v.Name,
-				SpriteCount:        v.SpriteCount,
-				AbilityIDs:         v.AbilityIDs,
-				AbilitySet:         createAbilitySet(v.AbilityIDs),
-				BackgroundIDs:      v.BackgroundIDs,
-				StyleIDs:           v.StyleIDs,
-				LevelTreeName:      v.LevelTreeName,
-				HealthCurveID:      v.HealthCurveID,
-				AttackCurveID:      v.AttackCurveID,
-			}
+	next.StatCurves = raw.Items.StatCurves
+
+	for k, v := range raw.Items.Backgrounds {
+		id, err := strconv.ParseUint(k, 10, 32)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid background ID %q: %w", k, err))
+			continue
 		}
+		next.Backgrounds[uint32(id)] = v
+	}
 
-		for k, v := range raw.Items.Classes {
-			id, err := strconv.ParseUint(k, 10, 32)
-			if err != nil {
-				parseErrors = append(parseErrors, fmt.Errorf("invalid class ID %q: %w", k, err))
-				continue
-			}
-			GameData.Classes[uint32(id)] = &Class{
-				Name:               v.Name,
-				SpriteCount:        v.SpriteCount,
-				AbilityIDs:         v.AbilityIDs,
-				AbilitySet:         createAbilitySet(v.AbilityIDs),
-				BackgroundIDs:      v.BackgroundIDs,
-				StyleIDs:           v.StyleIDs,
-				LevelTreeName:      v.LevelTreeName,
-				HealthCurveID:      v.HealthCurveID,
-				AttackCurveID:      v.AttackCurveID,
-			}
+	for k, v := range raw.Items.PieceStyles {
+		id, err := strconv.ParseUint(k, 10, 32)
+		if err != nil {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid piece style ID %q: %w", k, err))
+			continue
 		}
+		next.PieceStyles[uint32(id)] = v
+	}
 
-		GameData.StatCurves = raw.Items.StatCurves
+	switch rematch := raw.Economy.Rematch; rematch.Action {
+	case RematchActionLog, RematchActionReject:
+	case RematchActionReduce:
+		// 0 would read as "keep nothing" but scales nothing; spell out the percent kept.
+		if rematch.Limit > 0 && (rematch.RewardPercent <= 0 || rematch.RewardPercent >= 100) {
+			parseErrors = append(parseErrors, fmt.Errorf("economy.rematch.reward_percent must be between 1 and 99 for reduce, got %d", rematch.RewardPercent))
+		}
+	default:
+		if rematch.Limit > 0 {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid economy.rematch.action %q", rematch.Action))
+		}
+	}
 
-		for k, v := range raw.Items.Backgrounds {
-			id, err := strconv.ParseUint(k, 10, 32)
-			if err != nil {
-				parseErrors = append(parseErrors, fmt.Errorf("invalid background ID %q: %w", k, err))
-				continue
-			}
-			GameData.Backgrounds[uint32(id)] = v
+	if len(raw.Economy.DailyXPCurve) == 0 {
+		parseErrors = append(parseErrors, fmt.Errorf("economy.daily_xp_curve must not be empty"))
+	}
+	for _, m := range raw.Economy.DailyXPCurve {
+		if m < 0 {
+			parseErrors = append(parseErrors, fmt.Errorf("negative economy.daily_xp_curve multiplier %v", m))
+		}
+	}
+
+	if b := raw.Economy.ScoreBounds; (b.MaxMatch > 0 && b.MaxMatch < b.MinMatch) || (b.MaxRound > 0 && b.MaxRound < b.MinRound) {
+		parseErrors = append(parseErrors, fmt.Errorf("economy.score_bounds max below min: %+v", b))
+	}
+
+	for currency, amount := range raw.Economy.InitialWallet {
+		if amount < 0 {
+			parseErrors = append(parseErrors, fmt.Errorf("negative economy.initial_wallet amount %d for %q", amount, currency))
 		}
+	}
+
+	seenSteps := map[string]bool{}
+	for _, step := range raw.Economy.Onboarding {
+		if step.ID == "" || seenSteps[step.ID] {
+			parseErrors = append(parseErrors, fmt.Errorf("invalid or duplicate economy.onboarding step %q", step.ID))
+		}
+		seenSteps[step.ID] = true
+	}
 
-		for k, v := range raw.Items.PieceStyles {
-			id, err := strconv.ParseUint(k, 10, 32)
+	for _, t := range raw.AbilityRules.UniqueTypes {
+		if t == "" {
+			parseErrors = append(parseErrors, fmt.Errorf("empty ability_rules.unique_types entry"))
+		}
+	}
+	for _, pair := range raw.AbilityRules.ForbiddenPairs {
+		if pair[0] == pair[1] {
+			parseErrors = append(parseErrors, fmt.Errorf("ability_rules.forbidden_pairs pairs ability %d with itself", pair[0]))
+		}
+	}
+
+	treeErrors, treeWarnings := validateLevelTrees(next)
+	parseErrors = append(parseErrors, treeErrors...)
+	if len(parseErrors) > 0 {
+		return fmt.Errorf("%d parse errors: %+v", len(parseErrors), parseErrors)
+	}
+
+	gameDataMu.Lock()
+	GameData = next
+	economyConfig = &raw.Economy
+	starterPack = &raw.StarterPack
+	leaderboards = &raw.Leaderboards
+	achievementDefs = raw.Achievements
+	questsConfig = &raw.Quests
+	tournamentConfig = &raw.Tournament
+	abilityRules = &raw.AbilityRules
+	configVersion = raw.ConfigVersion
+	minClientVersion = raw.VersionRequirements.MinClientVersion
+	levelTreeWarnings = treeWarnings
+	gameDataMu.Unlock()
+	return nil
+}
+
+// LevelTreeWarnings returns non-fatal level tree issues found by LoadGameData.
+func LevelTreeWarnings() []string {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
+	return levelTreeWarnings
+}
+
+// validateLevelTrees checks reward tables against tree bounds and the items that use them.
+// Hard errors fail module init; pool overruns are warnings because GrantLevelRewards
+// already skips indices past the end of an item's ability/sprite list.
+func validateLevelTrees(data *GameDataStruct) (errs []error, warnings []string) {
+	for name, tree := range data.LevelTrees {
+		if tree.MaxLevel <= 0 {
+			errs = append(errs, fmt.Errorf("level tree %q has max_level %d (must be > 0)", name, tree.MaxLevel))
+			continue
+		}
+		if len(tree.LevelThresholds) > 0 && tree.LevelThresholds[0] < 0 {
+			errs = append(errs, fmt.Errorf("level tree %q has negative base threshold %d", name, tree.LevelThresholds[0]))
+		}
+		for key, reward := range tree.Rewards {
+			level, err := strconv.Atoi(key)
 			if err != nil {
-				parseErrors = append(parseErrors, fmt.Errorf("invalid piece style ID %q: %w", k, err))
+				errs = append(errs, fmt.Errorf("level tree %q has non-numeric reward level %q", name, key))
 				continue
 			}
-			GameData.PieceStyles[uint32(id)] = v
+			if level < 1 || level > tree.MaxLevel {
+				errs = append(errs, fmt.Errorf("level tree %q has reward at level %d outside 1..%d", name, level, tree.MaxLevel))
+			}
+			for field, value := range map[string]string{"gold": reward.Gold, "gems": reward.Gems} {
+				if value == "" {
+					continue
+				}
+				if n, err := strconv.Atoi(value); err != nil || n < 0 {
+					errs = append(errs, fmt.Errorf("level tree %q level %d has invalid %s amount %q", name, level, field, value))
+				}
+			}
 		}
-	})
+	}
 
-	if len(parseErrors) > 0 {
-		initErr = fmt.Errorf("%d parse errors: %+v", len(parseErrors), parseErrors)
+	// Index 0 of each ability/sprite list is pre-granted, so a tree can award at most len-1 more.
+	checkPools := func(kind string, id uint32, treeName string, abilities, sprites int) {
+		abilityRewards, spriteRewards := countTreePoolRewards(data.LevelTrees[treeName])
+		if abilityRewards > abilities-1 && abilities > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s %d: level tree %q awards %d abilities but only %d are unlockable", kind, id, treeName, abilityRewards, abilities-1))
+		}
+		if spriteRewards > sprites-1 && sprites > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s %d: level tree %q awards %d sprites but only %d are unlockable", kind, id, treeName, spriteRewards, sprites-1))
+		}
 	}
-	return initErr
+	for id, pet := range data.Pets {
+		if _, ok := data.LevelTrees[pet.LevelTreeName]; !ok {
+			errs = append(errs, fmt.Errorf("pet %d references unknown level tree %q", id, pet.LevelTreeName))
+			continue
+		}
+		checkPools("pet", id, pet.LevelTreeName, len(pet.AbilityIDs), pet.SpriteCount)
+	}
+	for id, class := range data.Classes {
+		if _, ok := data.LevelTrees[class.LevelTreeName]; !ok {
+			errs = append(errs, fmt.Errorf("class %d references unknown level tree %q", id, class.LevelTreeName))
+			continue
+		}
+		checkPools("class", id, class.LevelTreeName, len(class.AbilityIDs), class.SpriteCount)
+	}
+
+	return errs, warnings
+}
+
+func countTreePoolRewards(tree LevelTree) (abilities, sprites int) {
+	for _, reward := range tree.Rewards {
+		if reward.Abilities != "" {
+			abilities++
+		}
+		if reward.Sprites != "" {
+			sprites++
+		}
+	}
+	return abilities, sprites
 }
 
 // Game Data Access Functions
 
 func GetPet(id uint32) (*Pet, bool) {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	pet, exists := GameData.Pets[id]
 	return pet, exists
 }
 
 func GetClass(id uint32) (*Class, bool) {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	class, exists := GameData.Classes[id]
 	return class, exists
 }
 
 func GetLevelTree(name string) (LevelTree, bool) {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	tree, exists := GameData.LevelTrees[name]
 	return tree, exists
 }
@@ -177,6 +367,8 @@ func GetClassLevelTree(classID uint32) (LevelTree, bool) {
 }
 
 func GetLevelTreeName(category string, id uint32) (string, error) {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	switch category {
 	case storageKeyPet:
 		if pet, exists := GameData.Pets[id]; exists {
@@ -193,6 +385,8 @@ func GetLevelTreeName(category string, id uint32) (string, error) {
 }
 
 func ValidateItemExists(category string, id uint32) bool {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	switch category {
 	case storageKeyPet:
 		_, exists := GameData.Pets[id]
@@ -250,6 +444,14 @@ func CalculateLevel(treeName string, exp int) (int, error) {
 	return high + 1, nil
 }
 
+// currentGameData returns the active GameData snapshot. Callers that iterate maps should
+// use this rather than the GameData variable so a concurrent reload can't swap it mid-loop.
+func currentGameData() *GameDataStruct {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
+	return GameData
+}
+
 // Helper Functions
 
 func createAbilitySet(ids []uint32) map[uint32]struct{} {
@@ -263,6 +465,8 @@ func createAbilitySet(ids []uint32) map[uint32]struct{} {
 // GetStarterPack returns the starter item pack configuration.
 // Falls back to default IDs [0, 0, 0, 0] if not configured in items.json.
 func GetStarterPack() *StarterPack {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	if starterPack != nil {
 		return starterPack
 	}
@@ -278,12 +482,16 @@ func GetStarterPack() *StarterPack {
 // GetConfigVersion returns the data config version stamped at export time.
 // Empty string means the embedded items.json predates this feature.
 func GetConfigVersion() string {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	return configVersion
 }
 
 // GetMinClientVersion returns the minimum client version required for online play.
 // Empty string means no gate is currently enforced.
 func GetMinClientVersion() string {
+	gameDataMu.RLock()
+	defer gameDataMu.RUnlock()
 	return minClientVersion
 }
 
@@ -0,0 +1,91 @@
+package items
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// reloadWithItems reloads the embedded items.json after mutate edits its "items" section,
+// restoring the embedded data when the test ends.
+func reloadWithItems(t *testing.T, mutate func(items map[string]interface{})) error {
+	t.Helper()
+	return reloadWithGameData(t, func(doc map[string]interface{}) {
+		mutate(doc["items"].(map[string]interface{}))
+	})
+}
+
+// reloadWithGameData reloads the embedded items.json after mutate edits the whole document.
+func reloadWithGameData(t *testing.T, mutate func(doc map[string]interface{})) error {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal(gamedata, &doc); err != nil {
+		t.Fatalf("parse items.json: %v", err)
+	}
+	mutate(doc)
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal items.json: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := ReloadGameData(nil); err != nil {
+			t.Errorf("restore game data: %v", err)
+		}
+	})
+	return ReloadGameData(data)
+}
+
+func TestEmptyLevelTreeFallsBackToCategoryDefault(t *testing.T) {
+	petID := firstPetID(t)
+	err := reloadWithItems(t, func(items map[string]interface{}) {
+		pets := items["pets"].(map[string]interface{})
+		for _, pet := range pets {
+			delete(pet.(map[string]interface{}), "levelTreeName")
+		}
+	})
+	if err != nil {
+		t.Fatalf("ReloadGameData: %v", err)
+	}
+
+	treeName, err := GetLevelTreeName(storageKeyPet, petID)
+	if err != nil || treeName != "pet_basic" {
+		t.Fatalf("tree = %q, %v; want the pets default pet_basic", treeName, err)
+	}
+	tree, _ := GetLevelTree(treeName)
+	nk := newFakeNakama()
+	newLevel, _, err := PrepareExperience(testContext("u1"), nk, testLogger{}, "u1", storageKeyPet, petID, uint32(tree.LevelThresholds[2]))
+	if err != nil {
+		t.Fatalf("PrepareExperience: %v", err)
+	}
+	if newLevel < 2 {
+		t.Errorf("pet on the default tree reached level %d, want at least 2", newLevel)
+	}
+}
+
+func TestUnknownLevelTreeFailsLoad(t *testing.T) {
+	err := reloadWithItems(t, func(items map[string]interface{}) {
+		items["default_level_trees"].(map[string]interface{})["classes"] = "missing_tree"
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing_tree") {
+		t.Errorf("unknown default tree: ReloadGameData error = %v, want rejected", err)
+	}
+
+	err = reloadWithItems(t, func(items map[string]interface{}) {
+		for _, pet := range items["pets"].(map[string]interface{}) {
+			pet.(map[string]interface{})["levelTreeName"] = "missing_tree"
+			break
+		}
+	})
+	if err == nil || !strings.Contains(err.Error(), "missing_tree") {
+		t.Errorf("unknown pet tree: ReloadGameData error = %v, want rejected", err)
+	}
+}
+
+func TestEmptyDailyXPCurveFailsLoad(t *testing.T) {
+	err := reloadWithGameData(t, func(doc map[string]interface{}) {
+		doc["economy"].(map[string]interface{})["daily_xp_curve"] = []interface{}{}
+	})
+	if err == nil || !strings.Contains(err.Error(), "daily_xp_curve") {
+		t.Errorf("empty curve: ReloadGameData error = %v, want rejected", err)
+	}
+}
@@ -3,6 +3,8 @@ package items
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/heroiclabs/nakama-common/api"
 	"github.com/heroiclabs/nakama-common/runtime"
@@ -15,8 +17,28 @@ const (
 	DefaultPieceStyleID = 0
 
 	WhiteoutPieceStyleID = 8
+
+	// grantAllItemsOnInitEnvKey is a runtime env var; "true" grants new accounts the whole
+	// catalog. Dev servers only — with everything owned, lootboxes have nothing left to drop.
+	grantAllItemsOnInitEnvKey = "GRANT_ALL_ITEMS_ON_INIT"
+
+	// ProgressionKeyLoginVerify records the UTC day progression was last verified on login.
+	ProgressionKeyLoginVerify = "login_verify"
 )
 
+type loginVerifyState struct {
+	Day int64 `json:"day"` // UTC midnight, Unix seconds
+}
+
+// grantAllItemsOnInit reports whether the GRANT_ALL_ITEMS_ON_INIT env flag is set. Defaults to false.
+func grantAllItemsOnInit(ctx context.Context) bool {
+	env, ok := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	if !ok {
+		return false
+	}
+	return env[grantAllItemsOnInitEnvKey] == "true"
+}
+
 func AfterAuthorizeUserGC(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, out *api.Session, in *api.AuthenticateGameCenterRequest) error {
 	if err := InitializeUser(ctx, logger, db, nk, out); err != nil {
 		logger.Error("User initialization failed: %v", err)
@@ -34,8 +56,12 @@ func AfterAuthorizeUserDevice(ctx context.Context, logger runtime.Logger, db *sq
 }
 
 // InitializeUser sets up a new user's wallet, inventory, and equipment atomically.
+// Returning users instead get the once-a-day progression verification.
 func InitializeUser(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, out *api.Session) error {
 	if !out.Created {
+		if userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string); ok {
+			verifyProgressionOnLogin(ctx, nk, logger, userID)
+		}
 		return nil
 	}
 
@@ -59,16 +85,20 @@ func InitializeUser(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 	// Collect all initialization writes
 	pending := NewPendingWrites()
 
-	// Add wallet initialization
-	walletChangeset := map[string]int64{
-		"gold":      500,
-		"gems":      100,
-		"treats":    1,
+	// Add wallet initialization from economy.initial_wallet
+	if walletChangeset := GetEconomyConfig().initialWallet(); len(walletChangeset) > 0 {
+		pending.AddWalletUpdate(userID, walletChangeset)
 	}
-	pending.AddWalletUpdate(userID, walletChangeset)
 
-	// Grant only starter items to new accounts. Full catalog grants are prohibited here.
-	if err := GiveStarterItemsToUser(ctx, nk, logger, userID); err != nil {
+	// Grant only starter items to new accounts; the full catalog is a dev-only env flag.
+	// Folded into the same commit as the wallet and equipment: one inventory write per
+	// item type, with progression for new pets and classes in the same batch.
+	prepareGrants := prepareStarterItemGrants
+	if grantAllItemsOnInit(ctx) {
+		logger.Warn("%s is set; granting the full catalog to new user %s", grantAllItemsOnInitEnvKey, userID)
+		prepareGrants = prepareAllItemGrants
+	}
+	if err := prepareGrants(ctx, nk, logger, userID, pending); err != nil {
 		logger.WithFields(map[string]interface{}{
 			"user":  userID,
 			"error": err.Error(),
@@ -105,30 +135,91 @@ func InitializeUser(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 	return nil
 }
 
+// verifyProgressionOnLogin runs VerifyAndFixUserProgression for a returning user at most once per
+// UTC day, repairing drift left by failed partial writes. The day is claimed only after a successful
+// run, so a failed run is retried on the next login and parallel logins may both verify. Never fails
+// the login: errors are only logged.
+func verifyProgressionOnLogin(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) {
+	today := utcMidnight(time.Now()).Unix()
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyLoginVerify,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Warn("Failed to read login verification state for user %s: %v", userID, err)
+		return
+	}
+	version := "*"
+	if len(objects) > 0 {
+		var state loginVerifyState
+		if err := json.Unmarshal([]byte(objects[0].Value), &state); err == nil && state.Day >= today {
+			return
+		}
+		version = objects[0].Version
+	}
+
+	report, err := VerifyAndFixUserProgression(ctx, nk, logger, userID)
+	if err != nil {
+		// Today stays unclaimed, so the next login retries.
+		logger.Warn("Login progression verification failed for user %s: %v", userID, err)
+		return
+	}
+
+	// Claimed only after a full run. A concurrent login may verify too; repairs are idempotent.
+	value, _ := json.Marshal(loginVerifyState{Day: today})
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyLoginVerify,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Debug("Failed to record login verification for user %s: %v", userID, err)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"user":            userID,
+		"total_fixed":     report.TotalFixed,
+		"removed_invalid": report.RemovedInvalid,
+		"pet_repairs":     report.PetRepairs,
+		"class_repairs":   report.ClassRepairs,
+		"action":          "login_verify_progression",
+	}).Info("Login progression verification complete")
+}
+
 // prepareAllItemGrants collects all item grant writes into pending.
 func prepareAllItemGrants(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, pending *PendingWrites) error {
 	mutator := NewInventoryMutator()
+	data := currentGameData()
 
 	// Pets
-	for id := range GameData.Pets {
+	for id := range data.Pets {
 		mutator.AddItem(storageKeyPet, id)
 	}
 
 	// Classes
-	for id := range GameData.Classes {
+	for id := range data.Classes {
 		mutator.AddItem(storageKeyClass, id)
 	}
 
 	// Backgrounds
-	for id := range GameData.Backgrounds {
+	for id := range data.Backgrounds {
 		mutator.AddItem(storageKeyBackground, id)
 	}
 
 	// PieceStyles
-	for id := range GameData.PieceStyles {
+	for id := range data.PieceStyles {
 		mutator.AddItem(storageKeyPieceStyle, id)
 	}
 
+	if err := checkContext(ctx, "grant all items", 0, 1); err != nil {
+		return err
+	}
+
 	invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
 	if err == nil && invPending != nil {
 		pending.Merge(invPending)
@@ -140,34 +231,42 @@ func prepareAllItemGrants(ctx context.Context, nk runtime.NakamaModule, logger r
 	return nil
 }
 
-// GiveStarterItemsToUser grants only starter items atomically.
-// Item IDs are driven by starter_pack config in items.json.
-func GiveStarterItemsToUser(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) error {
-	pending := NewPendingWrites()
+// prepareStarterItemGrants collects the starter pack grant writes into pending.
+// Item IDs are driven by starter_pack config in items.json; an ID missing from game data
+// is skipped with a warning so one bad entry doesn't block account creation.
+func prepareStarterItemGrants(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, pending *PendingWrites) error {
 	pack := GetStarterPack()
-
 	mutator := NewInventoryMutator()
 
-	for _, id := range pack.Pets {
-		mutator.AddItem(storageKeyPet, id)
-	}
-	for _, id := range pack.Classes {
-		mutator.AddItem(storageKeyClass, id)
-	}
-	for _, id := range pack.Backgrounds {
-		mutator.AddItem(storageKeyBackground, id)
-	}
-	for _, id := range pack.PieceStyles {
-		mutator.AddItem(storageKeyPieceStyle, id)
+	for storageKey, ids := range map[string][]uint32{
+		storageKeyPet:        pack.Pets,
+		storageKeyClass:      pack.Classes,
+		storageKeyBackground: pack.Backgrounds,
+		storageKeyPieceStyle: pack.PieceStyles,
+	} {
+		for _, id := range ids {
+			if !ValidateItemExists(storageKey, id) {
+				logger.Warn("Starter pack %s %d not in game data; skipping for user %s", storageKey, id, userID)
+				continue
+			}
+			mutator.AddItem(storageKey, id)
+		}
 	}
 
 	invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
-	if err == nil && invPending != nil {
-		pending.Merge(invPending)
-	} else if err != nil {
+	if err != nil {
 		return err
 	}
+	pending.Merge(invPending)
+	return nil
+}
 
+// GiveStarterItemsToUser grants only starter items atomically.
+func GiveStarterItemsToUser(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) error {
+	pending := NewPendingWrites()
+	if err := prepareStarterItemGrants(ctx, nk, logger, userID, pending); err != nil {
+		return err
+	}
 	return CommitPendingWrites(ctx, nk, logger, pending)
 }
 
@@ -178,6 +277,10 @@ func GiveAllItemsToUser(ctx context.Context, nk runtime.NakamaModule, logger run
 	if err := prepareAllItemGrants(ctx, nk, logger, userID, pending); err != nil {
 		return err
 	}
+	// The catalog commit is one large MultiUpdate; skip it if the caller already gave up.
+	if err := checkContext(ctx, "grant all items", 0, 1); err != nil {
+		return err
+	}
 
 	return CommitPendingWrites(ctx, nk, logger, pending)
 }
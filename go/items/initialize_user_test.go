@@ -0,0 +1,67 @@
+package items
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+)
+
+func TestInitialWalletAppliedOnlyOnCreation(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.InitialWallet = map[string]int64{"gold": 42, "gems": 7} })
+	nk := newFakeNakama()
+
+	if err := InitializeUser(testContext("new"), testLogger{}, nil, nk, &api.Session{Created: true}); err != nil {
+		t.Fatalf("InitializeUser (new): %v", err)
+	}
+	if w := nk.wallet("new"); w["gold"] != 42 || w["gems"] != 7 || w["treats"] != 0 {
+		t.Errorf("new account wallet = %v, want the configured 42 gold, 7 gems", w)
+	}
+
+	nk.setWallet("existing", map[string]int64{"gold": 5})
+	if err := InitializeUser(testContext("existing"), testLogger{}, nil, nk, &api.Session{Created: false}); err != nil {
+		t.Fatalf("InitializeUser (existing): %v", err)
+	}
+	if w := nk.wallet("existing"); w["gold"] != 5 || w["gems"] != 0 {
+		t.Errorf("existing account wallet = %v, want it untouched", w)
+	}
+}
+
+func TestInitialWalletDefaultsWhenUnset(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.InitialWallet = nil })
+	nk := newFakeNakama()
+
+	if err := InitializeUser(testContext("new"), testLogger{}, nil, nk, &api.Session{Created: true}); err != nil {
+		t.Fatalf("InitializeUser: %v", err)
+	}
+	if w := nk.wallet("new"); w["gold"] != 500 || w["gems"] != 100 || w["treats"] != 1 {
+		t.Errorf("wallet = %v, want the default 500 gold, 100 gems, 1 treat", w)
+	}
+}
+
+func TestLoginVerifyDayClaimedOnlyAfterSuccess(t *testing.T) {
+	// Two pets missing from game data: each is removed with its own write.
+	invalid := []uint32{900001, 900002}
+	nk := newFakeNakama()
+	nk.put(t, storageCollectionInventory, storageKeyPet, "u1", InventoryData{Items: invalid})
+
+	// The login drops during the first removal.
+	ctx, cancel := context.WithCancel(testContext("u1"))
+	nk.storageWriteHook, nk.multiUpdateHook = cancel, cancel
+	verifyProgressionOnLogin(ctx, nk, testLogger{}, "u1")
+	var state loginVerifyState
+	if nk.get(t, storageCollectionProgression, ProgressionKeyLoginVerify, "u1", &state) {
+		t.Fatal("an interrupted verification claimed the day")
+	}
+
+	nk.storageWriteHook, nk.multiUpdateHook = nil, nil
+	verifyProgressionOnLogin(testContext("u1"), nk, testLogger{}, "u1")
+	if !nk.get(t, storageCollectionProgression, ProgressionKeyLoginVerify, "u1", &state) || state.Day != utcMidnight(time.Now()).Unix() {
+		t.Fatalf("retry did not claim today: %+v", state)
+	}
+	var inv InventoryData
+	if nk.get(t, storageCollectionInventory, storageKeyPet, "u1", &inv) && len(inv.Items) != 0 {
+		t.Errorf("pets = %v after the retry, want the invalid ones removed", inv.Items)
+	}
+}
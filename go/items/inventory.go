@@ -174,6 +174,72 @@ func EquipAbility(ctx context.Context, logger runtime.Logger, nk runtime.NakamaM
 	return SaveItemProgression(ctx, nk, logger, userID, progressionKey, req.ItemID, prog)
 }
 
+func EquipSprite(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, itemType string, payload string) error {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return err
+	}
+
+	var req SpriteEquipRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return errors.ErrUnmarshal
+	}
+
+	if !ValidateItemExists(itemType, req.ItemID) {
+		LogWarn(ctx, logger, "Invalid item ID for equip_sprite")
+		return errors.ErrInvalidItemID
+	}
+
+	owned, err := IsItemOwned(ctx, nk, userID, req.ItemID, itemType)
+	if err != nil || !owned {
+		return errors.ErrNotOwned
+	}
+
+	var spriteCount int
+	var itemExists bool
+
+	switch itemType {
+	case storageKeyPet:
+		if pet, exists := GetPet(req.ItemID); exists {
+			spriteCount = pet.SpriteCount
+			itemExists = true
+		}
+	case storageKeyClass:
+		if class, exists := GetClass(req.ItemID); exists {
+			spriteCount = class.SpriteCount
+			itemExists = true
+		}
+	}
+
+	if !itemExists {
+		return errors.ErrItemNotFound
+	}
+
+	if req.SpriteIndex < 0 || req.SpriteIndex >= spriteCount {
+		return errors.ErrInvalidSprite
+	}
+
+	var progressionKey string
+	if itemType == storageKeyPet {
+		progressionKey = ProgressionKeyPet
+	} else {
+		progressionKey = ProgressionKeyClass
+	}
+
+	prog, err := GetItemProgression(ctx, nk, logger, userID, progressionKey, req.ItemID)
+	if err != nil {
+		return err
+	}
+
+	if !prog.HasSprite(req.SpriteIndex) {
+		return errors.ErrSpriteNotUnlocked
+	}
+
+	prog.EquippedSprite = req.SpriteIndex
+
+	return SaveItemProgression(ctx, nk, logger, userID, progressionKey, req.ItemID, prog)
+}
+
 func IsAbilityAvailable(ctx context.Context, logger runtime.Logger, nk runtime.NakamaModule, userID string, itemID uint32, abilityID uint32, itemType string) error {
 	if !ValidateItemExists(itemType, itemID) {
 		return errors.ErrInvalidItemID
@@ -311,13 +377,35 @@ func IsItemOwned(ctx context.Context, nk runtime.NakamaModule, userID string, it
 	if err != nil {
 		return false, fmt.Errorf("inventory check: %w", err)
 	}
+	return data.Has(itemID), nil
+}
 
-	for _, id := range data.Items {
-		if id == itemID {
-			return true, nil
+// IsLoadoutOwned checks the equipped pet and class in one StorageRead.
+func IsLoadoutOwned(ctx context.Context, nk runtime.NakamaModule, userID string, petID, classID uint32) (bool, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{
+		{Collection: storageCollectionInventory, Key: storageKeyPet, UserID: userID},
+		{Collection: storageCollectionInventory, Key: storageKeyClass, UserID: userID},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	want := map[string]uint32{storageKeyPet: petID, storageKeyClass: classID}
+	found := 0
+	for _, obj := range objects {
+		itemID, ok := want[obj.Key]
+		if !ok {
+			continue
+		}
+		data, err := UnmarshalJSON[InventoryData](obj.Value)
+		if err != nil {
+			return false, fmt.Errorf("inventory check: %w", err)
+		}
+		if data.Has(itemID) {
+			found++
 		}
 	}
-	return false, nil
+	return found == len(want), nil
 }
 
 // PrepareItemGrant prepares writes to grant an item (inventory + progression if needed).
@@ -353,20 +441,42 @@ func GiveClass(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logg
 
 // GiveBackground grants a background to a user atomically.
 func GiveBackground(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, backgroundID uint32) error {
-	pending, err := PrepareItemGrant(ctx, nk, logger, userID, storageKeyBackground, backgroundID)
-	if err != nil {
-		return err
-	}
-	return CommitPendingWrites(ctx, nk, logger, pending)
+	_, err := GrantBackground(ctx, nk, logger, userID, backgroundID)
+	return err
 }
 
 // GivePieceStyle grants a piece style to a user atomically.
 func GivePieceStyle(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, styleID uint32) error {
-	pending, err := PrepareItemGrant(ctx, nk, logger, userID, storageKeyPieceStyle, styleID)
+	_, err := GrantPieceStyle(ctx, nk, logger, userID, styleID)
+	return err
+}
+
+// GrantBackground grants a background atomically and reports whether it was newly granted;
+// false means the user already owned it and the caller may apply duplicate compensation.
+func GrantBackground(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, backgroundID uint32) (bool, error) {
+	return grantCosmetic(ctx, nk, logger, userID, storageKeyBackground, backgroundID)
+}
+
+// GrantPieceStyle grants a piece style atomically and reports whether it was newly granted.
+func GrantPieceStyle(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, styleID uint32) (bool, error) {
+	return grantCosmetic(ctx, nk, logger, userID, storageKeyPieceStyle, styleID)
+}
+
+func grantCosmetic(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, storageKey string, itemID uint32) (bool, error) {
+	if !ValidateItemExists(storageKey, itemID) {
+		return false, errors.ErrInvalidItem
+	}
+
+	mutator := NewInventoryMutator()
+	mutator.AddItem(storageKey, itemID)
+	pending, err := mutator.CompileWrites(ctx, nk, logger, userID)
 	if err != nil {
-		return err
+		return false, err
 	}
-	return CommitPendingWrites(ctx, nk, logger, pending)
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		return false, err
+	}
+	return mutator.WasGranted(storageKey, itemID), nil
 }
 
 func RemoveItemFromInventory(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, itemType string, itemID uint32) error {
@@ -389,26 +499,13 @@ func RemoveItemFromInventory(ctx context.Context, nk runtime.NakamaModule, logge
 		return fmt.Errorf("inventory data unmarshal: %w", err)
 	}
 	version := objs[0].Version
-	current := *inventoryData
-
-	// Find and remove the itemID
-	found := false
-	newItems := make([]uint32, 0, len(current.Items))
-	for _, id := range current.Items {
-		if id == itemID {
-			found = true
-			continue // Skip the item to remove it
-		}
-		newItems = append(newItems, id)
-	}
 
-	if !found {
+	if !inventoryData.Remove(itemID) {
 		// Item not in inventory, nothing to do
 		return nil
 	}
 
-	data := InventoryData{Items: newItems}
-	value, err := json.Marshal(data)
+	value, err := json.Marshal(inventoryData)
 	if err != nil {
 		LogError(ctx, logger, "Inventory marshal failed for removal", err)
 		return fmt.Errorf("inventory marshal error: %w", err)
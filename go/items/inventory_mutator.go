@@ -18,6 +18,9 @@ type InventoryMutator struct {
 	
 	// Track progression init requirements for new items
 	progressionInits map[string][]uint32 
+
+	// Items CompileWrites found unowned and queued, by storage key
+	granted map[string][]uint32
 }
 
 func NewInventoryMutator() *InventoryMutator {
@@ -25,7 +28,19 @@ func NewInventoryMutator() *InventoryMutator {
 		adds:             make(map[string][]uint32),
 		removes:          make(map[string][]uint32),
 		progressionInits: make(map[string][]uint32),
+		granted:          make(map[string][]uint32),
+	}
+}
+
+// WasGranted reports whether the last CompileWrites queued itemID as a new grant,
+// i.e. the user didn't already own it. Lets callers compensate duplicates.
+func (m *InventoryMutator) WasGranted(itemType string, itemID uint32) bool {
+	for _, id := range m.granted[m.resolveStorageKey(itemType)] {
+		if id == itemID {
+			return true
+		}
 	}
+	return false
 }
 
 // AddItem queues an item to be granted.
@@ -88,7 +103,7 @@ func (m *InventoryMutator) CompileWrites(ctx context.Context, nk runtime.NakamaM
 		})
 	}
 
-	objs, err := nk.StorageRead(ctx, reads)
+	objs, err := storageReadWithRetry(ctx, nk, logger, reads)
 	if err != nil {
 		return nil, err
 	}
@@ -114,11 +129,11 @@ func (m *InventoryMutator) CompileWrites(ctx context.Context, nk runtime.NakamaM
 
 		changed := false
 
-		// Apply Adds
+		// Apply Adds (sorted insert; re-adding an owned item is a no-op)
 		for _, addID := range m.adds[k] {
-			if !contains(data.Items, addID) {
-				data.Items = append(data.Items, addID)
+			if data.Add(addID) {
 				changed = true
+				m.granted[k] = append(m.granted[k], addID)
 
 				// Only queue progression init if the item was truly newly added
 				if k == storageKeyPet || k == storageKeyClass {
@@ -133,17 +148,9 @@ func (m *InventoryMutator) CompileWrites(ctx context.Context, nk runtime.NakamaM
 
 		// Apply Removes
 		for _, remID := range m.removes[k] {
-			newItems := make([]uint32, 0)
-			removedLocally := false
-			for _, id := range data.Items {
-				if id == remID && !removedLocally {
-					removedLocally = true // Remove only one instance
-					changed = true
-				} else {
-					newItems = append(newItems, id)
-				}
+			if data.Remove(remID) {
+				changed = true
 			}
-			data.Items = newItems
 		}
 
 		// Queue exactly ONE write per key if changes occurred
@@ -197,13 +204,4 @@ func (m *InventoryMutator) CompileWrites(ctx context.Context, nk runtime.NakamaM
 	}
 
 	return pending, nil
-}
-
-func contains(arr []uint32, val uint32) bool {
-	for _, v := range arr {
-		if v == val {
-			return true
-		}
-	}
-	return false
 }
\ No newline at end of file
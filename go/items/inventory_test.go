@@ -0,0 +1,40 @@
+package items
+
+import "testing"
+
+func TestGrantCosmeticReportsNewlyGranted(t *testing.T) {
+	var backgroundID, styleID uint32
+	for id := range GameData.Backgrounds {
+		backgroundID = id
+		break
+	}
+	for id := range GameData.PieceStyles {
+		styleID = id
+		break
+	}
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	for _, grant := range []struct {
+		name  string
+		grant func() (bool, error)
+	}{
+		{"background", func() (bool, error) { return GrantBackground(ctx, nk, testLogger{}, "u1", backgroundID) }},
+		{"piece style", func() (bool, error) { return GrantPieceStyle(ctx, nk, testLogger{}, "u1", styleID) }},
+	} {
+		if granted, err := grant.grant(); err != nil || !granted {
+			t.Fatalf("first %s grant = %v, %v; want newly granted", grant.name, granted, err)
+		}
+		if granted, err := grant.grant(); err != nil || granted {
+			t.Errorf("re-granting an owned %s = %v, %v; want not newly granted", grant.name, granted, err)
+		}
+	}
+
+	inv, err := GetUserInventory(ctx, nk, testLogger{}, "u1")
+	if err != nil {
+		t.Fatalf("GetUserInventory: %v", err)
+	}
+	if len(inv.Backgrounds) != 1 || len(inv.PieceStyles) != 1 {
+		t.Errorf("inventory backgrounds %v, piece styles %v; want one of each", inv.Backgrounds, inv.PieceStyles)
+	}
+}
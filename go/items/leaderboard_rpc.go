@@ -13,23 +13,88 @@ import (
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
+// GetLeaderboardsConfig returns the configured boards with defaults applied to empty fields.
+func GetLeaderboardsConfig() *LeaderboardsConfig {
+	cfg := LeaderboardsConfig{}
+	gameDataMu.RLock()
+	if leaderboards != nil {
+		cfg = *leaderboards
+	}
+	gameDataMu.RUnlock()
+	withDefaults := func(def LeaderboardDefinition, id, operator, reset string) LeaderboardDefinition {
+		if def.ID == "" {
+			def.ID = id
+			// Reset cadence only defaults alongside the ID; an explicit ID opts into explicit config.
+			if def.Reset == "" {
+				def.Reset = reset
+			}
+		}
+		if def.SortOrder == "" {
+			def.SortOrder = "desc"
+		}
+		if def.Operator == "" {
+			def.Operator = operator
+		}
+		return def
+	}
+	cfg.SoloSeason = withDefaults(cfg.SoloSeason, LeaderboardSoloSeason, "best", "")
+	cfg.SoloWeekly = withDefaults(cfg.SoloWeekly, LeaderboardSoloWeekly, "best", "0 0 * * 1")
+	cfg.WinsSeason = withDefaults(cfg.WinsSeason, Leaderboard1v1Season, "incr", "")
+	cfg.WinsWeekly = withDefaults(cfg.WinsWeekly, Leaderboard1v1Weekly, "incr", "0 0 * * 1")
+	return &cfg
+}
+
+// All returns every configured board in creation order.
+func (c *LeaderboardsConfig) All() []LeaderboardDefinition {
+	return []LeaderboardDefinition{c.SoloSeason, c.SoloWeekly, c.WinsSeason, c.WinsWeekly}
+}
+
+// isKnownLeaderboard reports whether boardID is one of the configured boards.
+func isKnownLeaderboard(boardID string) bool {
+	for _, def := range GetLeaderboardsConfig().All() {
+		if def.ID == boardID {
+			return true
+		}
+	}
+	return false
+}
+
+// BootstrapLeaderboards creates every configured board. Non-fatal: boards may already
+// exist from a previous startup, so failures are logged and skipped.
+func BootstrapLeaderboards(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger) []string {
+	created := make([]string, 0, 4)
+	for _, lb := range GetLeaderboardsConfig().All() {
+		if err := nk.LeaderboardCreate(ctx, lb.ID, true, lb.SortOrder, lb.Operator, lb.Reset, nil, true); err != nil {
+			logger.Error("Failed to create leaderboard %s: %v", lb.ID, err)
+			continue
+		}
+		created = append(created, lb.ID)
+	}
+	return created
+}
+
 // Writes match result to leaderboards synchronously and returns the season rank, delta, board ID, and the new array of CompetitiveBoardStates.
-// Solo: BEST operator (writes always). 1v1: INCREMENT operator (writes on win only).
-func writeLeaderboardRecords(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req *MatchResultRequest, isSolo bool, actualWon bool) (int, int, string, []notify.CompetitiveBoardState) {
+// Solo: BEST operator (writes always). 1v1: INCREMENT operator (writes on win only). Shadow-banned users never write.
+func writeLeaderboardRecords(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req *MatchResultRequest, isSolo bool, actualWon bool, shadowBanned bool) (int, int, string, []notify.CompetitiveBoardState) {
+	if shadowBanned {
+		return 0, 0, "", nil
+	}
+
 	var globalBoard, weeklyBoard string
 	var score, subscore int64
+	boardsCfg := GetLeaderboardsConfig()
 
 	if isSolo {
-		globalBoard = LeaderboardSoloSeason
-		weeklyBoard = LeaderboardSoloWeekly
+		globalBoard = boardsCfg.SoloSeason.ID
+		weeklyBoard = boardsCfg.SoloWeekly.ID
 		score = int64(req.FinalScore)
 		subscore = int64(req.MatchDurationSec)
 	} else {
 		if !actualWon {
 			return 0, 0, "", nil
 		}
-		globalBoard = Leaderboard1v1Season
-		weeklyBoard = Leaderboard1v1Weekly
+		globalBoard = boardsCfg.WinsSeason.ID
+		weeklyBoard = boardsCfg.WinsWeekly.ID
 		score = 1
 		subscore = 0
 	}
@@ -147,6 +212,9 @@ func RpcGetLeaderboard(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 		return "", errors.ErrUnmarshal
 	}
 	if req.BoardID == "" {
+		req.BoardID = GetLeaderboardsConfig().WinsSeason.ID
+	}
+	if !isKnownLeaderboard(req.BoardID) {
 		return "", errors.ErrInvalidInput
 	}
 
@@ -189,6 +257,65 @@ func RpcGetLeaderboard(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 	return string(b), nil
 }
 
+// Fetches the caller's rank and the records around it via a haystack query, without paging the board.
+func RpcGetMyRank(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req MyRankRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+	}
+	if req.BoardID == "" {
+		req.BoardID = GetLeaderboardsConfig().WinsSeason.ID
+	}
+	if !isKnownLeaderboard(req.BoardID) {
+		return "", errors.ErrInvalidInput
+	}
+
+	around := req.Around
+	if around < 1 || around > 25 {
+		around = 5
+	}
+
+	haystack, err := nk.LeaderboardRecordsHaystack(ctx, req.BoardID, userID, around, "", 0)
+	if err != nil {
+		logger.Error("[leaderboard] Failed haystack on %s for %s: %v", req.BoardID, userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := MyRankResponse{
+		BoardID: req.BoardID,
+		Around:  make([]LeaderboardEntry, 0),
+	}
+	// An unranked caller has no haystack anchor; report unranked rather than whatever page came back.
+	if haystack != nil {
+		for _, r := range haystack.Records {
+			if r.OwnerId == userID {
+				entry := leaderboardEntryFromRecord(r)
+				resp.MyEntry = &entry
+				resp.Ranked = true
+				break
+			}
+		}
+		if resp.Ranked {
+			for _, r := range haystack.Records {
+				resp.Around = append(resp.Around, leaderboardEntryFromRecord(r))
+			}
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(b), nil
+}
+
 // Fetches a board filtered to the caller's mutual friends and self.
 func RpcGetFriendsLeaderboard(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	userID, err := GetUserIDFromContext(ctx, logger)
@@ -0,0 +1,58 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func myRank(t *testing.T, nk *fakeNakama, userID, payload string) MyRankResponse {
+	t.Helper()
+	out, err := RpcGetMyRank(testContext(userID), testLogger{}, nil, nk, payload)
+	if err != nil {
+		t.Fatalf("RpcGetMyRank: %v", err)
+	}
+	var resp MyRankResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return resp
+}
+
+func TestMyRankMatchesSeededBoard(t *testing.T) {
+	nk := newFakeNakama()
+	board := GetLeaderboardsConfig().WinsSeason.ID
+	for i, id := range []string{"u1", "u2", "u3", "u4", "u5", "u6"} {
+		nk.writeRecordLocked(board, id, id, int64(100-10*i), 0)
+	}
+
+	resp := myRank(t, nk, "u4", `{"around": 3}`)
+	if !resp.Ranked || resp.MyEntry == nil {
+		t.Fatalf("resp = %+v, want u4 ranked", resp)
+	}
+	if resp.BoardID != board || resp.MyEntry.Rank != 4 || resp.MyEntry.Score != 70 {
+		t.Errorf("my entry = %+v on %s, want rank 4 score 70 on %s", resp.MyEntry, resp.BoardID, board)
+	}
+	var ranks []int64
+	for _, e := range resp.Around {
+		ranks = append(ranks, e.Rank)
+	}
+	if len(ranks) != 3 || ranks[0] != 3 || ranks[2] != 5 {
+		t.Errorf("around ranks = %v, want [3 4 5]", ranks)
+	}
+}
+
+func TestMyRankUnranked(t *testing.T) {
+	nk := newFakeNakama()
+	nk.writeRecordLocked(GetLeaderboardsConfig().WinsSeason.ID, "u1", "u1", 50, 0)
+
+	resp := myRank(t, nk, "u2", "")
+	if resp.Ranked || resp.MyEntry != nil || len(resp.Around) != 0 {
+		t.Errorf("resp = %+v, want unranked with no neighbours", resp)
+	}
+}
+
+func TestMyRankRejectsUnknownBoard(t *testing.T) {
+	if _, err := RpcGetMyRank(testContext("u1"), testLogger{}, nil, newFakeNakama(), `{"board_id": "nope"}`); err == nil {
+		t.Error("unknown board accepted")
+	}
+}
@@ -0,0 +1,346 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionLoadouts holds one owner-read document of named presets per user.
+	storageCollectionLoadouts = "loadouts"
+	storageKeyLoadoutPresets  = "presets"
+	maxLoadoutPresets         = 10
+	maxLoadoutNameLen         = 32
+)
+
+// LoadoutPreset is a named full loadout: equipped items plus the pet and class ability and sprite.
+type LoadoutPreset struct {
+	Name             string `json:"name"`
+	PetID            uint32 `json:"pet_id"`
+	ClassID          uint32 `json:"class_id"`
+	BackgroundID     uint32 `json:"background_id"`
+	PieceStyleID     uint32 `json:"piece_style_id"`
+	PetAbilityID     uint32 `json:"pet_ability_id"`
+	ClassAbilityID   uint32 `json:"class_ability_id"`
+	PetSpriteIndex   int    `json:"pet_sprite_index"`
+	ClassSpriteIndex int    `json:"class_sprite_index"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+// LoadoutPresets is the stored preset list, in save order.
+type LoadoutPresets struct {
+	Presets []LoadoutPreset `json:"presets"`
+}
+
+type ApplyLoadoutRequest struct {
+	Name string `json:"name"`
+}
+
+// readLoadoutPresets returns the caller's presets and the storage version for an OCC write.
+func readLoadoutPresets(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) (*LoadoutPresets, string, error) {
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
+		Collection: storageCollectionLoadouts,
+		Key:        storageKeyLoadoutPresets,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(objects) == 0 {
+		return &LoadoutPresets{Presets: []LoadoutPreset{}}, "*", nil
+	}
+	presets, err := UnmarshalJSON[LoadoutPresets](objects[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	if presets.Presets == nil {
+		presets.Presets = []LoadoutPreset{}
+	}
+	return presets, objects[0].Version, nil
+}
+
+// loadoutAbilityIndex returns the index of abilityID in abilityIDs if the progression has it unlocked.
+func loadoutAbilityIndex(abilityIDs []uint32, prog *ItemProgression, abilityID uint32) (int, error) {
+	for i, id := range abilityIDs {
+		if id != abilityID {
+			continue
+		}
+		if !prog.HasAbility(i) {
+			return 0, errors.ErrAbilityNotUnlocked
+		}
+		return i, nil
+	}
+	return 0, errors.ErrInvalidAbility
+}
+
+// loadoutSpriteIndex checks index against the item's sprite count and the progression's unlocks.
+func loadoutSpriteIndex(spriteCount int, prog *ItemProgression, index int) (int, error) {
+	if index < 0 || index >= spriteCount {
+		return 0, errors.ErrInvalidSprite
+	}
+	if !prog.HasSprite(index) {
+		return 0, errors.ErrSpriteNotUnlocked
+	}
+	return index, nil
+}
+
+// validateLoadoutPreset checks that every item in p exists and is owned, that both abilities and
+// sprites are unlocked and that the abilities pass ability_rules. It returns the pet and class
+// progression with the preset's ability and sprite already equipped, ready to be written.
+func validateLoadoutPreset(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, p *LoadoutPreset) (*ItemProgression, *ItemProgression, error) {
+	pet, ok := GetPet(p.PetID)
+	if !ok {
+		return nil, nil, errors.ErrInvalidItemID
+	}
+	class, ok := GetClass(p.ClassID)
+	if !ok {
+		return nil, nil, errors.ErrInvalidItemID
+	}
+	if !ValidateItemExists(storageKeyBackground, p.BackgroundID) || !ValidateItemExists(storageKeyPieceStyle, p.PieceStyleID) {
+		return nil, nil, errors.ErrInvalidItemID
+	}
+
+	// One inventory read covers all four items.
+	want := map[string]uint32{
+		storageKeyPet:        p.PetID,
+		storageKeyClass:      p.ClassID,
+		storageKeyBackground: p.BackgroundID,
+		storageKeyPieceStyle: p.PieceStyleID,
+	}
+	reads := make([]*runtime.StorageRead, 0, len(want))
+	for key := range want {
+		reads = append(reads, &runtime.StorageRead{Collection: storageCollectionInventory, Key: key, UserID: userID})
+	}
+	objects, err := storageReadWithRetry(ctx, nk, logger, reads)
+	if err != nil {
+		return nil, nil, errors.ErrFailedCheckOwnership
+	}
+	owned := 0
+	for _, obj := range objects {
+		itemID, ok := want[obj.Key]
+		if !ok {
+			continue
+		}
+		data, err := UnmarshalJSON[InventoryData](obj.Value)
+		if err != nil {
+			return nil, nil, errors.ErrFailedCheckOwnership
+		}
+		if data.Has(itemID) {
+			owned++
+		}
+	}
+	if owned != len(want) {
+		return nil, nil, errors.ErrItemNotOwnedForbidden
+	}
+
+	petProg, err := GetItemProgression(ctx, nk, logger, userID, ProgressionKeyPet, p.PetID)
+	if err != nil {
+		return nil, nil, errors.ErrCouldNotReadStorage
+	}
+	classProg, err := GetItemProgression(ctx, nk, logger, userID, ProgressionKeyClass, p.ClassID)
+	if err != nil {
+		return nil, nil, errors.ErrCouldNotReadStorage
+	}
+
+	if petProg.EquippedAbility, err = loadoutAbilityIndex(pet.AbilityIDs, petProg, p.PetAbilityID); err != nil {
+		return nil, nil, err
+	}
+	if classProg.EquippedAbility, err = loadoutAbilityIndex(class.AbilityIDs, classProg, p.ClassAbilityID); err != nil {
+		return nil, nil, err
+	}
+	if petProg.EquippedSprite, err = loadoutSpriteIndex(pet.SpriteCount, petProg, p.PetSpriteIndex); err != nil {
+		return nil, nil, err
+	}
+	if classProg.EquippedSprite, err = loadoutSpriteIndex(class.SpriteCount, classProg, p.ClassSpriteIndex); err != nil {
+		return nil, nil, err
+	}
+
+	if reason := GetAbilityRulesConfig().Validate([]uint32{p.PetAbilityID, p.ClassAbilityID}); reason != "" {
+		logger.Warn("Loadout preset %q for user %s rejected: %s", p.Name, userID, reason)
+		return nil, nil, errors.ErrIllegalLoadout
+	}
+	return petProg, classProg, nil
+}
+
+// RpcSaveLoadout validates a preset and saves it under its name, replacing a preset of the same name.
+func RpcSaveLoadout(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var preset LoadoutPreset
+	if err := json.Unmarshal([]byte(payload), &preset); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	preset.Name = strings.TrimSpace(preset.Name)
+	if preset.Name == "" || len(preset.Name) > maxLoadoutNameLen {
+		return "", errors.ErrInvalidInput
+	}
+	if _, _, err := validateLoadoutPreset(ctx, nk, logger, userID, &preset); err != nil {
+		return "", err
+	}
+	preset.UpdatedAt = time.Now().Unix()
+
+	presets, version, err := readLoadoutPresets(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read loadout presets for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+	replaced := false
+	for i := range presets.Presets {
+		if presets.Presets[i].Name == preset.Name {
+			presets.Presets[i] = preset
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		if len(presets.Presets) >= maxLoadoutPresets {
+			return "", errors.ErrLoadoutPresetsFull
+		}
+		presets.Presets = append(presets.Presets, preset)
+	}
+
+	value, err := json.Marshal(presets)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionLoadouts,
+		Key:             storageKeyLoadoutPresets,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version, // OCC: a concurrent save fails rather than being lost
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to write loadout presets for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	return string(value), nil
+}
+
+// RpcApplyLoadout equips a saved preset. Ownership and unlocks are re-checked, since items can be
+// revoked after saving, and the four equipment writes and both progression writes commit together.
+func RpcApplyLoadout(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req ApplyLoadoutRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	presets, _, err := readLoadoutPresets(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read loadout presets for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+	var preset *LoadoutPreset
+	for i := range presets.Presets {
+		if presets.Presets[i].Name == req.Name {
+			preset = &presets.Presets[i]
+			break
+		}
+	}
+	if preset == nil {
+		return "", errors.ErrLoadoutNotFound
+	}
+
+	petProg, classProg, err := validateLoadoutPreset(ctx, nk, logger, userID, preset)
+	if err != nil {
+		return "", err
+	}
+
+	equipped := map[string]uint32{
+		storageKeyPet:        preset.PetID,
+		storageKeyClass:      preset.ClassID,
+		storageKeyBackground: preset.BackgroundID,
+		storageKeyPieceStyle: preset.PieceStyleID,
+	}
+	reads := make([]*runtime.StorageRead, 0, len(equipped))
+	for key := range equipped {
+		reads = append(reads, &runtime.StorageRead{Collection: storageCollectionEquipment, Key: key, UserID: userID})
+	}
+	objects, err := storageReadWithRetry(ctx, nk, logger, reads)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+	versions := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		versions[obj.Key] = obj.Version
+	}
+
+	pending := NewPendingWrites()
+	for key, itemID := range equipped {
+		value, err := json.Marshal(EquipmentData{ID: itemID})
+		if err != nil {
+			return "", errors.ErrMarshal
+		}
+		pending.AddStorageWrite(&runtime.StorageWrite{
+			Collection:      storageCollectionEquipment,
+			Key:             key,
+			UserID:          userID,
+			Value:           string(value),
+			PermissionRead:  2,
+			PermissionWrite: 0,
+			Version:         versions[key],
+		})
+	}
+	for _, p := range []struct {
+		key  string
+		id   uint32
+		prog *ItemProgression
+	}{
+		{ProgressionKeyPet, preset.PetID, petProg},
+		{ProgressionKeyClass, preset.ClassID, classProg},
+	} {
+		write, err := BuildProgressionWrite(userID, p.key, p.id, p.prog)
+		if err != nil {
+			return "", errors.ErrMarshal
+		}
+		pending.AddStorageWrite(write)
+	}
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Failed to apply loadout preset %q for user %s: %v", preset.Name, userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	respBytes, err := json.Marshal(preset)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// RpcListLoadouts returns the caller's saved presets.
+func RpcListLoadouts(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	presets, _, err := readLoadoutPresets(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read loadout presets for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	respBytes, err := json.Marshal(presets)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -16,14 +16,35 @@ import (
 
 const (
 	storageCollectionLootboxes = "lootboxes"
+
+	// openedLootboxRetention is how long an opened box, and with it its open proof, is kept.
+	// Older opened boxes are purged lazily by get_lootboxes.
+	openedLootboxRetention = 30 * 24 * time.Hour
 )
 
+// lootboxTypeToStorageKey maps pool item types to inventory keys. Shared by every open
+// so the lookup isn't rebuilt per request.
+var lootboxTypeToStorageKey = map[string]string{
+	"background":  storageKeyBackground,
+	"piece_style": storageKeyPieceStyle,
+	"pet":         storageKeyPet,
+	"class":       storageKeyClass,
+}
+
 // Lootbox represents an unopened or opened lootbox
 type Lootbox struct {
-	ID        string `json:"id"`
-	Tier      string `json:"tier"`
-	CreatedAt int64  `json:"created_at"`
-	Opened    bool   `json:"opened"`
+	ID         string        `json:"id"`
+	Tier       string        `json:"tier"`
+	CreatedAt  int64         `json:"created_at"`
+	Opened     bool          `json:"opened"`
+	Commitment string        `json:"commitment,omitempty"` // SHA-256 of the server secret; see lootbox_proof.go
+	Proof      *LootboxProof `json:"proof,omitempty"`      // Revealed on open
+}
+
+// LootboxListing is a get_lootboxes entry: the box plus the time left on its tier's open cooldown.
+type LootboxListing struct {
+	Lootbox
+	CooldownRemainingSec int64 `json:"cooldown_remaining_sec,omitempty"`
 }
 
 // LootboxContents represents the rewards from opening a lootbox (internal use)
@@ -34,9 +55,16 @@ type LootboxContents struct {
 	Items      []uint32                `json:"items"`
 	ItemTypes  []string                `json:"item_types"`
 	Duplicates []notify.DuplicateGrant `json:"duplicates"`
+	Event      string                  `json:"event,omitempty"` // Live-ops event whose multipliers applied to this roll
+	// PoolsExhausted: a pool roll hit a pool the player already owns in full, so the exhausted
+	// compensation below was paid in place of its duplicate fallback.
+	PoolsExhausted  bool `json:"pools_exhausted,omitempty"`
+	ExhaustedGems   int  `json:"exhausted_gems,omitempty"`
+	ExhaustedTreats int  `json:"exhausted_treats,omitempty"`
 }
 
-// RpcGetLootboxes returns all unopened lootboxes for a user
+// RpcGetLootboxes returns all unopened lootboxes for a user. Boxes whose tier is on its open
+// cooldown carry cooldown_remaining_sec; opening one earlier fails with ErrLootboxCooldown.
 func RpcGetLootboxes(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
 	if !ok {
@@ -49,7 +77,10 @@ func RpcGetLootboxes(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 		return "", errors.ErrCouldNotReadStorage
 	}
 
-	lootboxes := make([]Lootbox, 0)
+	now := time.Now()
+	lootboxes := make([]LootboxListing, 0)
+	var expired []*runtime.StorageDelete // Each purged box and its secret
+	purged := 0
 	for _, obj := range objects {
 		var lb Lootbox
 		if err := json.Unmarshal([]byte(obj.Value), &lb); err != nil {
@@ -57,15 +88,45 @@ func RpcGetLootboxes(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 			continue
 		}
 		if !lb.Opened {
-			lootboxes = append(lootboxes, lb)
+			lootboxes = append(lootboxes, LootboxListing{Lootbox: lb})
+			continue
+		}
+		// The open is the box's last write, so UpdateTime is when it was opened.
+		if obj.UpdateTime != nil && now.Sub(obj.UpdateTime.AsTime()) > openedLootboxRetention {
+			expired = append(expired, &runtime.StorageDelete{
+				Collection: storageCollectionLootboxes,
+				Key:        obj.Key,
+				UserID:     userID,
+				Version:    obj.Version,
+			}, &runtime.StorageDelete{
+				// Normally removed at open; this catches one whose delete failed then.
+				Collection: storageCollectionLootboxSecrets,
+				Key:        obj.Key,
+				UserID:     userID,
+			})
+			purged++
 		}
 	}
 
+	if err := setLootboxCooldowns(ctx, nk, userID, lootboxes, now); err != nil {
+		logger.Error("Failed to read lootbox cooldowns for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
 	respBytes, err := json.Marshal(lootboxes)
 	if err != nil {
 		return "", errors.ErrMarshal
 	}
 
+	// StorageDelete can't join a MultiUpdate; purging after the read is best-effort.
+	if len(expired) > 0 {
+		if err := nk.StorageDelete(ctx, expired); err != nil {
+			logger.Warn("Failed to purge %d opened lootboxes for user %s: %v", purged, userID, err)
+		} else {
+			logger.Debug("Purged %d opened lootboxes for user %s", purged, userID)
+		}
+	}
+
 	return string(respBytes), nil
 }
 
@@ -84,7 +145,7 @@ func RpcOpenLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 	}
 
 	// Read lootbox
-	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
 		Collection: storageCollectionLootboxes,
 		Key:        req.ID,
 		UserID:     userID,
@@ -102,52 +163,96 @@ func RpcOpenLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 		return "", errors.ErrLootboxAlreadyOpened
 	}
 
+	// Per-tier pacing; the stamp commits with the open so two racing opens can't both pass.
+	now := time.Now()
+	var cooldowns *LootboxCooldowns
+	cooldownVersion := ""
+	if cooldown := lootboxOpenCooldown(lootbox.Tier); cooldown > 0 {
+		cooldowns, cooldownVersion, err = readLootboxCooldowns(ctx, nk, userID)
+		if err != nil {
+			return "", errors.ErrCouldNotReadStorage
+		}
+		if cooldowns.remaining(lootbox.Tier, cooldown, now) > 0 {
+			return "", errors.ErrLootboxCooldown
+		}
+	}
+
+	// Contents are derived from the secret committed at creation (legacy boxes have none).
+	secret, err := readLootboxSecret(ctx, nk, userID, lootbox.ID)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+	rng, seed := lootboxRNG(secret, lootbox.ID)
+
+	firstBox, firstBoxVersion, err := isFirstLootboxOpen(ctx, nk, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
 	// Generate contents based on tier, filtering owned items
-	contents, err := generateLootboxContents(ctx, nk, logger, userID, lootbox.Tier)
+	contents, rollInputs, err := generateLootboxContents(ctx, nk, logger, userID, lootbox.Tier, rng, firstBox)
 	if err != nil {
-		return "", err
+		logger.Error("Failed to generate lootbox contents for user %s: %v", userID, err)
+		return "", errors.ErrLootboxOpenFailed
 	}
 
 	// Prepare all writes atomically
 	pending := NewPendingWrites()
 
 	// Currency rewards
-	if contents.Gold > 0 || contents.Gems > 0 || contents.Treats > 0 {
+	gems := contents.Gems + contents.ExhaustedGems
+	treats := contents.Treats + contents.ExhaustedTreats
+	if contents.Gold > 0 || gems > 0 || treats > 0 {
 		walletChanges := map[string]int64{
 			"gold":   int64(contents.Gold),
-			"gems":   int64(contents.Gems),
-			"treats": int64(contents.Treats),
+			"gems":   int64(gems),
+			"treats": int64(treats),
 		}
 		pending.AddWalletUpdate(userID, walletChanges)
 	}
 
-	// Item rewards - prepare inventory writes using the Centralized Fulfillment Engine
+	// Item rewards - prepare inventory writes using the Centralized Fulfillment Engine.
+	// Any item that can't be granted aborts the open before commit, leaving the box unopened
+	// and retryable rather than consuming it and dropping the item.
 	mutator := NewInventoryMutator()
-	
-	typeToKey := map[string]string{
-		"background":  storageKeyBackground,
-		"piece_style": storageKeyPieceStyle,
-		"pet":         storageKeyPet,
-		"class":       storageKeyClass,
-	}
 
 	for i, itemID := range contents.Items {
 		itemType := contents.ItemTypes[i]
-		storageKey, ok := typeToKey[itemType]
+		storageKey, ok := lootboxTypeToStorageKey[itemType]
 		if !ok {
-			logger.Warn("Unknown item type %s for item %d", itemType, itemID)
-			continue
+			logger.Error("Aborting lootbox %s open for user %s: unknown item type %s for item %d", lootbox.ID, userID, itemType, itemID)
+			return "", errors.ErrLootboxOpenFailed
 		}
 		mutator.AddItem(storageKey, itemID)
 	}
 
 	invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
-	if err == nil && invPending != nil {
+	if err != nil {
+		logger.Error("Aborting lootbox %s open for user %s: failed to prepare item grants: %v", lootbox.ID, userID, err)
+		return "", errors.ErrLootboxOpenFailed
+	}
+	if invPending != nil {
 		pending.Merge(invPending)
 	}
 
-	// Mark lootbox as opened
+	achPending, err := PrepareAchievementProgress(ctx, nk, logger, userID, map[string]int{AchievementStatLootboxesOpened: 1})
+	if err != nil {
+		logger.Warn("Failed to prepare achievement progress for user %s: %v", userID, err)
+	} else if achPending != nil {
+		pending.Merge(achPending)
+	}
+
+	// Mark lootbox as opened, revealing the secret so the open can be verified
 	lootbox.Opened = true
+	if secret != "" {
+		lootbox.Proof = &LootboxProof{
+			Version:  lootboxProofVersion,
+			Secret:   secret,
+			Seed:     seed,
+			Inputs:   rollInputs,
+			Contents: *contents,
+		}
+	}
 	lootboxValue, _ := json.Marshal(lootbox)
 	pending.AddStorageWrite(&runtime.StorageWrite{
 		Collection:      storageCollectionLootboxes,
@@ -159,15 +264,61 @@ func RpcOpenLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 		PermissionWrite: 0,
 	})
 
+	// Create-only flag: a concurrent second "first" open fails the whole batch.
+	if firstBox {
+		flagValue, _ := json.Marshal(map[string]int64{"opened_at": time.Now().Unix()})
+		pending.AddStorageWrite(&runtime.StorageWrite{
+			Collection:      storageCollectionProgression,
+			Key:             ProgressionKeyFirstBoxOpened,
+			UserID:          userID,
+			Value:           string(flagValue),
+			Version:         firstBoxVersion,
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		})
+	}
+
+	if cooldowns != nil {
+		cooldowns.LastOpened[lootbox.Tier] = now.Unix()
+		cooldownValue, _ := json.Marshal(cooldowns)
+		pending.AddStorageWrite(&runtime.StorageWrite{
+			Collection:      storageCollectionProgression,
+			Key:             ProgressionKeyLootboxCooldowns,
+			UserID:          userID,
+			Value:           string(cooldownValue),
+			Version:         cooldownVersion,
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		})
+	}
+
 	// Commit all writes atomically
+	pending.CapDailyEarnings()
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
 		logger.Error("Failed to commit lootbox open transaction: %v", err)
 		return "", errors.ErrLootboxOpenFailed
 	}
+	recordLootboxOpen(nk, lootbox.Tier, len(contents.Items), len(contents.Duplicates))
+
+	// The secret now lives on the opened box; the hidden copy is no longer needed.
+	if secret != "" {
+		if err := nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+			Collection: storageCollectionLootboxSecrets,
+			Key:        lootbox.ID,
+			UserID:     userID,
+		}}); err != nil {
+			logger.Warn("Failed to delete lootbox secret %s: %v", lootbox.ID, err)
+		}
+	}
 
 	// Build unified RewardPayload
 	result := notify.NewRewardPayload("lootbox")
 	result.ReasonKey = "reward.lootbox.opened"
+	if contents.PoolsExhausted {
+		// Tells the client why no item dropped.
+		result.ReasonKey = "reward.lootbox.pools_exhausted"
+	}
+	result.SetReasonArg(notify.ReasonArgTier, lootbox.Tier)
 
 	// Inventory from items
 	if len(contents.Items) > 0 {
@@ -185,13 +336,21 @@ func RpcOpenLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 		result.DuplicateGrants = contents.Duplicates
 	}
 
-	// Wallet from base currency ONLY (duplicates are kept separate for client presentation)
-	if contents.Gold > 0 || contents.Gems > 0 || contents.Treats > 0 {
+	// Wallet from base currency and exhausted compensation ONLY (duplicates are kept separate for client presentation)
+	if contents.Gold > 0 || gems > 0 || treats > 0 {
 		result.Wallet = &notify.WalletDelta{
 			Gold:   contents.Gold,
-			Gems:   contents.Gems,
-			Treats: contents.Treats,
+			Gems:   gems,
+			Treats: treats,
 		}
+		result.SetWalletReasonArgs()
+	}
+
+	applyWithheldToPayload(result, pending.Withheld)
+
+	// Achievement rewards arrive as a separate wallet grant alongside the box contents.
+	if achPending != nil && achPending.Payload != nil {
+		result.Achievements = achPending.Payload.Achievements
 	}
 
 	// Tier for display
@@ -224,31 +383,120 @@ func RpcOpenLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 
 // getOwnedItemsForLootbox loads all owned items across all lootbox-eligible types
 func getOwnedItemsForLootbox(ctx context.Context, nk runtime.NakamaModule, userID string) map[string][]uint32 {
-	owned := make(map[string][]uint32)
-
 	// All types that can drop from lootboxes
-	types := []string{storageKeyBackground, storageKeyPieceStyle, storageKeyPet, storageKeyClass}
-	for _, t := range types {
-		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
-			Collection: storageCollectionInventory,
-			Key:        t,
-			UserID:     userID,
-		}})
-		if err != nil || len(objects) == 0 {
-			continue
-		}
+	return readOwnedItems(ctx, nk, userID, []string{storageKeyBackground, storageKeyPieceStyle, storageKeyPet, storageKeyClass})
+}
+
+// ProgressionKeyFirstBoxOpened is set in the same commit as a player's first lootbox open.
+const ProgressionKeyFirstBoxOpened = "first_box_opened"
+
+// ProgressionKeyLootboxCooldowns holds the user's last open time per tier (progression collection).
+const ProgressionKeyLootboxCooldowns = "lootbox_cooldowns"
+
+type LootboxCooldowns struct {
+	LastOpened map[string]int64 `json:"last_opened"` // Tier -> Unix seconds
+}
+
+// lootboxOpenCooldown returns the tier's open_cooldown_seconds, 0 when unset or unknown.
+func lootboxOpenCooldown(tier string) int64 {
+	shopCfg := GetShopConfig()
+	if shopCfg == nil {
+		return 0
+	}
+	return shopCfg.LootboxTiers[tier].OpenCooldownSeconds
+}
 
-		var inv InventoryData
-		if err := json.Unmarshal([]byte(objects[0].Value), &inv); err != nil {
+// remaining returns the seconds left before tier can be opened again, 0 when it can be opened now.
+func (c *LootboxCooldowns) remaining(tier string, cooldown int64, now time.Time) int64 {
+	if left := c.LastOpened[tier] + cooldown - now.Unix(); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// setLootboxCooldowns fills in each listing's open cooldown. The stamps are only read when one
+// of the listed tiers has a cooldown.
+func setLootboxCooldowns(ctx context.Context, nk runtime.NakamaModule, userID string, lootboxes []LootboxListing, now time.Time) error {
+	var cooldowns *LootboxCooldowns
+	for i := range lootboxes {
+		cooldown := lootboxOpenCooldown(lootboxes[i].Tier)
+		if cooldown <= 0 {
 			continue
 		}
-		owned[t] = inv.Items
+		if cooldowns == nil {
+			var err error
+			if cooldowns, _, err = readLootboxCooldowns(ctx, nk, userID); err != nil {
+				return err
+			}
+		}
+		lootboxes[i].CooldownRemainingSec = cooldowns.remaining(lootboxes[i].Tier, cooldown, now)
+	}
+	return nil
+}
+
+// readLootboxCooldowns returns the user's cooldown stamps and the version for an OCC write ("*" if none).
+func readLootboxCooldowns(ctx context.Context, nk runtime.NakamaModule, userID string) (*LootboxCooldowns, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyLootboxCooldowns,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	cooldowns := &LootboxCooldowns{LastOpened: map[string]int64{}}
+	if len(objects) == 0 {
+		return cooldowns, "*", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), cooldowns); err != nil {
+		return nil, "", err
+	}
+	if cooldowns.LastOpened == nil {
+		cooldowns.LastOpened = map[string]int64{}
+	}
+	return cooldowns, objects[0].Version, nil
+}
+
+// isFirstLootboxOpen reports whether the user has never opened a lootbox, with the version for the
+// create-only flag write. Accounts that opened boxes before the flag existed are caught by the
+// lootboxes_opened achievement stat.
+func isFirstLootboxOpen(ctx context.Context, nk runtime.NakamaModule, userID string) (bool, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyFirstBoxOpened,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return false, "", err
 	}
+	if len(objects) > 0 {
+		return false, "", nil
+	}
+	achState, err := readAchievementState(ctx, nk, userID)
+	if err != nil {
+		return false, "", err
+	}
+	return achState.Stats[AchievementStatLootboxesOpened] == 0, "*", nil
+}
 
-	return owned
+// LootboxRollInputs is everything a roll reads besides the RNG. It is committed into the
+// proof so a verifier can replay the open without the shop config or the player's inventory.
+type LootboxRollInputs struct {
+	Tier                  string                       `json:"tier"`
+	DropTable             DropTable                    `json:"drop_table"`      // Inheritance resolved, before event scaling
+	Event                 *ActiveEvent                 `json:"event,omitempty"` // Set when an event was live at open
+	Pools                 map[string][]PoolItem        `json:"pools"`           // Only the pools DropTable references
+	DuplicateFallbacks    map[string]DuplicateFallback `json:"duplicate_fallbacks,omitempty"`
+	ExhaustedCompensation ExhaustedCompensation        `json:"exhausted_compensation"`
+	PriceGems             int                          `json:"price_gems"` // Caps the exhausted compensation
+	ExchangeRates         ExchangeRates                `json:"exchange_rates"`
+	Owned                 map[string][]uint32          `json:"owned"`                    // Inventory storage key -> IDs before the open
+	GuaranteeItem         bool                         `json:"guarantee_item,omitempty"` // A player's first box; adds the unowned pick
 }
 
-func generateLootboxContents(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, tier string) (*LootboxContents, error) {
+// lootboxRollInputs snapshots the tier config and the player's collection for one open.
+// Tiers are fully flattened (inherits_from resolved) at load; unknown tiers fall back to standard.
+func lootboxRollInputs(ctx context.Context, nk runtime.NakamaModule, userID string, tier string) (*LootboxRollInputs, error) {
 	shopCfg := GetShopConfig()
 	if shopCfg == nil {
 		return nil, fmt.Errorf("shop config not loaded")
@@ -256,58 +504,115 @@ func generateLootboxContents(ctx context.Context, nk runtime.NakamaModule, logge
 
 	tierDef, exists := shopCfg.LootboxTiers[tier]
 	if !exists {
-		tierDef = shopCfg.LootboxTiers["standard"]
-		if tierDef.DropTable.Gold.Max == 0 {
+		tier = "standard"
+		tierDef, exists = shopCfg.LootboxTiers[tier]
+		if !exists {
 			return nil, fmt.Errorf("no valid lootbox tier found")
 		}
 	}
 
-	// Load owned items to filter duplicates
-	ownedItems := getOwnedItemsForLootbox(ctx, nk, userID)
+	inputs := &LootboxRollInputs{
+		Tier:                  tier,
+		DropTable:             tierDef.DropTable,
+		Pools:                 make(map[string][]PoolItem, len(tierDef.DropTable.ItemPools)),
+		DuplicateFallbacks:    make(map[string]DuplicateFallback, len(tierDef.DropTable.ItemPools)),
+		ExhaustedCompensation: shopCfg.ExhaustedCompensation,
+		PriceGems:             tierDef.PriceGems,
+		ExchangeRates:         shopCfg.ExchangeRates,
+		Owned:                 getOwnedItemsForLootbox(ctx, nk, userID),
+	}
+	if event := shopCfg.ActiveEvent; event.activeAt(time.Now()) {
+		snapshot := *event
+		inputs.Event = &snapshot
+	}
+	for _, poolRef := range tierDef.DropTable.ItemPools {
+		inputs.Pools[poolRef.Pool] = shopCfg.ItemPools[poolRef.Pool]
+		if fallback, ok := shopCfg.DuplicateFallbacks[poolRef.Pool]; ok {
+			inputs.DuplicateFallbacks[poolRef.Pool] = fallback
+		}
+	}
+	return inputs, nil
+}
+
+// generateLootboxContents snapshots the roll inputs and rolls a box from rng. The inputs are
+// returned for the proof. With guaranteeItem set (a player's first box), a roll that
+// grants no new item is followed by one pick from the unowned items of each pool in order,
+// stopping at the first pool that has any. Roll order is part of the fairness proof
+// contract (see lootbox_proof.go) — don't reorder or add rolls without versioning the proof.
+func generateLootboxContents(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, tier string, rng *rand.Rand, guaranteeItem bool) (*LootboxContents, *LootboxRollInputs, error) {
+	inputs, err := lootboxRollInputs(ctx, nk, userID, tier)
+	if err != nil {
+		return nil, nil, err
+	}
+	inputs.GuaranteeItem = guaranteeItem
+	return rollLootboxContents(logger, inputs, rng), inputs, nil
+}
+
+// rollLootboxContents is the deterministic part of an open: the same inputs and seed always
+// produce the same contents. It reads nothing outside inputs.
+func rollLootboxContents(logger runtime.Logger, inputs *LootboxRollInputs, rng *rand.Rand) *LootboxContents {
+	// Index owned items once so each roll is O(1).
+	ownedItems := make(map[string]map[uint32]struct{})
+	for key, ids := range inputs.Owned {
+		set := make(map[uint32]struct{}, len(ids))
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+		ownedItems[key] = set
+	}
 
-	dt := tierDef.DropTable
+	dt := inputs.DropTable
+	event := inputs.Event
+	if event != nil {
+		// Scale a copy; the tier definition is shared across opens.
+		dt.Gold = event.currencyRange(dt.Gold)
+		dt.Gems = event.currencyRange(dt.Gems)
+		dt.Treats = event.currencyRange(dt.Treats)
+	}
 	contents := &LootboxContents{
-		Gold:       randomRange(dt.Gold.Min, dt.Gold.Max),
-		Gems:       randomRange(dt.Gems.Min, dt.Gems.Max),
-		Treats:     randomRange(dt.Treats.Min, dt.Treats.Max),
+		Gold:       randomRange(rng, dt.Gold.Min, dt.Gold.Max),
+		Gems:       randomRange(rng, dt.Gems.Min, dt.Gems.Max),
+		Treats:     randomRange(rng, dt.Treats.Min, dt.Treats.Max),
 		Items:      make([]uint32, 0),
 		ItemTypes:  make([]string, 0),
 		Duplicates: make([]notify.DuplicateGrant, 0),
 	}
-
-	typeToStorageKey := func(t string) string {
-		switch t {
-		case "background":
-			return storageKeyBackground
-		case "piece_style":
-			return storageKeyPieceStyle
-		case "pet":
-			return storageKeyPet
-		case "class":
-			return storageKeyClass
-		default:
-			return ""
-		}
+	if event != nil {
+		contents.Event = event.ID
 	}
+	applyGuaranteedTotal(contents, dt.GuaranteedTotal, inputs.ExchangeRates)
 
 	isOwned := func(storageKey string, itemID uint32) bool {
-		for _, id := range ownedItems[storageKey] {
-			if id == itemID {
-				return true
+		_, owned := ownedItems[storageKey][itemID]
+		return owned
+	}
+	poolExhausted := func(pool []PoolItem) bool {
+		for _, item := range pool {
+			if !isOwned(lootboxTypeToStorageKey[item.Type], item.ID) {
+				return false
 			}
 		}
-		return false
+		return true
 	}
 
 	// Each pool rolls independently — a single open can theoretically drop
 	// from multiple pools if configured that way.
 	for _, poolRef := range dt.ItemPools {
-		if rand.Float64() < poolRef.Chance {
-			itemType, itemID := pickRandomItemFromPool(poolRef.Pool)
+		chance := poolRef.Chance
+		if event != nil {
+			chance = event.itemChance(chance)
+		}
+		if rng.Float64() < chance {
+			itemType, itemID := pickRandomItemFromPool(rng, inputs.Pools[poolRef.Pool])
 			if itemType != "" {
-				sKey := typeToStorageKey(itemType)
-				if sKey != "" && isOwned(sKey, itemID) {
-					fallback := shopCfg.DuplicateFallbacks[poolRef.Pool]
+				sKey := lootboxTypeToStorageKey[itemType]
+				if sKey != "" && isOwned(sKey, itemID) && poolExhausted(inputs.Pools[poolRef.Pool]) {
+					// Nothing in this pool can ever drop again; compensate instead of a duplicate.
+					contents.PoolsExhausted = true
+					contents.ExhaustedGems += inputs.ExhaustedCompensation.Gems
+					contents.ExhaustedTreats += inputs.ExhaustedCompensation.Treats
+				} else if sKey != "" && isOwned(sKey, itemID) {
+					fallback := inputs.DuplicateFallbacks[poolRef.Pool]
 					if fallback.Amount > 0 {
 						contents.Duplicates = append(contents.Duplicates, notify.DuplicateGrant{
 							ItemID:           itemID,
@@ -323,35 +628,104 @@ func generateLootboxContents(ctx context.Context, nk runtime.NakamaModule, logge
 					contents.ItemTypes = append(contents.ItemTypes, itemType)
 					// Optimistically add to ownedItems so we don't grant the same item twice in one multi-pool roll
 					if sKey != "" {
-						ownedItems[sKey] = append(ownedItems[sKey], itemID)
+						if ownedItems[sKey] == nil {
+							ownedItems[sKey] = make(map[uint32]struct{})
+						}
+						ownedItems[sKey][itemID] = struct{}{}
 					}
 				}
 			}
 		}
 	}
 
-	return contents, nil
+	if inputs.GuaranteeItem && len(contents.Items) == 0 {
+		for _, poolRef := range dt.ItemPools {
+			// Count, then walk to the chosen index: same single roll as indexing an
+			// unowned slice, without building one per open.
+			pool := inputs.Pools[poolRef.Pool]
+			unowned := 0
+			for _, item := range pool {
+				if !isOwned(lootboxTypeToStorageKey[item.Type], item.ID) {
+					unowned++
+				}
+			}
+			if unowned == 0 {
+				continue
+			}
+			n := rng.Intn(unowned)
+			for _, item := range pool {
+				if isOwned(lootboxTypeToStorageKey[item.Type], item.ID) {
+					continue
+				}
+				if n == 0 {
+					contents.Items = append(contents.Items, item.ID)
+					contents.ItemTypes = append(contents.ItemTypes, item.Type)
+					break
+				}
+				n--
+			}
+			break
+		}
+	}
+
+	capExhaustedCompensation(contents, inputs.PriceGems, inputs.ExchangeRates)
+
+	return contents
 }
 
-// pickRandomItemFromPool picks a single item from a single named pool.
-func pickRandomItemFromPool(poolName string) (string, uint32) {
-	shopCfg := GetShopConfig()
-	if shopCfg == nil || len(shopCfg.ItemPools) == 0 {
+// capExhaustedCompensation keeps a box's exhausted compensation, gems first, within its price so
+// a completionist can't open boxes for a profit. Treats are valued at the shop exchange rate.
+func capExhaustedCompensation(contents *LootboxContents, priceGems int, rates ExchangeRates) {
+	contents.ExhaustedGems = max(min(contents.ExhaustedGems, priceGems), 0)
+	contents.ExhaustedTreats = max(min(contents.ExhaustedTreats, (priceGems-contents.ExhaustedGems)*rates.TreatsPerGem), 0)
+}
+
+// pickRandomItemFromPool picks a single item from a pool. Pools are parsed once in
+// LoadShopData and read in place; nothing is copied per open.
+func pickRandomItemFromPool(rng *rand.Rand, pool []PoolItem) (string, uint32) {
+	if len(pool) == 0 {
 		return "", 0
 	}
+	picked := pool[rng.Intn(len(pool))]
+	return picked.Type, picked.ID
+}
+
+// applyGuaranteedTotal tops up the currency worth the most in the roll until gold+gems+treats,
+// valued in gold, reaches floor. Values are scaled by TreatsPerGem so the math stays integral.
+// Consumes no rolls, so the rest of the box is unchanged.
+func applyGuaranteedTotal(contents *LootboxContents, floor int, rates ExchangeRates) {
+	if floor <= 0 || rates.GoldPerGem <= 0 || rates.TreatsPerGem <= 0 {
+		return
+	}
 
-	poolItems, ok := shopCfg.ItemPools[poolName]
-	if !ok || len(poolItems) == 0 {
-		return "", 0
+	currencies := []struct {
+		amount    *int
+		unitValue int
+	}{
+		{&contents.Gold, rates.TreatsPerGem},
+		{&contents.Gems, rates.GoldPerGem * rates.TreatsPerGem},
+		{&contents.Treats, rates.GoldPerGem},
 	}
 
-	picked := poolItems[rand.Intn(len(poolItems))]
-	return picked.Type, picked.ID
+	total, largest := 0, 0
+	for i, c := range currencies {
+		total += *c.amount * c.unitValue
+		if *c.amount*c.unitValue > *currencies[largest].amount*currencies[largest].unitValue {
+			largest = i
+		}
+	}
+
+	deficit := floor*rates.TreatsPerGem - total
+	if deficit <= 0 {
+		return
+	}
+	top := currencies[largest]
+	*top.amount += (deficit + top.unitValue - 1) / top.unitValue
 }
 
-func randomRange(min, max int) int {
+func randomRange(rng *rand.Rand, min, max int) int {
 	if min >= max {
 		return min
 	}
-	return min + rand.Intn(max-min+1)
+	return min + rng.Intn(max-min+1)
 }
@@ -0,0 +1,180 @@
+package items
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mrand "math/rand"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// Commit-reveal fairness for lootboxes:
+//   - At creation a random secret is stored server-side (hidden collection) and only its
+//     SHA-256 commitment is written to the player-readable box.
+//   - At open the RNG is seeded from SHA-256(secret + ":" + box ID), so contents were fixed
+//     the moment the box was created. The secret is then revealed on the opened box.
+//   - Anyone can check SHA-256(secret) == commitment, re-derive the seed, and replay the rolls
+//     with Go's math/rand (NewSource) in the documented order: gold, gems, treats, then for each
+//     drop-table pool a Float64 chance roll followed by an Intn pick on a hit. When
+//     contents.event is set, that shop active_event's multipliers scaled the ranges and chances.
+//     When inputs.guarantee_item is set (a player's first box) and no new item rolled, one more
+//     Intn pick follows over the unowned items of the first pool that has any (see
+//     generateLootboxContents). Exhausted-pool compensation and the guaranteed_total top-up
+//     consume no rolls.
+//   - Everything else the roll reads (drop table, live event, pools, duplicate fallbacks,
+//     exhausted compensation, box price, exchange rates, the owned-items snapshot and
+//     guarantee_item) is committed in the proof's inputs, so the replay needs no server state.
+const storageCollectionLootboxSecrets = "lootbox_secrets"
+
+// lootboxProofVersion is bumped whenever the roll order or the committed inputs change.
+// Version 1 (unset) proofs carry only the seed and contents and cannot be replayed.
+const lootboxProofVersion = 2
+
+// LootboxProof is the revealed fairness data persisted on an opened box.
+type LootboxProof struct {
+	Version  int                `json:"version,omitempty"`
+	Secret   string             `json:"secret"`
+	Seed     int64              `json:"seed"`
+	Inputs   *LootboxRollInputs `json:"inputs,omitempty"`
+	Contents LootboxContents    `json:"contents"`
+}
+
+// OpenProofResponse is returned by RpcGetOpenProof.
+type OpenProofResponse struct {
+	LootboxID  string             `json:"lootbox_id"`
+	Tier       string             `json:"tier"`
+	Commitment string             `json:"commitment"`
+	Secret     string             `json:"secret"`
+	Seed       int64              `json:"seed"`
+	Version    int                `json:"version"`
+	Inputs     *LootboxRollInputs `json:"inputs,omitempty"`
+	Contents   LootboxContents    `json:"contents"`
+}
+
+// newLootboxSecret returns a fresh hex secret and its SHA-256 commitment.
+func newLootboxSecret() (secret string, commitment string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	secret = hex.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(secret))
+	return secret, hex.EncodeToString(sum[:]), nil
+}
+
+// lootboxSeed derives the deterministic RNG seed for a box from its secret.
+func lootboxSeed(secret, lootboxID string) int64 {
+	sum := sha256.Sum256([]byte(secret + ":" + lootboxID))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+// buildLootboxSecretWrite stores the secret where only the server can read it.
+func buildLootboxSecretWrite(userID, lootboxID, secret string) *runtime.StorageWrite {
+	value, _ := json.Marshal(map[string]string{"secret": secret})
+	return &runtime.StorageWrite{
+		Collection:      storageCollectionLootboxSecrets,
+		Key:             lootboxID,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}
+}
+
+// readLootboxSecret returns the stored secret for a box, or "" for legacy boxes created without one.
+func readLootboxSecret(ctx context.Context, nk runtime.NakamaModule, userID, lootboxID string) (string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionLootboxSecrets,
+		Key:        lootboxID,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return "", err
+	}
+	if len(objects) == 0 {
+		return "", nil
+	}
+	var stored struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), &stored); err != nil {
+		return "", err
+	}
+	return stored.Secret, nil
+}
+
+// lootboxRNG returns the seeded RNG for a committed box, or a time-seeded one for legacy boxes.
+func lootboxRNG(secret, lootboxID string) (*mrand.Rand, int64) {
+	if secret == "" {
+		return mrand.New(mrand.NewSource(time.Now().UnixNano())), 0
+	}
+	seed := lootboxSeed(secret, lootboxID)
+	return mrand.New(mrand.NewSource(seed)), seed
+}
+
+// replayLootboxProof re-rolls a proof from its seed and committed inputs, as a verifier would.
+// The result must equal proof.Contents.
+func replayLootboxProof(logger runtime.Logger, proof *LootboxProof) (*LootboxContents, error) {
+	if proof == nil || proof.Version < 2 || proof.Inputs == nil {
+		return nil, fmt.Errorf("proof has no committed inputs to replay")
+	}
+	rng := mrand.New(mrand.NewSource(proof.Seed))
+	return rollLootboxContents(logger, proof.Inputs, rng), nil
+}
+
+// RpcGetOpenProof returns the commit-reveal proof for one of the caller's opened lootboxes.
+// Proofs are only available for openedLootboxRetention after the open.
+func RpcGetOpenProof(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionLootboxes,
+		Key:        req.ID,
+		UserID:     userID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	var lootbox Lootbox
+	if err := json.Unmarshal([]byte(objects[0].Value), &lootbox); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !lootbox.Opened || lootbox.Proof == nil || lootbox.Commitment == "" {
+		return "", errors.ErrProofUnavailable
+	}
+
+	respBytes, err := json.Marshal(OpenProofResponse{
+		LootboxID:  lootbox.ID,
+		Tier:       lootbox.Tier,
+		Commitment: lootbox.Commitment,
+		Secret:     lootbox.Proof.Secret,
+		Seed:       lootbox.Proof.Seed,
+		Version:    max(lootbox.Proof.Version, 1),
+		Inputs:     lootbox.Proof.Inputs,
+		Contents:   lootbox.Proof.Contents,
+	})
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
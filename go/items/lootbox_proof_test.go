@@ -0,0 +1,124 @@
+package items
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// grantTestLootbox writes a committed lootbox of tier for userID and returns its ID.
+func grantTestLootbox(t *testing.T, nk *fakeNakama, userID, tier string) string {
+	t.Helper()
+	lootbox, writes, err := PrepareCreateLootbox(userID, tier)
+	if err != nil {
+		t.Fatalf("PrepareCreateLootbox: %v", err)
+	}
+	if _, err := nk.StorageWrite(testContext(userID), writes); err != nil {
+		t.Fatalf("write lootbox: %v", err)
+	}
+	return lootbox.ID
+}
+
+func TestOpenProofReproducesContents(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000001"
+	nk := newFakeNakama()
+	ctx := testContext(userID)
+	// Not the first box, and part of the collection owned, so duplicates can roll.
+	nk.put(t, storageCollectionProgression, ProgressionKeyFirstBoxOpened, userID, map[string]int64{"opened_at": 1})
+	nk.put(t, storageCollectionInventory, storageKeyBackground, userID, InventoryData{Items: []uint32{1, 2, 3}})
+
+	for i := 0; i < 10; i++ {
+		id := grantTestLootbox(t, nk, userID, "standard")
+		var before InventoryData
+		nk.get(t, storageCollectionInventory, storageKeyBackground, userID, &before)
+		if _, err := RpcOpenLootbox(ctx, testLogger{}, nil, nk, `{"id":"`+id+`"}`); err != nil {
+			t.Fatalf("RpcOpenLootbox: %v", err)
+		}
+		resp, err := RpcGetOpenProof(ctx, testLogger{}, nil, nk, `{"id":"`+id+`"}`)
+		if err != nil {
+			t.Fatalf("RpcGetOpenProof: %v", err)
+		}
+		// Verify from the wire format only, as a third party would.
+		var proof OpenProofResponse
+		if err := json.Unmarshal([]byte(resp), &proof); err != nil {
+			t.Fatalf("unmarshal proof: %v", err)
+		}
+
+		sum := sha256.Sum256([]byte(proof.Secret))
+		if hex.EncodeToString(sum[:]) != proof.Commitment {
+			t.Fatalf("secret does not match commitment")
+		}
+		if lootboxSeed(proof.Secret, proof.LootboxID) != proof.Seed {
+			t.Fatalf("seed is not derived from the revealed secret")
+		}
+		if proof.Version != lootboxProofVersion || proof.Inputs == nil {
+			t.Fatalf("proof version %d with inputs %v, want version %d with inputs", proof.Version, proof.Inputs, lootboxProofVersion)
+		}
+		if !reflect.DeepEqual(proof.Inputs.Owned[storageKeyBackground], before.Items) {
+			t.Errorf("owned snapshot = %v, want the backgrounds %v held before the open", proof.Inputs.Owned, before.Items)
+		}
+
+		replayed, err := replayLootboxProof(testLogger{}, &LootboxProof{
+			Version: proof.Version, Secret: proof.Secret, Seed: proof.Seed, Inputs: proof.Inputs,
+		})
+		if err != nil {
+			t.Fatalf("replayLootboxProof: %v", err)
+		}
+		if !reflect.DeepEqual(*replayed, proof.Contents) {
+			t.Fatalf("replayed contents %+v, recorded %+v", *replayed, proof.Contents)
+		}
+	}
+}
+
+func TestLegacyProofIsNotReplayable(t *testing.T) {
+	if _, err := replayLootboxProof(testLogger{}, &LootboxProof{Seed: 1}); err == nil {
+		t.Error("replayed a proof without committed inputs")
+	}
+}
+
+func TestFirstBoxProofReplaysGuaranteedPick(t *testing.T) {
+	tier := GetShopConfig().LootboxTiers["standard"]
+	if len(tier.DropTable.ItemPools) == 0 {
+		t.Skip("standard tier has no item pools")
+	}
+	keep := GetShopConfig().ItemPools[tier.DropTable.ItemPools[0].Pool][0]
+
+	for i := 0; i < 10; i++ {
+		userID := fmt.Sprintf("00000000-0000-0000-0000-%012d", 100+i)
+		nk := newFakeNakama()
+		ctx := testContext(userID)
+		ownAllPoolItemsExcept(t, nk, userID, keep)
+
+		id := grantTestLootbox(t, nk, userID, "standard")
+		if _, err := RpcOpenLootbox(ctx, testLogger{}, nil, nk, `{"id":"`+id+`"}`); err != nil {
+			t.Fatalf("RpcOpenLootbox: %v", err)
+		}
+		resp, err := RpcGetOpenProof(ctx, testLogger{}, nil, nk, `{"id":"`+id+`"}`)
+		if err != nil {
+			t.Fatalf("RpcGetOpenProof: %v", err)
+		}
+		var proof OpenProofResponse
+		if err := json.Unmarshal([]byte(resp), &proof); err != nil {
+			t.Fatalf("unmarshal proof: %v", err)
+		}
+		if proof.Inputs == nil || !proof.Inputs.GuaranteeItem {
+			t.Fatalf("first box proof does not record guarantee_item: %+v", proof.Inputs)
+		}
+		if len(proof.Contents.Items) != 1 || proof.Contents.Items[0] != keep.ID {
+			t.Fatalf("first box items = %v, want the only unowned %d", proof.Contents.Items, keep.ID)
+		}
+
+		replayed, err := replayLootboxProof(testLogger{}, &LootboxProof{
+			Version: proof.Version, Secret: proof.Secret, Seed: proof.Seed, Inputs: proof.Inputs,
+		})
+		if err != nil {
+			t.Fatalf("replayLootboxProof: %v", err)
+		}
+		if !reflect.DeepEqual(*replayed, proof.Contents) {
+			t.Fatalf("replayed contents %+v, recorded %+v", *replayed, proof.Contents)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package items
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"block-server/errors"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ownAllPoolItemsExcept seeds userID's inventory with every pooled item other than keep.
+func ownAllPoolItemsExcept(t testing.TB, nk *fakeNakama, userID string, keep PoolItem) {
+	t.Helper()
+	owned := make(map[string][]uint32)
+	for _, pool := range GetShopConfig().ItemPools {
+		for _, item := range pool {
+			if item == keep {
+				continue
+			}
+			key := lootboxTypeToStorageKey[item.Type]
+			owned[key] = append(owned[key], item.ID)
+		}
+	}
+	for key, ids := range owned {
+		nk.put(t, storageCollectionInventory, key, userID, InventoryData{Items: ids})
+	}
+}
+
+func TestGuaranteedItemPicksLastUnowned(t *testing.T) {
+	tier := GetShopConfig().LootboxTiers["standard"]
+	if len(tier.DropTable.ItemPools) == 0 {
+		t.Skip("standard tier has no item pools")
+	}
+	keep := GetShopConfig().ItemPools[tier.DropTable.ItemPools[0].Pool][0]
+	nk := newFakeNakama()
+	ownAllPoolItemsExcept(t, nk, "u1", keep)
+
+	for seed := int64(0); seed < 20; seed++ {
+		contents, _, err := generateLootboxContents(testContext("u1"), nk, testLogger{}, "u1", "standard", rand.New(rand.NewSource(seed)), true)
+		if err != nil {
+			t.Fatalf("generateLootboxContents: %v", err)
+		}
+		if len(contents.Items) != 1 || contents.Items[0] != keep.ID || contents.ItemTypes[0] != keep.Type {
+			t.Fatalf("seed %d: items = %v %v, want the only unowned %s %d", seed, contents.Items, contents.ItemTypes, keep.Type, keep.ID)
+		}
+	}
+}
+
+func TestOwnsEverythingPaysCompensationOnlyForHits(t *testing.T) {
+	tier := GetShopConfig().LootboxTiers["standard"]
+	comp := GetShopConfig().ExhaustedCompensation
+	if len(tier.DropTable.ItemPools) == 0 || comp.Gems == 0 {
+		t.Skip("standard tier has no item pools or no compensation")
+	}
+	nk := newFakeNakama()
+	ownAllPoolItemsExcept(t, nk, "u1", PoolItem{})
+
+	compensated := 0
+	const opens = 200
+	for seed := int64(0); seed < opens; seed++ {
+		contents, _, err := generateLootboxContents(testContext("u1"), nk, testLogger{}, "u1", "standard", rand.New(rand.NewSource(seed)), false)
+		if err != nil {
+			t.Fatalf("generateLootboxContents: %v", err)
+		}
+		if len(contents.Items) != 0 || len(contents.Duplicates) != 0 {
+			t.Fatalf("seed %d: items %v, duplicates %v from fully owned pools", seed, contents.Items, contents.Duplicates)
+		}
+		if !contents.PoolsExhausted {
+			if contents.ExhaustedGems != 0 || contents.ExhaustedTreats != 0 {
+				t.Fatalf("seed %d: compensation paid without a pool hit", seed)
+			}
+			continue
+		}
+		compensated++
+		if contents.ExhaustedGems == 0 || contents.ExhaustedGems > tier.PriceGems {
+			t.Fatalf("seed %d: exhausted gems = %d, want 1..%d", seed, contents.ExhaustedGems, tier.PriceGems)
+		}
+	}
+	// Most opens miss every pool; compensating those would make boxes a gem farm.
+	if compensated == 0 || compensated > opens/2 {
+		t.Fatalf("%d of %d opens compensated, want only the pool hits", compensated, opens)
+	}
+}
+
+func TestCapExhaustedCompensation(t *testing.T) {
+	rates := ExchangeRates{GoldPerGem: 10, TreatsPerGem: 2}
+	tests := []struct {
+		name                 string
+		gems, treats, price  int
+		wantGems, wantTreats int
+	}{
+		{"under price", 10, 4, 25, 10, 4},
+		{"gems capped, no room for treats", 50, 5, 25, 25, 0},
+		{"treats fill the rest", 20, 20, 25, 20, 10},
+		{"free box pays nothing", 50, 5, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		contents := &LootboxContents{ExhaustedGems: tt.gems, ExhaustedTreats: tt.treats}
+		capExhaustedCompensation(contents, tt.price, rates)
+		if contents.ExhaustedGems != tt.wantGems || contents.ExhaustedTreats != tt.wantTreats {
+			t.Errorf("%s: got %d gems %d treats, want %d gems %d treats", tt.name, contents.ExhaustedGems, contents.ExhaustedTreats, tt.wantGems, tt.wantTreats)
+		}
+	}
+}
+
+func TestApplyGuaranteedTotal(t *testing.T) {
+	// A gem is worth 10 gold and a treat 5.
+	rates := ExchangeRates{GoldPerGem: 10, TreatsPerGem: 2}
+	tests := []struct {
+		name               string
+		gold, gems, treats int
+		floor              int
+		wantGold, wantGems int
+		wantTreats         int
+	}{
+		{"low roll tops up gold", 50, 2, 1, 100, 75, 2, 1},
+		{"low roll tops up gems", 10, 3, 0, 100, 10, 9, 0},
+		{"top-up rounds up", 0, 1, 0, 15, 0, 2, 0},
+		{"empty roll", 0, 0, 0, 100, 100, 0, 0},
+		{"roll at the floor", 100, 0, 0, 100, 100, 0, 0},
+		{"high roll untouched", 500, 10, 4, 100, 500, 10, 4},
+		{"no floor", 0, 0, 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		contents := &LootboxContents{Gold: tt.gold, Gems: tt.gems, Treats: tt.treats}
+		applyGuaranteedTotal(contents, tt.floor, rates)
+		if contents.Gold != tt.wantGold || contents.Gems != tt.wantGems || contents.Treats != tt.wantTreats {
+			t.Errorf("%s: got %d gold %d gems %d treats, want %d gold %d gems %d treats", tt.name, contents.Gold, contents.Gems, contents.Treats, tt.wantGold, tt.wantGems, tt.wantTreats)
+		}
+	}
+}
+
+func TestLoadShopDataRejectsUnknownPoolType(t *testing.T) {
+	original := shopdata
+	t.Cleanup(func() {
+		shopdata = original
+		if err := LoadShopData(); err != nil {
+			t.Fatalf("restore shop data: %v", err)
+		}
+	})
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(original, &doc); err != nil {
+		t.Fatalf("parse shop.json: %v", err)
+	}
+	pools := doc["item_pools"].(map[string]interface{})
+	pools["backgrounds"] = append(pools["backgrounds"].([]interface{}), map[string]interface{}{"type": "emote", "id": 1})
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal shop.json: %v", err)
+	}
+	shopdata = data
+
+	err = LoadShopData()
+	if err == nil || !strings.Contains(err.Error(), `unknown type "emote"`) {
+		t.Fatalf("LoadShopData error = %v, want unknown pool type", err)
+	}
+}
+
+func TestLootboxCooldownReportedByGetLootboxes(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000071"
+	tiers := GetShopConfig().LootboxTiers
+	standard := tiers["standard"]
+	t.Cleanup(func() { tiers["standard"] = standard })
+	withCooldown := standard
+	withCooldown.OpenCooldownSeconds = 3600
+	tiers["standard"] = withCooldown
+
+	nk := newFakeNakama()
+	ctx := testContext(userID)
+	first := grantTestLootbox(t, nk, userID, "standard")
+	second := grantTestLootbox(t, nk, userID, "standard")
+	if _, err := RpcOpenLootbox(ctx, testLogger{}, nil, nk, `{"id":"`+first+`"}`); err != nil {
+		t.Fatalf("first open: %v", err)
+	}
+	if _, err := RpcOpenLootbox(ctx, testLogger{}, nil, nk, `{"id":"`+second+`"}`); err != errors.ErrLootboxCooldown {
+		t.Fatalf("second open: err = %v, want ErrLootboxCooldown", err)
+	}
+
+	resp, err := RpcGetLootboxes(ctx, testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetLootboxes: %v", err)
+	}
+	var listed []LootboxListing
+	if err := json.Unmarshal([]byte(resp), &listed); err != nil {
+		t.Fatalf("unmarshal lootboxes: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != second {
+		t.Fatalf("listed %+v, want only the unopened box", listed)
+	}
+	if left := listed[0].CooldownRemainingSec; left <= 3590 || left > 3600 {
+		t.Errorf("cooldown_remaining_sec = %d, want about 3600", left)
+	}
+}
+
+func TestGetLootboxesPurgesExpiredOpenedBoxesAndSecrets(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000072"
+	nk := newFakeNakama()
+	past := timestamppb.New(time.Now().Add(-openedLootboxRetention - time.Hour))
+	// age marks a box opened or not and backdates its last write; its secret row stays behind.
+	age := func(id string, opened bool, at *timestamppb.Timestamp) {
+		var lb Lootbox
+		if !nk.get(t, storageCollectionLootboxes, id, userID, &lb) {
+			t.Fatalf("lootbox %s not stored", id)
+		}
+		lb.Opened = opened
+		nk.put(t, storageCollectionLootboxes, id, userID, lb)
+		nk.storage[storageID{storageCollectionLootboxes, id, userID}].UpdateTime = at
+	}
+	expired := grantTestLootbox(t, nk, userID, "standard")
+	age(expired, true, past)
+	recent := grantTestLootbox(t, nk, userID, "standard")
+	age(recent, true, timestamppb.Now())
+	unopened := grantTestLootbox(t, nk, userID, "standard")
+	age(unopened, false, past)
+
+	resp, err := RpcGetLootboxes(testContext(userID), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetLootboxes: %v", err)
+	}
+	var listed []LootboxListing
+	if err := json.Unmarshal([]byte(resp), &listed); err != nil {
+		t.Fatalf("unmarshal lootboxes: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != unopened {
+		t.Fatalf("listed %+v, want only the unopened box", listed)
+	}
+
+	var v map[string]interface{}
+	for _, c := range []struct {
+		collection, id string
+		kept           bool
+	}{
+		{storageCollectionLootboxes, expired, false},
+		{storageCollectionLootboxSecrets, expired, false},
+		{storageCollectionLootboxes, recent, true},
+		{storageCollectionLootboxSecrets, recent, true},
+		{storageCollectionLootboxes, unopened, true},
+		{storageCollectionLootboxSecrets, unopened, true},
+	} {
+		if got := nk.get(t, c.collection, c.id, userID, &v); got != c.kept {
+			t.Errorf("%s/%s kept = %v, want %v", c.collection, c.id, got, c.kept)
+		}
+	}
+}
+
+// BenchmarkGenerateLootboxContents covers a first box for a new player, where the
+// guaranteed-item pick scans a whole pool of unowned items on most opens.
+func BenchmarkGenerateLootboxContents(b *testing.B) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+	rng := rand.New(rand.NewSource(1))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := generateLootboxContents(ctx, nk, testLogger{}, "u1", "standard", rng, true); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ValidateLoadoutRequest is the loadout a player intends to bring into a match.
+// Omitted abilities default to the one currently equipped on the item.
+type ValidateLoadoutRequest struct {
+	PetID          uint32  `json:"pet_id"`
+	ClassID        uint32  `json:"class_id"`
+	PetAbilityID   *uint32 `json:"pet_ability_id,omitempty"`
+	ClassAbilityID *uint32 `json:"class_ability_id,omitempty"`
+}
+
+// ValidatedLoadout is the canonical loadout the match engine should use. Corrections lists every
+// change the server made to the request, e.g. a locked ability swapped for the equipped one.
+type ValidatedLoadout struct {
+	PetID          uint32   `json:"pet_id"`
+	ClassID        uint32   `json:"class_id"`
+	PetAbilityID   uint32   `json:"pet_ability_id"`
+	ClassAbilityID uint32   `json:"class_ability_id"`
+	PetLevel       int      `json:"pet_level"`
+	ClassLevel     int      `json:"class_level"`
+	Corrections    []string `json:"corrections"`
+}
+
+// resolveLoadoutAbility returns the ability the item will actually use: the requested one if it is
+// unlocked, otherwise the equipped one. A requested ID the item doesn't have is rejected outright.
+func resolveLoadoutAbility(itemType string, abilityIDs []uint32, prog *ItemProgression, requested *uint32) (uint32, string, error) {
+	equipped := prog.EquippedAbility
+	if equipped < 0 || equipped >= len(abilityIDs) {
+		equipped = 0
+	}
+	if len(abilityIDs) == 0 {
+		return 0, "", errors.ErrInvalidAbility
+	}
+	if requested == nil {
+		return abilityIDs[equipped], "", nil
+	}
+	for i, id := range abilityIDs {
+		if id != *requested {
+			continue
+		}
+		if i == 0 || prog.HasAbility(i) {
+			return id, "", nil
+		}
+		return abilityIDs[equipped], fmt.Sprintf("%s ability %d is locked; using %d", itemType, id, abilityIDs[equipped]), nil
+	}
+	return 0, "", errors.ErrInvalidAbility
+}
+
+// RpcValidateLoadoutForMatch checks the intended pet, class and abilities for ownership, unlock
+// state and ability_rules, and returns the canonical loadout for the match. notify_match_start
+// runs the same check and stamps the result on the match lock.
+func RpcValidateLoadoutForMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req ValidateLoadoutRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	resp, err := validateLoadoutForMatch(ctx, nk, logger, userID, req)
+	if err != nil {
+		return "", err
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// validateLoadoutForMatch resolves req into the canonical loadout, returning RPC errors.
+func validateLoadoutForMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req ValidateLoadoutRequest) (*ValidatedLoadout, error) {
+	pet, ok := GetPet(req.PetID)
+	if !ok {
+		return nil, errors.ErrInvalidItemID
+	}
+	class, ok := GetClass(req.ClassID)
+	if !ok {
+		return nil, errors.ErrInvalidItemID
+	}
+
+	owned, err := IsLoadoutOwned(ctx, nk, userID, req.PetID, req.ClassID)
+	if err != nil {
+		return nil, errors.ErrFailedCheckOwnership
+	}
+	if !owned {
+		logger.Warn("Loadout validation for user %s rejected unowned pet %d / class %d", userID, req.PetID, req.ClassID)
+		return nil, errors.ErrNotOwned
+	}
+
+	petProg, err := GetItemProgression(ctx, nk, logger, userID, ProgressionKeyPet, req.PetID)
+	if err != nil {
+		return nil, errors.ErrCouldNotReadStorage
+	}
+	classProg, err := GetItemProgression(ctx, nk, logger, userID, ProgressionKeyClass, req.ClassID)
+	if err != nil {
+		return nil, errors.ErrCouldNotReadStorage
+	}
+
+	resp := &ValidatedLoadout{
+		PetID:       req.PetID,
+		ClassID:     req.ClassID,
+		PetLevel:    max(petProg.Level, 1),
+		ClassLevel:  max(classProg.Level, 1),
+		Corrections: []string{},
+	}
+
+	var correction string
+	resp.PetAbilityID, correction, err = resolveLoadoutAbility(storageKeyPet, pet.AbilityIDs, petProg, req.PetAbilityID)
+	if err != nil {
+		return nil, err
+	}
+	if correction != "" {
+		resp.Corrections = append(resp.Corrections, correction)
+	}
+	resp.ClassAbilityID, correction, err = resolveLoadoutAbility(storageKeyClass, class.AbilityIDs, classProg, req.ClassAbilityID)
+	if err != nil {
+		return nil, err
+	}
+	if correction != "" {
+		resp.Corrections = append(resp.Corrections, correction)
+	}
+
+	if reason := GetAbilityRulesConfig().Validate([]uint32{resp.PetAbilityID, resp.ClassAbilityID}); reason != "" {
+		logger.Warn("Loadout validation for user %s rejected: %s", userID, reason)
+		return nil, errors.ErrIllegalLoadout
+	}
+	return resp, nil
+}
@@ -0,0 +1,55 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+
+	"block-server/errors"
+)
+
+// ownDefaultLoadout gives userID the default pet and class so a match can start.
+func ownDefaultLoadout(t *testing.T, nk *fakeNakama, userID string) {
+	t.Helper()
+	nk.put(t, storageCollectionInventory, storageKeyPet, userID, InventoryData{Items: []uint32{DefaultPetID}})
+	nk.put(t, storageCollectionInventory, storageKeyClass, userID, InventoryData{Items: []uint32{DefaultClassID}})
+}
+
+func TestMatchStartStampsValidatedLoadout(t *testing.T) {
+	pet, _ := GetPet(DefaultPetID)
+	if pet == nil || len(pet.AbilityIDs) == 0 {
+		t.Skip("default pet needs abilities")
+	}
+	nk := newFakeNakama()
+	start := func(req NotifyMatchStartRequest) error {
+		payload, _ := json.Marshal(req)
+		_, err := RpcNotifyMatchStart(testContext("u1"), testLogger{}, nil, nk, string(payload))
+		return err
+	}
+	loadout := &ValidateLoadoutRequest{PetID: DefaultPetID, ClassID: DefaultClassID, PetAbilityID: &pet.AbilityIDs[0]}
+
+	if err := start(NotifyMatchStartRequest{MatchID: "m1", Loadout: loadout}); err != errors.ErrNotOwned {
+		t.Fatalf("unowned loadout: err = %v, want ErrNotOwned", err)
+	}
+	if nk.count(storageCollectionActiveMatch, "u1") != 0 {
+		t.Fatal("a rejected loadout still wrote a match lock")
+	}
+
+	ownDefaultLoadout(t, nk, "u1")
+	if err := start(NotifyMatchStartRequest{MatchID: "m1", Loadout: loadout}); err != nil {
+		t.Fatalf("owned loadout: %v", err)
+	}
+	var lock ActiveMatch
+	nk.get(t, storageCollectionActiveMatch, activeMatchKey("m1"), "u1", &lock)
+	if l := lock.Loadout; l == nil || l.PetID != DefaultPetID || l.ClassID != DefaultClassID || l.PetAbilityID != pet.AbilityIDs[0] {
+		t.Errorf("lock loadout = %+v, want the validated default loadout", lock.Loadout)
+	}
+
+	// Without a loadout the equipped items are validated and stamped.
+	if err := start(NotifyMatchStartRequest{MatchID: "m2"}); err != nil {
+		t.Fatalf("equipped loadout: %v", err)
+	}
+	nk.get(t, storageCollectionActiveMatch, activeMatchKey("m2"), "u1", &lock)
+	if lock.Loadout == nil || lock.Loadout.PetID != DefaultPetID {
+		t.Errorf("lock loadout = %+v, want the equipped pet", lock.Loadout)
+	}
+}
@@ -11,12 +11,16 @@ import (
 	"block-server/errors"
 	"block-server/notify"
 
+	"github.com/heroiclabs/nakama-common/api"
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
 const (
 	storageCollectionActiveMatch = "active_match"
-	storageKeyCurrentMatch       = "current"
+	// activeMatchKeyPrefix + match ID keys each lock, so a player can hold several matches at once.
+	activeMatchKeyPrefix = "match_"
+	// storageKeyCurrentMatch is the old single-lock key, still honored until it clears or goes stale.
+	storageKeyCurrentMatch = "current"
 )
 
 type ActiveMatch struct {
@@ -25,21 +29,59 @@ type ActiveMatch struct {
 	OpponentID   string        `json:"opponent_id,omitempty"`
 	TokensBanked int           `json:"tokens_banked"`
 	Rounds       []RoundRecord `json:"rounds,omitempty"`
-	Version      string        `json:"-"`
+	// RewardPercent is stamped at start when the rematch policy reduces rewards; 0 = full rewards.
+	RewardPercent int `json:"reward_percent,omitempty"`
+	// ShadowBanned is stamped at start from the moderation flag; every reward path reads it from here.
+	ShadowBanned bool `json:"shadow_banned,omitempty"`
+	// Loadout is validated at start; the result is credited to it, not to the IDs the client reports.
+	Loadout *ValidatedLoadout `json:"loadout,omitempty"`
+	// Authoritative marks a lock taken by a server-refereed match; only the referee settles it.
+	Authoritative bool `json:"authoritative,omitempty"`
+	Key     string            `json:"-"` // Storage key the lock was read from
+	Version      string `json:"-"`
 }
 
 // MatchResultRecord stores a player's claimed result for consensus
 type MatchResultRecord struct {
 	UserID      string `json:"user_id"`
 	ClaimedWin  bool   `json:"claimed_win"`
+	ClaimedDraw bool   `json:"claimed_draw,omitempty"`
 	Score       int    `json:"score"`
 	SubmittedAt int64  `json:"submitted_at"`
 	Resolved    bool   `json:"resolved"` // True when this player was the second submitter and resolved consensus
+	// ShadowBanned is copied from the submitter's lock so the second submitter can withhold their deferred rewards.
+	ShadowBanned bool `json:"shadow_banned,omitempty"`
 }
 
 type NotifyMatchStartRequest struct {
 	MatchID    string `json:"match_id"`
 	OpponentID string `json:"opponent_id,omitempty"`
+	// Loadout is the pet, class and abilities the player brings; omitted means the equipped ones.
+	Loadout *ValidateLoadoutRequest `json:"loadout,omitempty"`
+}
+
+type ForfeitMatchRequest struct {
+	MatchID string `json:"match_id"`
+}
+
+// ActiveMatchSummary is one held lock as reported to a reconnecting client.
+type ActiveMatchSummary struct {
+	MatchID    string `json:"match_id"`
+	StartTime  int64  `json:"start_time"`
+	OpponentID string `json:"opponent_id,omitempty"`
+}
+
+// ActiveMatchResponse lets a reconnecting client decide whether to resume or start fresh.
+// Active is false when no lock is held; Cleared is true when a stale lock was just removed.
+// The top-level fields describe the most recently started match; Matches lists every live lock.
+type ActiveMatchResponse struct {
+	Active     bool                 `json:"active"`
+	MatchID    string               `json:"match_id,omitempty"`
+	StartTime  int64                `json:"start_time,omitempty"`
+	OpponentID string               `json:"opponent_id,omitempty"`
+	Stale      bool                 `json:"stale,omitempty"`
+	Cleared    bool                 `json:"cleared,omitempty"`
+	Matches    []ActiveMatchSummary `json:"matches,omitempty"`
 }
 
 // RpcNotifyMatchStart records the start of a match for validation
@@ -58,37 +100,163 @@ func RpcNotifyMatchStart(ctx context.Context, logger runtime.Logger, db *sql.DB,
 	if req.MatchID == "" {
 		return "", errors.ErrInvalidInput
 	}
+	if err := startActiveMatch(ctx, nk, logger, userID, req, false); err != nil {
+		return "", err
+	}
+	return "{}", nil
+}
+
+// startActiveMatch validates the loadout, applies the concurrent-match cap and rematch policy,
+// and writes the user's lock for req.MatchID. authoritative stamps a server-refereed match.
+func startActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req NotifyMatchStartRequest, authoritative bool) error {
+	intended := req.Loadout
+	if intended == nil {
+		petID, classID, err := equippedLoadoutIDs(ctx, nk, userID)
+		if err != nil {
+			logger.Error("Failed to read equipped loadout for user %s: %v", userID, err)
+			return errors.ErrCouldNotReadStorage
+		}
+		intended = &ValidateLoadoutRequest{PetID: petID, ClassID: classID}
+	}
+	loadout, err := validateLoadoutForMatch(ctx, nk, logger, userID, *intended)
+	if err != nil {
+		return err
+	}
 
-	// Overwrite active match lock. Player must still satisfy minMatchDurationMs.
+	// Stale locks are pruned here; a restart of the same match overwrites its own lock.
+	held, err := listActiveMatches(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to list active matches for user %s: %v", userID, err)
+		return errors.ErrCouldNotReadStorage
+	}
+	live := 0
+	for _, m := range held {
+		switch {
+		case m.MatchID == req.MatchID:
+			if m.Key != activeMatchKey(req.MatchID) {
+				clearActiveMatch(ctx, nk, logger, userID, m)
+			}
+		case isActiveMatchStale(m):
+			clearActiveMatch(ctx, nk, logger, userID, m)
+		default:
+			live++
+		}
+	}
+	if limit := GetEconomyConfig().maxActiveMatches(); live >= limit {
+		logger.Warn("User %s already holds %d active matches (limit %d); rejecting %s", userID, live, limit, req.MatchID)
+		notifyRateLimited(ctx, nk, logger, userID, "Finish your current match before starting another.")
+		return errors.ErrTooManyActiveMatches
+	}
 
 	activeMatch := ActiveMatch{
-		MatchID:    req.MatchID,
-		StartTime:  time.Now().UnixMilli(),
-		OpponentID: req.OpponentID,
-		Rounds:     make([]RoundRecord, 0),
+		MatchID:       req.MatchID,
+		StartTime:     time.Now().UnixMilli(),
+		OpponentID:    req.OpponentID,
+		Rounds:        make([]RoundRecord, 0),
+		Loadout:       loadout,
+		Authoritative: authoritative,
+	}
+
+	// One read for the moderation flag and the rematch window. Fails open: neither may block play.
+	var action string
+	var recentWrite *runtime.StorageWrite
+	startObjects, err := nk.StorageRead(ctx, []*runtime.StorageRead{shadowBanRead(userID), recentOpponentsRead(userID)})
+	if err != nil {
+		logger.Warn("Failed to read match start state for user %s: %v", userID, err)
+	} else {
+		activeMatch.ShadowBanned = shadowBannedIn(logger, userID, startObjects)
+		action, recentWrite = checkRematchPolicy(logger, userID, req.OpponentID, req.MatchID, startObjects)
+	}
+	switch action {
+	case RematchActionReject:
+		notifyRateLimited(ctx, nk, logger, userID, "You've played this opponent a lot recently. Try someone new!")
+		return errors.ErrRematchLimit
+	case RematchActionReduce:
+		activeMatch.RewardPercent = GetEconomyConfig().Rematch.RewardPercent
+		if err := notify.SendCenterMessage(ctx, nk, userID, "Rematch rewards reduced", notify.ToastWarning, 0); err != nil {
+			logger.Warn("Failed to send rematch notice to %s: %v", userID, err)
+		}
 	}
 
 	value, err := json.Marshal(activeMatch)
 	if err != nil {
-		return "", err
+		return errors.ErrMarshal
 	}
 
-	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+	writes := []*runtime.StorageWrite{{
 		Collection:      storageCollectionActiveMatch,
-		Key:             storageKeyCurrentMatch,
+		Key:             activeMatchKey(req.MatchID),
 		UserID:          userID,
 		Value:           string(value),
 		PermissionRead:  0, // Hidden
 		PermissionWrite: 0,
-	}})
+	}}
+	if recentWrite != nil {
+		writes = append(writes, recentWrite)
+	}
+	_, err = storageWriteWithRetry(ctx, nk, logger, writes)
 
 	if err != nil {
 		logger.Error("Failed to write active match: %v", err)
-		return "", errors.ErrCouldNotWriteStorage
+		return errors.ErrCouldNotWriteStorage
 	}
 
 	logger.Info("Match start notified for user %s: match_id=%s", userID, req.MatchID)
-	return "{}", nil
+	return nil
+}
+
+// RpcGetActiveMatch returns the caller's active match locks after a reconnect.
+// Locks past the mode's stale ceiling are cleared here rather than left for the next submit.
+func RpcGetActiveMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	held, err := listActiveMatches(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to read active match for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := ActiveMatchResponse{}
+	var latest, latestCleared *ActiveMatch
+	for _, activeMatch := range held {
+		if isActiveMatchStale(activeMatch) {
+			clearActiveMatch(ctx, nk, logger, userID, activeMatch)
+			resp.Cleared = true
+			if latestCleared == nil || activeMatch.StartTime > latestCleared.StartTime {
+				latestCleared = activeMatch
+			}
+			logger.Info("Cleared stale active match %s for user %s on reconnect", activeMatch.MatchID, userID)
+			continue
+		}
+		resp.Matches = append(resp.Matches, ActiveMatchSummary{
+			MatchID:    activeMatch.MatchID,
+			StartTime:  activeMatch.StartTime,
+			OpponentID: activeMatch.OpponentID,
+		})
+		if latest == nil || activeMatch.StartTime > latest.StartTime {
+			latest = activeMatch
+		}
+	}
+	if latest != nil {
+		resp.Active = true
+	} else if latestCleared != nil {
+		latest = latestCleared
+		resp.Stale = true
+	}
+	if latest != nil {
+		resp.MatchID = latest.MatchID
+		resp.StartTime = latest.StartTime
+		resp.OpponentID = latest.OpponentID
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
 }
 
 // RpcSubmitMatchResult handles match result submission and reward generation
@@ -104,7 +272,20 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 		return "", errors.ErrUnmarshal
 	}
 
-	// Idempotency check
+	// Idempotency check: an explicit key survives other matches finishing in between.
+	if req.IdempotencyKey != "" {
+		if len(req.IdempotencyKey) > maxIdempotencyKeyLen {
+			return "", errors.ErrInvalidInput
+		}
+		if entry, ok := lookupIdempotentMatchResult(ctx, nk, userID, req.IdempotencyKey); ok {
+			if entry.MatchID != req.MatchID {
+				logger.Warn("User %s reused idempotency key %q for match %s (cached for %s)", userID, req.IdempotencyKey, req.MatchID, entry.MatchID)
+				return "", errors.ErrInvalidInput
+			}
+			logger.Info("Returning cached reward payload for idempotency key %q user %s", req.IdempotencyKey, userID)
+			return string(entry.Payload), nil
+		}
+	}
 	cacheObj, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
 		Collection: "match_results_cache",
 		Key:        "latest_match_result",
@@ -118,7 +299,36 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 		}
 	}
 
+	if err := GetEconomyConfig().ScoreBounds.validate(&req); err != nil {
+		logger.Warn("Rejected match %s for user %s: %v", req.MatchID, userID, err)
+		return "", errors.ErrScoreOutOfBounds
+	}
+
+	// Validate equipped items exist and are owned, before any consensus record is written.
+	if !ValidateItemExists(storageKeyPet, req.EquippedPetID) {
+		logger.Warn("Invalid pet ID in match result: %d", req.EquippedPetID)
+		return "", errors.ErrInvalidItemID
+	}
+	if !ValidateItemExists(storageKeyClass, req.EquippedClassID) {
+		logger.Warn("Invalid class ID in match result: %d", req.EquippedClassID)
+		return "", errors.ErrInvalidItemID
+	}
+	owned, err := IsLoadoutOwned(ctx, nk, userID, req.EquippedPetID, req.EquippedClassID)
+	if err != nil {
+		logger.Error("Failed to check loadout ownership for user %s: %v", userID, err)
+		return "", errors.ErrFailedCheckOwnership
+	}
+	if !owned {
+		logger.Warn("User %s submitted unowned loadout (pet %d, class %d)", userID, req.EquippedPetID, req.EquippedClassID)
+		return "", errors.ErrItemNotOwnedForbidden
+	}
+
 	activeMatch, err := validateActiveMatch(ctx, nk, logger, userID, req.MatchID)
+	if activeMatch != nil && activeMatch.Authoritative {
+		// The referee pays refereed matches; a client claim would race it to the reward.
+		logger.Warn("User %s submitted a result for refereed match %s", userID, req.MatchID)
+		return "", errors.ErrMatchRefereed
+	}
 	if err != nil {
 		roundsPlayed := req.RoundsWon + req.RoundsLost
 		if err == errors.ErrMatchTooShort && roundsPlayed >= 1 && activeMatch != nil {
@@ -139,14 +349,10 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 				staleNote := notify.NewRewardPayload("match")
 				staleNote.Meta = &notify.RewardMeta{ErrorCode: errorCodeStaleMatch}
 				staleNote.ReasonKey = "reward.match.stale_resolved"
-				go func(oppID string) {
-					if sendErr := notify.SendReward(context.Background(), nk, oppID, staleNote); sendErr != nil {
-						logger.Warn("Failed to send stale-match notification to opponent %s: %v", oppID, sendErr)
-					}
-				}(activeMatch.OpponentID)
+				go SendRewardOrStore(context.Background(), nk, logger, activeMatch.OpponentID, staleNote)
 			}
 
-			clearActiveMatch(ctx, nk, logger, userID)
+			clearActiveMatch(ctx, nk, logger, userID, activeMatch)
 
 			errorPayload := notify.NewRewardPayload("match")
 			errorPayload.Meta = &notify.RewardMeta{ErrorCode: errorCodeStaleMatch}
@@ -161,17 +367,34 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 		}
 	}
 
-	validateRounds(ctx, nk, &req, userID, logger, activeMatch)
+	isSolo := activeMatch.OpponentID == ""
+	req.ServerDurationMs = activeMatch.elapsedMs()
+	if l := activeMatch.Loadout; l != nil && (req.EquippedPetID != l.PetID || req.EquippedClassID != l.ClassID) {
+		logger.Warn("User %s reported pet %d / class %d for match %s, started with %d / %d; using the start loadout",
+			userID, req.EquippedPetID, req.EquippedClassID, req.MatchID, l.PetID, l.ClassID)
+		req.EquippedPetID, req.EquippedClassID = l.PetID, l.ClassID
+	}
+	if req.Won && req.Draw {
+		return "", errors.ErrInvalidInput
+	}
+	if isSolo {
+		req.Draw = false // Solo has no opponent to draw against
+	}
+
+	if err := validateRounds(ctx, nk, &req, userID, logger, activeMatch); err != nil {
+		logger.Warn("Strict round validation rejected match %s for user %s: %v", req.MatchID, userID, err)
+		return "", errors.ErrInvalidInput
+	}
 
 	// Consensus check (unified path: solo short-circuits in resolveMatchConsensus)
-	consensusResult, err := resolveMatchConsensus(ctx, nk, logger, userID, activeMatch.OpponentID, req.MatchID, req.Won, req.FinalScore, req.OpponentForfeited)
+	consensusResult, opponentBanned, err := resolveMatchConsensus(ctx, nk, logger, userID, activeMatch.OpponentID, req.MatchID, req.Won, req.Draw, req.FinalScore, req.OpponentForfeited, activeMatch.ShadowBanned)
 	if err != nil {
 		logger.Warn("Consensus check failed for user %s: %v", userID, err)
 		return "", err
 	}
 
-	isSolo := activeMatch.OpponentID == ""
 	actualWon := req.Won
+	actualDraw := false
 	var opponentIDForDeferred string
 	var opponentWonForDeferred bool
 
@@ -186,13 +409,10 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 			opponentNote := notify.NewRewardPayload("match")
 			opponentNote.Meta = &notify.RewardMeta{ErrorCode: errorCodeOpponentSubmitted}
 			opponentNote.ReasonArgs = map[string]string{
-				"opponent_claimed_win": fmt.Sprintf("%v", req.Won),
+				"opponent_claimed_win":  fmt.Sprintf("%v", req.Won),
+				"opponent_claimed_draw": fmt.Sprintf("%v", req.Draw),
 			}
-			go func(oppID string) {
-				if sendErr := notify.SendReward(context.Background(), nk, oppID, opponentNote); sendErr != nil {
-					logger.Warn("Failed to send opponent-submitted notification to %s: %v", oppID, sendErr)
-				}
-			}(activeMatch.OpponentID)
+			go SendRewardOrStore(context.Background(), nk, logger, activeMatch.OpponentID, opponentNote)
 		}
 
 	case "resolved":
@@ -200,32 +420,34 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 		logger.Info("Match %s: user %s arrived late, rewards already resolved by opponent", req.MatchID, userID)
 
 	case "conflict":
-		logger.Warn("Match %s: Both players claimed victory. Voiding win for user %s", req.MatchID, userID)
+		logger.Warn("Match %s: Players disagree on outcome. Voiding win for user %s", req.MatchID, userID)
+		actualWon = false
+
+	case "draw":
 		actualWon = false
+		actualDraw = true
+		logger.Info("Match %s: draw confirmed by both players (user %s)", req.MatchID, userID)
 
 	case "ok", "forfeit_win":
 		actualWon = req.Won
-		if consensusResult == "ok" && activeMatch.OpponentID != "" {
+		// A shadow-banned first submitter's deferred rewards are withheld like their own.
+		if consensusResult == "ok" && activeMatch.OpponentID != "" && !opponentBanned {
 			opponentIDForDeferred = activeMatch.OpponentID
 			opponentWonForDeferred = !req.Won
 		}
 	}
 
-	// Validate equipped items exist
-	if !ValidateItemExists(storageKeyPet, req.EquippedPetID) {
-		logger.Warn("Invalid pet ID in match result: %d", req.EquippedPetID)
-		return "", errors.ErrInvalidItemID
-	}
-	if !ValidateItemExists(storageKeyClass, req.EquippedClassID) {
-		logger.Warn("Invalid class ID in match result: %d", req.EquippedClassID)
-		return "", errors.ErrInvalidItemID
-	}
-
 	// Override request with consensus-validated result
 	req.Won = actualWon
+	req.Draw = actualDraw
+
+	if req.Won && req.ServerDurationMs < fastWinFlagMs {
+		logger.Warn("[audit] Fast win: match %s user %s won after %dms server time (client claimed %ds)",
+			req.MatchID, userID, req.ServerDurationMs, req.MatchDurationSec)
+	}
 
 	// Process rewards atomically, then clean up active match
-	result, err := processMatchRewards(ctx, nk, logger, userID, &req, isSolo, activeMatch)
+	result, err := processMatchRewards(ctx, nk, logger, userID, &req, isSolo, activeMatch, streakOutcomeFor(consensusResult, actualWon, isSolo))
 	if err == nil {
 		// Emit authoritative telemetry metric (match_completed)
 		go func() {
@@ -248,19 +470,20 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 			}
 
 			telemetryData, _ := json.Marshal(map[string]interface{}{
-				"$type":           "MatchCompletedMetric",
-				"MatchId":         req.MatchID,
-				"WinnerId":        winnerID,
-				"LoserId":         loserID,
-				"WinnerScore":     winnerScore,
-				"LoserScore":      loserScore,
-				"DurationSeconds": float64(req.MatchDurationSec),
-				"GameMode":        gameMode,
-				"RoundsWon":       req.RoundsWon,
-				"RoundsLost":      req.RoundsLost,
-				"AbilitiesCast":   req.AbilitiesCast,
-				"APM":             req.APM,
-				"PiecesPlaced":    req.PiecesPlaced,
+				"$type":            "MatchCompletedMetric",
+				"MatchId":          req.MatchID,
+				"WinnerId":         winnerID,
+				"LoserId":          loserID,
+				"WinnerScore":      winnerScore,
+				"LoserScore":       loserScore,
+				"DurationSeconds":  float64(req.MatchDurationSec),
+				"ServerDurationMs": req.ServerDurationMs,
+				"GameMode":         gameMode,
+				"RoundsWon":        req.RoundsWon,
+				"RoundsLost":       req.RoundsLost,
+				"AbilitiesCast":    req.AbilitiesCast,
+				"APM":              req.APM,
+				"PiecesPlaced":     req.PiecesPlaced,
 			})
 
 			telemetryEvent := TelemetryEvent{
@@ -277,24 +500,32 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 
 	if err != nil {
 		logger.Error("Failed to process match rewards: %v", err)
-		return "", err
+		return "", errors.ErrMatchRewardCommit
 	}
 
-	// Second submitter: push deferred gold win bonus to first submitter
+	// Second submitter: grant the first submitter's deferred win bonus now that the outcome is confirmed
 	if opponentIDForDeferred != "" {
-		deferredReward, err := processDeferredWinBonus(ctx, nk, logger, opponentIDForDeferred, opponentWonForDeferred)
+		// The first submitter's outcome is only known now; move their streak.
+		streakReward, err := applyDeferredStreak(ctx, nk, logger, opponentIDForDeferred, req.MatchID, opponentWonForDeferred)
+		if err != nil {
+			logger.Warn("Failed to update win streak for opponent %s in match %s: %v", opponentIDForDeferred, req.MatchID, err)
+		}
+		if opponentWonForDeferred {
+			submitTournamentWin(ctx, nk, logger, opponentIDForDeferred)
+		}
+
+		deferredReward, err := processDeferredWinBonus(ctx, nk, logger, opponentIDForDeferred, req.MatchID, opponentWonForDeferred)
 		if err != nil {
 			logger.Error("Failed to grant deferred rewards to opponent %s in match %s: %v", opponentIDForDeferred, req.MatchID, err)
 			// Non-fatal: our own rewards succeeded. Opponent will have lost their win bonus — acceptable.
-		} else if deferredReward != nil {
-			if err := notify.SendReward(ctx, nk, opponentIDForDeferred, deferredReward); err != nil {
-				logger.Error("Failed to notify deferred reward to opponent %s: %v", opponentIDForDeferred, err)
-			}
 		}
+		// Both are already applied; sent as one notification, landing in the opponent's inbox
+		// only if it fails.
+		SendRewardsOrStore(ctx, nk, logger, opponentIDForDeferred, deferredReward, streakReward)
 	}
 
 	// Synchronous: Write leaderboard records (sets LeaderboardRank, delta, and BoardId in payload). Non-fatal on err.
-	leaderboardRank, leaderboardDelta, boardId, competitiveBoards := writeLeaderboardRecords(ctx, nk, logger, userID, &req, isSolo, actualWon)
+	leaderboardRank, leaderboardDelta, boardId, competitiveBoards := writeLeaderboardRecords(ctx, nk, logger, userID, &req, isSolo, actualWon, activeMatch.ShadowBanned)
 	if leaderboardRank > 0 {
 		result.LeaderboardRank = leaderboardRank
 		result.LeaderboardRankDelta = leaderboardDelta
@@ -366,22 +597,7 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 	}
 
 	// Atomic idempotency commit
-	cacheEntry := MatchResultCacheEntry{
-		MatchID: req.MatchID,
-		Payload: respBytes,
-	}
-	cacheBytes, _ := json.Marshal(cacheEntry)
-	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
-		Collection:      "match_results_cache",
-		Key:             "latest_match_result",
-		UserID:          userID,
-		Value:           string(cacheBytes),
-		PermissionRead:  0,
-		PermissionWrite: 0,
-	}})
-	if err != nil {
-		logger.Warn("Failed to cache match result for user %s match %s: %v", userID, req.MatchID, err)
-	}
+	cacheMatchResult(ctx, nk, logger, userID, req.MatchID, req.IdempotencyKey, respBytes)
 
 	xpAmount := 0
 	if result.Progression != nil && result.Progression.XpGranted != nil {
@@ -405,6 +621,213 @@ func RpcSubmitMatchResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 	return string(respBytes), nil
 }
 
+// RpcForfeitMatch concedes the caller's active match.
+// The loss is recorded through the normal consensus path so the opponent can claim
+// their win, then participation-only rewards are granted and the active match cleared.
+func RpcForfeitMatch(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req ForfeitMatchRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		logger.Error("Failed to unmarshal forfeit match: %v", err)
+		return "", errors.ErrUnmarshal
+	}
+	if req.MatchID == "" {
+		return "", errors.ErrInvalidInput
+	}
+
+	activeMatch, err := validateActiveMatch(ctx, nk, logger, userID, req.MatchID)
+	if activeMatch != nil && activeMatch.Authoritative {
+		// Leaving a refereed match is the forfeit; the referee settles it.
+		logger.Warn("User %s tried to forfeit refereed match %s", userID, req.MatchID)
+		return "", errors.ErrMatchRefereed
+	}
+	grantRewards := true
+	if err != nil {
+		if (err == errors.ErrMatchTooShort || err == errors.ErrStaleMatchExpired) && activeMatch != nil {
+			// Still concede so the opponent isn't left waiting, but an instant or
+			// abandoned forfeit earns nothing (anti-farming).
+			logger.Info("Match %s: forfeit by user %s outside duration window (%v), no rewards", req.MatchID, userID, err)
+			grantRewards = false
+		} else {
+			logger.Warn("Forfeit validation failed for user %s: %v", userID, err)
+			return "", err
+		}
+	}
+
+	isSolo := activeMatch.OpponentID == ""
+	consensusResult, _, err := resolveMatchConsensus(ctx, nk, logger, userID, activeMatch.OpponentID, req.MatchID, false, false, 0, false, activeMatch.ShadowBanned)
+	if err != nil {
+		logger.Warn("Consensus write failed for forfeit by user %s: %v", userID, err)
+		return "", err
+	}
+
+	if !isSolo && consensusResult == "pending" {
+		opponentNote := notify.NewRewardPayload("match")
+		opponentNote.Meta = &notify.RewardMeta{ErrorCode: errorCodeOpponentForfeited}
+		opponentNote.ReasonKey = "reward.match.opponent_forfeited"
+		go SendRewardOrStore(context.Background(), nk, logger, activeMatch.OpponentID, opponentNote)
+	}
+
+	var result *notify.RewardPayload
+	if grantRewards {
+		matchReq := &MatchResultRequest{
+			MatchID:          req.MatchID,
+			Won:              false,
+			ServerDurationMs: activeMatch.elapsedMs(),
+		}
+		if l := activeMatch.Loadout; l != nil {
+			matchReq.EquippedPetID, matchReq.EquippedClassID = l.PetID, l.ClassID
+		}
+		// Conceding breaks the win streak whatever the consensus state, but doesn't count toward loss protection.
+		forfeitStreak := streakUnresolved
+		if !isSolo {
+			forfeitStreak = streakConcede
+		}
+		result, err = processMatchRewards(ctx, nk, logger, userID, matchReq, isSolo, activeMatch, forfeitStreak)
+		if err != nil {
+			logger.Error("Failed to process forfeit rewards: %v", err)
+			return "", errors.ErrMatchRewardCommit
+		}
+		// Non-fatal; failures are logged inside.
+		_ = UpdatePlayerStatsAndHistory(ctx, nk, logger, userID, matchReq, isSolo, false, activeMatch.OpponentID)
+	} else {
+		clearActiveMatch(ctx, nk, logger, userID, activeMatch)
+		result = notify.NewRewardPayload("match")
+	}
+	result.ReasonKey = "reward.match.forfeit"
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("Failed to marshal forfeit response: %v", err)
+		return "", errors.ErrMarshal
+	}
+
+	// A late submit_match_result for the same match returns this payload instead of re-processing.
+	cacheMatchResult(ctx, nk, logger, userID, req.MatchID, "", respBytes)
+
+	logger.Info("Match %s forfeited by user %s (consensus=%s)", req.MatchID, userID, consensusResult)
+	return string(respBytes), nil
+}
+
+const (
+	// matchResultIdempotencyKey is the match_results_cache key holding payloads by idempotency key.
+	matchResultIdempotencyKey           = "idempotency"
+	matchResultIdempotencyWindow        = 15 * time.Minute
+	maxMatchResultIdempotencyEntries    = 10
+	maxIdempotencyKeyLen                = 64
+	matchResultIdempotencyWriteAttempts = 3
+)
+
+// readMatchResultIdempotency returns the caller's idempotency document with its version, empty
+// if none is stored. An unreadable document is treated as empty so the next write replaces it.
+func readMatchResultIdempotency(ctx context.Context, nk runtime.NakamaModule, userID string) (*MatchResultIdempotencyDocument, error) {
+	doc := &MatchResultIdempotencyDocument{Entries: map[string]MatchResultCacheEntry{}, Version: "*"}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: "match_results_cache",
+		Key:        matchResultIdempotencyKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) > 0 {
+		doc.Version = objects[0].Version
+		if err := json.Unmarshal([]byte(objects[0].Value), doc); err != nil || doc.Entries == nil {
+			doc.Entries = map[string]MatchResultCacheEntry{}
+		}
+	}
+	return doc, nil
+}
+
+// lookupIdempotentMatchResult returns the cached payload for key if it is still inside the window.
+func lookupIdempotentMatchResult(ctx context.Context, nk runtime.NakamaModule, userID, key string) (MatchResultCacheEntry, bool) {
+	doc, err := readMatchResultIdempotency(ctx, nk, userID)
+	if err != nil {
+		return MatchResultCacheEntry{}, false
+	}
+	entry, ok := doc.Entries[key]
+	if !ok || time.Since(time.UnixMilli(entry.CachedAt)) > matchResultIdempotencyWindow {
+		return MatchResultCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// cacheMatchResult stores the response payload for idempotent resubmission: always as the latest
+// result, and under idempotencyKey too when the client sent one.
+func cacheMatchResult(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, matchID, idempotencyKey string, respBytes []byte) {
+	now := time.Now()
+	cacheEntry := MatchResultCacheEntry{
+		MatchID:  matchID,
+		Payload:  respBytes,
+		CachedAt: now.UnixMilli(),
+	}
+	cacheBytes, _ := json.Marshal(cacheEntry)
+	_, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      "match_results_cache",
+		Key:             "latest_match_result",
+		UserID:          userID,
+		Value:           string(cacheBytes),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	if err != nil {
+		logger.Warn("Failed to cache match result for user %s match %s: %v", userID, matchID, err)
+	}
+
+	if idempotencyKey == "" {
+		return
+	}
+	// Submits for other matches may update the document concurrently; retry on a version conflict.
+	for attempt := 0; attempt < matchResultIdempotencyWriteAttempts; attempt++ {
+		if err = storeIdempotentMatchResult(ctx, nk, userID, idempotencyKey, cacheEntry); err == nil {
+			return
+		}
+	}
+	logger.Warn("Failed to cache match result for user %s match %s under its idempotency key: %v", userID, matchID, err)
+}
+
+// storeIdempotentMatchResult does one OCC read-modify-write of the idempotency document.
+func storeIdempotentMatchResult(ctx context.Context, nk runtime.NakamaModule, userID, idempotencyKey string, cacheEntry MatchResultCacheEntry) error {
+	doc, err := readMatchResultIdempotency(ctx, nk, userID)
+	if err != nil {
+		return err
+	}
+	for key, entry := range doc.Entries {
+		if time.Since(time.UnixMilli(entry.CachedAt)) > matchResultIdempotencyWindow {
+			delete(doc.Entries, key)
+		}
+	}
+	// Past the cap, drop the oldest entries to make room.
+	for len(doc.Entries) >= maxMatchResultIdempotencyEntries {
+		oldestKey, oldestAt := "", int64(0)
+		for key, entry := range doc.Entries {
+			if oldestKey == "" || entry.CachedAt < oldestAt {
+				oldestKey, oldestAt = key, entry.CachedAt
+			}
+		}
+		delete(doc.Entries, oldestKey)
+	}
+	doc.Entries[idempotencyKey] = cacheEntry
+	docBytes, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      "match_results_cache",
+		Key:             matchResultIdempotencyKey,
+		UserID:          userID,
+		Value:           string(docBytes),
+		Version:         doc.Version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
 const (
 	// minMatchDurationMs: floor gate for anti-farming.
 	minMatchDurationMs = 10000 // 10 seconds
@@ -416,6 +839,9 @@ const (
 	// Use a 1-hour ceiling purely to clean up sessions from crashed/uninstalled clients.
 	maxSoloMatchDurationMs = 60 * 60 * 1000 // 1 hour
 
+	// fastWinFlagMs: wins quicker than this (server time) are logged for review. Not a gate.
+	fastWinFlagMs = 60000 // 1 minute
+
 	storageCollectionResults = "match_results"
 
 	// errorCode constants: set in RewardMeta.ErrorCode when a validation gate rejects the match.
@@ -423,50 +849,55 @@ const (
 	errorCodeMatchTooShort     = "MATCH_TOO_SHORT"
 	errorCodeStaleMatch        = "STALE_MATCH"
 	errorCodeOpponentSubmitted = "OPPONENT_SUBMITTED"
+	errorCodeOpponentForfeited = "OPPONENT_FORFEITED"
 )
 
 func validateActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, matchID string) (*ActiveMatch, error) {
-	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
-		Collection: storageCollectionActiveMatch,
-		Key:        storageKeyCurrentMatch,
-		UserID:     userID,
-	}})
+	// The per-match key wins; the legacy single lock only counts if it holds this match.
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{
+		{Collection: storageCollectionActiveMatch, Key: activeMatchKey(matchID), UserID: userID},
+		{Collection: storageCollectionActiveMatch, Key: storageKeyCurrentMatch, UserID: userID},
+	})
 	if err != nil {
 		return nil, errors.ErrCouldNotReadStorage
 	}
 
-	if len(objects) == 0 {
-		return nil, errors.ErrNoActiveMatch
+	var activeMatch, legacy *ActiveMatch
+	for _, obj := range objects {
+		m, err := decodeActiveMatch(obj)
+		if err != nil {
+			return nil, errors.ErrUnmarshal
+		}
+		if obj.Key == storageKeyCurrentMatch {
+			legacy = m
+		} else {
+			activeMatch = m
+		}
 	}
-
-	var activeMatch ActiveMatch
-	if err := json.Unmarshal([]byte(objects[0].Value), &activeMatch); err != nil {
-		return nil, errors.ErrUnmarshal
+	if activeMatch == nil && legacy != nil {
+		if legacy.MatchID != matchID {
+			return nil, errors.ErrMatchIDMismatch
+		}
+		activeMatch = legacy
 	}
-	activeMatch.Version = objects[0].Version
-
-	if activeMatch.MatchID != matchID {
-		return nil, errors.ErrMatchIDMismatch
+	if activeMatch == nil {
+		return nil, errors.ErrNoActiveMatch
 	}
 
-	if time.Now().UnixMilli()-activeMatch.StartTime < minMatchDurationMs {
+	if activeMatch.elapsedMs() < minMatchDurationMs {
 		// Return the activeMatch alongside the error so the caller can apply semantic override.
 		// If the caller has round records proving meaningful play, it may proceed despite short duration.
-		return &activeMatch, errors.ErrMatchTooShort
+		return activeMatch, errors.ErrMatchTooShort
 	}
 
 	// Apply a mode-specific stale-session ceiling.
 	// Solo: generous cap (marathon sessions are valid). Multiplayer: tight cap (consensus enforces short matches).
-	maxDuration := int64(maxMatchDurationMs)
-	if activeMatch.OpponentID == "" {
-		maxDuration = int64(maxSoloMatchDurationMs)
-	}
-	if time.Now().UnixMilli()-activeMatch.StartTime > maxDuration {
+	if isActiveMatchStale(activeMatch) {
 		// Return activeMatch alongside error so caller can notify opponent before cleanup.
-		return &activeMatch, errors.ErrStaleMatchExpired
+		return activeMatch, errors.ErrStaleMatchExpired
 	}
 
-	return &activeMatch, nil
+	return activeMatch, nil
 }
 
 // Write-first single-resolution consensus.
@@ -478,19 +909,25 @@ func validateActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger ru
 //	ok          : Second submitter. Full rewards + deferred bonus to first submitter.
 //	forfeit_win : Opponent abandoned. Full rewards immediately.
 //	resolved    : Late arrival (opponent resolved). Participation-only.
-//	conflict    : Both claimed win. Both downgraded.
-func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, opponentID string, matchID string, claimedWin bool, score int, opponentForfeited bool) (string, error) {
+//	draw        : Second submitter, both claimed a draw. Draw-tier rewards.
+//	conflict    : Both claimed win, or only one claimed a draw. Both downgraded.
+//
+// shadowBanned is the caller's flag from their lock, recorded on their claim. The returned bool is
+// the opponent's recorded flag, so the second submitter can withhold the first's deferred rewards.
+func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, opponentID string, matchID string, claimedWin bool, claimedDraw bool, score int, opponentForfeited bool, shadowBanned bool) (string, bool, error) {
 	if opponentID == "" {
-		return "ok", nil // Solo — no consensus needed, caller handles isSolo reward reduction
+		return "ok", false, nil // Solo — no consensus needed, caller handles isSolo reward reduction
 	}
 
 	// Step 1: Write our claim FIRST (unconditional)
 	myRecord := MatchResultRecord{
 		UserID:      userID,
 		ClaimedWin:  claimedWin,
+		ClaimedDraw: claimedDraw,
 		Score:       score,
 		SubmittedAt: time.Now().UnixMilli(),
 		Resolved:    false,
+		ShadowBanned: shadowBanned,
 	}
 	myRecordBytes, _ := json.Marshal(myRecord)
 
@@ -503,7 +940,7 @@ func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger
 		PermissionWrite: 0,
 	}})
 	if err != nil {
-		return "", err
+		return "", false, errors.ErrCouldNotWriteStorage
 	}
 
 	// If opponent forfeited, bypass waiting for their claim and resolve unilaterally
@@ -519,7 +956,7 @@ func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger
 			PermissionRead:  0,
 			PermissionWrite: 0,
 		}})
-		return "forfeit_win", nil
+		return "forfeit_win", false, nil
 	}
 
 	// Step 2: Read opponent's claim AFTER writing ours
@@ -530,24 +967,30 @@ func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger
 	}})
 	if err != nil || len(opponentResults) == 0 {
 		// First submitter: opponent hasn't written yet
-		return "pending", nil
+		return "pending", false, nil
 	}
 
 	var opponentRecord MatchResultRecord
 	if err := json.Unmarshal([]byte(opponentResults[0].Value), &opponentRecord); err != nil {
-		return "pending", nil
+		return "pending", false, nil
 	}
 
 	// If opponent's record has Resolved=true, they were the second submitter and already resolved.
-	// Our deferred win bonus (if applicable) was already pushed via notify.SendReward.
+	// Our deferred win bonus (if applicable) was already granted and notified by them.
 	if opponentRecord.Resolved {
-		return "resolved", nil
+		return "resolved", false, nil
 	}
 
 	// Conflict: both claimed win simultaneously
 	if claimedWin && opponentRecord.ClaimedWin {
 		logger.Warn("CONFLICT: Match %s - both %s and %s claimed victory", matchID, userID, opponentID)
-		return "conflict", nil
+		return "conflict", false, nil
+	}
+
+	// Draw requires agreement; a draw against a win/loss claim is a disagreement.
+	if claimedDraw != opponentRecord.ClaimedDraw {
+		logger.Warn("CONFLICT: Match %s - draw claim mismatch between %s (draw=%v) and %s (draw=%v)", matchID, userID, claimedDraw, opponentID, opponentRecord.ClaimedDraw)
+		return "conflict", false, nil
 	}
 
 	// Mark local user record as resolved.
@@ -564,20 +1007,143 @@ func resolveMatchConsensus(ctx context.Context, nk runtime.NakamaModule, logger
 		PermissionWrite: 0,
 	}})
 
-	return "ok", nil
+	if claimedDraw {
+		return "draw", opponentRecord.ShadowBanned, nil
+	}
+	return "ok", opponentRecord.ShadowBanned, nil
 	// TODO(ATOM-D1): Cross-audit RoundRecords post-consensus.
 	// Validate playerA.round[N].PlayerWon == !playerB.round[N].PlayerWon.
 }
 
-func clearActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) {
+// ClearAbandonedActiveMatch removes the caller's active match locks on session end.
+// A lock is cleared if it has outlived the mode's max duration, or if neither player has
+// submitted a result yet. A match with a claim on file is mid-consensus and is left alone
+// so the reconnecting player can still submit. Returns true if any lock was cleared.
+func ClearAbandonedActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) (bool, error) {
+	held, err := listActiveMatches(ctx, nk, logger, userID)
+	if err != nil {
+		return false, err
+	}
+
+	var deletes []*runtime.StorageDelete
+	for _, activeMatch := range held {
+		if !isActiveMatchStale(activeMatch) {
+			submitted, err := hasSubmittedMatchResult(ctx, nk, userID, activeMatch)
+			if err != nil {
+				return false, err
+			}
+			if submitted {
+				continue
+			}
+		}
+		// Version-guarded so a lock rewritten concurrently on another session survives.
+		deletes = append(deletes, &runtime.StorageDelete{
+			Collection: storageCollectionActiveMatch,
+			Key:        activeMatch.Key,
+			UserID:     userID,
+			Version:    activeMatch.Version,
+		})
+	}
+	if len(deletes) == 0 {
+		return false, nil
+	}
+	if err := nk.StorageDelete(ctx, deletes); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasSubmittedMatchResult reports whether either participant has a consensus claim on file.
+func hasSubmittedMatchResult(ctx context.Context, nk runtime.NakamaModule, userID string, activeMatch *ActiveMatch) (bool, error) {
+	reads := []*runtime.StorageRead{{
+		Collection: storageCollectionResults,
+		Key:        activeMatch.MatchID + "_" + userID,
+		UserID:     userID,
+	}}
+	if activeMatch.OpponentID != "" {
+		reads = append(reads, &runtime.StorageRead{
+			Collection: storageCollectionResults,
+			Key:        activeMatch.MatchID + "_" + activeMatch.OpponentID,
+			UserID:     activeMatch.OpponentID,
+		})
+	}
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return false, err
+	}
+	return len(objects) > 0, nil
+}
+
+// isActiveMatchStale applies the mode-specific stale-session ceiling. Refereed matches end on
+// their own timeouts, so their locks get at least the longest series the referee allows.
+func isActiveMatchStale(activeMatch *ActiveMatch) bool {
+	maxDuration := int64(maxMatchDurationMs)
+	if activeMatch.OpponentID == "" {
+		maxDuration = int64(maxSoloMatchDurationMs)
+	}
+	if activeMatch.Authoritative && maxDuration < maxAuthMatchDurationMs {
+		maxDuration = maxAuthMatchDurationMs
+	}
+	return activeMatch.elapsedMs() > maxDuration
+}
+
+// elapsedMs is the server-measured time since the match started.
+func (m *ActiveMatch) elapsedMs() int64 {
+	return time.Now().UnixMilli() - m.StartTime
+}
+
+// activeMatchKey is the storage key of the lock for matchID.
+func activeMatchKey(matchID string) string {
+	return activeMatchKeyPrefix + matchID
+}
+
+// decodeActiveMatch unmarshals a lock, stamping the key and version it was read with.
+func decodeActiveMatch(obj *api.StorageObject) (*ActiveMatch, error) {
+	var activeMatch ActiveMatch
+	if err := json.Unmarshal([]byte(obj.Value), &activeMatch); err != nil {
+		return nil, err
+	}
+	activeMatch.Key = obj.Key
+	activeMatch.Version = obj.Version
+	return &activeMatch, nil
+}
+
+// listActiveMatches returns every lock the user holds, including a legacy "current" lock.
+// Unreadable locks are logged and skipped.
+func listActiveMatches(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) ([]*ActiveMatch, error) {
+	objects, err := listAllStorage(ctx, nk, logger, userID, storageCollectionActiveMatch)
+	if err != nil {
+		return nil, err
+	}
+	held := make([]*ActiveMatch, 0, len(objects))
+	for _, obj := range objects {
+		activeMatch, err := decodeActiveMatch(obj)
+		if err != nil {
+			logger.Warn("Skipping unreadable active match %s for user %s: %v", obj.Key, userID, err)
+			continue
+		}
+		held = append(held, activeMatch)
+	}
+	return held, nil
+}
+
+// clearActiveMatch deletes the lock for one match, leaving the user's other matches in place.
+func clearActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, activeMatch *ActiveMatch) {
+	if activeMatch == nil {
+		return
+	}
+	key := activeMatch.Key
+	if key == "" {
+		key = activeMatchKey(activeMatch.MatchID)
+	}
 	// Background context so client disconnect can't cancel the cleanup.
 	err := nk.StorageDelete(context.Background(), []*runtime.StorageDelete{{
 		Collection: storageCollectionActiveMatch,
-		Key:        storageKeyCurrentMatch,
+		Key:        key,
 		UserID:     userID,
 	}})
 	if err != nil {
-		logger.Error("Failed to clear active match for user %s: %v", userID, err)
+		logger.Error("Failed to clear active match %s for user %s: %v", activeMatch.MatchID, userID, err)
 	}
 }
 
@@ -585,7 +1151,7 @@ func clearActiveMatch(ctx context.Context, nk runtime.NakamaModule, logger runti
 // ExchangesLeft limits daily lootbox generation; 6 RoundTokens (half-units) exchange for 1 lootbox.
 // A single AccountGetId pre-read prevents wallet TOCTOU during reward generation.
 // Solo match XP is halved to prevent farming.
-func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req *MatchResultRequest, isSolo bool, activeMatch *ActiveMatch) (*notify.RewardPayload, error) {
+func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, req *MatchResultRequest, isSolo bool, activeMatch *ActiveMatch, outcome streakOutcome) (*notify.RewardPayload, error) {
 	cfg := GetEconomyConfig()
 	pending := NewPendingWrites()
 
@@ -594,40 +1160,78 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 	result.Progression = &notify.ProgressionDelta{}
 
 	// --- Daily Journey ---
+	// The XP boost state rides on the same read, and so does the player level when catch-up XP is on.
 	var dj DailyJourney
 	var djVersion string
-	djObjects, err := nk.StorageRead(ctx, []*runtime.StorageRead{
+	var xpBoost XpBoostState
+	var djObject *api.StorageObject
+	playerLevel := 0
+	playerKey := ProgressionKeyPlayer + "0"
+	djReads := []*runtime.StorageRead{
 		{
 			Collection: storageCollectionProgression,
 			Key:        ProgressionKeyDailyJourney,
 			UserID:     userID,
 		},
-	})
-	nowUTC := time.Now().UTC()
-	midnightUTC := time.Date(nowUTC.Year(), nowUTC.Month(), nowUTC.Day(), 0, 0, 0, 0, time.UTC)
-	if err == nil && len(djObjects) > 0 {
-		if err := json.Unmarshal([]byte(djObjects[0].Value), &dj); err == nil {
-			djVersion = djObjects[0].Version
+		xpBoostRead(userID),
+	}
+	if cfg.CatchUpXP.Enabled() {
+		djReads = append(djReads, &runtime.StorageRead{Collection: storageCollectionProgression, Key: playerKey, UserID: userID})
+	}
+	djObjects, err := nk.StorageRead(ctx, djReads)
+	for _, obj := range djObjects {
+		switch obj.Key {
+		case ProgressionKeyDailyJourney:
+			djObject = obj
+		case ProgressionKeyXpBoost:
+			xpBoost = parseXpBoost(obj)
+		case playerKey:
+			var prog ItemProgression
+			if json.Unmarshal([]byte(obj.Value), &prog) == nil {
+				playerLevel = prog.Level
+			}
 		}
-	} else {
-		dj = DailyJourney{
-			DailyMatches:       0,
-			DailyWarmupClaimed: false,
-			ResetUnix:          midnightUTC.Unix(),
+	}
+	nowUTC := time.Now().UTC()
+	midnightUTC := utcMidnight(nowUTC)
+	// A first-ever journey is created with "*", so two concurrent first matches can't both write it.
+	dj = DailyJourney{ExchangesLeft: DailyExchangeCap, ResetUnix: midnightUTC.Unix()}
+	djVersion = "*"
+	if err == nil && djObject != nil {
+		djVersion = djObject.Version
+		if err := json.Unmarshal([]byte(djObject.Value), &dj); err != nil {
+			logger.Warn("Failed to unmarshal daily journey for user %s, starting fresh: %v", userID, err)
+			dj = DailyJourney{ExchangesLeft: DailyExchangeCap, ResetUnix: midnightUTC.Unix()}
 		}
 	}
 
-	// Reset if reset_unix is before today's midnight
-	if time.Unix(dj.ResetUnix, 0).UTC().Before(midnightUTC) {
-		dj.DailyMatches = 0
-		dj.DailyWarmupClaimed = false
-		dj.ExchangesLeft = DailyExchangeCap
-		dj.RoundTokens = 0
-		dj.ResetUnix = midnightUTC.Unix()
-	}
+	// Reset at UTC midnight and count this match
+	incrementDailyMatchCount(&dj, nowUTC)
 
-	// Increment daily match count
-	dj.DailyMatches++
+	// Shadow-banned: the match still counts, but nothing is granted. The payload has the same
+	// shape as a real one, built from the unchanged journey, so the player can't tell.
+	if activeMatch != nil && activeMatch.ShadowBanned {
+		logger.Info("Match %s: rewards withheld for shadow-banned user %s", req.MatchID, userID)
+		if _, err := storageWriteWithRetry(ctx, nk, logger, []*runtime.StorageWrite{dailyJourneyWrite(userID, &dj, djVersion)}); err != nil {
+			logger.Warn("Failed to count withheld match %s for user %s: %v", req.MatchID, userID, err)
+		}
+		clearActiveMatch(ctx, nk, logger, userID, activeMatch)
+		result.RewardID = "match_" + req.MatchID
+		result.Progression.XpGranted = notify.IntPtr(0)
+		result.SetReasonArgInt(notify.ReasonArgXP, 0)
+		result.Meta = &notify.RewardMeta{
+			DailyMatches:  notify.IntPtr(dj.DailyMatches),
+			ExchangesLeft: notify.IntPtr(dj.ExchangesLeft),
+			RoundTokens:   notify.IntPtr(dj.RoundTokens),
+			TokensEarned:  notify.IntPtr(0),
+		}
+		result.Economy = &notify.EconomyState{
+			ExchangesLeft: notify.IntPtr(dj.ExchangesLeft),
+			RoundTokens:   notify.IntPtr(dj.RoundTokens),
+			TokensEarned:  notify.IntPtr(0),
+		}
+		return result, nil
+	}
 
 	// Check daily warmup completion
 	warmupGoal := cfg.DailyMatchesWarmupGoal
@@ -640,8 +1244,8 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 		if tier == "" {
 			tier = "standard"
 		}
-		if lootbox, lootboxWrite, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
-			pending.AddStorageWrite(lootboxWrite)
+		if lootbox, lootboxWrites, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
+			pending.AddStorageWrites(lootboxWrites...)
 			result.Lootboxes = append(result.Lootboxes, notify.LootboxGrant{
 				ID:     lootbox.ID,
 				Tier:   lootbox.Tier,
@@ -653,6 +1257,50 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 		}
 	}
 
+	// --- First win of the day ---
+	// Marker lives on the daily journey record, so it commits atomically with the rest of the match.
+	bonusTokens := 0
+	firstWin := req.Won && cfg.FirstWinBonus.Enabled() && firstWinBonusDue(&dj, nowUTC)
+	if firstWin {
+		dj.LastWinBonusDay = midnightUTC.Unix()
+		bonusTokens = cfg.FirstWinBonus.Tokens
+		if cfg.FirstWinBonus.Treats > 0 {
+			pending.AddWalletUpdate(userID, map[string]int64{"treats": int64(cfg.FirstWinBonus.Treats)})
+			pending.MergePayload(&notify.RewardPayload{Wallet: &notify.WalletDelta{Treats: cfg.FirstWinBonus.Treats}})
+		}
+		if tier := cfg.FirstWinBonus.LootboxTier; tier != "" {
+			if lootbox, lootboxWrites, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
+				pending.AddStorageWrites(lootboxWrites...)
+				result.Lootboxes = append(result.Lootboxes, notify.LootboxGrant{
+					ID:     lootbox.ID,
+					Tier:   lootbox.Tier,
+					Source: "first_win",
+				})
+			} else {
+				logger.Error("[DailyJourney] Failed to prepare first-win lootbox for user %s: %v", userID, lboxErr)
+			}
+		}
+		logger.Info("[DailyJourney] First win of the day bonus for user %s", userID)
+	}
+
+	// --- Win streak / loss protection (resolved outcomes only) ---
+	var winStreak *int
+	currentStreak := 0 // Post-match streak; stays 0 when the outcome is unresolved
+	consolationTokens := 0
+	if streak, streakPending, streakErr := prepareStreakUpdate(ctx, nk, logger, userID, req.MatchID, outcome); streakErr != nil {
+		logger.Warn("Failed to prepare win streak for user %s: %v", userID, streakErr)
+	} else if streakPending != nil {
+		pending.Merge(streakPending)
+		if streakPending.Payload != nil {
+			result.Lootboxes = append(result.Lootboxes, streakPending.Payload.Lootboxes...)
+		}
+		winStreak = notify.IntPtr(streak.Current)
+		currentStreak = streak.Current
+		if streak.consoled {
+			consolationTokens = cfg.LossProtection.Tokens
+		}
+	}
+
 	// Note: Serialization and AddStorageWrite for dj is moved to the end of the token exchange loop.
 
 	// --- Pre-read token state ---
@@ -663,6 +1311,11 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 	xpAmount := cfg.LossXP
 	if req.Won {
 		xpAmount = cfg.WinXP
+	} else if req.Draw {
+		xpAmount = cfg.DrawXP
+		if xpAmount <= 0 {
+			xpAmount = (cfg.WinXP + cfg.LossXP) / 2
+		}
 	}
 	if isSolo {
 		xpAmount = xpAmount / 2
@@ -670,15 +1323,29 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 			xpAmount = 1
 		}
 	}
+	xpAmount = scaleRematchReward(xpAmount, activeMatch)
 	result.Progression.XpGranted = notify.IntPtr(xpAmount)
+	result.SetReasonArgInt(notify.ReasonArgXP, xpAmount)
 
-	playerLevelUp, xpPending, err := preparePlayerXP(ctx, nk, logger, userID, xpAmount, dj.DailyMatches)
+	// Signup time is only needed when the level threshold alone doesn't already qualify.
+	var signup time.Time
+	if cfg.CatchUpXP.needsSignup(playerLevel) {
+		if account, err := nk.AccountGetId(ctx, userID); err == nil && account.User != nil && account.User.CreateTime != nil {
+			signup = account.User.CreateTime.AsTime()
+		}
+	}
+	catchUpMultiplier := cfg.CatchUpXP.multiplier(playerLevel, signup, nowUTC)
+
+	xpGranted, playerLevelUp, xpPending, err := preparePlayerXP(ctx, nk, logger, userID, xpAmount, dj.DailyMatches, catchUpMultiplier, xpBoost)
 	if err != nil {
 		logger.Warn("Failed to prepare player XP: %v", err)
 	} else {
 		pending.Merge(xpPending)
+		result.Progression.XpGranted = notify.IntPtr(xpGranted)
+		result.SetReasonArgInt(notify.ReasonArgXP, xpGranted)
 		if playerLevelUp > 0 {
 			result.Progression.NewPlayerLevel = notify.IntPtr(playerLevelUp)
+			result.SetReasonArgInt(notify.ReasonArgLevel, playerLevelUp)
 		}
 	}
 
@@ -696,7 +1363,7 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 		logger.Info("Match %s: %d tokens pre-banked, skipping delta (audit_confirmed)", req.MatchID, tokensBanked)
 	} else {
 		// Fallback: no round records — network failure, legacy client, or pre-Phase2 solo.
-		tokensEarned = computeTokensEarned(req, isSolo, cfg)
+		tokensEarned = scaleRematchReward(computeTokensEarned(req, isSolo, cfg), activeMatch)
 		if preExchanges <= 0 {
 			tokensEarned = 0
 		}
@@ -705,6 +1372,8 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 			logger.Info("Match %s: no round records, granting %d tokens (audit_unconfirmed)", req.MatchID, tokensEarned)
 		}
 	}
+	// Bonus and consolation tokens ride on top of round tokens, still subject to the exchange cap below.
+	postTokens += int64(bonusTokens + consolationTokens)
 
 	// Token -> Lootbox Exchange Loop
 	thresh := int64(cfg.TokenExchangeThresh)
@@ -719,13 +1388,10 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 		
 		dj.ExchangesLeft--
 
-		tier := GetLootboxConfig().MatchLossTier
-		if req.Won {
-			tier = GetLootboxConfig().MatchWinTier
-		}
+		tier := GetLootboxConfig().tierFor(req.Won, currentStreak)
 
-		if lootbox, lootboxWrite, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
-			pending.AddStorageWrite(lootboxWrite)
+		if lootbox, lootboxWrites, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
+			pending.AddStorageWrites(lootboxWrites...)
 			result.Lootboxes = append(result.Lootboxes, notify.LootboxGrant{
 				ID:     lootbox.ID,
 				Tier:   lootbox.Tier,
@@ -734,11 +1400,39 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 		}
 	}
 
+	// --- Achievements ---
+	achievementIncrements := map[string]int{AchievementStatMatchesPlayed: 1}
+	if req.Won {
+		achievementIncrements[AchievementStatMatchesWon] = 1
+	}
+	if achPending, achErr := PrepareAchievementProgress(ctx, nk, logger, userID, achievementIncrements); achErr != nil {
+		logger.Warn("Failed to prepare achievement progress for user %s: %v", userID, achErr)
+	} else if achPending != nil {
+		pending.Merge(achPending)
+	}
+
+	// --- Quests ---
+	questEvents := []QuestEvent{{Stat: QuestStatMatchesPlayed, Amount: 1, ClassID: req.EquippedClassID}}
+	if req.Won {
+		questEvents = append(questEvents, QuestEvent{Stat: QuestStatMatchesWon, Amount: 1, ClassID: req.EquippedClassID})
+	}
+	if questPending, questErr := PrepareQuestProgress(ctx, nk, logger, userID, questEvents); questErr != nil {
+		logger.Warn("Failed to prepare quest progress for user %s: %v", userID, questErr)
+	} else if questPending != nil {
+		pending.Merge(questPending)
+	}
+
 	// Serialize and prepare write for daily journey (includes match counts, warmup, tokens, and updated ExchangesLeft)
+	// With no exchanges left, tokens above the threshold are dropped rather than banked for tomorrow.
+	// Meta below reads dj.RoundTokens, so the client is shown the balance actually written.
+	tokensDropped := int64(0)
 	if finalExchanges <= 0 && finalTokens > thresh {
-		finalTokens = thresh
+		tokensDropped = finalTokens - thresh
+	}
+	dj.RoundTokens = int(finalTokens - tokensDropped)
+	if tokensDropped > 0 {
+		logger.Info("Match %s: no exchanges left, dropped %d tokens above threshold", req.MatchID, tokensDropped)
 	}
-	dj.RoundTokens = int(finalTokens)
 
 	djBytes, _ := json.Marshal(dj)
 	pending.AddStorageWrite(&runtime.StorageWrite{
@@ -752,13 +1446,38 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 	})
 
 	// --- Phase 2: Atomic commit (XP + tokens + exchange + lootbox) ---
-	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+	// Keyed by match so a duplicate submission that slips past the result cache can't double-apply.
+	result.RewardID = "match_" + req.MatchID
+	if pending.Payload == nil {
+		pending.Payload = notify.NewRewardPayload("match")
+	}
+	pending.Payload.RewardID = result.RewardID
+	pending.CapDailyEarnings()
+	applied, err := CommitRewardOnce(ctx, nk, logger, userID, pending)
+	if err != nil {
 		logger.Error("Match result commit failed: %v", err)
 		return nil, errors.ErrMatchRewardCommit
 	}
+	// StorageDelete cannot go in MultiUpdate; runs after commit. An already-applied reward
+	// still releases the lock, or the player stays blocked from queueing.
+	clearActiveMatch(ctx, nk, logger, userID, activeMatch)
+	if !applied {
+		return nil, errors.ErrRewardAlreadyClaimed
+	}
 
-	// StorageDelete cannot go in MultiUpdate; runs after commit.
-	clearActiveMatch(ctx, nk, logger, userID)
+	// Tournament scores only count consensus-resolved wins; written after the reward commit.
+	if outcome == streakWin {
+		submitTournamentWin(ctx, nk, logger, userID)
+	}
+
+	// Surface currency granted by level-ups and achievements folded into this commit.
+	if pending.Payload != nil {
+		if pending.Payload.Wallet != nil {
+			result.Wallet = pending.Payload.Wallet
+			result.SetWalletReasonArgs()
+		}
+		result.Achievements = pending.Payload.Achievements
+	}
 
 	// --- Metadata: derived from final state — no second AccountGetId ---
 	// RoundTokens always reflects the real wallet balance. The client detects
@@ -768,65 +1487,202 @@ func processMatchRewards(ctx context.Context, nk runtime.NakamaModule, logger ru
 	if tokensBanked > 0 {
 		effectiveEarned = tokensBanked
 	}
+	effectiveEarned += bonusTokens + consolationTokens
 	result.Meta = &notify.RewardMeta{
 		DailyMatches:    notify.IntPtr(dj.DailyMatches),
 		ExchangesLeft:   notify.IntPtr(int(finalExchanges)),
-		RoundTokens:     notify.IntPtr(int(finalTokens)), // always real balance
+		RoundTokens:     notify.IntPtr(dj.RoundTokens), // always the committed balance
 		TokensEarned:    notify.IntPtr(effectiveEarned),
 		ExchangesMade:   exchangesMade,
 		CarryOverTokens: nil,
+		WinStreak:       winStreak,
+	}
+	if pending.Payload != nil && pending.Payload.Meta != nil {
+		result.Meta.DailyCapReached = pending.Payload.Meta.DailyCapReached
+	}
+	if left := xpBoost.remaining(nowUTC); left > 0 {
+		result.Meta.XpBoostRemainingSec = notify.Int64Ptr(int64(left.Seconds()))
 	}
 	result.Economy = &notify.EconomyState{
 		ExchangesLeft:  notify.IntPtr(int(finalExchanges)),
-		RoundTokens:    notify.IntPtr(int(finalTokens)),
+		RoundTokens:    notify.IntPtr(dj.RoundTokens),
 		TokensEarned:   notify.IntPtr(effectiveEarned),
 		ExchangesMade:  exchangesMade,
+		FirstWinBonus:  firstWin,
 	}
 	// If an exchange occurred, expose carry-over so the client can snap to real balance
 	// after the exchange animation. The client uses ExchangesMade > 0 to detect
 	// the exchange event and CarryOverTokens for the post-snap value.
 	if exchangesMade > 0 {
-		result.Meta.CarryOverTokens = notify.IntPtr(int(finalTokens))
-		result.Economy.CarryOverTokens = notify.IntPtr(int(finalTokens))
+		result.Meta.CarryOverTokens = notify.IntPtr(dj.RoundTokens)
+		result.Economy.CarryOverTokens = notify.IntPtr(dj.RoundTokens)
 	}
 
 	return result, nil
 }
 
-// processDeferredWinBonus is a no-op cyrrentThe caller still invokes this function on the "ok" consensus path; returning
-// nil, nil causes the notification send to be skipped cleanly.
-func processDeferredWinBonus(_ context.Context, _ runtime.NakamaModule, _ runtime.Logger, _ string, _ bool) (*notify.RewardPayload, error) {
-	return nil, nil
+// processDeferredWinBonus grants the first submitter's confirmed win bonus (economy.deferred_win_bonus)
+// once the second submitter resolves the match, plus the first-win-of-the-day bonus if it is still due:
+// the first submitter's outcome was unresolved at their own commit, so it could not be paid then.
+// Gems and treats go to the wallet; tokens are banked on the daily journey and exchanged at the
+// player's next match. Applied once per match via CommitRewardOnce.
+// Returns nil, nil when the player lost or nothing is configured.
+func processDeferredWinBonus(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, matchID string, won bool) (*notify.RewardPayload, error) {
+	cfg := GetEconomyConfig()
+	bonus := cfg.DeferredWinBonus
+	if !won || (bonus.Gems <= 0 && bonus.Tokens <= 0 && !cfg.FirstWinBonus.Enabled()) {
+		return nil, nil
+	}
+	pending := NewPendingWrites()
+	payload := notify.NewRewardPayload("match")
+	payload.RewardID = "win_bonus_" + matchID
+	payload.ReasonKey = "reward.match.win_bonus"
+
+	if bonus.Gems > 0 {
+		pending.AddWalletUpdate(userID, map[string]int64{"gems": int64(bonus.Gems)})
+		payload.Wallet = &notify.WalletDelta{Gems: bonus.Gems}
+	}
+
+	now := time.Now().UTC()
+	midnightUTC := utcMidnight(now)
+	dj, djVersion, err := readDailyJourneyForBanking(ctx, nk, logger, userID, midnightUTC)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := bonus.Tokens
+	firstWin := cfg.FirstWinBonus.Enabled() && firstWinBonusDue(dj, now)
+	if firstWin {
+		dj.LastWinBonusDay = midnightUTC.Unix()
+		tokens += cfg.FirstWinBonus.Tokens
+		if cfg.FirstWinBonus.Treats > 0 {
+			pending.AddWalletUpdate(userID, map[string]int64{"treats": int64(cfg.FirstWinBonus.Treats)})
+			payload.Merge(&notify.RewardPayload{Wallet: &notify.WalletDelta{Treats: cfg.FirstWinBonus.Treats}})
+		}
+		if tier := cfg.FirstWinBonus.LootboxTier; tier != "" {
+			if lootbox, lootboxWrites, lboxErr := PrepareCreateLootbox(userID, tier); lboxErr == nil {
+				pending.AddStorageWrites(lootboxWrites...)
+				payload.Lootboxes = append(payload.Lootboxes, notify.LootboxGrant{
+					ID:     lootbox.ID,
+					Tier:   lootbox.Tier,
+					Source: "first_win",
+				})
+			} else {
+				logger.Error("[DailyJourney] Failed to prepare first-win lootbox for user %s: %v", userID, lboxErr)
+			}
+		}
+		logger.Info("[DailyJourney] First win of the day bonus for user %s (deferred, match %s)", userID, matchID)
+	}
+	payload.SetWalletReasonArgs()
+
+	if tokens > 0 || firstWin {
+		dj.RoundTokens += tokens
+		pending.AddStorageWrite(dailyJourneyWrite(userID, dj, djVersion))
+		payload.Economy = &notify.EconomyState{
+			RoundTokens:   notify.IntPtr(dj.RoundTokens),
+			TokensEarned:  notify.IntPtr(tokens),
+			FirstWinBonus: firstWin,
+		}
+	}
+
+	if !payload.HasContent() {
+		return nil, nil
+	}
+	pending.Payload = payload
+	pending.CapDailyEarnings()
+	applied, err := CommitRewardOnce(ctx, nk, logger, userID, pending)
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, nil
+	}
+	return payload, nil
+}
+
+// readDailyJourneyForBanking reads the daily journey, reset for midnightUTC, for a commit outside
+// the player's own match that banks tokens on it. The version is "*" when none exists yet.
+func readDailyJourneyForBanking(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, midnightUTC time.Time) (*DailyJourney, string, error) {
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyDailyJourney,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	dj := &DailyJourney{ExchangesLeft: DailyExchangeCap, ResetUnix: midnightUTC.Unix()}
+	if len(objects) == 0 {
+		return dj, "*", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), dj); err != nil {
+		return nil, "", err
+	}
+	resetDailyJourneyIfStale(dj, midnightUTC)
+	return dj, objects[0].Version, nil
+}
+
+// dailyJourneyWrite is the version-guarded write for a daily journey read by readDailyJourneyForBanking.
+func dailyJourneyWrite(userID string, dj *DailyJourney, version string) *runtime.StorageWrite {
+	djBytes, _ := json.Marshal(dj)
+	return &runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyDailyJourney,
+		UserID:          userID,
+		Value:           string(djBytes),
+		Version:         version,
+		PermissionRead:  2,
+		PermissionWrite: 0,
+	}
+}
+
+// resetDailyJourneyIfStale zeroes the per-day counters when dj was last reset before midnightUTC,
+// reporting whether it did.
+func resetDailyJourneyIfStale(dj *DailyJourney, midnightUTC time.Time) bool {
+	if !time.Unix(dj.ResetUnix, 0).UTC().Before(midnightUTC) {
+		return false
+	}
+	dj.DailyMatches = 0
+	dj.DailyWarmupClaimed = false
+	dj.ExchangesLeft = DailyExchangeCap
+	dj.RoundTokens = 0
+	dj.ResetUnix = midnightUTC.Unix()
+	return true
 }
 
-// preparePlayerXP applies diminishing returns and returns deferred progression writes.
+// incrementDailyMatchCount rolls dj over at UTC midnight, counts one match and returns the new count.
+// dj is committed with its read version in the match MultiUpdate, so a concurrent submit fails the
+// whole commit instead of double-counting or losing a match.
+func incrementDailyMatchCount(dj *DailyJourney, now time.Time) int {
+	resetDailyJourneyIfStale(dj, utcMidnight(now))
+	dj.DailyMatches++
+	return dj.DailyMatches
+}
+
+// preparePlayerXP applies diminishing returns, the catch-up multiplier and any XP boost, and
+// returns the XP actually granted with the deferred progression writes.
+// xpBoost is read by the caller alongside the daily journey to avoid a separate read.
 // Note: PrepareExperience operates on pets and classes, whereas this handles player level directly.
-func preparePlayerXP(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, xpAmount int, matchesToday int) (int, *PendingWrites, error) {
+func preparePlayerXP(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, xpAmount int, matchesToday int, catchUpMultiplier float64, xpBoost XpBoostState) (int, int, *PendingWrites, error) {
 	const treeName = "player_level"
 	const playerItemID = uint32(0)
 
 	pending := NewPendingWrites()
 
-	// Diminishing XP curve: 100%, 80%, 60%, 40%, 25%...
-	xpMultiplier := 1.0
-	switch {
-	case matchesToday <= 1:
-		xpMultiplier = 1.0
-	case matchesToday == 2:
-		xpMultiplier = 0.8
-	case matchesToday == 3:
-		xpMultiplier = 0.6
-	case matchesToday == 4:
-		xpMultiplier = 0.4
-	default:
-		xpMultiplier = 0.25
-	}
+	// Diminishing XP curve from economy.daily_xp_curve
+	xpMultiplier := GetEconomyConfig().dailyXPMultiplier(matchesToday)
 
 	adjustedXP := int(float64(xpAmount) * xpMultiplier)
 	if adjustedXP < 1 {
 		adjustedXP = 1
 	}
 
+	// Catch-up and the XP boost apply on top of the curve.
+	if catchUpMultiplier > 1 {
+		adjustedXP = int(float64(adjustedXP) * catchUpMultiplier)
+	}
+	adjustedXP = applyXpBoost(adjustedXP, xpBoost, time.Now())
+
 	var resultLevel int
 	var oldLevel int
 
@@ -863,7 +1719,7 @@ func preparePlayerXP(ctx context.Context, nk runtime.NakamaModule, logger runtim
 	})
 
 	if err != nil {
-		return 0, nil, err
+		return 0, 0, nil, err
 	}
 
 	if progWrite != nil {
@@ -891,7 +1747,7 @@ func preparePlayerXP(ctx context.Context, nk runtime.NakamaModule, logger runtim
 		}
 	}
 
-	return resultLevel, pending, nil
+	return adjustedXP, resultLevel, pending, nil
 }
 
 
@@ -901,6 +1757,8 @@ type EconomyConfig struct {
 	LossXP                        int    `json:"loss_xp"`
 	TokensPerRoundWin             int    `json:"tokens_per_round_win"`  // Half-units; default 2 = 1.0 token
 	TokensPerRoundLoss            int    `json:"tokens_per_round_loss"` // Half-units; default 1 = 0.5 token
+	TokensPerRoundDraw            int    `json:"tokens_per_round_draw"` // Half-units; replaces the loss rate in a drawn match; 0 = loss rate
+	DrawXP                        int    `json:"draw_xp"`               // 0 = midpoint of win_xp and loss_xp
 	TokensPerSoloRound            int    `json:"tokens_per_solo_round"` // Half-units; default 1 = 0.5 token
 	TokenExchangeThresh           int    `json:"token_exchange_thresh"` // Default 6 = 3.0 tokens trigger
 	TokenRoundCap                 int    `json:"token_round_cap"`       // Only rounds 1..N earn tokens; default 3
@@ -908,17 +1766,257 @@ type EconomyConfig struct {
 	TokenExchangesPerDay          int    `json:"token_exchanges_per_day"`
 	DailyMatchesWarmupGoal        int    `json:"daily_matches_warmup_goal"`
 	DailyMatchesWarmupLootboxTier string `json:"daily_matches_warmup_lootbox_tier"`
+
+	// StrictRoundValidation rejects implausible round histories instead of only logging them.
+	// Off by default so legacy clients keep working.
+	StrictRoundValidation bool  `json:"strict_round_validation"`
+	MinRoundDurationMs    int64 `json:"min_round_duration_ms"` // floor per round under strict mode; default 5000
+	RoundScoreTolerance   int   `json:"round_score_tolerance"` // allowed |FinalScore - sum(round scores)|
+
+	// ScoreBounds rejects match and round scores outside a plausible range. Applies whether or
+	// not strict round validation is on, so the defaults are deliberately wide.
+	ScoreBounds ScoreBoundsConfig `json:"score_bounds"`
+
+	// FirstWinBonus is paid on the first won match of each UTC day.
+	FirstWinBonus FirstWinBonusConfig `json:"first_win_bonus"`
+
+	// StreakMilestones pay out when a resolved 1v1 win streak reaches each Wins count.
+	StreakMilestones []StreakMilestone `json:"streak_milestones"`
+
+	// DeferredWinBonus is paid to a first submitter once the opponent's submission confirms their win.
+	// All zero (the default) disables it.
+	DeferredWinBonus DeferredWinBonusConfig `json:"deferred_win_bonus"`
+
+	// LossProtection consoles a player after consecutive resolved 1v1 losses. Off when losses is 0.
+	LossProtection LossProtectionConfig `json:"loss_protection"`
+
+	// Rematch flags repeated 1v1 pairings against the same opponent. Off when limit is 0.
+	Rematch RematchPolicyConfig `json:"rematch"`
+
+	// DailyEarnCap bounds currency earned per UTC day across match rewards, lootboxes and quests.
+	DailyEarnCap DailyEarnCapConfig `json:"daily_earn_cap"`
+
+	// PrestigeReward is granted each time a max-level pet or class is prestiged.
+	PrestigeReward PrestigeRewardConfig `json:"prestige_reward"`
+
+	// CatchUpXP boosts match XP for new or low-level players. Off unless a threshold is set.
+	CatchUpXP CatchUpXPConfig `json:"catch_up_xp"`
+
+	// XpBoost is the multiplier and duration of the xp_boost consumable.
+	XpBoost XpBoostConfig `json:"xp_boost"`
+
+	// Onboarding lists the tutorial steps in order, each with an optional one-time reward.
+	Onboarding []OnboardingStepConfig `json:"onboarding"`
+
+	// ItemCatchUpXP boosts pet and class XP for items lagging behind the player's best of that type.
+	ItemCatchUpXP ItemCatchUpXPConfig `json:"item_catch_up_xp"`
+
+	// Referral rewards both players when a new account redeems a referral code.
+	Referral ReferralConfig `json:"referral"`
+
+	// DailyXPCurve is the player XP multiplier for the Nth match of the UTC day; the last entry
+	// applies to every further match. Must be non-empty.
+	DailyXPCurve []float64 `json:"daily_xp_curve"`
+
+	// RewardBundleWindowMs merges reward notifications for a user arriving within this window
+	// into one. 0 sends each immediately.
+	RewardBundleWindowMs int `json:"reward_bundle_window_ms"`
+
+	// MaxActiveMatches caps how many unfinished matches a player may hold at once.
+	// Stale locks don't count. Values below 1 are treated as 1.
+	MaxActiveMatches int `json:"max_active_matches"`
+
+	// InitialWallet is credited once when an account is created. Omitted uses defaultInitialWallet.
+	InitialWallet map[string]int64 `json:"initial_wallet"`
+}
+
+// defaultInitialWallet is the starting wallet when economy.initial_wallet is not configured.
+var defaultInitialWallet = map[string]int64{
+	"gold":   500,
+	"gems":   100,
+	"treats": 1,
+}
+
+// maxActiveMatches returns the concurrent match cap, never less than one.
+func (c *EconomyConfig) maxActiveMatches() int {
+	return max(c.MaxActiveMatches, 1)
+}
+
+// initialWallet returns a copy of the starting wallet changeset.
+func (c *EconomyConfig) initialWallet() map[string]int64 {
+	source := c.InitialWallet
+	if source == nil {
+		source = defaultInitialWallet
+	}
+	wallet := make(map[string]int64, len(source))
+	for currency, amount := range source {
+		if amount != 0 {
+			wallet[currency] = amount
+		}
+	}
+	return wallet
+}
+
+// dailyXPMultiplier returns the daily_xp_curve entry for the matchesToday-th match (1-based).
+func (c *EconomyConfig) dailyXPMultiplier(matchesToday int) float64 {
+	if len(c.DailyXPCurve) == 0 {
+		return 1.0
+	}
+	i := matchesToday - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(c.DailyXPCurve) {
+		i = len(c.DailyXPCurve) - 1
+	}
+	return c.DailyXPCurve[i]
+}
+
+// ItemCatchUpXPConfig is economy.item_catch_up_xp in items.json. An item more than LevelGap levels
+// below the user's highest item of the same type earns Multiplier times the XP. LevelGap 0 disables it.
+type ItemCatchUpXPConfig struct {
+	LevelGap   int     `json:"level_gap"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// Enabled reports whether a gap threshold is configured with a real boost.
+func (c ItemCatchUpXPConfig) Enabled() bool {
+	return c.LevelGap > 0 && c.Multiplier > 1
+}
+
+// needsSignup reports whether multiplier depends on the signup time for a player at level.
+func (c CatchUpXPConfig) needsSignup(level int) bool {
+	return c.Enabled() && c.WithinDays > 0 && !(c.BelowLevel > 0 && level < c.BelowLevel)
+}
+
+// multiplier returns the catch-up factor for an item at level against the baseline level.
+func (c ItemCatchUpXPConfig) multiplier(level int, baseline int) float64 {
+	if !c.Enabled() || baseline-level <= c.LevelGap {
+		return 1
+	}
+	return c.Multiplier
+}
+
+// CatchUpXPConfig is economy.catch_up_xp in items.json. A player qualifies while below BelowLevel
+// or within WithinDays of signup; either threshold at 0 is ignored.
+type CatchUpXPConfig struct {
+	BelowLevel int     `json:"below_level"`
+	WithinDays int     `json:"within_days"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+// Enabled reports whether any catch-up threshold is configured with a real boost.
+func (c CatchUpXPConfig) Enabled() bool {
+	return c.Multiplier > 1 && (c.BelowLevel > 0 || c.WithinDays > 0)
+}
+
+// multiplier returns the catch-up factor for a player at level who signed up at signup (zero if unknown).
+func (c CatchUpXPConfig) multiplier(level int, signup time.Time, now time.Time) float64 {
+	if !c.Enabled() {
+		return 1
+	}
+	if c.BelowLevel > 0 && level < c.BelowLevel {
+		return c.Multiplier
+	}
+	if c.WithinDays > 0 && !signup.IsZero() && now.Sub(signup) < time.Duration(c.WithinDays)*24*time.Hour {
+		return c.Multiplier
+	}
+	return 1
+}
+
+// DeferredWinBonusConfig is economy.deferred_win_bonus in items.json.
+type DeferredWinBonusConfig struct {
+	Gems   int `json:"gems"`
+	Tokens int `json:"tokens"` // Half-units
+}
+
+// ScoreBoundsConfig: a zero max falls back to the wide default; mins default to 0.
+type ScoreBoundsConfig struct {
+	MinMatch int `json:"min_match"`
+	MaxMatch int `json:"max_match"`
+	MinRound int `json:"min_round"`
+	MaxRound int `json:"max_round"`
+}
+
+const (
+	defaultMaxMatchScore = 10000000
+	defaultMaxRoundScore = 1000000
+)
+
+// validate checks the submitted match scores and every round score against ScoreBounds.
+func (b ScoreBoundsConfig) validate(req *MatchResultRequest) error {
+	for _, score := range []int{req.FinalScore, req.OpponentScore} {
+		if err := b.validateMatch(score); err != nil {
+			return err
+		}
+	}
+	for _, r := range req.Rounds {
+		if err := b.validateRound(r.RoundNumber, r.Score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxMatch returns the configured whole-match ceiling, or the wide default.
+func (b ScoreBoundsConfig) maxMatch() int {
+	if b.MaxMatch <= 0 {
+		return defaultMaxMatchScore
+	}
+	return b.MaxMatch
+}
+
+// validateMatch checks a whole-match score against ScoreBounds.
+func (b ScoreBoundsConfig) validateMatch(score int) error {
+	maxMatch := b.maxMatch()
+	if score < b.MinMatch || score > maxMatch {
+		return fmt.Errorf("match score %d outside [%d, %d]", score, b.MinMatch, maxMatch)
+	}
+	return nil
+}
+
+// validateRound checks one round's score against ScoreBounds.
+func (b ScoreBoundsConfig) validateRound(round, score int) error {
+	maxRound := b.MaxRound
+	if maxRound <= 0 {
+		maxRound = defaultMaxRoundScore
+	}
+	if score < b.MinRound || score > maxRound {
+		return fmt.Errorf("round %d score %d outside [%d, %d]", round, score, b.MinRound, maxRound)
+	}
+	return nil
+}
+
+// FirstWinBonusConfig: all zero disables the bonus.
+type FirstWinBonusConfig struct {
+	Tokens      int    `json:"tokens"` // Half-units, like the round token rates
+	Treats      int    `json:"treats"`
+	LootboxTier string `json:"lootbox_tier"`
+}
+
+func (b FirstWinBonusConfig) Enabled() bool {
+	return b.Tokens > 0 || b.Treats > 0 || b.LootboxTier != ""
+}
+
+// firstWinBonusDue reports whether the first-win bonus hasn't been paid yet for now's UTC day.
+func firstWinBonusDue(dj *DailyJourney, now time.Time) bool {
+	return dj.LastWinBonusDay < utcMidnight(now).Unix()
 }
 
 var economyConfig *EconomyConfig
 
 func GetEconomyConfig() *EconomyConfig {
-	if economyConfig == nil {
-		economyConfig = &EconomyConfig{
+	gameDataMu.RLock()
+	cfg := economyConfig
+	gameDataMu.RUnlock()
+	if cfg == nil {
+		return &EconomyConfig{
 			WinXP:                         100,
 			LossXP:                        25,
 			TokensPerRoundWin:             2, // 1.0 token
 			TokensPerRoundLoss:            1, // 0.5 token
+			TokensPerRoundDraw:            1, // 0.5 token
+			DrawXP:                        60,
 			TokensPerSoloRound:            1, // 0.5 token per completed round
 			TokenExchangeThresh:           6, // 3.0 tokens
 			TokenRoundCap:                 3, // rounds 4+ earn nothing
@@ -928,7 +2026,7 @@ func GetEconomyConfig() *EconomyConfig {
 			DailyMatchesWarmupLootboxTier: "standard",
 		}
 	}
-	return economyConfig
+	return cfg
 }
 
 // maxRoundsPerMatch is a hard server-side ceiling on round counts.
@@ -950,6 +2048,12 @@ const maxRoundsPerMatch = 99
 func computeTokensEarned(req *MatchResultRequest, isSolo bool, cfg *EconomyConfig) int {
 	var earned int
 
+	// A drawn match pays non-won rounds at the draw rate, sitting between win and loss.
+	lossRate := cfg.TokensPerRoundLoss
+	if req.Draw && cfg.TokensPerRoundDraw > 0 {
+		lossRate = cfg.TokensPerRoundDraw
+	}
+
 	if len(req.Rounds) > 0 {
 		// Preferred path: iterate round history, honour cap by RoundNumber.
 		for _, r := range req.Rounds {
@@ -964,7 +2068,7 @@ func computeTokensEarned(req *MatchResultRequest, isSolo bool, cfg *EconomyConfi
 			} else if r.PlayerWon {
 				earned += cfg.TokensPerRoundWin
 			} else {
-				earned += cfg.TokensPerRoundLoss
+				earned += lossRate
 			}
 		}
 	} else {
@@ -988,7 +2092,7 @@ func computeTokensEarned(req *MatchResultRequest, isSolo bool, cfg *EconomyConfi
 		if isSolo {
 			earned = won * cfg.TokensPerSoloRound
 		} else {
-			earned = won*cfg.TokensPerRoundWin + lost*cfg.TokensPerRoundLoss
+			earned = won*cfg.TokensPerRoundWin + lost*lossRate
 		}
 	}
 
@@ -1006,21 +2110,48 @@ func computeTokensEarned(req *MatchResultRequest, isSolo bool, cfg *EconomyConfi
 // validateRounds checks round history plausibility and self-heals count mismatches.
 // Also performs a cross-stream audit: compares the client's self-report against server
 // RoundRecord objects written by report_round_result. Discrepancies are warn-only —
-// no rewards are withheld in this pass.
-func validateRounds(ctx context.Context, nk runtime.NakamaModule, req *MatchResultRequest, userID string, logger runtime.Logger, activeMatch *ActiveMatch) {
+// no rewards are withheld in this pass — unless EconomyConfig.StrictRoundValidation is set,
+// in which case a short round or a FinalScore that disagrees with the per-round scores
+// returns an error.
+func validateRounds(ctx context.Context, nk runtime.NakamaModule, req *MatchResultRequest, userID string, logger runtime.Logger, activeMatch *ActiveMatch) error {
 	if len(req.Rounds) == 0 {
-		return // Legacy client or solo fallback — skip silently
+		return nil // Legacy client or solo fallback — skip silently
+	}
+	cfg := GetEconomyConfig()
+	minRoundMs := cfg.MinRoundDurationMs
+	if minRoundMs <= 0 {
+		minRoundMs = 5000
 	}
+
 	derivedWon, derivedLost := 0, 0
+	roundScoreSum := 0
+	hasRoundScores := false
 	for _, r := range req.Rounds {
 		if r.PlayerWon {
 			derivedWon++
 		} else {
 			derivedLost++
 		}
-		if r.DurationMs < 5000 {
+		if r.Score != 0 {
+			hasRoundScores = true
+		}
+		roundScoreSum += r.Score
+		if r.DurationMs < minRoundMs {
 			logger.Warn("[match_result] Suspiciously short round %d: %dms (match %s)",
 				r.RoundNumber, r.DurationMs, req.MatchID)
+			if cfg.StrictRoundValidation {
+				return fmt.Errorf("round %d duration %dms below floor %dms", r.RoundNumber, r.DurationMs, minRoundMs)
+			}
+		}
+	}
+
+	if cfg.StrictRoundValidation && hasRoundScores {
+		delta := req.FinalScore - roundScoreSum
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta > cfg.RoundScoreTolerance {
+			return fmt.Errorf("final score %d inconsistent with round total %d (tolerance %d)", req.FinalScore, roundScoreSum, cfg.RoundScoreTolerance)
 		}
 	}
 
@@ -1061,36 +2192,79 @@ func validateRounds(ctx context.Context, nk runtime.NakamaModule, req *MatchResu
 		req.RoundsWon = derivedWon
 		req.RoundsLost = derivedLost
 	}
+	return nil
 }
 
 // TODO move lootbox stuff out of here?
-// LootboxConfig holds lootbox tier configuration
+// LootboxConfig holds the tiers of lootboxes exchanged from match tokens (shop.json "match_lootboxes").
+// MatchStreakTier replaces the win tier once a resolved win streak reaches MatchStreakWins.
 type LootboxConfig struct {
-	MatchWinTier  string `json:"match_win_tier"`
-	MatchLossTier string `json:"match_loss_tier"`
+	MatchWinTier    string `json:"match_win_tier"`
+	MatchLossTier   string `json:"match_loss_tier"`
+	MatchStreakTier string `json:"match_streak_tier,omitempty"`
+	MatchStreakWins int    `json:"match_streak_wins,omitempty"` // 0 disables the streak tier
 }
 
-var lootboxConfig *LootboxConfig
+var defaultLootboxConfig = LootboxConfig{
+	MatchWinTier:  "standard",
+	MatchLossTier: "standard",
+}
 
 func GetLootboxConfig() *LootboxConfig {
-	if lootboxConfig == nil {
-		lootboxConfig = &LootboxConfig{
-			MatchWinTier:  "standard",
-			MatchLossTier: "standard",
+	if shopCfg := GetShopConfig(); shopCfg != nil {
+		return &shopCfg.MatchLootboxes
+	}
+	return &defaultLootboxConfig
+}
+
+// tierFor picks the match lootbox tier for a result and the player's post-match win streak.
+func (c *LootboxConfig) tierFor(won bool, streak int) string {
+	if !won {
+		return c.MatchLossTier
+	}
+	if c.MatchStreakTier != "" && c.MatchStreakWins > 0 && streak >= c.MatchStreakWins {
+		return c.MatchStreakTier
+	}
+	return c.MatchWinTier
+}
+
+// validateMatchLootboxes fills default tiers and checks every configured tier exists.
+func validateMatchLootboxes(c *LootboxConfig, tiers map[string]LootboxTierDef) error {
+	if c.MatchWinTier == "" {
+		c.MatchWinTier = defaultLootboxConfig.MatchWinTier
+	}
+	if c.MatchLossTier == "" {
+		c.MatchLossTier = defaultLootboxConfig.MatchLossTier
+	}
+	if c.MatchStreakTier != "" && c.MatchStreakWins < 1 {
+		return fmt.Errorf("match_lootboxes: match_streak_tier requires match_streak_wins >= 1")
+	}
+	for _, tier := range []string{c.MatchWinTier, c.MatchLossTier, c.MatchStreakTier} {
+		if tier == "" {
+			continue
+		}
+		if _, ok := tiers[tier]; !ok {
+			return fmt.Errorf("match_lootboxes: unknown lootbox tier %q", tier)
 		}
 	}
-	return lootboxConfig
+	return nil
 }
 
 // PrepareCreateLootbox prepares a lootbox creation without committing.
-// Returns the lootbox and the storage write to be committed later.
-func PrepareCreateLootbox(userID string, tier string) (*Lootbox, *runtime.StorageWrite, error) {
+// Returns the lootbox and the storage writes (box + hidden fairness secret) to be committed together.
+func PrepareCreateLootbox(userID string, tier string) (*Lootbox, []*runtime.StorageWrite, error) {
+	secret, commitment, err := newLootboxSecret()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	timestamp := time.Now().UnixMilli()
 	lootbox := &Lootbox{
-		ID:        fmt.Sprintf("lb_%s_%d_%04x", userID[:8], timestamp, rand.Intn(0xFFFF)),
-		Tier:      tier,
-		CreatedAt: timestamp,
-		Opened:    false,
+		ID:         fmt.Sprintf("lb_%s_%d_%04x", userID[:8], timestamp, rand.Intn(0xFFFF)),
+		Tier:       tier,
+		CreatedAt:  timestamp,
+		Opened:     false,
+		Commitment: commitment,
 	}
 
 	value, err := json.Marshal(lootbox)
@@ -1098,26 +2272,29 @@ func PrepareCreateLootbox(userID string, tier string) (*Lootbox, *runtime.Storag
 		return nil, nil, errors.ErrMarshal
 	}
 
-	write := &runtime.StorageWrite{
-		Collection:      storageCollectionLootboxes,
-		Key:             lootbox.ID,
-		UserID:          userID,
-		Value:           string(value),
-		PermissionRead:  1,
-		PermissionWrite: 0,
+	writes := []*runtime.StorageWrite{
+		{
+			Collection:      storageCollectionLootboxes,
+			Key:             lootbox.ID,
+			UserID:          userID,
+			Value:           string(value),
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		},
+		buildLootboxSecretWrite(userID, lootbox.ID, secret),
 	}
 
-	return lootbox, write, nil
+	return lootbox, writes, nil
 }
 
 // createLootbox creates a new unopened lootbox for the user
 func createLootbox(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, tier string) (*Lootbox, error) {
-	lootbox, write, err := PrepareCreateLootbox(userID, tier)
+	lootbox, writes, err := PrepareCreateLootbox(userID, tier)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{write})
+	_, err = nk.StorageWrite(ctx, writes)
 	if err != nil {
 		return nil, errors.ErrLootboxWriteFailed
 	}
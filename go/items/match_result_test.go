@@ -0,0 +1,456 @@
+package items
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+)
+
+func strictRoundsRequest() *MatchResultRequest {
+	return &MatchResultRequest{
+		MatchID:    "m1",
+		FinalScore: 300,
+		Rounds: []RoundResult{
+			{RoundNumber: 1, PlayerWon: true, DurationMs: 30000, Score: 100},
+			{RoundNumber: 2, PlayerWon: true, DurationMs: 1000, Score: 200},
+		},
+	}
+}
+
+func TestValidateRoundsLenientAllowsShortRounds(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.StrictRoundValidation = false
+		cfg.MinRoundDurationMs = 5000
+	})
+	req := strictRoundsRequest()
+	req.FinalScore = 9999 // inconsistent totals are only logged in lenient mode
+	if err := validateRounds(testContext("u1"), newFakeNakama(), req, "u1", testLogger{}, nil); err != nil {
+		t.Fatalf("lenient mode rejected submission: %v", err)
+	}
+}
+
+func TestValidateRoundsStrictRejectsShortRound(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.StrictRoundValidation = true
+		cfg.MinRoundDurationMs = 5000
+		cfg.RoundScoreTolerance = 0
+	})
+	if err := validateRounds(testContext("u1"), newFakeNakama(), strictRoundsRequest(), "u1", testLogger{}, nil); err == nil {
+		t.Fatal("strict mode accepted a round below the duration floor")
+	}
+}
+
+func TestValidateRoundsStrictScoreTolerance(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.StrictRoundValidation = true
+		cfg.MinRoundDurationMs = 500
+		cfg.RoundScoreTolerance = 10
+	})
+	cases := []struct {
+		final   int
+		wantErr bool
+	}{
+		{300, false},
+		{310, false},
+		{290, false},
+		{311, true},
+		{289, true},
+	}
+	for _, c := range cases {
+		req := strictRoundsRequest()
+		req.FinalScore = c.final
+		err := validateRounds(testContext("u1"), newFakeNakama(), req, "u1", testLogger{}, nil)
+		if (err != nil) != c.wantErr {
+			t.Errorf("final score %d: err = %v, want error %v", c.final, err, c.wantErr)
+		}
+	}
+}
+
+const firstWinUser = "00000000-0000-0000-0000-000000000003"
+
+func withFirstWinTreats(t *testing.T, treats int) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.FirstWinBonus = FirstWinBonusConfig{Treats: treats}
+		cfg.DeferredWinBonus = DeferredWinBonusConfig{}
+	})
+}
+
+func soloWin(t *testing.T, matchID string) *MatchResultRequest {
+	t.Helper()
+	return &MatchResultRequest{MatchID: matchID, Won: true, FinalScore: 100, EquippedPetID: firstPetID(t)}
+}
+
+func TestFirstWinBonusOncePerDay(t *testing.T) {
+	withFirstWinTreats(t, 7)
+	nk := newFakeNakama()
+	ctx := testContext(firstWinUser)
+
+	first, err := processMatchRewards(ctx, nk, testLogger{}, firstWinUser, soloWin(t, "m1"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("first win: %v", err)
+	}
+	if first.Economy == nil || !first.Economy.FirstWinBonus {
+		t.Fatalf("first win of the day did not pay the bonus: %+v", first.Economy)
+	}
+	if treats := nk.wallet(firstWinUser)["treats"]; treats != 7 {
+		t.Errorf("treats = %d after the first win, want 7", treats)
+	}
+
+	second, err := processMatchRewards(ctx, nk, testLogger{}, firstWinUser, soloWin(t, "m2"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("second win: %v", err)
+	}
+	if second.Economy != nil && second.Economy.FirstWinBonus {
+		t.Error("second win of the day paid the bonus again")
+	}
+	if treats := nk.wallet(firstWinUser)["treats"]; treats != 7 {
+		t.Errorf("treats = %d after the second win, want 7", treats)
+	}
+
+	// A new UTC day makes the bonus due again.
+	var dj DailyJourney
+	nk.get(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser, &dj)
+	yesterday := utcMidnight(time.Now()).Add(-24 * time.Hour).Unix()
+	dj.LastWinBonusDay, dj.ResetUnix = yesterday, yesterday
+	nk.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser, dj)
+	third, err := processMatchRewards(ctx, nk, testLogger{}, firstWinUser, soloWin(t, "m3"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("next-day win: %v", err)
+	}
+	if third.Economy == nil || !third.Economy.FirstWinBonus {
+		t.Error("first win of a new day did not pay the bonus")
+	}
+}
+
+func TestFirstWinBonusPaidAtConsensusToFirstSubmitter(t *testing.T) {
+	withFirstWinTreats(t, 7)
+	nk := newFakeNakama()
+	ctx := testContext(firstWinUser)
+
+	payload, err := processDeferredWinBonus(ctx, nk, testLogger{}, firstWinUser, "m1", true)
+	if err != nil {
+		t.Fatalf("processDeferredWinBonus: %v", err)
+	}
+	if payload == nil || payload.Economy == nil || !payload.Economy.FirstWinBonus {
+		t.Fatalf("confirmed first-submitter win did not pay the first-win bonus: %+v", payload)
+	}
+	if treats := nk.wallet(firstWinUser)["treats"]; treats != 7 {
+		t.Errorf("treats = %d, want 7", treats)
+	}
+
+	// Later wins that day, on either path, do not pay it again.
+	if payload, err := processDeferredWinBonus(ctx, nk, testLogger{}, firstWinUser, "m2", true); err != nil || payload != nil {
+		t.Errorf("second deferred win = %+v, %v; want nothing", payload, err)
+	}
+	second, err := processMatchRewards(ctx, nk, testLogger{}, firstWinUser, soloWin(t, "m3"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("later win: %v", err)
+	}
+	if second.Economy != nil && second.Economy.FirstWinBonus {
+		t.Error("a later win paid the bonus after the deferred path already did")
+	}
+	if treats := nk.wallet(firstWinUser)["treats"]; treats != 7 {
+		t.Errorf("treats = %d after later wins, want 7", treats)
+	}
+
+	if payload, err := processDeferredWinBonus(ctx, nk, testLogger{}, firstWinUser, "m4", false); err != nil || payload != nil {
+		t.Errorf("deferred loss = %+v, %v; want nothing", payload, err)
+	}
+}
+
+func TestTokensDroppedWithNoExchangesLeft(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.FirstWinBonus = FirstWinBonusConfig{Tokens: 4}
+		cfg.DeferredWinBonus = DeferredWinBonusConfig{}
+	})
+	nk := newFakeNakama()
+	thresh := GetEconomyConfig().TokenExchangeThresh
+	today := utcMidnight(time.Now()).Unix()
+	nk.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser,
+		DailyJourney{RoundTokens: thresh - 1, ExchangesLeft: 0, ResetUnix: today, DailyWarmupClaimed: true})
+
+	result, err := processMatchRewards(testContext(firstWinUser), nk, testLogger{}, firstWinUser, soloWin(t, "m1"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("processMatchRewards: %v", err)
+	}
+	var dj DailyJourney
+	nk.get(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser, &dj)
+	if dj.RoundTokens != thresh {
+		t.Errorf("stored round tokens = %d, want clamped to the threshold %d", dj.RoundTokens, thresh)
+	}
+	if len(result.Lootboxes) != 0 {
+		t.Errorf("exchanged %d lootboxes with no exchanges left", len(result.Lootboxes))
+	}
+	if got := *result.Meta.RoundTokens; got != dj.RoundTokens {
+		t.Errorf("meta round tokens = %d, stored balance = %d", got, dj.RoundTokens)
+	}
+	if got := *result.Economy.RoundTokens; got != dj.RoundTokens {
+		t.Errorf("economy round tokens = %d, stored balance = %d", got, dj.RoundTokens)
+	}
+}
+
+const (
+	catchUpLowUser  = "00000000-0000-0000-0000-000000000011"
+	catchUpHighUser = "00000000-0000-0000-0000-000000000012"
+)
+
+func withCatchUpXP(t *testing.T, catchUp CatchUpXPConfig) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.CatchUpXP = catchUp
+		cfg.DailyXPCurve = nil
+		cfg.FirstWinBonus = FirstWinBonusConfig{}
+		cfg.DeferredWinBonus = DeferredWinBonusConfig{}
+	})
+}
+
+// catchUpWin plays a solo win for a player seeded at level and returns the reported and stored XP.
+func catchUpWin(t *testing.T, nk *fakeNakama, userID string, level int) (int, int) {
+	t.Helper()
+	nk.put(t, storageCollectionProgression, ProgressionKeyPlayer+"0", userID, ItemProgression{Level: level})
+	result, err := processMatchRewards(testContext(userID), nk, testLogger{}, userID, soloWin(t, "m1"), true, nil, streakWin)
+	if err != nil {
+		t.Fatalf("processMatchRewards: %v", err)
+	}
+	var prog ItemProgression
+	nk.get(t, storageCollectionProgression, ProgressionKeyPlayer+"0", userID, &prog)
+	if result.Progression == nil || result.Progression.XpGranted == nil {
+		t.Fatalf("payload has no xp_granted: %+v", result.Progression)
+	}
+	if arg := result.ReasonArgs[notify.ReasonArgXP]; arg != strconv.Itoa(*result.Progression.XpGranted) {
+		t.Errorf("xp reason arg = %q, xp_granted = %d", arg, *result.Progression.XpGranted)
+	}
+	return *result.Progression.XpGranted, prog.Exp
+}
+
+func TestCatchUpXPBelowLevel(t *testing.T) {
+	withCatchUpXP(t, CatchUpXPConfig{BelowLevel: 5, Multiplier: 2})
+	nk := newFakeNakama()
+	base := GetEconomyConfig().WinXP / 2 // solo
+
+	if granted, stored := catchUpWin(t, nk, catchUpLowUser, 2); granted != base*2 || stored != base*2 {
+		t.Errorf("low-level player: granted %d, stored %d; want %d", granted, stored, base*2)
+	}
+	if granted, stored := catchUpWin(t, nk, catchUpHighUser, 10); granted != base || stored != base {
+		t.Errorf("high-level player: granted %d, stored %d; want %d", granted, stored, base)
+	}
+}
+
+func TestCatchUpXPWithinSignupWindow(t *testing.T) {
+	withCatchUpXP(t, CatchUpXPConfig{WithinDays: 7, Multiplier: 2})
+	nk := newFakeNakama()
+	base := GetEconomyConfig().WinXP / 2
+	nk.setAccountCreated(catchUpLowUser, time.Now().Add(-48*time.Hour))
+	nk.setAccountCreated(catchUpHighUser, time.Now().Add(-30*24*time.Hour))
+
+	if granted, _ := catchUpWin(t, nk, catchUpLowUser, 10); granted != base*2 {
+		t.Errorf("new account: granted %d, want %d", granted, base*2)
+	}
+	if granted, _ := catchUpWin(t, nk, catchUpHighUser, 10); granted != base {
+		t.Errorf("old account: granted %d, want %d", granted, base)
+	}
+}
+
+func TestDailyXPCurveUsesNthThenLastMultiplier(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.DailyXPCurve = []float64{1.0, 0.5, 0.2}
+	})
+	nk := newFakeNakama()
+
+	for _, tc := range []struct {
+		matchesToday int
+		want         int
+	}{
+		{1, 100},
+		{2, 50},
+		{3, 20},
+		{4, 20},
+		{12, 20},
+	} {
+		granted, _, _, err := preparePlayerXP(testContext("u1"), nk, testLogger{}, "u1", 100, tc.matchesToday, 1, XpBoostState{})
+		if err != nil {
+			t.Fatalf("preparePlayerXP: %v", err)
+		}
+		if granted != tc.want {
+			t.Errorf("match %d: granted %d XP, want %d", tc.matchesToday, granted, tc.want)
+		}
+	}
+}
+
+func TestIncrementDailyMatchCountResetsAtMidnight(t *testing.T) {
+	now := time.Now()
+	today := utcMidnight(now)
+
+	dj := DailyJourney{DailyMatches: 2, ExchangesLeft: 1, RoundTokens: 5, ResetUnix: today.Unix()}
+	if n := incrementDailyMatchCount(&dj, now); n != 3 || dj.RoundTokens != 5 || dj.ExchangesLeft != 1 {
+		t.Errorf("same day: count %d, tokens %d, exchanges %d; want 3, 5, 1", n, dj.RoundTokens, dj.ExchangesLeft)
+	}
+
+	dj = DailyJourney{DailyMatches: 7, ExchangesLeft: 0, RoundTokens: 5, DailyWarmupClaimed: true, ResetUnix: today.AddDate(0, 0, -1).Unix()}
+	if n := incrementDailyMatchCount(&dj, now); n != 1 {
+		t.Errorf("after midnight: count %d, want 1", n)
+	}
+	if dj.RoundTokens != 0 || dj.ExchangesLeft != DailyExchangeCap || dj.DailyWarmupClaimed || dj.ResetUnix != today.Unix() {
+		t.Errorf("after midnight: journey %+v not reset to %d", dj, today.Unix())
+	}
+}
+
+func TestFirstDailyJourneyIsCreateOnly(t *testing.T) {
+	withFirstWinTreats(t, 0)
+	nk := newFakeNakama()
+	today := utcMidnight(time.Now()).Unix()
+	// A concurrent first match creates the journey between this match's read and its commit.
+	nk.multiUpdateHook = func() {
+		nk.multiUpdateHook = nil
+		nk.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser,
+			DailyJourney{DailyMatches: 1, ExchangesLeft: DailyExchangeCap, ResetUnix: today})
+	}
+
+	_, err := processMatchRewards(testContext(firstWinUser), nk, testLogger{}, firstWinUser, soloWin(t, "m1"), true, nil, streakWin)
+	var dj DailyJourney
+	nk.get(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser, &dj)
+	if err == nil && dj.DailyMatches != 2 || err != nil && dj.DailyMatches != 1 {
+		t.Errorf("matches today = %d (err %v); the concurrent journey was overwritten", dj.DailyMatches, err)
+	}
+}
+
+func TestDailyStatsReportStaleJourneyAsReset(t *testing.T) {
+	nk := newFakeNakama()
+	yesterday := utcMidnight(time.Now()).AddDate(0, 0, -1).Unix()
+	nk.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, firstWinUser,
+		DailyJourney{DailyMatches: 9, RoundTokens: 3, ResetUnix: yesterday})
+
+	resp, err := RpcGetDailyStats(testContext(firstWinUser), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetDailyStats: %v", err)
+	}
+	var stats DailyStatsResponse
+	if err := json.Unmarshal([]byte(resp), &stats); err != nil {
+		t.Fatalf("unmarshal stats: %v", err)
+	}
+	if stats.MatchesToday != 0 || stats.RoundTokens != 0 || stats.ExchangesLeft != DailyExchangeCap {
+		t.Errorf("stale journey reported as %+v, want reset", stats)
+	}
+}
+
+// serverDurationOf returns the server duration recorded in userID's history for matchID.
+func serverDurationOf(t *testing.T, nk *fakeNakama, userID, matchID string) int64 {
+	t.Helper()
+	var doc MatchHistoryDocument
+	if !nk.get(t, storageCollectionMatchHistory, "history", userID, &doc) {
+		t.Fatal("no match history written")
+	}
+	for _, entry := range doc.Matches {
+		if entry.MatchID == matchID {
+			return entry.ServerDurationMs
+		}
+	}
+	t.Fatalf("match %s not in history", matchID)
+	return 0
+}
+
+func TestForfeitRecordsServerDuration(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000091"
+	nk := newFakeNakama()
+	started := time.Now().Add(-90 * time.Second).UnixMilli()
+	nk.put(t, storageCollectionActiveMatch, activeMatchKey("m1"), userID, ActiveMatch{MatchID: "m1", StartTime: started})
+
+	if _, err := RpcForfeitMatch(testContext(userID), testLogger{}, nil, nk, `{"match_id":"m1"}`); err != nil {
+		t.Fatalf("RpcForfeitMatch: %v", err)
+	}
+	if ms := serverDurationOf(t, nk, userID, "m1"); ms < 90000 || ms > 100000 {
+		t.Fatalf("server_duration_ms = %d, want about 90000", ms)
+	}
+}
+
+func TestConcurrentSubmitsKeepEachIdempotencyKey(t *testing.T) {
+	const userID = "u-idem"
+	nk := newFakeNakama()
+
+	// Pair up the first two writes of each submit so both read the document before either writes it.
+	var mu sync.Mutex
+	writes := 0
+	paired := []chan struct{}{make(chan struct{}), make(chan struct{})}
+	nk.storageWriteHook = func() {
+		mu.Lock()
+		writes++
+		n := writes
+		mu.Unlock()
+		if n > 2*len(paired) {
+			return
+		}
+		if n%2 == 0 {
+			close(paired[(n-1)/2])
+		}
+		<-paired[(n-1)/2]
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"key-a", "key-b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cacheMatchResult(testContext(userID), nk, testLogger{}, userID, "match-"+key, key, []byte(`{}`))
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range []string{"key-a", "key-b"} {
+		entry, ok := lookupIdempotentMatchResult(testContext(userID), nk, userID, key)
+		if !ok || entry.MatchID != "match-"+key {
+			t.Errorf("idempotency key %s was lost", key)
+		}
+	}
+}
+
+func TestScoreBoundsAtLimits(t *testing.T) {
+	bounds := ScoreBoundsConfig{MinMatch: 10, MaxMatch: 1000, MinRound: 1, MaxRound: 500}
+	match := func(final, opponent int, rounds ...int) *MatchResultRequest {
+		req := &MatchResultRequest{FinalScore: final, OpponentScore: opponent}
+		for i, score := range rounds {
+			req.Rounds = append(req.Rounds, RoundResult{RoundNumber: i + 1, Score: score})
+		}
+		return req
+	}
+	cases := []struct {
+		name   string
+		bounds ScoreBoundsConfig
+		req    *MatchResultRequest
+		ok     bool
+	}{
+		{"match at min", bounds, match(10, 10), true},
+		{"match below min", bounds, match(9, 10), false},
+		{"match at max", bounds, match(1000, 10), true},
+		{"match above max", bounds, match(1001, 10), false},
+		{"opponent above max", bounds, match(10, 1001), false},
+		{"round at min", bounds, match(10, 10, 1), true},
+		{"round below min", bounds, match(10, 10, 0), false},
+		{"round at max", bounds, match(500, 10, 500), true},
+		{"round above max", bounds, match(1000, 10, 499, 501), false},
+		{"default match max", ScoreBoundsConfig{}, match(defaultMaxMatchScore, 0), true},
+		{"above default match max", ScoreBoundsConfig{}, match(defaultMaxMatchScore+1, 0), false},
+		{"default round max", ScoreBoundsConfig{}, match(0, 0, defaultMaxRoundScore), true},
+		{"above default round max", ScoreBoundsConfig{}, match(0, 0, defaultMaxRoundScore+1), false},
+		{"negative score by default", ScoreBoundsConfig{}, match(-1, 0), false},
+	}
+	for _, c := range cases {
+		if err := c.bounds.validate(c.req); (err == nil) != c.ok {
+			t.Errorf("%s: validate = %v, want ok=%v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestSubmitRejectsScoreOutOfBounds(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.ScoreBounds = ScoreBoundsConfig{MaxMatch: 1000}
+	})
+	nk := newFakeNakama()
+	_, err := RpcSubmitMatchResult(testContext("u-bounds"), testLogger{}, nil, nk, `{"match_id":"m-bounds","final_score":1001}`)
+	if err != errors.ErrScoreOutOfBounds {
+		t.Fatalf("err = %v, want ErrScoreOutOfBounds", err)
+	}
+}
@@ -0,0 +1,636 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMain(m *testing.M) {
+	if err := LoadGameData(); err != nil {
+		fmt.Fprintf(os.Stderr, "load game data: %v\n", err)
+		os.Exit(1)
+	}
+	if err := LoadShopData(); err != nil {
+		fmt.Fprintf(os.Stderr, "load shop data: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// testLogger discards everything; tests assert on state, not log lines.
+type testLogger struct{}
+
+func (testLogger) Debug(string, ...interface{})                       {}
+func (testLogger) Info(string, ...interface{})                        {}
+func (testLogger) Warn(string, ...interface{})                        {}
+func (testLogger) Error(string, ...interface{})                       {}
+func (l testLogger) WithField(string, interface{}) runtime.Logger     { return l }
+func (l testLogger) WithFields(map[string]interface{}) runtime.Logger { return l }
+func (testLogger) Fields() map[string]interface{}                     { return nil }
+
+type storageID struct {
+	collection, key, userID string
+}
+
+// fakeNakama is an in-memory NakamaModule covering the calls the items package makes.
+// Anything not overridden panics through the nil embedded interface, which flags a test
+// that wandered into an unexpected code path.
+type fakeNakama struct {
+	runtime.NakamaModule
+
+	mu       sync.Mutex
+	seq      int
+	storage  map[storageID]*api.StorageObject
+	wallets  map[string]map[string]int64
+	accounts map[string]*api.Account
+
+	notifications []*runtime.NotificationSend
+	metrics       map[string]float64
+	records       map[string]map[string]*api.LeaderboardRecord
+	tournaments   map[string]*api.Tournament
+
+	// Fault injection. Each counter fails that many upcoming calls, then clears.
+	failStorageReads   int
+	failNotifications  int
+	failWalletUpdates  int
+	storageWriteCalls  int
+	storageReadCalls   int
+	accountGetIDCalls  int
+	storageListCalls   int
+	multiUpdateHook    func() // runs inside MultiUpdate before validation, lock released
+	storageWriteHook   func() // runs inside StorageWrite before validation, lock released
+	notificationsSent  int
+	leaderboardWrites  int
+	tournamentWrites   int
+	sessionDisconnects []string
+}
+
+func newFakeNakama() *fakeNakama {
+	return &fakeNakama{
+		storage:     make(map[storageID]*api.StorageObject),
+		wallets:     make(map[string]map[string]int64),
+		accounts:    make(map[string]*api.Account),
+		metrics:     make(map[string]float64),
+		records:     make(map[string]map[string]*api.LeaderboardRecord),
+		tournaments: make(map[string]*api.Tournament),
+	}
+}
+
+func testContext(userID string) context.Context {
+	return context.WithValue(context.Background(), runtime.RUNTIME_CTX_USER_ID, userID)
+}
+
+func (f *fakeNakama) nextVersion() string {
+	f.seq++
+	return strconv.Itoa(f.seq)
+}
+
+// put seeds a storage object, marshalling v to JSON.
+func (f *fakeNakama) put(t testing.TB, collection, key, userID string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %s/%s: %v", collection, key, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := storageID{collection, key, userID}
+	now := timestamppb.Now()
+	f.storage[id] = &api.StorageObject{
+		Collection: collection, Key: key, UserId: userID, Value: string(data),
+		Version: f.nextVersion(), CreateTime: now, UpdateTime: now,
+	}
+}
+
+// get decodes a stored object into v and reports whether it existed.
+func (f *fakeNakama) get(t testing.TB, collection, key, userID string, v interface{}) bool {
+	t.Helper()
+	f.mu.Lock()
+	obj, ok := f.storage[storageID{collection, key, userID}]
+	f.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if v != nil {
+		if err := json.Unmarshal([]byte(obj.Value), v); err != nil {
+			t.Fatalf("unmarshal %s/%s: %v", collection, key, err)
+		}
+	}
+	return true
+}
+
+func (f *fakeNakama) count(collection, userID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for id := range f.storage {
+		if id.collection == collection && id.userID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeNakama) setWallet(userID string, wallet map[string]int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := make(map[string]int64, len(wallet))
+	for k, v := range wallet {
+		w[k] = v
+	}
+	f.wallets[userID] = w
+}
+
+func (f *fakeNakama) wallet(userID string) map[string]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := make(map[string]int64, len(f.wallets[userID]))
+	for k, v := range f.wallets[userID] {
+		w[k] = v
+	}
+	return w
+}
+
+func (f *fakeNakama) sent(subject string) []*runtime.NotificationSend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*runtime.NotificationSend
+	for _, n := range f.notifications {
+		if subject == "" || n.Subject == subject {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (f *fakeNakama) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storageReadCalls++
+	if f.failStorageReads > 0 {
+		f.failStorageReads--
+		return nil, fmt.Errorf("storage read: injected failure")
+	}
+	var out []*api.StorageObject
+	for _, r := range reads {
+		if obj, ok := f.storage[storageID{r.Collection, r.Key, r.UserID}]; ok {
+			out = append(out, proto.Clone(obj).(*api.StorageObject))
+		}
+	}
+	return out, nil
+}
+
+// checkWriteLocked applies Nakama's OCC rules: "" is unconditional, "*" is create-only,
+// anything else must match the stored version.
+func (f *fakeNakama) checkWriteLocked(collection, key, userID, version string) error {
+	existing, ok := f.storage[storageID{collection, key, userID}]
+	switch {
+	case version == "":
+		return nil
+	case version == "*":
+		if ok {
+			return runtime.ErrStorageRejectedVersion
+		}
+	case !ok || existing.Version != version:
+		return runtime.ErrStorageRejectedVersion
+	}
+	return nil
+}
+
+func (f *fakeNakama) applyWriteLocked(w *runtime.StorageWrite) *api.StorageObjectAck {
+	id := storageID{w.Collection, w.Key, w.UserID}
+	now := timestamppb.Now()
+	created := now
+	if existing, ok := f.storage[id]; ok {
+		created = existing.CreateTime
+	}
+	obj := &api.StorageObject{
+		Collection: w.Collection, Key: w.Key, UserId: w.UserID, Value: w.Value,
+		Version: f.nextVersion(), PermissionRead: int32(w.PermissionRead), PermissionWrite: int32(w.PermissionWrite),
+		CreateTime: created, UpdateTime: now,
+	}
+	f.storage[id] = obj
+	return &api.StorageObjectAck{Collection: obj.Collection, Key: obj.Key, UserId: obj.UserId, Version: obj.Version}
+}
+
+func (f *fakeNakama) StorageWrite(ctx context.Context, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	if f.storageWriteHook != nil {
+		f.storageWriteHook()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storageWriteCalls++
+	for _, w := range writes {
+		if err := f.checkWriteLocked(w.Collection, w.Key, w.UserID, w.Version); err != nil {
+			return nil, err
+		}
+	}
+	acks := make([]*api.StorageObjectAck, 0, len(writes))
+	for _, w := range writes {
+		acks = append(acks, f.applyWriteLocked(w))
+	}
+	return acks, nil
+}
+
+func (f *fakeNakama) StorageDelete(ctx context.Context, deletes []*runtime.StorageDelete) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range deletes {
+		if d.Version == "" {
+			continue
+		}
+		if err := f.checkWriteLocked(d.Collection, d.Key, d.UserID, d.Version); err != nil {
+			return err
+		}
+	}
+	for _, d := range deletes {
+		delete(f.storage, storageID{d.Collection, d.Key, d.UserID})
+	}
+	return nil
+}
+
+func (f *fakeNakama) StorageList(ctx context.Context, callerID, userID, collection string, limit int, cursor string) ([]*api.StorageObject, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.storageListCalls++
+	var keys []string
+	for id := range f.storage {
+		if id.collection == collection && (userID == "" || id.userID == userID) {
+			keys = append(keys, id.userID+"\x00"+id.key)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil {
+			return nil, "", fmt.Errorf("bad cursor %q", cursor)
+		}
+		start = n
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	end := start + limit
+	next := strconv.Itoa(end)
+	if end >= len(keys) {
+		end = len(keys)
+		next = ""
+	}
+	var out []*api.StorageObject
+	for _, k := range keys[start:end] {
+		for id, obj := range f.storage {
+			if id.collection == collection && id.userID+"\x00"+id.key == k {
+				out = append(out, proto.Clone(obj).(*api.StorageObject))
+			}
+		}
+	}
+	return out, next, nil
+}
+
+func (f *fakeNakama) accountLocked(userID string) *api.Account {
+	acct, ok := f.accounts[userID]
+	if !ok {
+		acct = &api.Account{User: &api.User{Id: userID, Username: "user_" + userID, Metadata: "{}", CreateTime: timestamppb.Now()}}
+		f.accounts[userID] = acct
+	}
+	return acct
+}
+
+func (f *fakeNakama) AccountGetId(ctx context.Context, userID string) (*api.Account, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accountGetIDCalls++
+	acct := f.accountLocked(userID)
+	wallet, _ := json.Marshal(f.wallets[userID])
+	if f.wallets[userID] == nil {
+		wallet = []byte("{}")
+	}
+	return &api.Account{User: proto.Clone(acct.User).(*api.User), Wallet: string(wallet)}, nil
+}
+
+func (f *fakeNakama) UsersGetId(ctx context.Context, userIDs []string, facebookIDs []string) ([]*api.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*api.User
+	for _, id := range userIDs {
+		if acct, ok := f.accounts[id]; ok {
+			out = append(out, proto.Clone(acct.User).(*api.User))
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNakama) setAccountCreated(userID string, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.accountLocked(userID).User.CreateTime = timestamppb.New(at)
+}
+
+func (f *fakeNakama) AccountUpdateId(ctx context.Context, userID, username string, metadata map[string]interface{}, displayName, timezone, location, langTag, avatarURL string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	acct := f.accountLocked(userID)
+	if metadata != nil {
+		data, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		acct.User.Metadata = string(data)
+	}
+	if username != "" {
+		acct.User.Username = username
+	}
+	if displayName != "" {
+		acct.User.DisplayName = displayName
+	}
+	return nil
+}
+
+// walletUpdateLocked validates every changeset before applying any of them.
+func (f *fakeNakama) walletUpdatesLocked(updates []*runtime.WalletUpdate) ([]*runtime.WalletUpdateResult, error) {
+	for _, u := range updates {
+		current := f.wallets[u.UserID]
+		for path, delta := range u.Changeset {
+			if current[path]+delta < 0 {
+				return nil, &runtime.WalletNegativeError{UserID: u.UserID, Path: path, Current: current[path], Amount: delta}
+			}
+		}
+	}
+	results := make([]*runtime.WalletUpdateResult, 0, len(updates))
+	for _, u := range updates {
+		if f.wallets[u.UserID] == nil {
+			f.wallets[u.UserID] = make(map[string]int64)
+		}
+		w := f.wallets[u.UserID]
+		prev := make(map[string]int64, len(w))
+		for k, v := range w {
+			prev[k] = v
+		}
+		for path, delta := range u.Changeset {
+			w[path] += delta
+		}
+		updated := make(map[string]int64, len(w))
+		for k, v := range w {
+			updated[k] = v
+		}
+		results = append(results, &runtime.WalletUpdateResult{UserID: u.UserID, Updated: updated, Previous: prev})
+	}
+	return results, nil
+}
+
+func (f *fakeNakama) WalletUpdate(ctx context.Context, userID string, changeset map[string]int64, metadata map[string]interface{}, updateLedger bool) (map[string]int64, map[string]int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failWalletUpdates > 0 {
+		f.failWalletUpdates--
+		return nil, nil, fmt.Errorf("wallet update: injected failure")
+	}
+	res, err := f.walletUpdatesLocked([]*runtime.WalletUpdate{{UserID: userID, Changeset: changeset, Metadata: metadata}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res[0].Updated, res[0].Previous, nil
+}
+
+func (f *fakeNakama) MultiUpdate(ctx context.Context, accountUpdates []*runtime.AccountUpdate, storageWrites []*runtime.StorageWrite, storageDeletes []*runtime.StorageDelete, walletUpdates []*runtime.WalletUpdate, updateLedger bool) ([]*api.StorageObjectAck, []*runtime.WalletUpdateResult, error) {
+	if f.multiUpdateHook != nil {
+		f.multiUpdateHook()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failWalletUpdates > 0 && len(walletUpdates) > 0 {
+		f.failWalletUpdates--
+		return nil, nil, fmt.Errorf("multi update: injected failure")
+	}
+	for _, w := range storageWrites {
+		if err := f.checkWriteLocked(w.Collection, w.Key, w.UserID, w.Version); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, d := range storageDeletes {
+		if d.Version == "" {
+			continue
+		}
+		if err := f.checkWriteLocked(d.Collection, d.Key, d.UserID, d.Version); err != nil {
+			return nil, nil, err
+		}
+	}
+	// Validate wallets against a snapshot so a rejected update leaves nothing applied.
+	snapshot := make(map[string]map[string]int64, len(f.wallets))
+	for u, w := range f.wallets {
+		cp := make(map[string]int64, len(w))
+		for k, v := range w {
+			cp[k] = v
+		}
+		snapshot[u] = cp
+	}
+	results, err := f.walletUpdatesLocked(walletUpdates)
+	if err != nil {
+		f.wallets = snapshot
+		return nil, nil, err
+	}
+	for _, u := range accountUpdates {
+		acct := f.accountLocked(u.UserID)
+		if u.Metadata != nil {
+			data, _ := json.Marshal(u.Metadata)
+			acct.User.Metadata = string(data)
+		}
+	}
+	acks := make([]*api.StorageObjectAck, 0, len(storageWrites))
+	for _, w := range storageWrites {
+		acks = append(acks, f.applyWriteLocked(w))
+	}
+	for _, d := range storageDeletes {
+		delete(f.storage, storageID{d.Collection, d.Key, d.UserID})
+	}
+	return acks, results, nil
+}
+
+func (f *fakeNakama) NotificationSend(ctx context.Context, userID, subject string, content map[string]interface{}, code int, sender string, persistent bool) error {
+	return f.NotificationsSend(ctx, []*runtime.NotificationSend{{UserID: userID, Subject: subject, Content: content, Code: code, Sender: sender, Persistent: persistent}})
+}
+
+func (f *fakeNakama) NotificationsSend(ctx context.Context, notifications []*runtime.NotificationSend) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failNotifications > 0 {
+		f.failNotifications--
+		return fmt.Errorf("notification send: injected failure")
+	}
+	f.notificationsSent += len(notifications)
+	f.notifications = append(f.notifications, notifications...)
+	return nil
+}
+
+func (f *fakeNakama) MetricsCounterAdd(name string, tags map[string]string, delta int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.metrics[name] += float64(delta)
+}
+
+func (f *fakeNakama) MetricsGaugeSet(name string, tags map[string]string, value float64) {}
+
+func (f *fakeNakama) MetricsTimerRecord(name string, tags map[string]string, value time.Duration) {}
+
+func (f *fakeNakama) LeaderboardRecordWrite(ctx context.Context, id, ownerID, username string, score, subscore int64, metadata map[string]interface{}, overrideOperator *int) (*api.LeaderboardRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leaderboardWrites++
+	return f.writeRecordLocked(id, ownerID, username, score, subscore), nil
+}
+
+func (f *fakeNakama) writeRecordLocked(id, ownerID, username string, score, subscore int64) *api.LeaderboardRecord {
+	board := f.records[id]
+	if board == nil {
+		board = make(map[string]*api.LeaderboardRecord)
+		f.records[id] = board
+	}
+	rec, ok := board[ownerID]
+	if !ok {
+		rec = &api.LeaderboardRecord{LeaderboardId: id, OwnerId: ownerID, Username: wrapperspb.String(username)}
+		board[ownerID] = rec
+	}
+	rec.Score += score
+	rec.Subscore += subscore
+	rec.NumScore++
+	return rec
+}
+
+// recordsSorted returns a board's records ordered by score, descending, with ranks filled in.
+func (f *fakeNakama) recordsSortedLocked(id string) []*api.LeaderboardRecord {
+	var out []*api.LeaderboardRecord
+	for _, r := range f.records[id] {
+		out = append(out, proto.Clone(r).(*api.LeaderboardRecord))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].OwnerId < out[j].OwnerId
+	})
+	for i, r := range out {
+		r.Rank = int64(i + 1)
+	}
+	return out
+}
+
+func (f *fakeNakama) LeaderboardRecordsList(ctx context.Context, id string, ownerIDs []string, limit int, cursor string, expiry int64) ([]*api.LeaderboardRecord, []*api.LeaderboardRecord, string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all := f.recordsSortedLocked(id)
+	var owners []*api.LeaderboardRecord
+	for _, r := range all {
+		for _, o := range ownerIDs {
+			if r.OwnerId == o {
+				owners = append(owners, r)
+			}
+		}
+	}
+	start := 0
+	if cursor != "" {
+		start, _ = strconv.Atoi(cursor)
+	}
+	if limit <= 0 {
+		limit = len(all)
+	}
+	end := start + limit
+	next := ""
+	if end < len(all) {
+		next = strconv.Itoa(end)
+	} else {
+		end = len(all)
+	}
+	return all[start:end], owners, next, "", nil
+}
+
+func (f *fakeNakama) LeaderboardRecordsHaystack(ctx context.Context, id, ownerID string, limit int, cursor string, expiry int64) (*api.LeaderboardRecordList, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all := f.recordsSortedLocked(id)
+	idx := -1
+	for i, r := range all {
+		if r.OwnerId == ownerID {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return &api.LeaderboardRecordList{}, nil
+	}
+	lo := max(0, idx-limit/2)
+	hi := min(len(all), lo+limit)
+	return &api.LeaderboardRecordList{Records: all[lo:hi]}, nil
+}
+
+func (f *fakeNakama) TournamentRecordWrite(ctx context.Context, id, ownerID, username string, score, subscore int64, metadata map[string]interface{}, operatorOverride *int) (*api.LeaderboardRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tournamentWrites++
+	if t := f.tournaments[id]; t != nil && t.JoinRequired && f.records[id][ownerID] == nil {
+		return nil, runtime.ErrTournamentWriteJoinRequired
+	}
+	return f.writeRecordLocked(id, ownerID, username, score, subscore), nil
+}
+
+// TournamentJoin writes a zero-score record, as Nakama does.
+func (f *fakeNakama) TournamentJoin(ctx context.Context, id, ownerID, username string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.records[id][ownerID] == nil {
+		f.writeRecordLocked(id, ownerID, username, 0, 0).NumScore = 0
+	}
+	return nil
+}
+
+func (f *fakeNakama) TournamentsGetId(ctx context.Context, tournamentIDs []string) ([]*api.Tournament, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*api.Tournament
+	for _, id := range tournamentIDs {
+		if t, ok := f.tournaments[id]; ok {
+			out = append(out, proto.Clone(t).(*api.Tournament))
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNakama) TournamentRecordsList(ctx context.Context, tournamentID string, ownerIDs []string, limit int, cursor string, overrideExpiry int64) ([]*api.LeaderboardRecord, []*api.LeaderboardRecord, string, string, error) {
+	records, owners, next, prev, err := f.LeaderboardRecordsList(ctx, tournamentID, ownerIDs, limit, cursor, overrideExpiry)
+	return records, owners, prev, next, err
+}
+
+func (f *fakeNakama) SessionDisconnect(ctx context.Context, sessionID string, reason ...runtime.PresenceReason) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessionDisconnects = append(f.sessionDisconnects, sessionID)
+	return nil
+}
+
+// withEconomyConfig swaps in a copy of the economy config changed by mutate for the duration of the test.
+func withEconomyConfig(t testing.TB, mutate func(cfg *EconomyConfig)) {
+	t.Helper()
+	cfg := *GetEconomyConfig()
+	mutate(&cfg)
+	gameDataMu.Lock()
+	original := economyConfig
+	economyConfig = &cfg
+	gameDataMu.Unlock()
+	t.Cleanup(func() {
+		gameDataMu.Lock()
+		economyConfig = original
+		gameDataMu.Unlock()
+	})
+}
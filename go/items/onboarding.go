@@ -0,0 +1,276 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ProgressionKeyOnboarding tracks completed tutorial steps (progression collection).
+const ProgressionKeyOnboarding = "onboarding"
+
+// OnboardingStepConfig is one entry of economy.onboarding in items.json, in tutorial order.
+// A step with no reward fields only records progress.
+type OnboardingStepConfig struct {
+	ID          string `json:"id"`
+	Gold        int    `json:"gold,omitempty"`
+	Gems        int    `json:"gems,omitempty"`
+	Treats      int    `json:"treats,omitempty"`
+	LootboxTier string `json:"lootbox_tier,omitempty"`
+}
+
+func findOnboardingStep(id string) (*OnboardingStepConfig, bool) {
+	steps := GetEconomyConfig().Onboarding
+	for i := range steps {
+		if steps[i].ID == id {
+			return &steps[i], true
+		}
+	}
+	return nil, false
+}
+
+// onboardingStepReached checks the server record behind a step: match history for first_match,
+// a non-default equipped item for first_equip, and the first-open marker for first_lootbox.
+// Other steps (intro, shop_tour) have nothing to check and are taken from the client.
+func onboardingStepReached(ctx context.Context, nk runtime.NakamaModule, userID string, stepID string) (bool, error) {
+	switch stepID {
+	case "first_match":
+		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+			Collection: storageCollectionMatchHistory,
+			Key:        "history",
+			UserID:     userID,
+		}})
+		if err != nil || len(objects) == 0 {
+			return false, err
+		}
+		var doc MatchHistoryDocument
+		if err := json.Unmarshal([]byte(objects[0].Value), &doc); err != nil {
+			return false, err
+		}
+		return len(doc.Matches) > 0, nil
+
+	case "first_equip":
+		defaults := map[string]uint32{
+			storageKeyPet:        DefaultPetID,
+			storageKeyClass:      DefaultClassID,
+			storageKeyBackground: DefaultBackgroundID,
+			storageKeyPieceStyle: DefaultPieceStyleID,
+		}
+		reads := make([]*runtime.StorageRead, 0, len(defaults))
+		for key := range defaults {
+			reads = append(reads, &runtime.StorageRead{Collection: storageCollectionEquipment, Key: key, UserID: userID})
+		}
+		objects, err := nk.StorageRead(ctx, reads)
+		if err != nil {
+			return false, err
+		}
+		for _, obj := range objects {
+			var equipped EquipmentData
+			if json.Unmarshal([]byte(obj.Value), &equipped) == nil && equipped.ID != defaults[obj.Key] {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "first_lootbox":
+		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+			Collection: storageCollectionProgression,
+			Key:        ProgressionKeyFirstBoxOpened,
+			UserID:     userID,
+		}})
+		if err != nil {
+			return false, err
+		}
+		return len(objects) > 0, nil
+	}
+	return true, nil
+}
+
+// OnboardingState is the per-user record: step ID -> unix time it was completed.
+type OnboardingState struct {
+	Completed map[string]int64 `json:"completed"`
+}
+
+// readOnboarding returns the user's onboarding state and its version ("*" when none exists yet).
+func readOnboarding(ctx context.Context, nk runtime.NakamaModule, userID string) (*OnboardingState, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyOnboarding,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	state := &OnboardingState{Completed: map[string]int64{}}
+	if len(objects) == 0 {
+		return state, "*", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), state); err != nil {
+		return nil, "", err
+	}
+	if state.Completed == nil {
+		state.Completed = map[string]int64{}
+	}
+	return state, objects[0].Version, nil
+}
+
+type OnboardingStepState struct {
+	ID          string `json:"id"`
+	Completed   bool   `json:"completed"`
+	CompletedAt int64  `json:"completed_at,omitempty"`
+	HasReward   bool   `json:"has_reward"`
+}
+
+type OnboardingStateResponse struct {
+	Steps    []OnboardingStepState `json:"steps"`
+	Finished bool                  `json:"finished"`
+}
+
+// RpcGetOnboardingState returns every configured tutorial step with the caller's completion state.
+func RpcGetOnboardingState(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	state, _, err := readOnboarding(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read onboarding for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	steps := GetEconomyConfig().Onboarding
+	resp := OnboardingStateResponse{Steps: make([]OnboardingStepState, 0, len(steps)), Finished: true}
+	for _, step := range steps {
+		at, done := state.Completed[step.ID]
+		resp.Steps = append(resp.Steps, OnboardingStepState{
+			ID:          step.ID,
+			Completed:   done,
+			CompletedAt: at,
+			HasReward:   step.Gold > 0 || step.Gems > 0 || step.Treats > 0 || step.LootboxTier != "",
+		})
+		if !done {
+			resp.Finished = false
+		}
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+type CompleteOnboardingStepRequest struct {
+	StepID string `json:"step_id"`
+}
+
+// RpcCompleteOnboardingStep marks a tutorial step complete and grants its one-time reward.
+// Steps with a server record are only accepted once that record exists (ErrOnboardingStepNotMet).
+// The step marker and the reward commit in one MultiUpdate guarded by the onboarding version,
+// so a step can never pay twice. Completing an already-done step returns ErrRewardAlreadyClaimed.
+func RpcCompleteOnboardingStep(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req CompleteOnboardingStepRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	step, ok := findOnboardingStep(req.StepID)
+	if !ok {
+		return "", errors.ErrInvalidInput
+	}
+
+	state, version, err := readOnboarding(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read onboarding for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+	if _, done := state.Completed[step.ID]; done {
+		return "", errors.ErrRewardAlreadyClaimed
+	}
+	reached, err := onboardingStepReached(ctx, nk, userID, step.ID)
+	if err != nil {
+		logger.Error("Failed to check onboarding step %s for user %s: %v", step.ID, userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+	if !reached {
+		return "", errors.ErrOnboardingStepNotMet
+	}
+	state.Completed[step.ID] = time.Now().Unix()
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	pending := NewPendingWrites()
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyOnboarding,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	})
+
+	result := notify.NewRewardPayload("onboarding")
+	result.ReasonKey = "reward.onboarding.step"
+	result.SetReasonArg("step", step.ID)
+
+	changeset := map[string]int64{}
+	if step.Gold > 0 {
+		changeset["gold"] = int64(step.Gold)
+	}
+	if step.Gems > 0 {
+		changeset["gems"] = int64(step.Gems)
+	}
+	if step.Treats > 0 {
+		changeset["treats"] = int64(step.Treats)
+	}
+	if len(changeset) > 0 {
+		pending.AddWalletUpdate(userID, changeset)
+		result.Wallet = &notify.WalletDelta{Gold: step.Gold, Gems: step.Gems, Treats: step.Treats}
+		result.SetWalletReasonArgs()
+	}
+	if step.LootboxTier != "" {
+		lootbox, writes, err := PrepareCreateLootbox(userID, step.LootboxTier)
+		if err != nil {
+			logger.Error("Failed to prepare onboarding lootbox for user %s: %v", userID, err)
+			return "", errors.ErrPrepareFailed
+		}
+		pending.AddStorageWrites(writes...)
+		result.Lootboxes = append(result.Lootboxes, notify.LootboxGrant{
+			ID:     lootbox.ID,
+			Tier:   lootbox.Tier,
+			Source: "onboarding",
+		})
+	}
+
+	// A version conflict means a concurrent completion of this or another step won; the client retries.
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Failed to commit onboarding step %s for user %s: %v", step.ID, userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"user":   userID,
+		"step":   step.ID,
+		"action": "complete_onboarding_step",
+	}).Info("Onboarding step completed")
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
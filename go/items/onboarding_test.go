@@ -0,0 +1,80 @@
+package items
+
+import (
+	"testing"
+
+	"block-server/errors"
+)
+
+const onboardingUser = "00000000-0000-0000-0000-000000000021"
+
+func withOnboardingSteps(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.Onboarding = []OnboardingStepConfig{
+			{ID: "intro"},
+			{ID: "first_match", Gold: 200},
+			{ID: "first_equip", Treats: 2},
+			{ID: "first_lootbox", Gems: 20},
+		}
+	})
+}
+
+func completeStep(nk *fakeNakama, stepID string) error {
+	_, err := RpcCompleteOnboardingStep(testContext(onboardingUser), testLogger{}, nil, nk, `{"step_id":"`+stepID+`"}`)
+	return err
+}
+
+func TestOnboardingStepPaysOnce(t *testing.T) {
+	withOnboardingSteps(t)
+	nk := newFakeNakama()
+
+	if err := completeStep(nk, "first_match"); err != errors.ErrOnboardingStepNotMet {
+		t.Fatalf("first_match with no history: err = %v, want ErrOnboardingStepNotMet", err)
+	}
+	if gold := nk.wallet(onboardingUser)["gold"]; gold != 0 {
+		t.Fatalf("gold = %d after a rejected step", gold)
+	}
+
+	nk.put(t, storageCollectionMatchHistory, "history", onboardingUser,
+		MatchHistoryDocument{Matches: []MatchHistoryEntry{{MatchID: "m1"}}})
+	if err := completeStep(nk, "first_match"); err != nil {
+		t.Fatalf("first_match: %v", err)
+	}
+	if err := completeStep(nk, "first_match"); err != errors.ErrRewardAlreadyClaimed {
+		t.Errorf("repeat first_match: err = %v, want ErrRewardAlreadyClaimed", err)
+	}
+	if gold := nk.wallet(onboardingUser)["gold"]; gold != 200 {
+		t.Errorf("gold = %d, want 200 paid once", gold)
+	}
+
+	// Steps without a server record are taken from the client.
+	if err := completeStep(nk, "intro"); err != nil {
+		t.Errorf("intro: %v", err)
+	}
+}
+
+func TestOnboardingStepsCheckServerState(t *testing.T) {
+	withOnboardingSteps(t)
+	nk := newFakeNakama()
+	nk.put(t, storageCollectionEquipment, storageKeyPet, onboardingUser, EquipmentData{ID: DefaultPetID})
+
+	if err := completeStep(nk, "first_equip"); err != errors.ErrOnboardingStepNotMet {
+		t.Errorf("first_equip with defaults equipped: err = %v, want ErrOnboardingStepNotMet", err)
+	}
+	if err := completeStep(nk, "first_lootbox"); err != errors.ErrOnboardingStepNotMet {
+		t.Errorf("first_lootbox with no box opened: err = %v, want ErrOnboardingStepNotMet", err)
+	}
+
+	nk.put(t, storageCollectionEquipment, storageKeyPet, onboardingUser, EquipmentData{ID: DefaultPetID + 1})
+	nk.put(t, storageCollectionProgression, ProgressionKeyFirstBoxOpened, onboardingUser, map[string]int64{"opened_at": 1})
+	if err := completeStep(nk, "first_equip"); err != nil {
+		t.Errorf("first_equip: %v", err)
+	}
+	if err := completeStep(nk, "first_lootbox"); err != nil {
+		t.Errorf("first_lootbox: %v", err)
+	}
+	wallet := nk.wallet(onboardingUser)
+	if wallet["treats"] != 2 || wallet["gems"] != 20 {
+		t.Errorf("wallet = %v, want treats=2 gems=20", wallet)
+	}
+}
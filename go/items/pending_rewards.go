@@ -0,0 +1,238 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	storageCollectionPendingRewards = "pending_rewards"
+
+	PendingReasonDeferred       = "deferred"        // granted on claim
+	PendingReasonDeliveryFailed = "delivery_failed" // already applied; only the notification was lost
+
+	// ActionClaimPendingReward deep-links the client to claim_pending_reward with ActionPayload = RewardID.
+	ActionClaimPendingReward = "claim_pending_reward"
+)
+
+// PendingReward is an inbox entry. Collection: pending_rewards, Key: RewardID.
+// Applied=false entries carry grants that are committed when the client claims them.
+type PendingReward struct {
+	Payload   *notify.RewardPayload `json:"payload"`
+	Reason    string                `json:"reason"`
+	Applied   bool                  `json:"applied"`
+	CreatedAt int64                 `json:"created_at"`
+}
+
+// StorePendingReward writes payload to the user's inbox, keyed by its RewardID.
+func StorePendingReward(ctx context.Context, nk runtime.NakamaModule, userID string, payload *notify.RewardPayload, reason string, applied bool) error {
+	if payload == nil || payload.RewardID == "" {
+		return errors.ErrInvalidInput
+	}
+	value, err := json.Marshal(PendingReward{
+		Payload:   payload,
+		Reason:    reason,
+		Applied:   applied,
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return errors.ErrMarshal
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionPendingRewards,
+		Key:             payload.RewardID,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// SendRewardOrStore notifies an already-applied reward, falling back to the inbox if the
+// notification fails so the client can still pull it. With economy.reward_bundle_window_ms set,
+// grant-only rewards arriving within the window are merged into one notification.
+func SendRewardOrStore(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payload *notify.RewardPayload) {
+	if window := rewardBundleWindow(); window > 0 && bundleReward(ctx, nk, logger, userID, payload, window) {
+		return
+	}
+	sendRewardNow(ctx, nk, logger, userID, payload)
+}
+
+// SendRewardsOrStore merges already-applied payloads into the first non-nil one and sends it
+// through SendRewardOrStore, so one action that yields several grants produces one toast.
+func SendRewardsOrStore(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payloads ...*notify.RewardPayload) {
+	var merged *notify.RewardPayload
+	for _, payload := range payloads {
+		if payload == nil {
+			continue
+		}
+		if merged == nil {
+			merged = payload
+			continue
+		}
+		merged.Merge(payload)
+	}
+	if merged != nil {
+		SendRewardOrStore(ctx, nk, logger, userID, merged)
+	}
+}
+
+func sendRewardNow(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payload *notify.RewardPayload) {
+	sendErr := notify.SendReward(ctx, nk, userID, payload)
+	if sendErr == nil {
+		return
+	}
+	logger.Warn("Failed to send reward %s to %s, storing in inbox: %v", payload.RewardID, userID, sendErr)
+	if err := StorePendingReward(ctx, nk, userID, payload, PendingReasonDeliveryFailed, true); err != nil {
+		logger.Error("Failed to store undelivered reward %s for %s: %v", payload.RewardID, userID, err)
+	}
+}
+
+// DeferReward stores an unapplied grant in the inbox and nudges the client to claim it.
+// The nudge carries no grants: nothing lands until the claim, so it must not show currency.
+// It is sent directly rather than through SendRewardOrStore since the entry is already in the inbox.
+func DeferReward(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payload *notify.RewardPayload) error {
+	if err := StorePendingReward(ctx, nk, userID, payload, PendingReasonDeferred, false); err != nil {
+		return err
+	}
+	nudge := notify.NewRewardPayload(payload.Source)
+	nudge.ReasonKey = "reward.pending.available"
+	nudge.Action = ActionClaimPendingReward
+	nudge.ActionPayload = payload.RewardID
+	if err := notify.SendReward(ctx, nk, userID, nudge); err != nil {
+		logger.Warn("Failed to notify deferred reward %s to %s: %v", payload.RewardID, userID, err)
+	}
+	return nil
+}
+
+// RpcGetPendingRewards lists the caller's inbox, oldest first.
+func RpcGetPendingRewards(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	objects, err := listAllStorage(ctx, nk, logger, userID, storageCollectionPendingRewards)
+	if err != nil {
+		logger.Error("Failed to list pending rewards for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	rewards := make([]PendingReward, 0, len(objects))
+	for _, obj := range objects {
+		var pr PendingReward
+		if err := json.Unmarshal([]byte(obj.Value), &pr); err != nil || pr.Payload == nil {
+			logger.Warn("Failed to unmarshal pending reward %s: %v", obj.Key, err)
+			continue
+		}
+		rewards = append(rewards, pr)
+	}
+	sort.Slice(rewards, func(i, j int) bool { return rewards[i].CreatedAt < rewards[j].CreatedAt })
+
+	respBytes, err := json.Marshal(rewards)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// RpcClaimPendingReward applies (if deferred) and removes one inbox entry.
+// Application goes through CommitRewardOnce, so a claim retried after a failed delete is a no-op.
+func RpcClaimPendingReward(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req struct {
+		RewardID string `json:"reward_id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if req.RewardID == "" {
+		return "", errors.ErrInvalidInput
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionPendingRewards,
+		Key:        req.RewardID,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+	if len(objects) == 0 {
+		return "", errors.ErrRewardAlreadyClaimed
+	}
+
+	var pr PendingReward
+	if err := json.Unmarshal([]byte(objects[0].Value), &pr); err != nil || pr.Payload == nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	if !pr.Applied {
+		pending, err := preparePendingRewardGrant(ctx, nk, logger, userID, pr.Payload)
+		if err != nil {
+			logger.Error("Failed to prepare pending reward %s for user %s: %v", req.RewardID, userID, err)
+			return "", errors.ErrPrepareFailed
+		}
+		if _, err := CommitRewardOnce(ctx, nk, logger, userID, pending); err != nil {
+			logger.Error("Failed to commit pending reward %s for user %s: %v", req.RewardID, userID, err)
+			return "", errors.ErrTransactionFailed
+		}
+	}
+
+	if err := nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+		Collection: storageCollectionPendingRewards,
+		Key:        req.RewardID,
+		UserID:     userID,
+		Version:    objects[0].Version,
+	}}); err != nil {
+		logger.Warn("Failed to delete claimed pending reward %s for user %s: %v", req.RewardID, userID, err)
+	}
+
+	respBytes, err := json.Marshal(pr.Payload)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// preparePendingRewardGrant turns a deferred payload's wallet and inventory deltas into writes.
+func preparePendingRewardGrant(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payload *notify.RewardPayload) (*PendingWrites, error) {
+	pending := NewPendingWrites()
+
+	if w := payload.Wallet; w != nil && (w.Gold > 0 || w.Gems > 0 || w.Treats > 0) {
+		pending.AddWalletUpdate(userID, map[string]int64{
+			"gold":   int64(w.Gold),
+			"gems":   int64(w.Gems),
+			"treats": int64(w.Treats),
+		})
+	}
+
+	if payload.Inventory != nil && len(payload.Inventory.Items) > 0 {
+		mutator := NewInventoryMutator()
+		for _, item := range payload.Inventory.Items {
+			mutator.AddItem(item.Type, item.ID)
+		}
+		invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
+		if err != nil {
+			return nil, err
+		}
+		pending.Merge(invPending)
+	}
+
+	// Carry the inbox payload's RewardID so CommitRewardOnce keys on it.
+	pending.Payload = payload
+	return pending, nil
+}
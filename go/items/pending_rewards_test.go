@@ -0,0 +1,127 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+
+	"block-server/notify"
+)
+
+func rewardNotifications(nk *fakeNakama) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, n := range nk.sent("") {
+		if n.Code == notify.CodeReward {
+			out = append(out, n.Content)
+		}
+	}
+	return out
+}
+
+func TestFailedRewardNotificationLandsInInbox(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+	nk.failNotifications = 1
+
+	payload := notify.NewRewardPayload("tournament")
+	payload.Wallet = &notify.WalletDelta{Gems: 10}
+	SendRewardOrStore(ctx, nk, testLogger{}, "u1", payload)
+
+	resp, err := RpcGetPendingRewards(ctx, testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetPendingRewards: %v", err)
+	}
+	var inbox []PendingReward
+	if err := json.Unmarshal([]byte(resp), &inbox); err != nil {
+		t.Fatalf("unmarshal inbox: %v", err)
+	}
+	if len(inbox) != 1 {
+		t.Fatalf("inbox has %d entries, want 1", len(inbox))
+	}
+	got := inbox[0]
+	if got.Payload.RewardID != payload.RewardID || got.Reason != PendingReasonDeliveryFailed || !got.Applied {
+		t.Errorf("inbox entry = %+v, want applied delivery_failed %s", got, payload.RewardID)
+	}
+
+	// Claiming an already-applied entry removes it without granting again.
+	if _, err := RpcClaimPendingReward(ctx, testLogger{}, nil, nk, `{"reward_id":"`+payload.RewardID+`"}`); err != nil {
+		t.Fatalf("RpcClaimPendingReward: %v", err)
+	}
+	if gems := nk.wallet("u1")["gems"]; gems != 0 {
+		t.Errorf("gems = %d after claiming a delivery-failed entry, want 0", gems)
+	}
+	if nk.count(storageCollectionPendingRewards, "u1") != 0 {
+		t.Error("claimed entry is still in the inbox")
+	}
+}
+
+func TestDeferRewardNudgeCarriesNoGrants(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	payload := notify.NewRewardPayload("match")
+	payload.Wallet = &notify.WalletDelta{Gold: 40}
+	if err := DeferReward(ctx, nk, testLogger{}, "u1", payload); err != nil {
+		t.Fatalf("DeferReward: %v", err)
+	}
+
+	sent := rewardNotifications(nk)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d reward notifications, want 1 nudge", len(sent))
+	}
+	nudge := sent[0]
+	if _, ok := nudge["wallet"]; ok {
+		t.Errorf("nudge carries a wallet delta before the claim: %v", nudge)
+	}
+	if _, ok := nudge["reason_args"]; ok {
+		t.Errorf("nudge carries reason args before the claim: %v", nudge)
+	}
+	if nudge["action"] != ActionClaimPendingReward || nudge["action_payload"] != payload.RewardID {
+		t.Errorf("nudge action = %v %v, want claim of %s", nudge["action"], nudge["action_payload"], payload.RewardID)
+	}
+	if gold := nk.wallet("u1")["gold"]; gold != 0 {
+		t.Fatalf("gold = %d before the claim, want 0", gold)
+	}
+
+	if _, err := RpcClaimPendingReward(ctx, testLogger{}, nil, nk, `{"reward_id":"`+payload.RewardID+`"}`); err != nil {
+		t.Fatalf("RpcClaimPendingReward: %v", err)
+	}
+	if gold := nk.wallet("u1")["gold"]; gold != 40 {
+		t.Errorf("gold = %d after the claim, want 40", gold)
+	}
+}
+
+func TestSendRewardsOrStoreSendsOneMergedReward(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	bonus := notify.NewRewardPayload("match")
+	bonus.Wallet = &notify.WalletDelta{Gold: 30}
+	streak := notify.NewRewardPayload("streak")
+	streak.Wallet = &notify.WalletDelta{Gold: 20, Gems: 1}
+	SendRewardsOrStore(ctx, nk, testLogger{}, "u1", bonus, nil, streak)
+
+	sent := rewardNotifications(nk)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d reward notifications, want 1", len(sent))
+	}
+	wallet, _ := sent[0]["wallet"].(map[string]interface{})
+	if wallet["gold"] != 50.0 || wallet["gems"] != 1.0 {
+		t.Errorf("merged wallet = %v, want gold 50 gems 1", wallet)
+	}
+
+	// A failed merged send lands in the inbox once, not per payload.
+	nk.failNotifications = 1
+	a := notify.NewRewardPayload("match")
+	a.Wallet = &notify.WalletDelta{Gold: 1}
+	b := notify.NewRewardPayload("streak")
+	b.Wallet = &notify.WalletDelta{Gold: 2}
+	SendRewardsOrStore(ctx, nk, testLogger{}, "u1", a, b)
+	if n := nk.count(storageCollectionPendingRewards, "u1"); n != 1 {
+		t.Errorf("inbox has %d entries after a failed merged send, want 1", n)
+	}
+
+	SendRewardsOrStore(ctx, nk, testLogger{}, "u1", nil, nil)
+	if n := len(rewardNotifications(nk)); n != 1 {
+		t.Errorf("sent %d notifications in total, want nothing for nil payloads", n)
+	}
+}
@@ -24,6 +24,14 @@ type PendingWrites struct {
 	WalletUpdates []*runtime.WalletUpdate
 	Payload       *notify.RewardPayload
 	Telemetry     []PendingTelemetry
+
+	// Withheld is what the daily earn cap clamped off this batch at commit, per currency.
+	Withheld map[string]int64
+
+	// WalletResults holds the MultiUpdate wallet results once the batch has committed.
+	WalletResults []*runtime.WalletUpdateResult
+
+	capDailyEarnings bool
 }
 
 // NewPendingWrites creates a new PendingWrites collector
@@ -40,6 +48,11 @@ func (pw *PendingWrites) AddStorageWrite(write *runtime.StorageWrite) {
 	pw.StorageWrites = append(pw.StorageWrites, write)
 }
 
+// AddStorageWrites adds several storage writes to the pending batch
+func (pw *PendingWrites) AddStorageWrites(writes ...*runtime.StorageWrite) {
+	pw.StorageWrites = append(pw.StorageWrites, writes...)
+}
+
 // AddWalletUpdate adds a wallet update to the pending batch
 func (pw *PendingWrites) AddWalletUpdate(userID string, changeset map[string]int64) {
 	pw.WalletUpdates = append(pw.WalletUpdates, &runtime.WalletUpdate{
@@ -69,7 +82,9 @@ func (pw *PendingWrites) AddWalletUpdate(userID string, changeset map[string]int
 	}
 }
 
-// AddWalletDeduction is a convenience method for deducting currency
+// AddWalletDeduction is a convenience method for deducting currency.
+// Callers' balance pre-checks are only for a friendly early error: the commit itself fails with
+// the matching insufficient-funds error when Nakama rejects a balance going negative.
 func (pw *PendingWrites) AddWalletDeduction(userID string, currency string, amount int64) {
 	pw.AddWalletUpdate(userID, map[string]int64{currency: -amount})
 }
@@ -82,6 +97,7 @@ func (pw *PendingWrites) Merge(other *PendingWrites) {
 	pw.StorageWrites = append(pw.StorageWrites, other.StorageWrites...)
 	pw.WalletUpdates = append(pw.WalletUpdates, other.WalletUpdates...)
 	pw.Telemetry = append(pw.Telemetry, other.Telemetry...)
+	pw.capDailyEarnings = pw.capDailyEarnings || other.capDailyEarnings
 
 	// Merge payloads
 	if other.Payload != nil {
@@ -101,29 +117,21 @@ func (pw *PendingWrites) MergePayload(other *notify.RewardPayload) {
 	if pw.Payload == nil {
 		pw.Payload = notify.NewRewardPayload("")
 	}
+	pw.Payload.Merge(other)
+}
 
-	if other.Wallet != nil {
-		if pw.Payload.Wallet == nil {
-			pw.Payload.Wallet = &notify.WalletDelta{}
-		}
-		pw.Payload.Wallet.Gold += other.Wallet.Gold
-		pw.Payload.Wallet.Gems += other.Wallet.Gems
-		pw.Payload.Wallet.Treats += other.Wallet.Treats
-	}
-
-	if other.Inventory != nil {
-		if pw.Payload.Inventory == nil {
-			pw.Payload.Inventory = &notify.InventoryDelta{Items: []notify.ItemGrant{}}
+// BalanceAfterCommit returns userID's committed balance of currency from WalletResults.
+func (pw *PendingWrites) BalanceAfterCommit(userID string, currency string) (int64, bool) {
+	for i := len(pw.WalletResults) - 1; i >= 0; i-- {
+		result := pw.WalletResults[i]
+		if result == nil || result.UserID != userID {
+			continue
 		}
-		pw.Payload.Inventory.Items = append(pw.Payload.Inventory.Items, other.Inventory.Items...)
-	}
-
-	if other.Progression != nil && len(other.Progression.Unlocks) > 0 {
-		if pw.Payload.Progression == nil {
-			pw.Payload.Progression = &notify.ProgressionDelta{}
+		if balance, ok := result.Updated[currency]; ok {
+			return balance, true
 		}
-		pw.Payload.Progression.Unlocks = append(pw.Payload.Progression.Unlocks, other.Progression.Unlocks...)
 	}
+	return 0, false
 }
 
 // IsEmpty returns true if no writes are pending
@@ -151,7 +159,7 @@ func BuildProgressionWrite(userID string, progressionKey string, itemID uint32,
 
 // BuildInventoryWrite creates a storage write for inventory data
 func BuildInventoryWrite(userID string, storageKey string, items []uint32, version string) (*runtime.StorageWrite, error) {
-	data := InventoryData{Items: items}
+	data := InventoryData{Items: normalizeItemIDs(items)}
 	value, err := json.Marshal(data)
 	if err != nil {
 		return nil, err
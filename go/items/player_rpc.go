@@ -12,6 +12,7 @@ import (
 	"block-server/errors"
 	"block-server/notify"
 
+	"github.com/heroiclabs/nakama-common/api"
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
@@ -23,7 +24,7 @@ func RpcCompleteOnboarding(ctx context.Context, logger runtime.Logger, db *sql.D
 
 	account, err := nk.AccountGetId(ctx, userID)
 	if err != nil {
-		return "", err
+		return "", errors.ErrCouldNotGetAccount
 	}
 
 	var metadata map[string]interface{}
@@ -39,14 +40,15 @@ func RpcCompleteOnboarding(ctx context.Context, logger runtime.Logger, db *sql.D
 
 	metadata["has_completed_onboarding"] = true
 	if err := nk.AccountUpdateId(ctx, userID, "", metadata, "", "", "", "", ""); err != nil {
-		return "", err
+		logger.Error("Failed to mark onboarding complete for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotUpdateAccount
 	}
 
 	pending := NewPendingWrites()
 
-	lootbox, lootboxWrite, err := PrepareCreateLootbox(userID, "standard")
+	lootbox, lootboxWrites, err := PrepareCreateLootbox(userID, "standard")
 	if err == nil {
-		pending.AddStorageWrite(lootboxWrite)
+		pending.AddStorageWrites(lootboxWrites...)
 		if pending.Payload == nil {
 			pending.Payload = notify.NewRewardPayload("onboarding")
 		}
@@ -57,7 +59,7 @@ func RpcCompleteOnboarding(ctx context.Context, logger runtime.Logger, db *sql.D
 	}
 
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
-		return "", err
+		return "", errors.ErrTransactionFailed
 	}
 
 	// Emit authoritative onboarding_completed telemetry
@@ -90,7 +92,7 @@ func RpcGetEquipment(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 		{Collection: storageCollectionEquipment, Key: storageKeyPieceStyle, UserID: userID},
 	}
 
-	objs, err := nk.StorageRead(ctx, reads)
+	objs, err := storageReadWithRetry(ctx, nk, logger, reads)
 	if err != nil {
 		logger.WithFields(map[string]interface{}{
 			"user":  userID,
@@ -100,28 +102,34 @@ func RpcGetEquipment(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 	}
 
 	for _, obj := range objs {
-		if obj == nil {
+		applyEquipmentObject(logger, userID, &equipped, obj)
+	}
+
+	// Read-only: a missing progression record stays at index 0 rather than being initialized here.
+	petProgKey := fmt.Sprintf("%s%d", ProgressionKeyPet, equipped.Pet)
+	classProgKey := fmt.Sprintf("%s%d", ProgressionKeyClass, equipped.Class)
+	progObjs, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{
+		{Collection: storageCollectionProgression, Key: petProgKey, UserID: userID},
+		{Collection: storageCollectionProgression, Key: classProgKey, UserID: userID},
+	})
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":  userID,
+			"error": err.Error(),
+		}).Warn("Equipment progression read failure")
+	}
+	for _, obj := range progObjs {
+		prog, err := UnmarshalJSON[ItemProgression](obj.Value)
+		if err != nil {
 			continue
 		}
-
-		var data EquipmentData
-		if err := json.Unmarshal([]byte(obj.Value), &data); err == nil {
-			switch obj.Key {
-			case storageKeyPet:
-				equipped.Pet = data.ID
-			case storageKeyClass:
-				equipped.Class = data.ID
-			case storageKeyBackground:
-				equipped.Background = data.ID
-			case storageKeyPieceStyle:
-				equipped.PieceStyle = data.ID
-			}
-		} else {
-			logger.WithFields(map[string]interface{}{
-				"user":  userID,
-				"key":   obj.Key,
-				"error": err.Error(),
-			}).Warn("Failed to unmarshal equipment data")
+		switch obj.Key {
+		case petProgKey:
+			equipped.PetAbility = prog.EquippedAbility
+			equipped.PetSprite = prog.EquippedSprite
+		case classProgKey:
+			equipped.ClassAbility = prog.EquippedAbility
+			equipped.ClassSprite = prog.EquippedSprite
 		}
 	}
 
@@ -137,6 +145,116 @@ func RpcGetEquipment(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 	return string(resp), nil
 }
 
+// applyEquipmentObject decodes one equipment storage object into the matching response field.
+func applyEquipmentObject(logger runtime.Logger, userID string, equipped *EquipmentResponse, obj *api.StorageObject) {
+	if obj == nil {
+		return
+	}
+
+	var data EquipmentData
+	if err := json.Unmarshal([]byte(obj.Value), &data); err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":  userID,
+			"key":   obj.Key,
+			"error": err.Error(),
+		}).Warn("Failed to unmarshal equipment data")
+		return
+	}
+
+	switch obj.Key {
+	case storageKeyPet:
+		equipped.Pet = data.ID
+	case storageKeyClass:
+		equipped.Class = data.ID
+	case storageKeyBackground:
+		equipped.Background = data.ID
+	case storageKeyPieceStyle:
+		equipped.PieceStyle = data.ID
+	}
+}
+
+// PlayerStateResponse bundles everything the home screen needs on cold start.
+type PlayerStateResponse struct {
+	Equipment EquipmentResponse `json:"equipment"`
+	Inventory InventoryResponse `json:"inventory"`
+	Wallet    map[string]int64  `json:"wallet"`
+}
+
+// RpcGetPlayerState returns equipment, inventory and wallet in one round trip:
+// a single StorageRead batch for all eight equipment/inventory keys plus one AccountGetId.
+func RpcGetPlayerState(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		logger.Error("No user ID found in context for get player state")
+		return "", errors.ErrNoUserIdFound
+	}
+
+	state := PlayerStateResponse{
+		Equipment: EquipmentResponse{
+			Pet:        DefaultPetID,
+			Class:      DefaultClassID,
+			Background: DefaultBackgroundID,
+			PieceStyle: DefaultPieceStyleID,
+		},
+		Inventory: InventoryResponse{
+			Pets:        make([]uint32, 0),
+			Classes:     make([]uint32, 0),
+			Backgrounds: make([]uint32, 0),
+			PieceStyles: make([]uint32, 0),
+		},
+		Wallet: make(map[string]int64),
+	}
+
+	keys := []string{storageKeyPet, storageKeyClass, storageKeyBackground, storageKeyPieceStyle}
+	reads := make([]*runtime.StorageRead, 0, len(keys)*2)
+	for _, key := range keys {
+		reads = append(reads,
+			&runtime.StorageRead{Collection: storageCollectionEquipment, Key: key, UserID: userID},
+			&runtime.StorageRead{Collection: storageCollectionInventory, Key: key, UserID: userID},
+		)
+	}
+
+	objs, err := storageReadWithRetry(ctx, nk, logger, reads)
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":  userID,
+			"error": err.Error(),
+		}).Error("Player state storage read failure")
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	for _, obj := range objs {
+		if obj == nil {
+			continue
+		}
+		switch obj.Collection {
+		case storageCollectionEquipment:
+			applyEquipmentObject(logger, userID, &state.Equipment, obj)
+		case storageCollectionInventory:
+			applyInventoryObject(logger, &state.Inventory, obj)
+		}
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	if err := json.Unmarshal([]byte(account.Wallet), &state.Wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	resp, err := json.Marshal(state)
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":  userID,
+			"error": err.Error(),
+		}).Error("Failed to marshal player state response")
+		return "", errors.ErrMarshal
+	}
+
+	return string(resp), nil
+}
+
 func RpcGetInventory(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	userID, err := GetUserIDFromContext(ctx, logger)
 	if err != nil {
@@ -172,12 +290,19 @@ func RpcGetProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 		return "", errors.ErrNoUserIdFound
 	}
 
+	var req ProgressionRequest
+	if payload != "" && payload != "{}" && payload != "null" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+	}
+
 	progression := ProgressionResponse{
 		Pets:    make(map[uint32]ItemProgression),
 		Classes: make(map[uint32]ItemProgression),
 	}
 
-	objects, err := listAllStorage(ctx, nk, logger, userID, storageCollectionProgression)
+	objects, nextCursor, err := listStorageFrom(ctx, nk, logger, userID, storageCollectionProgression, req.Cursor)
 	if err != nil {
 		logger.WithFields(map[string]interface{}{
 			"user":  userID,
@@ -185,6 +310,8 @@ func RpcGetProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 		}).Error("Progression storage list failure")
 		return "", errors.ErrProgressionUnavailable
 	}
+	progression.NextCursor = nextCursor
+	progression.HasMore = nextCursor != ""
 
 	dailyJourneyFound := false
 
@@ -197,31 +324,11 @@ func RpcGetProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 			if obj.Key == ProgressionKeyDailyJourney {
 				var dj DailyJourney
 				if err := json.Unmarshal([]byte(obj.Value), &dj); err == nil {
-					nowUTC := time.Now().UTC()
-					midnightUTC := time.Date(nowUTC.Year(), nowUTC.Month(), nowUTC.Day(), 0, 0, 0, 0, time.UTC)
-					
-					// Lazy Reset Check
-					if time.Unix(dj.ResetUnix, 0).UTC().Before(midnightUTC) {
-						dj.DailyMatches = 0
-						dj.DailyWarmupClaimed = false
-						dj.ExchangesLeft = DailyExchangeCap
-						dj.RoundTokens = 0
-						dj.ResetUnix = midnightUTC.Unix()
-						
-						// Save reset state back asynchronously or inline
-						go func(uID string, dJourney DailyJourney) {
-							val, _ := json.Marshal(dJourney)
-							_, _ = nk.StorageWrite(context.Background(), []*runtime.StorageWrite{
-								{
-									Collection:      storageCollectionProgression,
-									Key:             ProgressionKeyDailyJourney,
-									UserID:          uID,
-									Value:           string(val),
-									PermissionRead:  2,
-									PermissionWrite: 0,
-								},
-							})
-						}(userID, dj)
+					// Lazy reset, saved back with the listed version so it can't clobber a concurrent match.
+					if resetDailyJourneyIfStale(&dj, utcMidnight(time.Now())) {
+						go func(uID, version string, dJourney DailyJourney) {
+							_, _ = nk.StorageWrite(context.Background(), []*runtime.StorageWrite{dailyJourneyWrite(uID, &dJourney, version)})
+						}(userID, obj.Version, dj)
 					}
 					
 					progression.DailyJourney = &DailyJourneyResponse{
@@ -287,9 +394,10 @@ func RpcGetProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 		}
 	}
 
-	if !dailyJourneyFound {
+	// Only a complete listing proves the daily journey is missing; a partial one may just not reach it.
+	if !dailyJourneyFound && req.Cursor == "" && !progression.HasMore {
 		nowUTC := time.Now().UTC()
-		midnightUTC := time.Date(nowUTC.Year(), nowUTC.Month(), nowUTC.Day(), 0, 0, 0, 0, time.UTC)
+		midnightUTC := utcMidnight(nowUTC)
 		dj := DailyJourney{
 			DailyMatches:       0,
 			DailyWarmupClaimed: false,
@@ -331,6 +439,34 @@ func RpcGetProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, n
 	return string(resp), nil
 }
 
+// RpcVerifyProgression runs progression verification for the caller and returns the repair report,
+// so support can ask a player to self-heal missing progression records or stale inventory entries.
+func RpcVerifyProgression(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	report, err := VerifyAndFixUserProgression(ctx, nk, logger, userID)
+	if isPartialCompletion(err) {
+		// The repairs so far are applied; report them and let the client run it again.
+		logger.Warn("Progression verification for user %s stopped early: %v", userID, err)
+	} else if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":   userID,
+			"error":  err.Error(),
+			"action": "verify_progression",
+		}).Error("Progression verification failed")
+		return "", errors.ErrInternalError
+	}
+
+	resp, err := json.Marshal(report)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(resp), nil
+}
+
 func RpcEquipPetAbility(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	userID, err := GetUserIDFromContext(ctx, logger)
 	if err != nil {
@@ -367,6 +503,42 @@ func RpcEquipClassAbility(ctx context.Context, logger runtime.Logger, db *sql.DB
 	return `{"success": true}`, nil
 }
 
+func RpcEquipPetSprite(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		logger.Error("No user ID found in context for pet sprite equip")
+		return "", errors.ErrNoUserIdFound
+	}
+
+	if err := EquipSprite(ctx, logger, nk, storageKeyPet, payload); err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":   userID,
+			"error":  err.Error(),
+			"action": "equip_pet_sprite",
+		}).Error("Failed to equip pet sprite")
+		return "", errors.ErrCouldNotEquipSprite
+	}
+	return `{"success": true}`, nil
+}
+
+func RpcEquipClassSprite(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		logger.Error("No user ID found in context for class sprite equip")
+		return "", errors.ErrNoUserIdFound
+	}
+
+	if err := EquipSprite(ctx, logger, nk, storageKeyClass, payload); err != nil {
+		logger.WithFields(map[string]interface{}{
+			"user":   userID,
+			"error":  err.Error(),
+			"action": "equip_class_sprite",
+		}).Error("Failed to equip class sprite")
+		return "", errors.ErrCouldNotEquipSprite
+	}
+	return `{"success": true}`, nil
+}
+
 // equip items
 func RpcEquipPet(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
 	userID, err := GetUserIDFromContext(ctx, logger)
@@ -536,8 +708,20 @@ func RpcUsePetTreat(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 	// Deduct from dynamic cost currency in one wallet write
 	pending.AddWalletDeduction(userID, costCurrency, costAmount)
 
+	// Trees priced in gold don't spend treats, so they don't count toward treat quests.
+	if costCurrency == "treats" {
+		if questPending, questErr := PrepareQuestProgress(ctx, nk, logger, userID, []QuestEvent{{Stat: QuestStatPetTreatsUsed, Amount: int(costAmount)}}); questErr != nil {
+			logger.Warn("Failed to prepare quest progress for user %s: %v", userID, questErr)
+		} else if questPending != nil {
+			pending.Merge(questPending)
+		}
+	}
+
 	// Commit all writes atomically via MultiUpdate
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return "", err
+		}
 		logger.WithFields(map[string]interface{}{
 			"user":   userID,
 			"petID":  req.PetID,
@@ -554,9 +738,17 @@ func RpcUsePetTreat(ctx context.Context, logger runtime.Logger, db *sql.DB, nk r
 	}
 	result.Source = "pet_treat"
 	result.ReasonKey = "reward.pet_treat.used"
+	result.SetReasonArgInt(notify.ReasonArgXP, int(xpAmount))
+	if balance, ok := pending.BalanceAfterCommit(userID, costCurrency); ok {
+		if result.Meta == nil {
+			result.Meta = &notify.RewardMeta{}
+		}
+		result.Meta.WalletBalances = map[string]int64{costCurrency: balance}
+	}
 
 	if newLevel > 0 && result.Progression != nil {
 		result.Progression.NewPetLevel = notify.IntPtr(newLevel)
+		result.SetReasonArgInt(notify.ReasonArgLevel, newLevel)
 	}
 
 	logger.WithFields(map[string]interface{}{
@@ -674,6 +866,9 @@ func RpcUseGoldForClassXP(ctx context.Context, logger runtime.Logger, db *sql.DB
 
 	// Commit all writes atomically via MultiUpdate
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return "", err
+		}
 		logger.WithFields(map[string]interface{}{
 			"user":     userID,
 			"classID":  req.ClassID,
@@ -692,9 +887,11 @@ func RpcUseGoldForClassXP(ctx context.Context, logger runtime.Logger, db *sql.DB
 	}
 	result.Source = "class_training"
 	result.ReasonKey = "reward.class_training.complete"
+	result.SetReasonArgInt(notify.ReasonArgXP, int(xpAmount))
 
 	if newLevel > 0 && result.Progression != nil {
 		result.Progression.NewClassLevel = notify.IntPtr(newLevel)
+		result.SetReasonArgInt(notify.ReasonArgLevel, newLevel)
 	}
 
 	logger.WithFields(map[string]interface{}{
@@ -1008,7 +1205,7 @@ func RpcClaimAllProgressionRewards(ctx context.Context, logger runtime.Logger, d
 	// Read current progression first to know what levels to claim
 	prog, err := GetItemProgression(ctx, nk, logger, userID, progressionKey, req.ItemID)
 	if err != nil {
-		return "", err
+		return "", errors.ErrProgressionUnavailable
 	}
 
 	var levelsToClaim []int
@@ -1284,25 +1481,9 @@ func RpcDeleteAccount(ctx context.Context, logger runtime.Logger, db *sql.DB, nk
 	}
 
 	for _, col := range collections {
-		objects, _, err := nk.StorageList(ctx, userID, userID, col, 100, "")
-		if err != nil {
-			logger.Warn("Failed to list storage for collection %s during account deletion: %v", col, err)
-			continue
-		}
-
-		var deletes []*runtime.StorageDelete
-		for _, o := range objects {
-			deletes = append(deletes, &runtime.StorageDelete{
-				Collection: col,
-				Key:        o.Key,
-				UserID:     userID,
-			})
-		}
-
-		if len(deletes) > 0 {
-			if err := nk.StorageDelete(ctx, deletes); err != nil {
-				logger.Warn("Failed to delete storage objects in collection %s: %v", col, err)
-			}
+		// Pages through the whole collection; a single 100-object list left progression behind.
+		if _, err := deleteUserCollection(ctx, nk, logger, userID, col); err != nil {
+			logger.Warn("Failed to delete storage objects in collection %s during account deletion: %v", col, err)
 		}
 	}
 
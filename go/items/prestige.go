@@ -0,0 +1,134 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// PrestigeRewardConfig is economy.prestige_reward in items.json, granted on every prestige.
+type PrestigeRewardConfig struct {
+	Gems        int    `json:"gems"`
+	LootboxTier string `json:"lootbox_tier"`
+}
+
+type PrestigeRequest struct {
+	ItemType string `json:"item_type"` // "pets" (storageKeyPet) or "classes" (storageKeyClass)
+	ItemID   uint32 `json:"item_id"`
+}
+
+// RpcPrestigeItem resets a max-level pet or class to level 1 and bumps its PrestigeLevel.
+// Unlocked abilities, sprites and claimed tiers are kept, so re-levelling never re-grants tier rewards.
+func RpcPrestigeItem(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req PrestigeRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	var progressionKey string
+	switch req.ItemType {
+	case storageKeyPet:
+		progressionKey = ProgressionKeyPet
+	case storageKeyClass:
+		progressionKey = ProgressionKeyClass
+	default:
+		return "", errors.ErrInvalidItemType
+	}
+	if !ValidateItemExists(req.ItemType, req.ItemID) {
+		return "", errors.ErrInvalidItemID
+	}
+
+	owned, err := IsItemOwned(ctx, nk, userID, req.ItemID, req.ItemType)
+	if err != nil {
+		return "", errors.ErrFailedCheckOwnership
+	}
+	if !owned {
+		return "", errors.ErrNotOwned
+	}
+
+	treeName, err := GetLevelTreeName(req.ItemType, req.ItemID)
+	if err != nil {
+		return "", errors.ErrInvalidLevelTree
+	}
+	tree, exists := GetLevelTree(treeName)
+	if !exists {
+		return "", errors.ErrInvalidLevelTree
+	}
+
+	prog, progWrite, err := PrepareProgressionUpdate(ctx, nk, logger, userID, progressionKey, req.ItemID, func(prog *ItemProgression) error {
+		level, err := CalculateLevel(treeName, prog.Exp)
+		if err != nil {
+			return err
+		}
+		if level < tree.MaxLevel {
+			return errors.ErrNotMaxLevel
+		}
+		prog.Level = 1
+		prog.Exp = 0
+		prog.PrestigeLevel++
+		return nil
+	})
+	if err == errors.ErrNotMaxLevel {
+		return "", err
+	}
+	if err != nil || progWrite == nil {
+		logger.Error("Failed to prepare prestige for user %s %s %d: %v", userID, req.ItemType, req.ItemID, err)
+		return "", errors.ErrPrepareFailed
+	}
+
+	pending := NewPendingWrites()
+	pending.AddStorageWrite(progWrite)
+
+	result := notify.NewRewardPayload("prestige")
+	result.ReasonKey = "reward.prestige.complete"
+	result.SetReasonArgInt("prestige", prog.PrestigeLevel)
+
+	reward := GetEconomyConfig().PrestigeReward
+	if reward.Gems > 0 {
+		pending.AddWalletUpdate(userID, map[string]int64{"gems": int64(reward.Gems)})
+		result.Wallet = &notify.WalletDelta{Gems: reward.Gems}
+		result.SetWalletReasonArgs()
+	}
+	if reward.LootboxTier != "" {
+		lootbox, writes, err := PrepareCreateLootbox(userID, reward.LootboxTier)
+		if err != nil {
+			logger.Error("Failed to prepare prestige lootbox for user %s: %v", userID, err)
+			return "", errors.ErrPrepareFailed
+		}
+		pending.AddStorageWrites(writes...)
+		result.Lootboxes = append(result.Lootboxes, notify.LootboxGrant{
+			ID:     lootbox.ID,
+			Tier:   lootbox.Tier,
+			Source: "prestige",
+		})
+	}
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Failed to commit prestige for user %s: %v", userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"user":     userID,
+		"itemType": req.ItemType,
+		"itemID":   req.ItemID,
+		"prestige": prog.PrestigeLevel,
+		"action":   "prestige_item",
+	}).Info("Item prestiged")
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"block-server/errors"
@@ -69,6 +70,83 @@ func SaveItemProgression(ctx context.Context, nk runtime.NakamaModule, logger ru
 	return err
 }
 
+// bestItemLevel is the high-water mark of a user's item levels under one progression prefix,
+// so item catch-up needs a single read. Prestige doesn't lower it.
+type bestItemLevel struct {
+	Level int `json:"level"`
+}
+
+// bestItemLevelKey is e.g. "best_pet_level" for ProgressionKeyPet.
+func bestItemLevelKey(keyPrefix string) string {
+	return "best_" + strings.TrimSuffix(keyPrefix, "_") + "_level"
+}
+
+// readBestItemLevel returns the user's highest item level under keyPrefix and the record version.
+// A missing record is backfilled from highestItemLevel once and returned with version "*".
+func readBestItemLevel(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, keyPrefix string) (int, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        bestItemLevelKey(keyPrefix),
+		UserID:     userID,
+	}})
+	if err != nil {
+		return 0, "", err
+	}
+	if len(objects) > 0 {
+		var best bestItemLevel
+		if err := json.Unmarshal([]byte(objects[0].Value), &best); err != nil {
+			return 0, "", err
+		}
+		return best.Level, objects[0].Version, nil
+	}
+	level, err := highestItemLevel(ctx, nk, logger, userID, keyPrefix)
+	if err != nil {
+		return 0, "", err
+	}
+	return level, "*", nil
+}
+
+// bestItemLevelWrite stores level as the high-water mark under keyPrefix.
+func bestItemLevelWrite(userID string, keyPrefix string, level int, version string) *runtime.StorageWrite {
+	value, _ := json.Marshal(bestItemLevel{Level: level})
+	return &runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             bestItemLevelKey(keyPrefix),
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}
+}
+
+// highestItemLevel returns the highest stored level across the user's progressions under keyPrefix
+// (e.g. every pet). Keys whose suffix isn't an item ID are ignored. It lists the whole collection,
+// so it only backfills the bestItemLevel record.
+func highestItemLevel(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, keyPrefix string) (int, error) {
+	objects, err := listAllStorage(ctx, nk, logger, userID, storageCollectionProgression)
+	if err != nil {
+		return 0, err
+	}
+	highest := 0
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Key, keyPrefix) {
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.TrimPrefix(obj.Key, keyPrefix), 10, 32); err != nil {
+			continue
+		}
+		var prog ItemProgression
+		if err := json.Unmarshal([]byte(obj.Value), &prog); err != nil {
+			continue
+		}
+		if prog.Level > highest {
+			highest = prog.Level
+		}
+	}
+	return highest, nil
+}
+
 // Reads progression, applies update func, and returns a storage write.
 // Does not commit; caller must collect and execute via MultiUpdate.
 func PrepareProgressionUpdate(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger,
@@ -195,7 +273,23 @@ type ProgressionVerificationReport struct {
 	PetRepairs       map[uint32]string `json:"pet_repairs"`
 	ClassRepairs     map[uint32]string `json:"class_repairs"`
 	TotalFixed       int               `json:"total_fixed"`
+	RemovedInvalid   int               `json:"removed_invalid"` // Inventory items no longer in game data
 	VerificationTime time.Time         `json:"verification_time"`
+	// Partial is set when verification stopped early; the repairs listed were applied, the rest weren't checked.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// tally recomputes TotalFixed and RemovedInvalid from the repair maps.
+func (r *ProgressionVerificationReport) tally() {
+	r.TotalFixed = len(r.PetRepairs) + len(r.ClassRepairs)
+	r.RemovedInvalid = 0
+	for _, repairs := range []map[uint32]string{r.PetRepairs, r.ClassRepairs} {
+		for _, action := range repairs {
+			if action == "removed_invalid_item" {
+				r.RemovedInvalid++
+			}
+		}
+	}
 }
 
 // Verification logging helpers
@@ -232,6 +326,12 @@ func VerifyAndFixUserProgression(ctx context.Context, nk runtime.NakamaModule, l
 	}
 
 	petFixes, err := verifyAndFixItemProgression(ctx, nk, logger, userID, storageKeyPet, inventory.Pets, existingProgression.Pets, ProgressionKeyPet)
+	if isPartialCompletion(err) {
+		report.PetRepairs = petFixes
+		report.Partial = true
+		report.tally()
+		return report, err
+	}
 	if err != nil {
 		logVerificationIssue(ctx, logger, "error", "Failed to verify pet progression",
 			"pet", 0, userID, "verify_pet_progression", err)
@@ -240,6 +340,12 @@ func VerifyAndFixUserProgression(ctx context.Context, nk runtime.NakamaModule, l
 	}
 
 	classFixes, err := verifyAndFixItemProgression(ctx, nk, logger, userID, storageKeyClass, inventory.Classes, existingProgression.Classes, ProgressionKeyClass)
+	if isPartialCompletion(err) {
+		report.ClassRepairs = classFixes
+		report.Partial = true
+		report.tally()
+		return report, err
+	}
 	if err != nil {
 		logVerificationIssue(ctx, logger, "error", "Failed to verify class progression",
 			"class", 0, userID, "verify_class_progression", err)
@@ -247,7 +353,7 @@ func VerifyAndFixUserProgression(ctx context.Context, nk runtime.NakamaModule, l
 		report.ClassRepairs = classFixes
 	}
 
-	report.TotalFixed = len(report.PetRepairs) + len(report.ClassRepairs)
+	report.tally()
 
 	if report.TotalFixed > 0 {
 		LogWithUser(ctx, logger, "info", "Progression verification completed with repairs", map[string]interface{}{
@@ -274,7 +380,11 @@ func verifyAndFixItemProgression(ctx context.Context, nk runtime.NakamaModule, l
 		ItemID         uint32
 	}
 
-	for _, itemID := range inventoryItems {
+	for i, itemID := range inventoryItems {
+		// Each item may issue its own storage write; stop between items once the caller is gone.
+		if err := checkContext(ctx, "verify "+itemType+" progression", i, len(inventoryItems)); err != nil {
+			return repairs, err
+		}
 		if _, exists := existingProgression[itemID]; !exists {
 			if !ValidateItemExists(itemType, itemID) {
 				// Remove invalid item from inventory
@@ -403,9 +513,21 @@ func verifyAndFixItemProgression(ctx context.Context, nk runtime.NakamaModule, l
 				}
 			}
 
+			// Config may have shrunk the item's ability/sprite counts since these were unlocked.
+			clamped := false
+			if abilityCount, spriteCount, ok := itemUnlockCounts(itemType, itemID); ok {
+				clamped = clampUnlockedIndices(&prog, abilityCount, spriteCount)
+				needsSave = needsSave || clamped
+			}
+
 			if needsSave {
 				if err := SaveItemProgression(ctx, nk, logger, userID, progressionKeyPrefix, itemID, &prog); err != nil {
 					repairs[itemID] = "failed_to_repair_missing_unlocks"
+				} else if clamped {
+					repairs[itemID] = "clamped_unlocks_to_item_counts"
+					logVerificationIssue(ctx, logger, "warn",
+						fmt.Sprintf("Clamped unlocked abilities/sprites for %s ID %d to current item counts", itemType, itemID),
+						itemType, itemID, userID, "clamped_unlock_counts", nil)
 				} else {
 					repairs[itemID] = "restored_missing_unclaimed_tiers"
 					logVerificationIssue(ctx, logger, "info",
@@ -418,6 +540,9 @@ func verifyAndFixItemProgression(ctx context.Context, nk runtime.NakamaModule, l
 
 	// Use optimized batch operation to create all missing progression records efficiently
 	if len(progressionRecords) > 0 {
+		if err := checkContext(ctx, "initialize "+itemType+" progression", 0, len(progressionRecords)); err != nil {
+			return repairs, err
+		}
 		if err := BatchInitializeProgression(ctx, nk, logger, userID, progressionRecords); err != nil {
 			logVerificationIssue(ctx, logger, "error",
 				fmt.Sprintf("Failed to initialize missing progression records for %s", itemType),
@@ -440,3 +565,58 @@ func verifyAndFixItemProgression(ctx context.Context, nk runtime.NakamaModule, l
 
 	return repairs, nil
 }
+
+// itemUnlockCounts returns how many abilities and sprites the item currently defines.
+func itemUnlockCounts(itemType string, itemID uint32) (int, int, bool) {
+	switch itemType {
+	case storageKeyPet:
+		if pet, ok := GetPet(itemID); ok {
+			return len(pet.AbilityIDs), pet.SpriteCount, true
+		}
+	case storageKeyClass:
+		if class, ok := GetClass(itemID); ok {
+			return len(class.AbilityIDs), class.SpriteCount, true
+		}
+	}
+	return 0, 0, false
+}
+
+// clampUnlockedIndices drops unlocked indices beyond the item's counts and resets out-of-range
+// equipped indices to the default (0). Reports whether anything changed.
+func clampUnlockedIndices(prog *ItemProgression, abilityCount, spriteCount int) bool {
+	changed := false
+
+	abilities := prog.UnlockedAbilityIndices[:0]
+	for _, idx := range prog.UnlockedAbilityIndices {
+		if idx >= 0 && int(idx) < abilityCount {
+			abilities = append(abilities, idx)
+		} else {
+			changed = true
+		}
+	}
+	prog.UnlockedAbilityIndices = abilities
+
+	sprites := prog.UnlockedSpriteIndices[:0]
+	for _, idx := range prog.UnlockedSpriteIndices {
+		if int(idx) < spriteCount {
+			sprites = append(sprites, idx)
+		} else {
+			changed = true
+		}
+	}
+	prog.UnlockedSpriteIndices = sprites
+
+	if prog.EquippedAbility < 0 || prog.EquippedAbility >= abilityCount {
+		if prog.EquippedAbility != 0 {
+			prog.EquippedAbility = 0
+			changed = true
+		}
+	}
+	if prog.EquippedSprite < 0 || prog.EquippedSprite >= spriteCount {
+		if prog.EquippedSprite != 0 {
+			prog.EquippedSprite = 0
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,151 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestVerifyClampsOverCountUnlocks(t *testing.T) {
+	petID := firstPetID(t)
+	pet, _ := GetPet(petID)
+	abilityCount, spriteCount := len(pet.AbilityIDs), pet.SpriteCount
+	nk := newFakeNakama()
+	key := ProgressionKeyPet + strconv.Itoa(int(petID))
+	nk.put(t, storageCollectionInventory, storageKeyPet, "u1", InventoryData{Items: []uint32{petID}})
+	nk.put(t, storageCollectionProgression, key, "u1", ItemProgression{
+		Level:                  1,
+		UnlockedAbilityIndices: []int32{0, int32(abilityCount), int32(abilityCount + 5)},
+		UnlockedSpriteIndices:  []uint32{0, uint32(spriteCount + 3)},
+		EquippedAbility:        abilityCount + 5,
+		EquippedSprite:         spriteCount + 3,
+		TierStates:             map[string]TierState{},
+	})
+
+	report, err := VerifyAndFixUserProgression(testContext("u1"), nk, testLogger{}, "u1")
+	if err != nil {
+		t.Fatalf("VerifyAndFixUserProgression: %v", err)
+	}
+	if got := report.PetRepairs[petID]; got != "clamped_unlocks_to_item_counts" {
+		t.Errorf("repair = %q, want clamped_unlocks_to_item_counts", got)
+	}
+
+	var prog ItemProgression
+	nk.get(t, storageCollectionProgression, key, "u1", &prog)
+	for _, idx := range prog.UnlockedAbilityIndices {
+		if int(idx) >= abilityCount {
+			t.Errorf("ability index %d kept, pet has %d abilities", idx, abilityCount)
+		}
+	}
+	for _, idx := range prog.UnlockedSpriteIndices {
+		if int(idx) >= spriteCount {
+			t.Errorf("sprite index %d kept, pet has %d sprites", idx, spriteCount)
+		}
+	}
+	if prog.EquippedAbility != 0 || prog.EquippedSprite != 0 {
+		t.Errorf("equipped ability %d sprite %d, want both reset to 0", prog.EquippedAbility, prog.EquippedSprite)
+	}
+
+	// A second pass finds nothing left to fix.
+	report, err = VerifyAndFixUserProgression(testContext("u1"), nk, testLogger{}, "u1")
+	if err != nil {
+		t.Fatalf("second pass: %v", err)
+	}
+	if _, ok := report.PetRepairs[petID]; ok {
+		t.Errorf("second pass repaired again: %v", report.PetRepairs)
+	}
+}
+
+func TestVerifyProgressionRPCReturnsPartialReport(t *testing.T) {
+	// Two pets missing from game data: each is removed with its own write.
+	invalid := []uint32{900001, 900002}
+	for _, id := range invalid {
+		if _, ok := GetPet(id); ok {
+			t.Skipf("pet %d exists", id)
+		}
+	}
+	nk := newFakeNakama()
+	nk.put(t, storageCollectionInventory, storageKeyPet, "u1", InventoryData{Items: invalid})
+
+	// The client drops during the first removal.
+	ctx, cancel := context.WithCancel(testContext("u1"))
+	defer cancel()
+	nk.storageWriteHook, nk.multiUpdateHook = cancel, cancel
+
+	resp, err := RpcVerifyProgression(ctx, testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcVerifyProgression: %v", err)
+	}
+	var report ProgressionVerificationReport
+	if err := json.Unmarshal([]byte(resp), &report); err != nil {
+		t.Fatalf("unmarshal report: %v", err)
+	}
+	if !report.Partial {
+		t.Fatal("report of an interrupted verification is not marked partial")
+	}
+	if report.RemovedInvalid != 1 {
+		t.Fatalf("report = %+v, want the one removal applied before the stop", report)
+	}
+}
+
+// seedProgressionFiller writes n progression records that sort before every pet key.
+func seedProgressionFiller(t *testing.T, nk *fakeNakama, userID string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		nk.put(t, storageCollectionProgression, fmt.Sprintf("a_filler_%04d", i), userID, map[string]int{"n": i})
+	}
+}
+
+func getProgression(t *testing.T, nk *fakeNakama, userID, payload string) ProgressionResponse {
+	t.Helper()
+	resp, err := RpcGetProgression(testContext(userID), testLogger{}, nil, nk, payload)
+	if err != nil {
+		t.Fatalf("RpcGetProgression: %v", err)
+	}
+	var out ProgressionResponse
+	if err := json.Unmarshal([]byte(resp), &out); err != nil {
+		t.Fatalf("unmarshal progression: %v", err)
+	}
+	return out
+}
+
+func TestGetProgressionListsPastFirstPage(t *testing.T) {
+	petID := firstPetID(t)
+	nk := newFakeNakama()
+	seedProgressionFiller(t, nk, "u1", 150)
+	nk.put(t, storageCollectionProgression, ProgressionKeyPet+strconv.Itoa(int(petID)), "u1", ItemProgression{Level: 3})
+
+	out := getProgression(t, nk, "u1", "")
+	if out.HasMore || out.NextCursor != "" {
+		t.Fatalf("has_more = %v, next_cursor = %q for 151 records, want a complete listing", out.HasMore, out.NextCursor)
+	}
+	if out.Pets[petID].Level != 3 {
+		t.Fatalf("pet %d missing past the first 100 records", petID)
+	}
+}
+
+func TestGetProgressionContinuesFromCursor(t *testing.T) {
+	petID := firstPetID(t)
+	nk := newFakeNakama()
+	seedProgressionFiller(t, nk, "u1", listAllStorageMaxPages*100+50)
+	nk.put(t, storageCollectionProgression, ProgressionKeyPet+strconv.Itoa(int(petID)), "u1", ItemProgression{Level: 3})
+
+	first := getProgression(t, nk, "u1", "")
+	if !first.HasMore || first.NextCursor == "" {
+		t.Fatal("a listing cut at the page cap did not report more records")
+	}
+	if _, ok := first.Pets[petID]; ok {
+		t.Fatal("pet listed before the cursor reached it")
+	}
+
+	payload, _ := json.Marshal(ProgressionRequest{Cursor: first.NextCursor})
+	rest := getProgression(t, nk, "u1", string(payload))
+	if rest.HasMore {
+		t.Fatal("continued listing still reports more records")
+	}
+	if rest.Pets[petID].Level != 3 {
+		t.Fatalf("pet %d missing from the continued listing", petID)
+	}
+}
@@ -0,0 +1,381 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	storageCollectionQuests = "quests"
+	storageKeyQuestState    = "state"
+
+	QuestPeriodDaily  = "daily"
+	QuestPeriodWeekly = "weekly"
+
+	// Quest stat keys. Templates in items.json reference these by name.
+	QuestStatMatchesPlayed = "matches_played"
+	QuestStatMatchesWon    = "matches_won"
+	QuestStatPetTreatsUsed = "pet_treats_used"
+)
+
+// QuestTemplate is one entry of quests.templates in items.json.
+// ClassID restricts progress to events played with that class (e.g. "win 3 with class 2").
+type QuestTemplate struct {
+	ID      string  `json:"id"`
+	Period  string  `json:"period"`
+	Stat    string  `json:"stat"`
+	Target  int     `json:"target"`
+	ClassID *uint32 `json:"class_id,omitempty"`
+	Reward  struct {
+		Gold   int `json:"gold,omitempty"`
+		Gems   int `json:"gems,omitempty"`
+		Treats int `json:"treats,omitempty"`
+	} `json:"reward"`
+}
+
+// QuestsConfig is the "quests" block of items.json.
+type QuestsConfig struct {
+	DailyCount  int             `json:"daily_count"`
+	WeeklyCount int             `json:"weekly_count"`
+	Templates   []QuestTemplate `json:"templates"`
+}
+
+// QuestProgress tracks one rolled quest.
+type QuestProgress struct {
+	ID       string `json:"id"`
+	Progress int    `json:"progress"`
+	Claimed  bool   `json:"claimed"`
+}
+
+// QuestSet is the rolled quests for one period. ResetUnix is the boundary the set was rolled at.
+type QuestSet struct {
+	ResetUnix int64           `json:"reset_unix"`
+	Quests    []QuestProgress `json:"quests"`
+}
+
+// QuestState is the per-user quest document.
+// Collection: quests, Key: "state". OCC-protected via Version.
+type QuestState struct {
+	Daily   QuestSet `json:"daily"`
+	Weekly  QuestSet `json:"weekly"`
+	Version string   `json:"-"`
+}
+
+// QuestEvent is one progress increment. ClassID is the class equipped for match events (0 otherwise).
+type QuestEvent struct {
+	Stat    string
+	Amount  int
+	ClassID uint32
+}
+
+var questsConfig *QuestsConfig
+
+// GetQuestsConfig returns the loaded quest config with defaults applied.
+func GetQuestsConfig() *QuestsConfig {
+	cfg := QuestsConfig{}
+	gameDataMu.RLock()
+	if questsConfig != nil {
+		cfg = *questsConfig
+	}
+	gameDataMu.RUnlock()
+	if cfg.DailyCount <= 0 {
+		cfg.DailyCount = 3
+	}
+	if cfg.WeeklyCount <= 0 {
+		cfg.WeeklyCount = 2
+	}
+	return &cfg
+}
+
+func getQuestTemplate(id string) (QuestTemplate, bool) {
+	for _, t := range GetQuestsConfig().Templates {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return QuestTemplate{}, false
+}
+
+// rollQuestSet picks up to count random templates for the period.
+// rng is injected so a fixed seed gives a reproducible set.
+func rollQuestSet(rng *rand.Rand, period string, count int, reset time.Time) QuestSet {
+	var pool []QuestTemplate
+	for _, t := range GetQuestsConfig().Templates {
+		if t.Period == period && t.Target > 0 {
+			pool = append(pool, t)
+		}
+	}
+	set := QuestSet{ResetUnix: reset.Unix(), Quests: make([]QuestProgress, 0, count)}
+	for _, i := range rng.Perm(len(pool)) {
+		if len(set.Quests) >= count {
+			break
+		}
+		set.Quests = append(set.Quests, QuestProgress{ID: pool[i].ID})
+	}
+	return set
+}
+
+// refreshQuestSets re-rolls any set whose boundary has passed. Daily sets use the same
+// midnight-UTC boundary as the daily journey; weekly sets roll on Monday like the weekly boards.
+func refreshQuestSets(state *QuestState) bool {
+	cfg := GetQuestsConfig()
+	now := time.Now()
+	rng := rand.New(rand.NewSource(now.UnixNano()))
+	changed := false
+	if daily := utcMidnight(now); time.Unix(state.Daily.ResetUnix, 0).UTC().Before(daily) {
+		state.Daily = rollQuestSet(rng, QuestPeriodDaily, cfg.DailyCount, daily)
+		changed = true
+	}
+	if weekly := utcWeekStart(now); time.Unix(state.Weekly.ResetUnix, 0).UTC().Before(weekly) {
+		state.Weekly = rollQuestSet(rng, QuestPeriodWeekly, cfg.WeeklyCount, weekly)
+		changed = true
+	}
+	return changed
+}
+
+// readQuestState loads the quest document, re-rolling expired sets. rolled reports whether
+// the returned state differs from storage because of a re-roll.
+func readQuestState(ctx context.Context, nk runtime.NakamaModule, userID string) (state *QuestState, rolled bool, err error) {
+	state = &QuestState{}
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionQuests,
+		Key:        storageKeyQuestState,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(objects) > 0 {
+		if err := json.Unmarshal([]byte(objects[0].Value), state); err != nil {
+			return nil, false, err
+		}
+		state.Version = objects[0].Version
+	}
+	return state, refreshQuestSets(state), nil
+}
+
+func buildQuestWrite(userID string, state *QuestState) (*runtime.StorageWrite, error) {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.StorageWrite{
+		Collection:      storageCollectionQuests,
+		Key:             storageKeyQuestState,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         state.Version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}, nil
+}
+
+func applyQuestEvents(set *QuestSet, events []QuestEvent) bool {
+	changed := false
+	for i := range set.Quests {
+		q := &set.Quests[i]
+		tmpl, ok := getQuestTemplate(q.ID)
+		if !ok || q.Claimed || q.Progress >= tmpl.Target {
+			continue
+		}
+		for _, ev := range events {
+			if ev.Stat != tmpl.Stat || ev.Amount <= 0 {
+				continue
+			}
+			if tmpl.ClassID != nil && *tmpl.ClassID != ev.ClassID {
+				continue
+			}
+			q.Progress += ev.Amount
+			if q.Progress > tmpl.Target {
+				q.Progress = tmpl.Target
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// PrepareQuestProgress applies events to the user's active quests and returns the deferred
+// state write, or nil if nothing changed. Rewards are granted on claim, not here.
+func PrepareQuestProgress(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, events []QuestEvent) (*PendingWrites, error) {
+	if len(GetQuestsConfig().Templates) == 0 || len(events) == 0 {
+		return nil, nil
+	}
+
+	state, rolled, err := readQuestState(ctx, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+	daily := applyQuestEvents(&state.Daily, events)
+	weekly := applyQuestEvents(&state.Weekly, events)
+	if !rolled && !daily && !weekly {
+		return nil, nil
+	}
+
+	write, err := buildQuestWrite(userID, state)
+	if err != nil {
+		return nil, errors.ErrMarshal
+	}
+	pending := NewPendingWrites()
+	pending.AddStorageWrite(write)
+	return pending, nil
+}
+
+// QuestStatus is one row of the get_quests response.
+type QuestStatus struct {
+	QuestTemplate
+	Progress  int   `json:"progress"`
+	Completed bool  `json:"completed"`
+	Claimed   bool  `json:"claimed"`
+	ResetsAt  int64 `json:"resets_at"`
+}
+
+func buildQuestStatuses(set QuestSet, next time.Time) []QuestStatus {
+	out := make([]QuestStatus, 0, len(set.Quests))
+	for _, q := range set.Quests {
+		tmpl, ok := getQuestTemplate(q.ID)
+		if !ok {
+			continue
+		}
+		out = append(out, QuestStatus{
+			QuestTemplate: tmpl,
+			Progress:      q.Progress,
+			Completed:     q.Progress >= tmpl.Target,
+			Claimed:       q.Claimed,
+			ResetsAt:      next.Unix(),
+		})
+	}
+	return out
+}
+
+// RpcGetQuests returns the caller's daily and weekly quests, rolling new sets when due.
+func RpcGetQuests(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	state, rolled, err := readQuestState(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read quests for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	// Persist freshly rolled sets so the selection is stable across calls.
+	// A lost OCC race just means another request rolled first; the next read picks it up.
+	if rolled {
+		if write, err := buildQuestWrite(userID, state); err == nil {
+			if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{write}); err != nil {
+				logger.Warn("Failed to persist rolled quests for user %s: %v", userID, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	resp := map[string]interface{}{
+		"daily":  buildQuestStatuses(state.Daily, utcMidnight(now).AddDate(0, 0, 1)),
+		"weekly": buildQuestStatuses(state.Weekly, utcWeekStart(now).AddDate(0, 0, 7)),
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// RpcClaimQuestReward grants a completed quest's reward. The claimed flag and the wallet
+// update commit in one MultiUpdate, so a reward can't be granted twice.
+func RpcClaimQuestReward(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	state, _, err := readQuestState(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read quests for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	var quest *QuestProgress
+	for _, set := range []*QuestSet{&state.Daily, &state.Weekly} {
+		for i := range set.Quests {
+			if set.Quests[i].ID == req.ID {
+				quest = &set.Quests[i]
+			}
+		}
+	}
+	if quest == nil {
+		return "", errors.ErrQuestNotFound
+	}
+	tmpl, ok := getQuestTemplate(quest.ID)
+	if !ok {
+		return "", errors.ErrQuestNotFound
+	}
+	if quest.Claimed {
+		return "", errors.ErrRewardAlreadyClaimed
+	}
+	if quest.Progress < tmpl.Target {
+		return "", errors.ErrQuestIncomplete
+	}
+	quest.Claimed = true
+
+	pending := NewPendingWrites()
+	write, err := buildQuestWrite(userID, state)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	pending.AddStorageWrite(write)
+
+	result := notify.NewRewardPayload("quest")
+	result.ReasonKey = "reward.quest.complete"
+	walletChanges := make(map[string]int64)
+	if tmpl.Reward.Gold > 0 {
+		walletChanges["gold"] = int64(tmpl.Reward.Gold)
+	}
+	if tmpl.Reward.Gems > 0 {
+		walletChanges["gems"] = int64(tmpl.Reward.Gems)
+	}
+	if tmpl.Reward.Treats > 0 {
+		walletChanges["treats"] = int64(tmpl.Reward.Treats)
+	}
+	if len(walletChanges) > 0 {
+		pending.AddWalletUpdate(userID, walletChanges)
+		result.Wallet = &notify.WalletDelta{
+			Gold:   tmpl.Reward.Gold,
+			Gems:   tmpl.Reward.Gems,
+			Treats: tmpl.Reward.Treats,
+		}
+		result.SetWalletReasonArgs()
+	}
+
+	pending.CapDailyEarnings()
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Failed to commit quest claim for user %s: %v", userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+	applyWithheldToPayload(result, pending.Withheld)
+
+	logger.Info("[quests] User %s claimed %s", userID, tmpl.ID)
+
+	respBytes, err := json.Marshal(result)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,174 @@
+package items
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"block-server/errors"
+)
+
+// seedQuests stores a current daily set holding ids (and an empty current weekly set).
+func seedQuests(t *testing.T, nk *fakeNakama, userID string, ids ...string) {
+	t.Helper()
+	now := time.Now()
+	state := QuestState{
+		Daily:  QuestSet{ResetUnix: utcMidnight(now).Unix()},
+		Weekly: QuestSet{ResetUnix: utcWeekStart(now).Unix()},
+	}
+	for _, id := range ids {
+		state.Daily.Quests = append(state.Daily.Quests, QuestProgress{ID: id})
+	}
+	nk.put(t, storageCollectionQuests, storageKeyQuestState, userID, state)
+}
+
+func questProgress(t *testing.T, nk *fakeNakama, userID, id string) QuestProgress {
+	t.Helper()
+	var state QuestState
+	nk.get(t, storageCollectionQuests, storageKeyQuestState, userID, &state)
+	for _, q := range append(state.Daily.Quests, state.Weekly.Quests...) {
+		if q.ID == id {
+			return q
+		}
+	}
+	t.Fatalf("quest %s not in state", id)
+	return QuestProgress{}
+}
+
+// withPetTreeCurrency prices the first pet's level tree in currency for the test.
+func withPetTreeCurrency(t *testing.T, petID uint32, currency string) {
+	t.Helper()
+	name, err := GetLevelTreeName(storageKeyPet, petID)
+	if err != nil {
+		t.Fatalf("GetLevelTreeName: %v", err)
+	}
+	gameDataMu.Lock()
+	original := GameData.LevelTrees[name]
+	tree := original
+	tree.UpgradeCostCurrency = currency
+	GameData.LevelTrees[name] = tree
+	gameDataMu.Unlock()
+	t.Cleanup(func() {
+		gameDataMu.Lock()
+		GameData.LevelTrees[name] = original
+		gameDataMu.Unlock()
+	})
+}
+
+func usePetTreat(t *testing.T, nk *fakeNakama, userID string, petID uint32, count int) error {
+	t.Helper()
+	nk.put(t, storageCollectionInventory, storageKeyPet, userID, InventoryData{Items: []uint32{petID}})
+	_, err := RpcUsePetTreat(testContext(userID), testLogger{}, nil, nk, fmt.Sprintf(`{"pet_id":%d,"count":%d}`, petID, count))
+	return err
+}
+
+func TestPetTreatsCountTowardTreatQuests(t *testing.T) {
+	nk := newFakeNakama()
+	petID := firstPetID(t)
+	withPetTreeCurrency(t, petID, "treats")
+	seedQuests(t, nk, "u1", "daily_treats_5")
+	nk.setWallet("u1", map[string]int64{"treats": 10})
+
+	if err := usePetTreat(t, nk, "u1", petID, 3); err != nil {
+		t.Fatalf("RpcUsePetTreat: %v", err)
+	}
+	if got := questProgress(t, nk, "u1", "daily_treats_5").Progress; got != 3 {
+		t.Errorf("treat quest progress = %d, want 3", got)
+	}
+}
+
+func TestGoldPricedTreeDoesNotCountTowardTreatQuests(t *testing.T) {
+	nk := newFakeNakama()
+	petID := firstPetID(t)
+	withPetTreeCurrency(t, petID, "gold")
+	seedQuests(t, nk, "u1", "daily_treats_5")
+	nk.setWallet("u1", map[string]int64{"gold": 1000})
+
+	if err := usePetTreat(t, nk, "u1", petID, 5); err != nil {
+		t.Fatalf("RpcUsePetTreat: %v", err)
+	}
+	if got := questProgress(t, nk, "u1", "daily_treats_5").Progress; got != 0 {
+		t.Errorf("gold spend advanced treat quest to %d", got)
+	}
+}
+
+func TestQuestClassFilter(t *testing.T) {
+	set := QuestSet{Quests: []QuestProgress{{ID: "daily_win_class_0"}, {ID: "daily_win_1"}}}
+	applyQuestEvents(&set, []QuestEvent{{Stat: QuestStatMatchesWon, Amount: 1, ClassID: 3}})
+	if set.Quests[0].Progress != 0 {
+		t.Errorf("class-0 quest progressed on a class-3 win")
+	}
+	if set.Quests[1].Progress != 1 {
+		t.Errorf("unrestricted win quest progress = %d, want 1", set.Quests[1].Progress)
+	}
+	applyQuestEvents(&set, []QuestEvent{{Stat: QuestStatMatchesWon, Amount: 5, ClassID: 0}})
+	if set.Quests[0].Progress != 1 {
+		t.Errorf("class-0 quest progress = %d, want capped at 1", set.Quests[0].Progress)
+	}
+}
+
+func TestQuestSetsRollAtBoundary(t *testing.T) {
+	yesterday := utcMidnight(time.Now()).AddDate(0, 0, -1)
+	state := &QuestState{
+		Daily:  QuestSet{ResetUnix: yesterday.Unix(), Quests: []QuestProgress{{ID: "daily_play_3", Progress: 3}}},
+		Weekly: QuestSet{ResetUnix: utcWeekStart(time.Now()).Unix()},
+	}
+	if !refreshQuestSets(state) {
+		t.Fatal("stale daily set was not re-rolled")
+	}
+	if state.Daily.ResetUnix != utcMidnight(time.Now()).Unix() {
+		t.Errorf("daily reset = %d, want today's midnight", state.Daily.ResetUnix)
+	}
+	for _, q := range state.Daily.Quests {
+		if q.Progress != 0 {
+			t.Errorf("re-rolled quest %s kept progress %d", q.ID, q.Progress)
+		}
+	}
+	if refreshQuestSets(state) {
+		t.Error("current sets were re-rolled again")
+	}
+}
+
+func TestClaimQuestRewardOnce(t *testing.T) {
+	nk := newFakeNakama()
+	seedQuests(t, nk, "u1", "daily_win_1")
+	ctx := testContext("u1")
+	req := `{"id":"daily_win_1"}`
+
+	if _, err := RpcClaimQuestReward(ctx, testLogger{}, nil, nk, req); err != errors.ErrQuestIncomplete {
+		t.Fatalf("claim incomplete quest: err = %v, want ErrQuestIncomplete", err)
+	}
+
+	pending, err := PrepareQuestProgress(ctx, nk, testLogger{}, "u1", []QuestEvent{{Stat: QuestStatMatchesWon, Amount: 1}})
+	if err != nil {
+		t.Fatalf("PrepareQuestProgress: %v", err)
+	}
+	if err := CommitPendingWrites(ctx, nk, testLogger{}, pending); err != nil {
+		t.Fatalf("commit progress: %v", err)
+	}
+
+	resp, err := RpcClaimQuestReward(ctx, testLogger{}, nil, nk, req)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	var payload struct {
+		Wallet struct {
+			Gold int `json:"gold"`
+		} `json:"wallet"`
+	}
+	json.Unmarshal([]byte(resp), &payload)
+	if payload.Wallet.Gold != 200 || nk.wallet("u1")["gold"] != 200 {
+		t.Errorf("claim paid %d gold (wallet %d), want 200", payload.Wallet.Gold, nk.wallet("u1")["gold"])
+	}
+	if !questProgress(t, nk, "u1", "daily_win_1").Claimed {
+		t.Error("quest not marked claimed")
+	}
+
+	if _, err := RpcClaimQuestReward(ctx, testLogger{}, nil, nk, req); err != errors.ErrRewardAlreadyClaimed {
+		t.Errorf("second claim: err = %v, want ErrRewardAlreadyClaimed", err)
+	}
+	if nk.wallet("u1")["gold"] != 200 {
+		t.Errorf("second claim changed wallet to %d", nk.wallet("u1")["gold"])
+	}
+}
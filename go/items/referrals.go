@@ -0,0 +1,357 @@
+package items
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionReferrals holds the system-owned code index ("code_<CODE>"), the redeemer's
+	// "redeemed" marker and the referrer's "referred_<userID>" entries.
+	storageCollectionReferrals = "referrals"
+	storageKeyReferralRedeemed = "redeemed"
+	storageKeyReferrerStats    = "referrer_stats"
+	referralCodePrefix         = "code_"
+	referredKeyPrefix          = "referred_"
+
+	// referralSecretEnvKey is the Nakama runtime env var keying referral code derivation, so codes
+	// can't be computed from public user IDs. Unset means referrals are disabled.
+	referralSecretEnvKey = "REFERRAL_SECRET"
+)
+
+// referralCodeLengths are tried in order; a longer code is only used on a hash collision.
+var referralCodeLengths = []int{8, 12, 16}
+
+// ReferralConfig is economy.referral in items.json. MaxAccountAgeHours bounds how new a redeemer
+// must be; 0 disables redemption entirely. MaxRewardedReferrals caps how many redemptions pay the
+// referrer; later ones still pay the redeemer. 0 means uncapped.
+type ReferralConfig struct {
+	MaxAccountAgeHours   int                  `json:"max_account_age_hours"`
+	MaxRewardedReferrals int                  `json:"max_rewarded_referrals"`
+	ReferrerReward       ReferralRewardConfig `json:"referrer_reward"`
+	RedeemerReward       ReferralRewardConfig `json:"redeemer_reward"`
+}
+
+type ReferralRewardConfig struct {
+	Gold        int    `json:"gold,omitempty"`
+	Gems        int    `json:"gems,omitempty"`
+	LootboxTier string `json:"lootbox_tier,omitempty"`
+}
+
+type referralCodeIndex struct {
+	UserID string `json:"user_id"`
+}
+
+// ReferralRecord is written under the redeemer (key "redeemed") and the referrer ("referred_<redeemer>").
+type ReferralRecord struct {
+	Code       string `json:"code"`
+	ReferrerID string `json:"referrer_id"`
+	RedeemerID string `json:"redeemer_id"`
+	RedeemedAt int64  `json:"redeemed_at"`
+}
+
+// referrerStats counts the redemptions that paid the referrer (key "referrer_stats").
+type referrerStats struct {
+	Rewarded int `json:"rewarded"`
+}
+
+// referralSecret returns the configured derivation key, or "" when unset.
+func referralSecret(ctx context.Context) string {
+	env, ok := ctx.Value(runtime.RUNTIME_CTX_ENV).(map[string]string)
+	if !ok {
+		return ""
+	}
+	return env[referralSecretEnvKey]
+}
+
+func referralCodeCandidate(secret string, userID string, length int) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil)))[:length]
+}
+
+// ensureReferralCode returns the user's code, registering it in the system-owned index on first use.
+func ensureReferralCode(ctx context.Context, nk runtime.NakamaModule, userID string) (string, error) {
+	secret := referralSecret(ctx)
+	if secret == "" {
+		return "", errors.ErrReferralsDisabled
+	}
+	for _, length := range referralCodeLengths {
+		code := referralCodeCandidate(secret, userID, length)
+		owner, err := lookupReferralCode(ctx, nk, code)
+		if err != nil {
+			return "", err
+		}
+		if owner == userID {
+			return code, nil
+		}
+		if owner != "" {
+			continue // Collision with another player's code
+		}
+		value, _ := json.Marshal(referralCodeIndex{UserID: userID})
+		if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+			Collection:      storageCollectionReferrals,
+			Key:             referralCodePrefix + code,
+			Value:           string(value),
+			Version:         "*",
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		}}); err != nil {
+			// Lost a create race; re-check who owns the code.
+			if owner, readErr := lookupReferralCode(ctx, nk, code); readErr == nil && owner == userID {
+				return code, nil
+			}
+			continue
+		}
+		return code, nil
+	}
+	return "", errors.ErrCouldNotWriteStorage
+}
+
+// lookupReferralCode returns the owner of code, or "" when the code is unregistered.
+func lookupReferralCode(ctx context.Context, nk runtime.NakamaModule, code string) (string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionReferrals,
+		Key:        referralCodePrefix + code,
+	}})
+	if err != nil {
+		return "", err
+	}
+	if len(objects) == 0 {
+		return "", nil
+	}
+	var index referralCodeIndex
+	if err := json.Unmarshal([]byte(objects[0].Value), &index); err != nil {
+		return "", err
+	}
+	return index.UserID, nil
+}
+
+type ReferralCodeResponse struct {
+	Code string `json:"code"`
+}
+
+// RpcGetReferralCode returns the caller's referral code.
+func RpcGetReferralCode(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	code, err := ensureReferralCode(ctx, nk, userID)
+	if err == errors.ErrReferralsDisabled {
+		return "", err
+	}
+	if err != nil {
+		logger.Error("Failed to register referral code for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	respBytes, err := json.Marshal(ReferralCodeResponse{Code: code})
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// stageReferralReward adds reward for userID to pending and returns the matching payload.
+func stageReferralReward(pending *PendingWrites, userID string, reward ReferralRewardConfig, reasonKey string) (*notify.RewardPayload, error) {
+	payload := notify.NewRewardPayload("referral")
+	payload.ReasonKey = reasonKey
+
+	changeset := map[string]int64{}
+	if reward.Gold > 0 {
+		changeset["gold"] = int64(reward.Gold)
+	}
+	if reward.Gems > 0 {
+		changeset["gems"] = int64(reward.Gems)
+	}
+	if len(changeset) > 0 {
+		pending.AddWalletUpdate(userID, changeset)
+		payload.Wallet = &notify.WalletDelta{Gold: reward.Gold, Gems: reward.Gems}
+		payload.SetWalletReasonArgs()
+	}
+	if reward.LootboxTier != "" {
+		lootbox, writes, err := PrepareCreateLootbox(userID, reward.LootboxTier)
+		if err != nil {
+			return nil, err
+		}
+		pending.AddStorageWrites(writes...)
+		payload.Lootboxes = append(payload.Lootboxes, notify.LootboxGrant{
+			ID:     lootbox.ID,
+			Tier:   lootbox.Tier,
+			Source: "referral",
+		})
+	}
+	return payload, nil
+}
+
+type RedeemReferralRequest struct {
+	Code string `json:"code"`
+}
+
+// RpcRedeemReferral redeems another player's code, rewarding both players.
+// Only accounts younger than referral.max_account_age_hours may redeem, once, and never their own code.
+// The referrer is paid for at most referral.max_rewarded_referrals redemptions, counted in their
+// referrer_stats record. The create-only "redeemed" marker, the referrer's entry, the count and
+// both grants commit in one MultiUpdate.
+func RpcRedeemReferral(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req RedeemReferralRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if code == "" {
+		return "", errors.ErrInvalidReferralCode
+	}
+
+	cfg := GetEconomyConfig().Referral
+	if cfg.MaxAccountAgeHours <= 0 {
+		return "", errors.ErrReferralNotEligible
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	if account.User == nil || account.User.CreateTime == nil ||
+		time.Since(account.User.CreateTime.AsTime()) > time.Duration(cfg.MaxAccountAgeHours)*time.Hour {
+		return "", errors.ErrReferralNotEligible
+	}
+
+	referrerID, err := lookupReferralCode(ctx, nk, code)
+	if err != nil {
+		logger.Error("Failed to look up referral code %s: %v", code, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+	if referrerID == "" {
+		return "", errors.ErrInvalidReferralCode
+	}
+	if referrerID == userID {
+		return "", errors.ErrSelfReferral
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{
+		{Collection: storageCollectionReferrals, Key: storageKeyReferralRedeemed, UserID: userID},
+		{Collection: storageCollectionReferrals, Key: storageKeyReferrerStats, UserID: referrerID},
+	})
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+	var stats referrerStats
+	statsVersion := "*"
+	for _, obj := range objects {
+		switch {
+		case obj.UserId == userID && obj.Key == storageKeyReferralRedeemed:
+			return "", errors.ErrReferralAlreadyRedeemed
+		case obj.UserId == referrerID && obj.Key == storageKeyReferrerStats:
+			if err := json.Unmarshal([]byte(obj.Value), &stats); err != nil {
+				return "", errors.ErrCouldNotUnmarshal
+			}
+			statsVersion = obj.Version
+		}
+	}
+	payReferrer := cfg.MaxRewardedReferrals <= 0 || stats.Rewarded < cfg.MaxRewardedReferrals
+
+	record := ReferralRecord{
+		Code:       code,
+		ReferrerID: referrerID,
+		RedeemerID: userID,
+		RedeemedAt: time.Now().Unix(),
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+
+	pending := NewPendingWrites()
+	pending.AddStorageWrites(
+		&runtime.StorageWrite{
+			Collection:      storageCollectionReferrals,
+			Key:             storageKeyReferralRedeemed,
+			UserID:          userID,
+			Value:           string(value),
+			Version:         "*", // create-only: a concurrent second redeem fails the whole batch
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		},
+		&runtime.StorageWrite{
+			Collection:      storageCollectionReferrals,
+			Key:             referredKeyPrefix + userID,
+			UserID:          referrerID,
+			Value:           string(value),
+			Version:         "*",
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		},
+	)
+
+	redeemerPayload, err := stageReferralReward(pending, userID, cfg.RedeemerReward, "reward.referral.redeemed")
+	if err != nil {
+		logger.Error("Failed to prepare referral reward for user %s: %v", userID, err)
+		return "", errors.ErrPrepareFailed
+	}
+	var referrerPayload *notify.RewardPayload
+	if payReferrer {
+		referrerPayload, err = stageReferralReward(pending, referrerID, cfg.ReferrerReward, "reward.referral.referrer")
+		if err != nil {
+			logger.Error("Failed to prepare referral reward for referrer %s: %v", referrerID, err)
+			return "", errors.ErrPrepareFailed
+		}
+		// Versioned by the read: concurrent redemptions of one referrer's code can't both pass the cap.
+		stats.Rewarded++
+		statsValue, err := json.Marshal(stats)
+		if err != nil {
+			return "", errors.ErrMarshal
+		}
+		pending.AddStorageWrite(&runtime.StorageWrite{
+			Collection:      storageCollectionReferrals,
+			Key:             storageKeyReferrerStats,
+			UserID:          referrerID,
+			Value:           string(statsValue),
+			Version:         statsVersion,
+			PermissionRead:  1,
+			PermissionWrite: 0,
+		})
+	} else {
+		logger.Info("Referrer %s reached the rewarded referral cap; only the redeemer is paid", referrerID)
+	}
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Failed to commit referral %s -> %s: %v", referrerID, userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	if referrerPayload != nil {
+		SendRewardOrStore(ctx, nk, logger, referrerID, referrerPayload)
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"user":     userID,
+		"referrer": referrerID,
+		"code":     code,
+		"action":   "redeem_referral",
+	}).Info("Referral redeemed")
+
+	respBytes, err := json.Marshal(redeemerPayload)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
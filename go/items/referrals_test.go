@@ -0,0 +1,104 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const referrerUser = "00000000-0000-0000-0000-000000000031"
+
+func referralContext(userID string) context.Context {
+	return context.WithValue(testContext(userID), runtime.RUNTIME_CTX_ENV, map[string]string{referralSecretEnvKey: "test-secret"})
+}
+
+func withReferralConfig(t *testing.T, maxRewarded int) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.Referral = ReferralConfig{
+			MaxAccountAgeHours:   72,
+			MaxRewardedReferrals: maxRewarded,
+			ReferrerReward:       ReferralRewardConfig{Gems: 50},
+			RedeemerReward:       ReferralRewardConfig{Gold: 500},
+		}
+	})
+}
+
+func referralCode(t *testing.T, nk *fakeNakama, userID string) string {
+	t.Helper()
+	resp, err := RpcGetReferralCode(referralContext(userID), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetReferralCode: %v", err)
+	}
+	var out ReferralCodeResponse
+	if err := json.Unmarshal([]byte(resp), &out); err != nil {
+		t.Fatalf("unmarshal code: %v", err)
+	}
+	return out.Code
+}
+
+func redeemReferral(nk *fakeNakama, userID, code string) error {
+	_, err := RpcRedeemReferral(referralContext(userID), testLogger{}, nil, nk, `{"code":"`+code+`"}`)
+	return err
+}
+
+func TestReferralRewardsBothPlayersOnce(t *testing.T) {
+	withReferralConfig(t, 0)
+	nk := newFakeNakama()
+	code := referralCode(t, nk, referrerUser)
+	redeemer := "00000000-0000-0000-0000-000000000032"
+
+	if err := redeemReferral(nk, referrerUser, code); err != errors.ErrSelfReferral {
+		t.Errorf("self-referral: err = %v, want ErrSelfReferral", err)
+	}
+	if err := redeemReferral(nk, redeemer, code); err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	if err := redeemReferral(nk, redeemer, code); err != errors.ErrReferralAlreadyRedeemed {
+		t.Errorf("second redeem: err = %v, want ErrReferralAlreadyRedeemed", err)
+	}
+	if gold := nk.wallet(redeemer)["gold"]; gold != 500 {
+		t.Errorf("redeemer gold = %d, want 500", gold)
+	}
+	if gems := nk.wallet(referrerUser)["gems"]; gems != 50 {
+		t.Errorf("referrer gems = %d, want 50", gems)
+	}
+}
+
+func TestReferralCapStopsReferrerRewards(t *testing.T) {
+	withReferralConfig(t, 2)
+	nk := newFakeNakama()
+	code := referralCode(t, nk, referrerUser)
+
+	redeemers := []string{
+		"00000000-0000-0000-0000-000000000041",
+		"00000000-0000-0000-0000-000000000042",
+		"00000000-0000-0000-0000-000000000043",
+	}
+	for _, redeemer := range redeemers {
+		if err := redeemReferral(nk, redeemer, code); err != nil {
+			t.Fatalf("redeem by %s: %v", redeemer, err)
+		}
+		if gold := nk.wallet(redeemer)["gold"]; gold != 500 {
+			t.Errorf("redeemer %s gold = %d, want 500", redeemer, gold)
+		}
+	}
+	if gems := nk.wallet(referrerUser)["gems"]; gems != 100 {
+		t.Errorf("referrer gems = %d, want 100 for two capped referrals", gems)
+	}
+}
+
+func TestReferralCodeNeedsSecret(t *testing.T) {
+	nk := newFakeNakama()
+	if _, err := RpcGetReferralCode(testContext(referrerUser), testLogger{}, nil, nk, ""); err != errors.ErrReferralsDisabled {
+		t.Errorf("no secret: err = %v, want ErrReferralsDisabled", err)
+	}
+
+	// The code depends on the secret, not just the public user ID.
+	if referralCodeCandidate("a", referrerUser, 8) == referralCodeCandidate("b", referrerUser, 8) {
+		t.Error("different secrets derived the same code")
+	}
+}
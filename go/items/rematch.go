@@ -0,0 +1,120 @@
+package items
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ProgressionKeyRecentOpponents holds the per-user rematch window (progression collection).
+const ProgressionKeyRecentOpponents = "recent_opponents"
+
+// Rematch policy actions for economy.rematch.action.
+const (
+	RematchActionLog    = "log"    // Only log repeated pairings
+	RematchActionReduce = "reduce" // Scale match XP and tokens by RewardPercent
+	RematchActionReject = "reject" // Refuse to start the match
+)
+
+// RematchPolicyConfig is economy.rematch in items.json. Catches two accounts farming each other;
+// separate from the global RPC rate limit. Limit 0 disables.
+type RematchPolicyConfig struct {
+	Limit         int    `json:"limit"`          // The Nth match against one opponent inside the window triggers Action
+	WindowMinutes int    `json:"window_minutes"` // Sliding window per opponent
+	Action        string `json:"action"`         // log | reduce | reject
+	RewardPercent int    `json:"reward_percent"` // reduce only: percent of XP and tokens kept, 1-99
+}
+
+// RecentOpponents is the sliding list of 1v1 match starts used by the rematch policy.
+type RecentOpponents struct {
+	Matches []RecentOpponentMatch `json:"matches"`
+}
+
+type RecentOpponentMatch struct {
+	OpponentID string `json:"opponent_id"`
+	MatchID    string `json:"match_id"`
+	StartedAt  int64  `json:"started_at"` // Unix seconds
+}
+
+func recentOpponentsRead(userID string) *runtime.StorageRead {
+	return &runtime.StorageRead{Collection: storageCollectionProgression, Key: ProgressionKeyRecentOpponents, UserID: userID}
+}
+
+// checkRematchPolicy records a 1v1 match start and returns the policy action it triggers ("" for none)
+// plus the recent_opponents write to commit alongside the active match lock. objects is the match
+// start read batch, which includes recentOpponentsRead. Callers skip the check when that read fails:
+// a lost entry only weakens anti-farming, it never blocks play.
+func checkRematchPolicy(logger runtime.Logger, userID, opponentID, matchID string, objects []*api.StorageObject) (string, *runtime.StorageWrite) {
+	policy := GetEconomyConfig().Rematch
+	if policy.Limit <= 0 || opponentID == "" {
+		return "", nil
+	}
+
+	recent := &RecentOpponents{}
+	for _, obj := range objects {
+		if obj.Collection != storageCollectionProgression || obj.Key != ProgressionKeyRecentOpponents {
+			continue
+		}
+		if err := json.Unmarshal([]byte(obj.Value), recent); err != nil {
+			logger.Warn("Failed to unmarshal recent opponents for user %s: %v", userID, err)
+			recent = &RecentOpponents{}
+		}
+	}
+
+	now := time.Now().Unix()
+	cutoff := now - int64(policy.WindowMinutes)*60
+	kept := recent.Matches[:0]
+	count := 1 // This match
+	for _, m := range recent.Matches {
+		if m.StartedAt < cutoff {
+			continue
+		}
+		if m.MatchID == matchID {
+			continue // Re-notified start of the same match
+		}
+		kept = append(kept, m)
+		if m.OpponentID == opponentID {
+			count++
+		}
+	}
+	recent.Matches = append(kept, RecentOpponentMatch{OpponentID: opponentID, MatchID: matchID, StartedAt: now})
+
+	action := ""
+	if count >= policy.Limit {
+		action = policy.Action
+		logger.WithFields(map[string]interface{}{
+			"user":     userID,
+			"opponent": opponentID,
+			"count":    count,
+			"action":   action,
+		}).Warn("Repeated opponent pairing inside rematch window")
+	}
+	if action == RematchActionReject {
+		// Rejected starts aren't recorded, so waiting out the window always clears the block.
+		return action, nil
+	}
+
+	value, err := json.Marshal(recent)
+	if err != nil {
+		return action, nil
+	}
+	return action, &runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyRecentOpponents,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}
+}
+
+// scaleRematchReward applies the reward cut stamped on the active match at start. 0 means the match
+// was not reduced; the config itself can't hold 0 (rejected at load).
+func scaleRematchReward(amount int, activeMatch *ActiveMatch) int {
+	if activeMatch == nil || activeMatch.RewardPercent <= 0 || activeMatch.RewardPercent >= 100 {
+		return amount
+	}
+	return amount * activeMatch.RewardPercent / 100
+}
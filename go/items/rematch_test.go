@@ -0,0 +1,78 @@
+package items
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func withRematchPolicy(t *testing.T, policy RematchPolicyConfig) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.Rematch = policy })
+}
+
+// startRematch records one match start against opponentID and returns the triggered action.
+func startRematch(t *testing.T, nk *fakeNakama, userID, opponentID, matchID string) string {
+	t.Helper()
+	objects, err := nk.StorageRead(testContext(userID), []*runtime.StorageRead{recentOpponentsRead(userID)})
+	if err != nil {
+		t.Fatalf("read recent opponents: %v", err)
+	}
+	action, write := checkRematchPolicy(testLogger{}, userID, opponentID, matchID, objects)
+	if write != nil {
+		if _, err := nk.StorageWrite(testContext(userID), []*runtime.StorageWrite{write}); err != nil {
+			t.Fatalf("write recent opponents: %v", err)
+		}
+	}
+	return action
+}
+
+func TestRematchPolicyTriggersOnThirdRematch(t *testing.T) {
+	for _, action := range []string{RematchActionLog, RematchActionReduce, RematchActionReject} {
+		withRematchPolicy(t, RematchPolicyConfig{Limit: 3, WindowMinutes: 30, Action: action, RewardPercent: 50})
+		nk := newFakeNakama()
+
+		for i, matchID := range []string{"m1", "m2"} {
+			if got := startRematch(t, nk, "u1", "u2", matchID); got != "" {
+				t.Errorf("%s: match %d triggered %q", action, i+1, got)
+			}
+		}
+		// Another opponent and a re-notified start of the same match don't count.
+		startRematch(t, nk, "u1", "u3", "m3")
+		if got := startRematch(t, nk, "u1", "u2", "m2"); got != "" {
+			t.Errorf("%s: re-notified start triggered %q", action, got)
+		}
+		if got := startRematch(t, nk, "u1", "u2", "m4"); got != action {
+			t.Errorf("third rematch triggered %q, want %q", got, action)
+		}
+	}
+}
+
+func TestRematchReduceScalesRewards(t *testing.T) {
+	if got := scaleRematchReward(10, &ActiveMatch{RewardPercent: 50}); got != 5 {
+		t.Errorf("reduced reward = %d, want 5", got)
+	}
+	if got := scaleRematchReward(10, &ActiveMatch{}); got != 10 {
+		t.Errorf("unreduced reward = %d, want 10", got)
+	}
+}
+
+func TestRematchRewardPercentValidatedAtLoad(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(gamedata, &doc); err != nil {
+		t.Fatalf("parse items.json: %v", err)
+	}
+	rematch := doc["economy"].(map[string]interface{})["rematch"].(map[string]interface{})
+	rematch["action"] = RematchActionReduce
+	for _, percent := range []int{0, -10, 100} {
+		rematch["reward_percent"] = percent
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatalf("marshal items.json: %v", err)
+		}
+		if err := ReloadGameData(data); err == nil || !strings.Contains(err.Error(), "reward_percent") {
+			t.Errorf("reward_percent %d: ReloadGameData error = %v, want rejected", percent, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionReports holds cheating reports, owned by the reporter and hidden from clients.
+	storageCollectionReports = "reports"
+
+	// ProgressionKeyReportQuota tracks how many reports the user filed today (progression collection).
+	ProgressionKeyReportQuota = "report_quota"
+
+	maxReportsPerDay     = 5
+	maxReportReasonLen   = 500
+	maxReportsListLimit  = 100
+	defaultReportsListed = 50
+)
+
+type ReportPlayerRequest struct {
+	TargetID string `json:"target_id"`
+	MatchID  string `json:"match_id"`
+	Reason   string `json:"reason"`
+}
+
+// PlayerReport is one stored report. MatchResults snapshots both players' consensus claims
+// at report time, since the result records are pruned with the match.
+type PlayerReport struct {
+	ReporterID   string              `json:"reporter_id"`
+	TargetID     string              `json:"target_id"`
+	MatchID      string              `json:"match_id"`
+	Reason       string              `json:"reason"`
+	CreatedAt    int64               `json:"created_at"`
+	MatchResults []MatchResultRecord `json:"match_results,omitempty"`
+}
+
+// ReportQuota is the reporter's daily counter; Day is the UTC midnight it applies to.
+type ReportQuota struct {
+	Day   int64 `json:"day"`
+	Count int   `json:"count"`
+}
+
+// RpcReportPlayer files a cheating report against the caller's opponent in a match.
+// Both players must have a result record for the match. One report per target per match;
+// at most maxReportsPerDay per reporter.
+func RpcReportPlayer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req ReportPlayerRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if req.TargetID == "" || req.MatchID == "" || req.Reason == "" || req.TargetID == userID {
+		return "", errors.ErrInvalidInput
+	}
+	if reason := []rune(req.Reason); len(reason) > maxReportReasonLen {
+		req.Reason = string(reason[:maxReportReasonLen])
+	}
+	if users, err := nk.UsersGetId(ctx, []string{req.TargetID}, nil); err != nil || len(users) == 0 {
+		return "", errors.ErrInvalidInput
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{
+		{Collection: storageCollectionProgression, Key: ProgressionKeyReportQuota, UserID: userID},
+		{Collection: storageCollectionResults, Key: req.MatchID + "_" + userID, UserID: userID},
+		{Collection: storageCollectionResults, Key: req.MatchID + "_" + req.TargetID, UserID: req.TargetID},
+	})
+	if err != nil {
+		logger.Error("Failed to read report context for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	now := time.Now()
+	today := utcMidnight(now).Unix()
+	quota := ReportQuota{Day: today}
+	quotaVersion := "*"
+	report := PlayerReport{
+		ReporterID: userID,
+		TargetID:   req.TargetID,
+		MatchID:    req.MatchID,
+		Reason:     req.Reason,
+		CreatedAt:  now.Unix(),
+	}
+	for _, obj := range objects {
+		if obj.Collection == storageCollectionProgression {
+			quotaVersion = obj.Version
+			var stored ReportQuota
+			if err := json.Unmarshal([]byte(obj.Value), &stored); err == nil && stored.Day == today {
+				quota = stored
+			}
+			continue
+		}
+		var record MatchResultRecord
+		if err := json.Unmarshal([]byte(obj.Value), &record); err == nil {
+			report.MatchResults = append(report.MatchResults, record)
+		}
+	}
+	// Only players who were in the match can report each other.
+	if len(report.MatchResults) != 2 {
+		return "", errors.ErrReportMatchNotFound
+	}
+	if quota.Count >= maxReportsPerDay {
+		return "", errors.ErrReportLimitReached
+	}
+	quota.Count++
+
+	quotaBytes, err := json.Marshal(quota)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	reportBytes, err := json.Marshal(report)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+
+	// One transaction: the "*" version on the report rejects a duplicate, and the quota version
+	// rejects a concurrent report racing past the daily cap.
+	reportKey := req.MatchID + "_" + req.TargetID
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{
+		{
+			Collection:      storageCollectionProgression,
+			Key:             ProgressionKeyReportQuota,
+			UserID:          userID,
+			Value:           string(quotaBytes),
+			Version:         quotaVersion,
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		},
+		{
+			Collection:      storageCollectionReports,
+			Key:             reportKey,
+			UserID:          userID,
+			Value:           string(reportBytes),
+			Version:         "*",
+			PermissionRead:  0,
+			PermissionWrite: 0,
+		},
+	})
+	if err != nil {
+		// The conflict doesn't say which write failed; only an existing report is a duplicate.
+		// A quota conflict is a concurrent report from the same player and can be retried.
+		if stderrors.Is(err, runtime.ErrStorageRejectedVersion) && reportExists(ctx, nk, userID, reportKey) {
+			logger.Info("Report by %s against %s (match %s) rejected as a duplicate", userID, req.TargetID, req.MatchID)
+			return "", errors.ErrAlreadyReported
+		}
+		logger.Error("Failed to write report by %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"reporter": userID,
+		"target":   req.TargetID,
+		"match":    req.MatchID,
+	}).Info("Player report filed")
+	return `{"success": true}`, nil
+}
+
+func reportExists(ctx context.Context, nk runtime.NakamaModule, userID, key string) bool {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{Collection: storageCollectionReports, Key: key, UserID: userID}})
+	return err == nil && len(objects) > 0
+}
+
+type GetReportsRequest struct {
+	Secret   string `json:"secret"`
+	Operator string `json:"operator"`
+	Limit    int    `json:"limit"`
+	Cursor   string `json:"cursor"`
+}
+
+type GetReportsResponse struct {
+	Reports []PlayerReport `json:"reports"`
+	Cursor  string         `json:"cursor,omitempty"`
+}
+
+// RpcGetReports pages through every filed report. Admin only.
+func RpcGetReports(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req GetReportsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !checkAdminSecret(ctx, req.Secret) {
+		logger.Warn("[admin] Rejected get_reports (operator=%q)", req.Operator)
+		return "", errors.ErrAdminUnauthorized
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultReportsListed
+	}
+	if req.Limit > maxReportsListLimit {
+		req.Limit = maxReportsListLimit
+	}
+
+	// Empty user ID lists the collection across all owners.
+	objects, cursor, err := nk.StorageList(ctx, "", "", storageCollectionReports, req.Limit, req.Cursor)
+	if err != nil {
+		logger.Error("[admin] Failed to list reports: %v", err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := GetReportsResponse{Reports: make([]PlayerReport, 0, len(objects)), Cursor: cursor}
+	for _, obj := range objects {
+		var report PlayerReport
+		if err := json.Unmarshal([]byte(obj.Value), &report); err != nil {
+			logger.Warn("[admin] Skipping unreadable report %s/%s: %v", obj.UserId, obj.Key, err)
+			continue
+		}
+		resp.Reports = append(resp.Reports, report)
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,101 @@
+package items
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"block-server/errors"
+)
+
+// seedReportMatch gives both players a result record for matchID and a target account.
+func seedReportMatch(t *testing.T, nk *fakeNakama, matchID, reporter, target string) {
+	t.Helper()
+	nk.AccountGetId(testContext(target), target)
+	nk.put(t, storageCollectionResults, matchID+"_"+reporter, reporter, MatchResultRecord{UserID: reporter})
+	nk.put(t, storageCollectionResults, matchID+"_"+target, target, MatchResultRecord{UserID: target, ClaimedWin: true})
+}
+
+func reportPlayer(nk *fakeNakama, reporter, target, matchID, reason string) error {
+	req, _ := json.Marshal(ReportPlayerRequest{TargetID: target, MatchID: matchID, Reason: reason})
+	_, err := RpcReportPlayer(testContext(reporter), testLogger{}, nil, nk, string(req))
+	return err
+}
+
+func TestReportRecordedAndRateLimited(t *testing.T) {
+	nk := newFakeNakama()
+	for i := 0; i <= maxReportsPerDay; i++ {
+		seedReportMatch(t, nk, "m"+string(rune('a'+i)), "u1", "u2")
+	}
+
+	if err := reportPlayer(nk, "u1", "u2", "ma", "speed hacks"); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	var report PlayerReport
+	if !nk.get(t, storageCollectionReports, "ma_u2", "u1", &report) {
+		t.Fatal("report was not stored")
+	}
+	if report.ReporterID != "u1" || report.Reason != "speed hacks" || len(report.MatchResults) != 2 {
+		t.Errorf("report = %+v, want u1's reason with both result records", report)
+	}
+	if err := reportPlayer(nk, "u1", "u2", "ma", "again"); err != errors.ErrAlreadyReported {
+		t.Errorf("duplicate report err = %v, want ErrAlreadyReported", err)
+	}
+
+	for i := 1; i < maxReportsPerDay; i++ {
+		if err := reportPlayer(nk, "u1", "u2", "m"+string(rune('a'+i)), "cheating"); err != nil {
+			t.Fatalf("report %d: %v", i+1, err)
+		}
+	}
+	last := "m" + string(rune('a'+maxReportsPerDay))
+	if err := reportPlayer(nk, "u1", "u2", last, "cheating"); err != errors.ErrReportLimitReached {
+		t.Errorf("report past the daily limit err = %v, want ErrReportLimitReached", err)
+	}
+}
+
+func TestReportRequiresBothMatchRecords(t *testing.T) {
+	nk := newFakeNakama()
+	nk.AccountGetId(testContext("u2"), "u2")
+	nk.put(t, storageCollectionResults, "m1_u2", "u2", MatchResultRecord{UserID: "u2"})
+
+	if err := reportPlayer(nk, "u1", "u2", "m1", "cheating"); err != errors.ErrReportMatchNotFound {
+		t.Errorf("report without the reporter's record err = %v, want ErrReportMatchNotFound", err)
+	}
+	if err := reportPlayer(nk, "u1", "u2", "m2", "cheating"); err != errors.ErrReportMatchNotFound {
+		t.Errorf("report for an unknown match err = %v, want ErrReportMatchNotFound", err)
+	}
+	if nk.count(storageCollectionReports, "u1") != 0 {
+		t.Error("a rejected report was stored")
+	}
+}
+
+func TestReportReasonTruncatedByRune(t *testing.T) {
+	nk := newFakeNakama()
+	seedReportMatch(t, nk, "m1", "u1", "u2")
+
+	if err := reportPlayer(nk, "u1", "u2", "m1", strings.Repeat("é", maxReportReasonLen+10)); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	var report PlayerReport
+	nk.get(t, storageCollectionReports, "m1_u2", "u1", &report)
+	if !utf8.ValidString(report.Reason) || utf8.RuneCountInString(report.Reason) != maxReportReasonLen {
+		t.Errorf("stored reason has %d runes (valid UTF-8: %v), want %d", utf8.RuneCountInString(report.Reason), utf8.ValidString(report.Reason), maxReportReasonLen)
+	}
+}
+
+func TestReportQuotaConflictIsNotDuplicate(t *testing.T) {
+	nk := newFakeNakama()
+	seedReportMatch(t, nk, "m1", "u1", "u2")
+	// A concurrent report from the same player bumps the quota between read and write.
+	nk.storageWriteHook = func() {
+		nk.storageWriteHook = nil
+		nk.put(t, storageCollectionProgression, ProgressionKeyReportQuota, "u1", ReportQuota{Day: utcMidnight(time.Now()).Unix(), Count: 1})
+	}
+
+	err := reportPlayer(nk, "u1", "u2", "m1", "cheating")
+	if err == nil || err == errors.ErrAlreadyReported {
+		t.Errorf("quota conflict err = %v, want a write failure other than ErrAlreadyReported", err)
+	}
+}
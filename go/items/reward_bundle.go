@@ -0,0 +1,118 @@
+package items
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// rewardBundle is the notification being collected for a user inside the bundling window.
+// MemberIDs are the rewards merged into Payload after the first; their delivery records are
+// acknowledged once the bundle, which carries their grants, has been sent.
+type rewardBundle struct {
+	Payload   *notify.RewardPayload
+	MemberIDs []string
+}
+
+// rewardBundles holds each user's open bundle. Rewards are already committed when they get here,
+// and every buffered reward has a delivery record, so a restart only delays the notification.
+var rewardBundles = struct {
+	sync.Mutex
+	users map[string]*rewardBundle
+}{users: map[string]*rewardBundle{}}
+
+// rewardBundleWindow is economy.reward_bundle_window_ms; 0 sends every reward immediately.
+func rewardBundleWindow() time.Duration {
+	return time.Duration(GetEconomyConfig().RewardBundleWindowMs) * time.Millisecond
+}
+
+// sameRewardReason reports whether a and b share a reason key and every non-wallet reason arg,
+// so merging them keeps the text the client shows. Wallet args are rebuilt by Merge.
+func sameRewardReason(a, b *notify.RewardPayload) bool {
+	if a.ReasonKey != b.ReasonKey {
+		return false
+	}
+	walletArg := func(key string) bool {
+		return key == notify.ReasonArgGold || key == notify.ReasonArgGems || key == notify.ReasonArgTreats
+	}
+	for _, args := range [][2]map[string]string{{a.ReasonArgs, b.ReasonArgs}, {b.ReasonArgs, a.ReasonArgs}} {
+		for key, value := range args[0] {
+			if !walletArg(key) && args[1][key] != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bundleReward merges payload into the user's open bundle, or opens one that flushes after window.
+// Returns false when the payload can't be bundled and must be sent directly: Merge would drop some
+// of it (match results, XP, meta, deep links), or its delivery record couldn't be written.
+// An open bundle with a different reason is sent at once and replaced.
+func bundleReward(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, payload *notify.RewardPayload, window time.Duration) bool {
+	if !payload.Mergeable() {
+		return false
+	}
+	payload.SetWalletReasonArgs()
+	if err := notify.TrackReward(ctx, nk, userID, payload); err != nil {
+		logger.Warn("Failed to track bundled reward %s for %s, sending directly: %v", payload.RewardID, userID, err)
+		return false
+	}
+
+	rewardBundles.Lock()
+	open, ok := rewardBundles.users[userID]
+	if ok && sameRewardReason(open.Payload, payload) {
+		open.Payload.Merge(payload)
+		open.MemberIDs = append(open.MemberIDs, payload.RewardID)
+		rewardBundles.Unlock()
+		return true
+	}
+
+	// Merge mutates the bundle, and callers may still hold payload (e.g. to return it), so copy it.
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		rewardBundles.Unlock()
+		return false
+	}
+	bundle := &rewardBundle{Payload: &notify.RewardPayload{}}
+	if err := json.Unmarshal(raw, bundle.Payload); err != nil {
+		rewardBundles.Unlock()
+		return false
+	}
+	rewardBundles.users[userID] = bundle
+	rewardBundles.Unlock()
+
+	time.AfterFunc(window, func() { flushRewardBundle(nk, logger, userID, bundle) })
+	if ok {
+		sendRewardBundle(ctx, nk, logger, userID, open)
+	}
+	return true
+}
+
+// flushRewardBundle sends bundle if it is still the user's open bundle.
+func flushRewardBundle(nk runtime.NakamaModule, logger runtime.Logger, userID string, bundle *rewardBundle) {
+	rewardBundles.Lock()
+	if rewardBundles.users[userID] != bundle {
+		rewardBundles.Unlock()
+		return // Already sent when a reward with a different reason replaced it
+	}
+	delete(rewardBundles.users, userID)
+	rewardBundles.Unlock()
+	sendRewardBundle(context.Background(), nk, logger, userID, bundle)
+}
+
+// sendRewardBundle sends the merged payload, storing it in the inbox if delivery fails, then
+// acknowledges the members it now carries.
+func sendRewardBundle(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, bundle *rewardBundle) {
+	sendRewardNow(ctx, nk, logger, userID, bundle.Payload)
+	for _, rewardID := range bundle.MemberIDs {
+		if err := notify.AcknowledgeReward(ctx, nk, userID, rewardID); err != nil {
+			logger.Warn("Failed to clear bundled reward %s for %s: %v", rewardID, userID, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package items
+
+import (
+	"testing"
+	"time"
+
+	"block-server/notify"
+)
+
+// waitForRewardNotifications polls until n reward notifications were sent or a second passes.
+func waitForRewardNotifications(t *testing.T, nk *fakeNakama, n int) []map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		sent := rewardNotifications(nk)
+		if len(sent) >= n || time.Now().After(deadline) {
+			return sent
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRewardsWithinWindowMergeIntoOneNotification(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.RewardBundleWindowMs = 50 })
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	for _, gems := range []int{10, 5} {
+		payload := notify.NewRewardPayload("tournament")
+		payload.ReasonKey = "reward.tournament.payout"
+		payload.Wallet = &notify.WalletDelta{Gems: gems}
+		SendRewardOrStore(ctx, nk, testLogger{}, "u1", payload)
+	}
+	if sent := rewardNotifications(nk); len(sent) != 0 {
+		t.Fatalf("%d notifications sent inside the window, want them held", len(sent))
+	}
+	// Both buffered rewards are tracked, so a restart before the flush still re-sends them.
+	if n := nk.count(notify.StorageCollectionDeliveries, "u1"); n != 2 {
+		t.Errorf("%d delivery records while buffered, want 2", n)
+	}
+
+	sent := waitForRewardNotifications(t, nk, 1)
+	if len(sent) != 1 {
+		t.Fatalf("%d notifications after the window, want 1", len(sent))
+	}
+	if wallet, _ := sent[0]["wallet"].(map[string]interface{}); wallet["gems"] != float64(15) {
+		t.Errorf("bundled wallet = %v, want 15 gems", sent[0]["wallet"])
+	}
+	if n := nk.count(notify.StorageCollectionDeliveries, "u1"); n != 1 {
+		t.Errorf("%d delivery records after the flush, want only the bundle's", n)
+	}
+}
+
+func TestMatchRewardsAreNotBundled(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) { cfg.RewardBundleWindowMs = 50 })
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+
+	match := notify.NewRewardPayload("match")
+	match.Wallet = &notify.WalletDelta{Gold: 20}
+	match.Progression = &notify.ProgressionDelta{XpGranted: notify.IntPtr(40)}
+	match.Meta = &notify.RewardMeta{DailyMatches: notify.IntPtr(1)}
+	SendRewardOrStore(ctx, nk, testLogger{}, "u1", match)
+	if sent := rewardNotifications(nk); len(sent) != 1 {
+		t.Fatalf("%d notifications for a match payload, want it sent at once", len(sent))
+	}
+
+	// A grant with a different reason doesn't join the open bundle and lose its text.
+	for _, reason := range []string{"reward.tournament.payout", "reward.referral.referrer"} {
+		payload := notify.NewRewardPayload("tournament")
+		payload.ReasonKey = reason
+		payload.Wallet = &notify.WalletDelta{Gems: 5}
+		SendRewardOrStore(ctx, nk, testLogger{}, "u1", payload)
+	}
+	sent := waitForRewardNotifications(t, nk, 3)
+	if len(sent) != 3 {
+		t.Fatalf("%d notifications, want the match plus one per reason", len(sent))
+	}
+}
@@ -2,6 +2,7 @@ package items
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -260,6 +261,7 @@ func PrepareRewardItems(ctx context.Context, nk runtime.NakamaModule, logger run
 			Gold: int(walletChanges["gold"]),
 			Gems: int(walletChanges["gems"]),
 		}
+		payload.SetWalletReasonArgs()
 		hasContent = true
 	}
 
@@ -313,9 +315,30 @@ func PrepareExperience(ctx context.Context, nk runtime.NakamaModule, logger runt
 	var resultLevel int
 	var deltaMap map[string]notify.TierState
 
+	// An active XP boost multiplies pet and class XP too; a read failure just skips the boost.
+	if boost, _, boostErr := readXpBoost(ctx, nk, userID); boostErr == nil {
+		exp = uint32(applyXpBoost(int(exp), boost, time.Now()))
+	}
+
+	// Catch-up compares against the user's best item of this type; only read when configured.
+	itemCatchUp := GetEconomyConfig().ItemCatchUpXP
+	baselineLevel, baselineVersion := 0, ""
+	if itemCatchUp.Enabled() {
+		if baselineLevel, baselineVersion, err = readBestItemLevel(ctx, nk, logger, userID, progressionKey); err != nil {
+			LogWarn(ctx, logger, "Failed to read progression baseline, skipping item catch-up")
+			baselineLevel, baselineVersion = 0, ""
+		}
+	}
+
 	// Prepare progression update
+	gained := int(exp)
+	var expBefore, levelBefore, maxLevel int
 	prog, progWrite, err := PrepareProgressionUpdate(ctx, nk, logger, userID, progressionKey, itemID, func(prog *ItemProgression) error {
-		newExp := prog.Exp + int(exp)
+		expBefore, levelBefore = prog.Exp, prog.Level
+		if m := itemCatchUp.multiplier(prog.Level, baselineLevel); m > 1 {
+			gained = int(float64(gained) * m)
+		}
+		newExp := prog.Exp + gained
 
 		// Integer overflow protection
 		if newExp < prog.Exp {
@@ -328,6 +351,7 @@ func PrepareExperience(ctx context.Context, nk runtime.NakamaModule, logger runt
 		}
 
 		// Cap experience at max level threshold
+		maxLevel = tree.MaxLevel
 		maxExp := tree.LevelThresholds[tree.MaxLevel]
 		if newExp > maxExp {
 			newExp = maxExp
@@ -375,6 +399,17 @@ func PrepareExperience(ctx context.Context, nk runtime.NakamaModule, logger runt
 		pending.AddStorageWrite(progWrite)
 	}
 
+	// Keep the best-level record current on level-ups, and store a freshly backfilled one.
+	if resultLevel > 0 && baselineVersion == "" {
+		if baselineLevel, baselineVersion, err = readBestItemLevel(ctx, nk, logger, userID, progressionKey); err != nil {
+			LogWarn(ctx, logger, "Failed to read best item level, not updating it")
+			baselineVersion = ""
+		}
+	}
+	if baselineVersion != "" && (resultLevel > baselineLevel || baselineVersion == "*") {
+		pending.AddStorageWrite(bestItemLevelWrite(userID, progressionKey, max(resultLevel, baselineLevel), baselineVersion))
+	}
+
 	// Add final level to payload
 	if resultLevel > 0 {
 		if pending.Payload == nil {
@@ -383,7 +418,7 @@ func PrepareExperience(ctx context.Context, nk runtime.NakamaModule, logger runt
 		if pending.Payload.Progression == nil {
 			pending.Payload.Progression = &notify.ProgressionDelta{}
 		}
-		pending.Payload.Progression.XpGranted = notify.IntPtr(int(exp))
+		pending.Payload.Progression.XpGranted = notify.IntPtr(gained)
 
 		switch itemType {
 		case storageKeyPet:
@@ -403,7 +438,27 @@ func PrepareExperience(ctx context.Context, nk runtime.NakamaModule, logger runt
 		if pending.Payload.Progression == nil {
 			pending.Payload.Progression = &notify.ProgressionDelta{}
 		}
-		pending.Payload.Progression.XpGranted = notify.IntPtr(int(exp))
+		pending.Payload.Progression.XpGranted = notify.IntPtr(gained)
+	}
+
+	// Resulting state, so clients can redraw the level bar without re-reading progression
+	if prog != nil && pending.Payload != nil && pending.Payload.Progression != nil {
+		pending.Payload.Progression.ItemLevel = notify.IntPtr(max(prog.Level, 1))
+		pending.Payload.Progression.ItemExp = notify.IntPtr(prog.Exp)
+	}
+
+	// Pet achievements hook in here, where XP is applied, so every XP source counts toward them.
+	if itemType == storageKeyPet && prog != nil {
+		increments := map[string]int{AchievementStatPetXP: prog.Exp - expBefore}
+		if prog.Level >= maxLevel && levelBefore < maxLevel {
+			increments[AchievementStatPetsMaxed] = 1
+		}
+		achPending, achErr := PrepareAchievementProgress(ctx, nk, logger, userID, increments)
+		if achErr != nil {
+			LogWarn(ctx, logger, "Failed to prepare pet achievement progress")
+		} else if achPending != nil {
+			pending.Merge(achPending)
+		}
 	}
 
 	return resultLevel, pending, nil
@@ -415,13 +470,29 @@ func CommitPendingWrites(ctx context.Context, nk runtime.NakamaModule, logger ru
 		return nil
 	}
 
-	_, _, err := nk.MultiUpdate(ctx, nil, pending.StorageWrites, nil, pending.WalletUpdates, true)
+	if err := applyDailyEarnCap(ctx, nk, logger, pending); err != nil {
+		LogError(ctx, logger, "Daily earn cap check failed", err)
+		return fmt.Errorf("daily earn cap: %w", err)
+	}
+
+	_, walletResults, err := nk.MultiUpdate(ctx, nil, pending.StorageWrites, nil, pending.WalletUpdates, true)
 	if err != nil {
+		// The ledger is the only race-free balance check: concurrent deductions that each passed
+		// their caller's pre-check are rejected here.
+		var negative *runtime.WalletNegativeError
+		if stderrors.As(err, &negative) {
+			logger.Warn("Commit for user %s rejected: %s would go negative", negative.UserID, negative.Path)
+			return insufficientFundsError(negative.Path)
+		}
 		LogError(ctx, logger, "MultiUpdate commit failed", err)
 		return fmt.Errorf("atomic commit failed: %w", err)
 	}
+	pending.WalletResults = walletResults
+
+	recordPendingWalletDeltas(ctx, nk, logger, pending)
 
 	for _, t := range pending.Telemetry {
+		recordCurrencyFlow(nk, t.Currency, t.Amount)
 		if t.Amount > 0 {
 			EmitServerTelemetry(logger, t.UserID, "currency_gained", map[string]interface{}{
 				"currency": t.Currency,
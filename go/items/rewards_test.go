@@ -0,0 +1,77 @@
+package items
+
+import (
+	"sort"
+	"strconv"
+	"testing"
+
+	"block-server/notify"
+)
+
+func firstPetID(t *testing.T) uint32 {
+	t.Helper()
+	for id := range GameData.Pets {
+		return id
+	}
+	t.Fatal("game data has no pets")
+	return 0
+}
+
+func TestPrepareRewardItemsSetsWalletReasonArgs(t *testing.T) {
+	nk := newFakeNakama()
+	petID := firstPetID(t)
+
+	pending, err := PrepareRewardItems(testContext("u1"), nk, testLogger{}, "u1",
+		map[string]uint32{"gold": 50, "gems": 2}, storageKeyPet, petID, &RewardMutations{}, nil)
+	if err != nil {
+		t.Fatalf("PrepareRewardItems: %v", err)
+	}
+	if pending.Payload == nil || pending.Payload.Wallet == nil {
+		t.Fatalf("expected a wallet payload, got %+v", pending.Payload)
+	}
+	args := pending.Payload.ReasonArgs
+	if args[notify.ReasonArgGold] != "50" || args[notify.ReasonArgGems] != "2" {
+		t.Errorf("reason args = %v, want gold=50 gems=2", args)
+	}
+}
+
+func TestItemCatchUpUsesBestLevelRecord(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.ItemCatchUpXP = ItemCatchUpXPConfig{LevelGap: 5, Multiplier: 2}
+	})
+	var pets []uint32
+	for id := range GameData.Pets {
+		pets = append(pets, id)
+	}
+	if len(pets) < 2 {
+		t.Skip("game data needs two pets")
+	}
+	sort.Slice(pets, func(i, j int) bool { return pets[i] < pets[j] })
+	best, lagging := pets[0], pets[1]
+
+	nk := newFakeNakama()
+	ctx := testContext("u1")
+	nk.put(t, storageCollectionProgression, ProgressionKeyPet+strconv.Itoa(int(best)), "u1", ItemProgression{Level: 10})
+	nk.put(t, storageCollectionProgression, ProgressionKeyPet+strconv.Itoa(int(lagging)), "u1", ItemProgression{Level: 1})
+
+	for i := 0; i < 2; i++ {
+		_, pending, err := PrepareExperience(ctx, nk, testLogger{}, "u1", storageKeyPet, lagging, 10)
+		if err != nil {
+			t.Fatalf("PrepareExperience: %v", err)
+		}
+		if err := CommitPendingWrites(ctx, nk, testLogger{}, pending); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		if got := *pending.Payload.Progression.XpGranted; got != 20 {
+			t.Errorf("grant %d: xp_granted = %d, want the boosted 20", i, got)
+		}
+	}
+	if nk.storageListCalls != 1 {
+		t.Errorf("listed progression %d times, want once to backfill", nk.storageListCalls)
+	}
+	var record bestItemLevel
+	nk.get(t, storageCollectionProgression, bestItemLevelKey(ProgressionKeyPet), "u1", &record)
+	if record.Level != 10 {
+		t.Errorf("best pet level = %d, want 10", record.Level)
+	}
+}
@@ -112,6 +112,11 @@ func RpcReportRoundResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 		}
 	}
 
+	tokensGranted = scaleRematchReward(tokensGranted, activeMatch)
+	if activeMatch.ShadowBanned {
+		tokensGranted = 0
+	}
+
 	// Grant 0 if daily exchanges are exhausted.
 	var dj DailyJourney
 	var djObj *api.StorageObject
@@ -151,7 +156,7 @@ func RpcReportRoundResult(ctx context.Context, logger runtime.Logger, db *sql.DB
 	pending := NewPendingWrites()
 	pending.AddStorageWrite(&runtime.StorageWrite{
 		Collection:      storageCollectionActiveMatch,
-		Key:             storageKeyCurrentMatch,
+		Key:             activeMatch.Key,
 		UserID:          userID,
 		Value:           string(activeMatchBytes),
 		Version:         activeMatch.Version, // OCC protection
@@ -216,3 +221,90 @@ func marshalRoundResponse(ctx context.Context, nk runtime.NakamaModule, logger r
 }
 
 
+
+// TokenStatusResponse is the token progress meter. Token fields are half-units; *Display fields are value / 2.0.
+type TokenStatusResponse struct {
+	RoundTokens        int     `json:"round_tokens"`
+	RoundTokensDisplay float64 `json:"round_tokens_display"`
+	ExchangesLeft      int     `json:"exchanges_left"`
+	ExchangeThresh     int     `json:"exchange_thresh"`
+	TokensToNext       int     `json:"tokens_to_next"` // 0 when no exchanges are left today
+	ResetAt            int64   `json:"reset_at"`       // Next UTC midnight
+}
+
+// RpcGetTokenStatus returns the caller's round-token balance and exchange progress without a match.
+// A journey record from a previous day is reported as already reset, matching what the next match will see.
+func RpcGetTokenStatus(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	dj, _, err := getDailyJourneyState(ctx, logger, nk)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	midnightUTC := utcMidnight(time.Now())
+	resetDailyJourneyIfStale(&dj, midnightUTC)
+
+	thresh := GetEconomyConfig().TokenExchangeThresh
+	resp := TokenStatusResponse{
+		RoundTokens:        dj.RoundTokens,
+		RoundTokensDisplay: float64(dj.RoundTokens) / 2.0,
+		ExchangesLeft:      dj.ExchangesLeft,
+		ExchangeThresh:     thresh,
+		ResetAt:            midnightUTC.AddDate(0, 0, 1).Unix(),
+	}
+	if dj.ExchangesLeft > 0 && thresh > dj.RoundTokens {
+		resp.TokensToNext = thresh - dj.RoundTokens
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(b), nil
+}
+
+// DailyStatsResponse summarizes the caller's UTC day so the client can warn before queueing,
+// e.g. "next match earns reduced XP".
+type DailyStatsResponse struct {
+	MatchesToday     int     `json:"matches_today"`
+	XpMultiplier     float64 `json:"xp_multiplier"`      // Applied to the most recent match today (1.0 before any)
+	NextXpMultiplier float64 `json:"next_xp_multiplier"` // Will apply to the next match
+	NextXpTier       int     `json:"next_xp_tier"`       // Index into economy.daily_xp_curve for the next match
+	RoundTokens      int     `json:"round_tokens"`
+	ExchangesLeft    int     `json:"exchanges_left"` // Token-exchange lootbox drops remaining today
+	ResetAt          int64   `json:"reset_at"`       // Next UTC midnight
+}
+
+// RpcGetDailyStats returns today's match count, XP curve position and token/drop allowance
+// from the same daily journey record the match flow maintains.
+func RpcGetDailyStats(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	dj, _, err := getDailyJourneyState(ctx, logger, nk)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	midnightUTC := utcMidnight(time.Now())
+	resetDailyJourneyIfStale(&dj, midnightUTC)
+
+	economy := GetEconomyConfig()
+	resp := DailyStatsResponse{
+		MatchesToday:     dj.DailyMatches,
+		XpMultiplier:     economy.dailyXPMultiplier(dj.DailyMatches),
+		NextXpMultiplier: economy.dailyXPMultiplier(dj.DailyMatches + 1),
+		NextXpTier:       dj.DailyMatches,
+		RoundTokens:      dj.RoundTokens,
+		ExchangesLeft:    dj.ExchangesLeft,
+		ResetAt:          midnightUTC.AddDate(0, 0, 1).Unix(),
+	}
+	if last := len(economy.DailyXPCurve) - 1; resp.NextXpTier > last {
+		resp.NextXpTier = last
+	}
+	if resp.NextXpTier < 0 {
+		resp.NextXpTier = 0
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(b), nil
+}
@@ -0,0 +1,184 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+type SellItemRequest struct {
+	Type   string `json:"type"` // "background" or "piece_style"
+	ItemID uint32 `json:"item_id"`
+}
+
+type SellItemResponse struct {
+	Success    bool           `json:"success"`
+	GoldGained int            `json:"gold_gained"`
+	Wallet     map[string]int `json:"wallet,omitempty"` // Post-sale wallet state for client reconciliation
+}
+
+// sellableStorageKeys maps the cosmetic types that can be sold back to their inventory keys.
+var sellableStorageKeys = map[string]string{
+	"background":  storageKeyBackground,
+	"piece_style": storageKeyPieceStyle,
+}
+
+// findShopPrice returns the catalog price of a cosmetic, including items only sold through a rotation pool.
+func findShopPrice(itemType string, itemID uint32) (Price, bool) {
+	if shopConfig == nil {
+		return Price{}, false
+	}
+	for i := range shopConfig.ShopItems {
+		item := &shopConfig.ShopItems[i]
+		if item.Pool != "" {
+			for _, p := range shopConfig.ItemPools[item.Pool] {
+				if p.Type == itemType && p.ID == itemID {
+					return item.Price, true
+				}
+			}
+			continue
+		}
+		if item.Type == itemType && item.ItemID == itemID {
+			return item.Price, true
+		}
+	}
+	return Price{}, false
+}
+
+// sellValue is shop.sell_back_percent of the price, with gems converted at the gold exchange rate.
+func sellValue(price Price) int {
+	gold := price.Gold + price.Gems*shopConfig.ExchangeRates.GoldPerGem
+	value := gold * shopConfig.SellBackPercent / 100
+	if value < 1 && gold > 0 {
+		value = 1
+	}
+	return value
+}
+
+func isStarterItem(storageKey string, itemID uint32) bool {
+	starter := GetStarterPack()
+	var ids []uint32
+	switch storageKey {
+	case storageKeyBackground:
+		ids = starter.Backgrounds
+	case storageKeyPieceStyle:
+		ids = starter.PieceStyles
+	}
+	for _, id := range ids {
+		if id == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// RpcSellItem sells an owned background or piece style back for gold.
+// The inventory removal and the gold credit commit in one MultiUpdate.
+func RpcSellItem(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req SellItemRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	if shopConfig == nil {
+		return "", errors.ErrShopNotConfigured
+	}
+	if shopConfig.SellBackPercent <= 0 {
+		return "", errors.ErrItemNotSellable
+	}
+
+	storageKey, ok := sellableStorageKeys[req.Type]
+	if !ok {
+		return "", errors.ErrWrongItemType
+	}
+	if !ValidateItemExists(storageKey, req.ItemID) {
+		return "", errors.ErrInvalidItemID
+	}
+	if isStarterItem(storageKey, req.ItemID) {
+		return "", errors.ErrItemNotSellable
+	}
+	price, listed := findShopPrice(req.Type, req.ItemID)
+	if !listed || (price.Gold <= 0 && price.Gems <= 0) {
+		return "", errors.ErrItemNotSellable
+	}
+
+	owned, err := IsItemOwned(ctx, nk, userID, req.ItemID, storageKey)
+	if err != nil {
+		return "", errors.ErrFailedCheckOwnership
+	}
+	if !owned {
+		return "", errors.ErrNotOwned
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionEquipment,
+		Key:        storageKey,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return "", errors.ErrEquipmentUnavailable
+	}
+	if len(objects) > 0 {
+		var equipped EquipmentData
+		if err := json.Unmarshal([]byte(objects[0].Value), &equipped); err == nil && equipped.ID == req.ItemID {
+			return "", errors.ErrItemEquipped
+		}
+	}
+
+	gold := sellValue(price)
+
+	mutator := NewInventoryMutator()
+	mutator.RemoveItem(storageKey, req.ItemID)
+	pending, err := mutator.CompileWrites(ctx, nk, logger, userID)
+	if err != nil {
+		logger.Error("Failed to prepare sale of %s %d for user %s: %v", req.Type, req.ItemID, userID, err)
+		return "", errors.ErrInventoryFailure
+	}
+	pending.AddWalletUpdate(userID, map[string]int64{"gold": int64(gold)})
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		logger.Error("Sale commit failed for user %s item %s %d: %v", userID, req.Type, req.ItemID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	resp := SellItemResponse{Success: true, GoldGained: gold}
+	if account, err := nk.AccountGetId(ctx, userID); err == nil {
+		var wallet map[string]int64
+		if err := json.Unmarshal([]byte(account.Wallet), &wallet); err == nil {
+			resp.Wallet = map[string]int{
+				"gold": int(wallet["gold"]),
+				"gems": int(wallet["gems"]),
+			}
+		}
+	}
+
+	logger.Info("User %s sold %s %d for %d gold", userID, req.Type, req.ItemID, gold)
+
+	telemetryData, _ := json.Marshal(map[string]interface{}{
+		"action":      "sell_item",
+		"item_type":   req.Type,
+		"item_id":     req.ItemID,
+		"gold_gained": gold,
+	})
+	processTelemetryEvent(context.Background(), logger, db, nk, userID, TelemetryEvent{
+		EventType: "economy_transaction",
+		Timestamp: float64(time.Now().Unix()),
+		Data:      string(telemetryData),
+	})
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,89 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// NotificationSettingsRequest updates notification opt-outs. Omitted fields keep their current value.
+type NotificationSettingsRequest struct {
+	Toasts       *bool `json:"toasts,omitempty"`
+	Social       *bool `json:"social,omitempty"`
+	DailyRefresh *bool `json:"daily_refresh,omitempty"`
+}
+
+// RpcGetNotificationSettings returns the caller's notification settings (defaults if never set).
+func RpcGetNotificationSettings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	settings, err := notify.GetSettings(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read notification settings for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	respBytes, err := json.Marshal(settings)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// RpcSetNotificationSettings merges the request into the caller's stored settings.
+// Critical categories (device, rewards) are not configurable.
+func RpcSetNotificationSettings(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req NotificationSettingsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	settings, err := notify.GetSettings(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read notification settings for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	if req.Toasts != nil {
+		settings.Toasts = *req.Toasts
+	}
+	if req.Social != nil {
+		settings.Social = *req.Social
+	}
+	if req.DailyRefresh != nil {
+		settings.DailyRefresh = *req.DailyRefresh
+	}
+
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      notify.StorageCollectionSettings,
+		Key:             notify.StorageKeySettings,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         settings.Version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to write notification settings for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	return string(value), nil
+}
@@ -0,0 +1,122 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionModeration holds enforcement flags. Owner-unreadable so a flagged player
+	// can't discover the flag from the client.
+	storageCollectionModeration = "moderation"
+	storageKeyShadowBan         = "shadow_ban"
+)
+
+// ShadowBan is the per-user enforcement flag. A shadow-banned player keeps playing, but match
+// rewards are silently withheld and nothing reaches leaderboards or the tournament.
+// The flag is read once at match start and stamped on the active match lock (and from there on the
+// consensus record), so reward paths never read it themselves. A ban applies from the next match.
+type ShadowBan struct {
+	Banned    bool   `json:"banned"`
+	Reason    string `json:"reason,omitempty"`
+	Operator  string `json:"operator"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func shadowBanRead(userID string) *runtime.StorageRead {
+	return &runtime.StorageRead{Collection: storageCollectionModeration, Key: storageKeyShadowBan, UserID: userID}
+}
+
+// shadowBannedIn finds userID's flag in a read batch that included shadowBanRead.
+// Fails open: an unreadable flag must never strip rewards from a legitimate player.
+func shadowBannedIn(logger runtime.Logger, userID string, objects []*api.StorageObject) bool {
+	for _, obj := range objects {
+		if obj.Collection != storageCollectionModeration || obj.Key != storageKeyShadowBan || obj.UserId != userID {
+			continue
+		}
+		var ban ShadowBan
+		if err := json.Unmarshal([]byte(obj.Value), &ban); err != nil {
+			logger.Warn("Failed to unmarshal shadow ban for user %s: %v", userID, err)
+			return false
+		}
+		return ban.Banned
+	}
+	return false
+}
+
+type SetShadowBanRequest struct {
+	Secret   string `json:"secret"`
+	Operator string `json:"operator"`
+	UserID   string `json:"user_id"`
+	Banned   bool   `json:"banned"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// RpcSetShadowBan sets or lifts a user's shadow ban. Admin only.
+// Banning also removes the user's existing leaderboard and tournament records.
+func RpcSetShadowBan(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	var req SetShadowBanRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if !checkAdminSecret(ctx, req.Secret) {
+		logger.Warn("[admin] Rejected set_shadow_ban (operator=%q, target=%s)", req.Operator, req.UserID)
+		return "", errors.ErrAdminUnauthorized
+	}
+	if req.UserID == "" || req.Operator == "" {
+		return "", errors.ErrInvalidInput
+	}
+	if _, err := nk.AccountGetId(ctx, req.UserID); err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+
+	ban := ShadowBan{
+		Banned:    req.Banned,
+		Reason:    req.Reason,
+		Operator:  req.Operator,
+		UpdatedAt: time.Now().Unix(),
+	}
+	value, err := json.Marshal(ban)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	if _, err := storageWriteWithRetry(ctx, nk, logger, []*runtime.StorageWrite{{
+		Collection:      storageCollectionModeration,
+		Key:             storageKeyShadowBan,
+		UserID:          req.UserID,
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("[admin] Failed to write shadow ban for %s: %v", req.UserID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	if req.Banned {
+		for _, lb := range GetLeaderboardsConfig().All() {
+			if err := nk.LeaderboardRecordDelete(ctx, lb.ID, req.UserID); err != nil {
+				logger.Warn("[admin] Failed to remove %s record for %s: %v", lb.ID, req.UserID, err)
+			}
+		}
+		if id := GetTournamentConfig().ID; id != "" {
+			if err := nk.TournamentRecordDelete(ctx, id, req.UserID); err != nil {
+				logger.Warn("[admin] Failed to remove %s record for %s: %v", id, req.UserID, err)
+			}
+		}
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"operator": req.Operator,
+		"target":   req.UserID,
+		"banned":   req.Banned,
+		"reason":   req.Reason,
+	}).Info("[admin] Shadow ban updated")
+	return `{"success": true}`, nil
+}
@@ -0,0 +1,124 @@
+package items
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+const bannedUser = "00000000-0000-0000-0000-000000000005"
+const cleanUser = "00000000-0000-0000-0000-000000000006"
+
+// startMatch runs RpcNotifyMatchStart with the default loadout owned and returns the stored lock.
+func startMatch(t *testing.T, nk *fakeNakama, userID, matchID, opponentID string) *ActiveMatch {
+	t.Helper()
+	ownDefaultLoadout(t, nk, userID)
+	req, _ := json.Marshal(NotifyMatchStartRequest{MatchID: matchID, OpponentID: opponentID})
+	if _, err := RpcNotifyMatchStart(testContext(userID), testLogger{}, nil, nk, string(req)); err != nil {
+		t.Fatalf("RpcNotifyMatchStart: %v", err)
+	}
+	var lock ActiveMatch
+	if !nk.get(t, storageCollectionActiveMatch, activeMatchKey(matchID), userID, &lock) {
+		t.Fatal("match start wrote no lock")
+	}
+	return &lock
+}
+
+// grantFields only appear when something was granted, so they differ between any two matches.
+var grantFields = map[string]bool{"wallet": true, "achievements": true, "lootboxes": true}
+
+// payloadKeys lists a payload's JSON fields one level deep, leaving out grantFields.
+func payloadKeys(t *testing.T, v interface{}) []string {
+	t.Helper()
+	data, _ := json.Marshal(v)
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	var keys []string
+	for k, raw := range m {
+		if grantFields[k] || k == "reason_args" {
+			continue
+		}
+		keys = append(keys, k)
+		var nested map[string]interface{}
+		if json.Unmarshal(raw, &nested) == nil {
+			for nk := range nested {
+				keys = append(keys, k+"."+nk)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestShadowBannedWinIsWithheld(t *testing.T) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.FirstWinBonus = FirstWinBonusConfig{}
+		cfg.LossProtection = LossProtectionConfig{}
+		cfg.StreakMilestones = nil
+	})
+	nk := newFakeNakama()
+	nk.put(t, storageCollectionModeration, storageKeyShadowBan, bannedUser, ShadowBan{Banned: true, Operator: "ops"})
+	today := utcMidnight(time.Now()).Unix()
+	for _, id := range []string{bannedUser, cleanUser} {
+		nk.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, id,
+			DailyJourney{ExchangesLeft: DailyExchangeCap, ResetUnix: today, DailyWarmupClaimed: true})
+	}
+
+	bannedLock := startMatch(t, nk, bannedUser, "m1", "")
+	cleanLock := startMatch(t, nk, cleanUser, "m2", "")
+	if !bannedLock.ShadowBanned || cleanLock.ShadowBanned {
+		t.Fatalf("locks stamped banned=%v clean=%v, want only the banned player flagged", bannedLock.ShadowBanned, cleanLock.ShadowBanned)
+	}
+
+	reads := nk.storageReadCalls
+	banned, err := processMatchRewards(testContext(bannedUser), nk, testLogger{}, bannedUser, soloWin(t, "m1"), true, bannedLock, streakUnresolved)
+	if err != nil {
+		t.Fatalf("banned rewards: %v", err)
+	}
+	// The flag rides on the lock: the only read is the daily journey every match makes.
+	if got := nk.storageReadCalls - reads; got != 1 {
+		t.Errorf("withheld match made %d storage reads, want 1", got)
+	}
+	clean, err := processMatchRewards(testContext(cleanUser), nk, testLogger{}, cleanUser, soloWin(t, "m2"), true, cleanLock, streakUnresolved)
+	if err != nil {
+		t.Fatalf("clean rewards: %v", err)
+	}
+
+	if w := nk.wallet(bannedUser); len(w) != 0 {
+		t.Errorf("banned wallet = %v, want nothing granted", w)
+	}
+	if *banned.Progression.XpGranted != 0 || *banned.Meta.TokensEarned != 0 {
+		t.Errorf("banned payload granted xp %d tokens %d", *banned.Progression.XpGranted, *banned.Meta.TokensEarned)
+	}
+	if got, want := payloadKeys(t, banned), payloadKeys(t, clean); !reflect.DeepEqual(got, want) {
+		t.Errorf("banned payload fields %v differ from a normal payload %v", got, want)
+	}
+
+	if rank, _, _, _ := writeLeaderboardRecords(testContext(bannedUser), nk, testLogger{}, bannedUser, soloWin(t, "m1"), true, true, bannedLock.ShadowBanned); rank != 0 || nk.leaderboardWrites != 0 {
+		t.Errorf("banned win reached the leaderboard: rank %d, %d writes", rank, nk.leaderboardWrites)
+	}
+	if nk.count(storageCollectionActiveMatch, bannedUser) != 0 {
+		t.Error("banned player's lock was not released")
+	}
+}
+
+func TestShadowBanCarriedToOpponentThroughConsensus(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := testContext(bannedUser)
+
+	consensus, _, err := resolveMatchConsensus(ctx, nk, testLogger{}, bannedUser, cleanUser, "m1", true, false, 100, false, true)
+	if err != nil || consensus != "pending" {
+		t.Fatalf("first submit = %q, %v; want pending", consensus, err)
+	}
+	consensus, opponentBanned, err := resolveMatchConsensus(testContext(cleanUser), nk, testLogger{}, cleanUser, bannedUser, "m1", false, false, 50, false, false)
+	if err != nil || consensus != "ok" {
+		t.Fatalf("second submit = %q, %v; want ok", consensus, err)
+	}
+	if !opponentBanned {
+		t.Error("second submitter did not see the first submitter's shadow ban")
+	}
+}
@@ -28,6 +28,54 @@ type ShopConfig struct {
 	IAPProducts        []IAPProduct                `json:"iap_products"`
 	ItemPools          map[string][]PoolItem       `json:"item_pools"`
 	DuplicateFallbacks map[string]DuplicateFallback `json:"duplicate_fallbacks"`
+	StarterOffer       *StarterOffer               `json:"starter_offer,omitempty"`
+	SellBackPercent    int                         `json:"sell_back_percent"` // 0 disables selling cosmetics back
+	ActiveEvent        *ActiveEvent                `json:"active_event,omitempty"`
+
+	// ExhaustedCompensation replaces the duplicate fallback when a pool roll hits a pool the
+	// player owns in full. A box's total is capped at its price_gems.
+	ExhaustedCompensation ExhaustedCompensation `json:"exhausted_compensation"`
+	MatchLootboxes        LootboxConfig         `json:"match_lootboxes"`
+}
+
+// ActiveEvent is a live-ops lootbox event, e.g. a "double item chance" weekend. Inside
+// [StartsAt, EndsAt) every pool chance is multiplied by ItemChanceMultiplier (capped at 1.0) and
+// currency drop maxes by CurrencyMaxMultiplier. Multipliers <= 1 leave that part unchanged.
+type ActiveEvent struct {
+	ID                    string  `json:"id"`
+	StartsAt              string  `json:"starts_at"` // RFC3339
+	EndsAt                string  `json:"ends_at"`   // RFC3339, exclusive
+	ItemChanceMultiplier  float64 `json:"item_chance_multiplier"`
+	CurrencyMaxMultiplier float64 `json:"currency_max_multiplier"`
+
+	start time.Time
+	end   time.Time
+}
+
+// activeAt reports whether the event window contains now. A nil event is never active.
+func (e *ActiveEvent) activeAt(now time.Time) bool {
+	return e != nil && !now.Before(e.start) && now.Before(e.end)
+}
+
+// itemChance scales a pool chance for the event, clamped to 1.0.
+func (e *ActiveEvent) itemChance(chance float64) float64 {
+	if e.ItemChanceMultiplier <= 1 {
+		return chance
+	}
+	chance *= e.ItemChanceMultiplier
+	if chance > 1 {
+		return 1
+	}
+	return chance
+}
+
+// currencyRange scales a currency drop max for the event; min is untouched.
+func (e *ActiveEvent) currencyRange(r DropRange) DropRange {
+	if e.CurrencyMaxMultiplier <= 1 {
+		return r
+	}
+	r.Max = int(float64(r.Max) * e.CurrencyMaxMultiplier)
+	return r
 }
 
 type DuplicateFallback struct {
@@ -35,6 +83,11 @@ type DuplicateFallback struct {
 	Amount   int    `json:"amount"`
 }
 
+type ExhaustedCompensation struct {
+	Gems   int `json:"gems"`
+	Treats int `json:"treats"`
+}
+
 type PoolItem struct {
 	Type string `json:"type"` // "background", "piece_style", "pet", "class"
 	ID   uint32 `json:"id"`
@@ -46,8 +99,13 @@ type ExchangeRates struct {
 }
 
 type LootboxTierDef struct {
-	PriceGems int       `json:"price_gems"`
-	DropTable DropTable `json:"drop_table"`
+	// InheritsFrom names a parent tier. drop_table fields this tier omits are taken from the
+	// parent; price_gems and open_cooldown_seconds are never inherited. Resolved once in LoadShopData.
+	InheritsFrom string    `json:"inherits_from,omitempty"`
+	PriceGems    int       `json:"price_gems"`
+	DropTable    DropTable `json:"drop_table"`
+	// OpenCooldownSeconds is the minimum time between two opens of this tier per user; 0 disables.
+	OpenCooldownSeconds int64 `json:"open_cooldown_seconds,omitempty"`
 }
 
 type DropTable struct {
@@ -55,6 +113,9 @@ type DropTable struct {
 	Gems      DropRange `json:"gems"`
 	Treats    DropRange `json:"treats"`
 	ItemPools []PoolRef `json:"item_pools"`
+	// GuaranteedTotal is an opt-in floor on the rolled gold+gems+treats, valued in gold at the
+	// shop exchange rates. A roll below it has its largest currency topped up. 0 disables.
+	GuaranteedTotal int `json:"guaranteed_total,omitempty"`
 }
 
 // PoolRef defines a named item pool with an independent drop chance (0.0–1.0).
@@ -120,6 +181,35 @@ func LoadShopData() error {
 		return fmt.Errorf("failed to parse shop.json: %w", err)
 	}
 
+	tiers, err := resolveLootboxTiers(shopdata)
+	if err != nil {
+		return err
+	}
+	shopConfig.LootboxTiers = tiers
+
+	for name, tier := range tiers {
+		floor := tier.DropTable.GuaranteedTotal
+		if floor < 0 {
+			return fmt.Errorf("lootbox tier %q: guaranteed_total must not be negative", name)
+		}
+		if floor > 0 && (shopConfig.ExchangeRates.GoldPerGem <= 0 || shopConfig.ExchangeRates.TreatsPerGem <= 0) {
+			return fmt.Errorf("lootbox tier %q: guaranteed_total requires gold_per_gem and treats_per_gem exchange rates", name)
+		}
+	}
+
+	if err := validateMatchLootboxes(&shopConfig.MatchLootboxes, tiers); err != nil {
+		return err
+	}
+
+	// Every pool entry must be grantable; lootbox rolls read the pools in place.
+	for name, pool := range shopConfig.ItemPools {
+		for _, item := range pool {
+			if _, ok := lootboxTypeToStorageKey[item.Type]; !ok {
+				return fmt.Errorf("item pool %q: item %d has unknown type %q", name, item.ID, item.Type)
+			}
+		}
+	}
+
 	// Auto-generate deterministic shop item IDs from type + item_id.
 	// Eliminates stale manual slugs (e.g. "style_pixel" → "piece_style_3").
 	// Lootbox items use their tier name as the identifier.
@@ -137,6 +227,30 @@ func LoadShopData() error {
 		}
 	}
 
+	if event := shopConfig.ActiveEvent; event != nil {
+		if event.start, err = time.Parse(time.RFC3339, event.StartsAt); err != nil {
+			return fmt.Errorf("active_event %q: invalid starts_at: %w", event.ID, err)
+		}
+		if event.end, err = time.Parse(time.RFC3339, event.EndsAt); err != nil {
+			return fmt.Errorf("active_event %q: invalid ends_at: %w", event.ID, err)
+		}
+		if !event.end.After(event.start) {
+			return fmt.Errorf("active_event %q: ends_at must be after starts_at", event.ID)
+		}
+	}
+
+	// The starter pack is purchased by ID through RpcPurchaseShopItem, so it must not shadow a catalog item.
+	if offer := shopConfig.StarterOffer; offer != nil {
+		if offer.ID == "" {
+			return fmt.Errorf("starter_offer requires an id")
+		}
+		for _, item := range shopConfig.ShopItems {
+			if item.ID == offer.ID {
+				return fmt.Errorf("starter_offer id %q collides with shop item", offer.ID)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -187,12 +301,16 @@ type IAPPurchaseGrant struct {
 type PurchaseRequest struct {
 	ShopItemID string `json:"shop_item_id"`
 	RequestId  string `json:"request_id,omitempty"` // Client-generated UUID for idempotency
+	DryRun     bool   `json:"dry_run,omitempty"`    // Validate and preview only; nothing is charged, granted or logged
 }
 
 type PurchaseResponse struct {
-	Success bool           `json:"success"`
-	Error   string         `json:"error,omitempty"`
-	Wallet  map[string]int `json:"wallet,omitempty"` // Post-purchase wallet state for client reconciliation
+	Success bool              `json:"success"`
+	Error   string            `json:"error,omitempty"`
+	Wallet  map[string]int    `json:"wallet,omitempty"` // Post-purchase wallet state for client reconciliation
+	DryRun  bool              `json:"dry_run,omitempty"`
+	Charged *Price            `json:"charged,omitempty"` // Dry run: price that would be charged
+	Grants  []IAPBundleReward `json:"grants,omitempty"`  // Dry run: what would be granted
 }
 
 type PurchaseLootboxRequest struct {
@@ -295,6 +413,10 @@ func RpcPurchaseShopItem(ctx context.Context, logger runtime.Logger, db *sql.DB,
 	if err := json.Unmarshal([]byte(payload), &req); err != nil {
 		return "", errors.ErrUnmarshal
 	}
+	if req.DryRun {
+		// A preview must not replay a cached purchase or write failure entries to the purchase log.
+		req.RequestId = ""
+	}
 
 	// ── Idempotency check ────────────────────────────────────────────────
 	if req.RequestId != "" {
@@ -305,6 +427,11 @@ func RpcPurchaseShopItem(ctx context.Context, logger runtime.Logger, db *sql.DB,
 		}
 	}
 
+	// Starter pack is a bundle with its own lifetime limit, not a catalog item.
+	if offer := shopConfig.StarterOffer; offer != nil && req.ShopItemID == offer.ID {
+		return purchaseStarterOffer(ctx, logger, db, nk, userID, req, offer)
+	}
+
 	// Find the shop item
 	var item *ShopItem
 	var resolvedID, resolvedType string
@@ -386,8 +513,15 @@ func RpcPurchaseShopItem(ctx context.Context, logger runtime.Logger, db *sql.DB,
 	}
 	pending.Merge(itemPending)
 
+	if req.DryRun {
+		return purchasePreview(wallet, item.Price, []IAPBundleReward{{Type: resolvedType, ItemID: resolvedItemID}})
+	}
+
 	// Commit atomically
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return purchaseFail(req.RequestId, userID, nk, logger, err)
+		}
 		logger.Error("Purchase commit failed for user %s item %s: %v", userID, resolvedID, err)
 		return "", errors.ErrInternalError
 	}
@@ -470,14 +604,17 @@ func RpcPurchaseLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB,
 	pending.AddWalletDeduction(userID, "gems", int64(price))
 
 	// Lootbox creation
-	lootbox, lootboxWrite, err := PrepareCreateLootbox(userID, req.Tier)
+	lootbox, lootboxWrites, err := PrepareCreateLootbox(userID, req.Tier)
 	if err != nil {
 		return "", errors.ErrPrepareFailed
 	}
-	pending.AddStorageWrite(lootboxWrite)
+	pending.AddStorageWrites(lootboxWrites...)
 
 	// Commit atomically
 	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return "", err
+		}
 		return "", errors.ErrTransactionFailed
 	}
 
@@ -500,6 +637,101 @@ func RpcPurchaseLootbox(ctx context.Context, logger runtime.Logger, db *sql.DB,
 	return string(respBytes), nil
 }
 
+type BuyTreatsRequest struct {
+	Gems int `json:"gems"` // Gems to spend; each converts at exchange_rates.treats_per_gem
+}
+
+type BuyTreatsResponse struct {
+	TreatsGained int `json:"treats_gained"`
+	Treats       int `json:"treats"` // Post-purchase treat balance
+	Gems         int `json:"gems"`
+}
+
+// RpcBuyTreats converts gems to pet treats at exchange_rates.treats_per_gem.
+// The gem deduction and treat credit commit in one MultiUpdate.
+func RpcBuyTreats(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req BuyTreatsRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if req.Gems <= 0 {
+		return "", errors.ErrInvalidInput
+	}
+
+	if shopConfig == nil {
+		return "", errors.ErrShopNotConfigured
+	}
+	rate := shopConfig.ExchangeRates.TreatsPerGem
+	if rate <= 0 {
+		return "", errors.ErrShopNotConfigured
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	var wallet map[string]int64
+	if err := json.Unmarshal([]byte(account.Wallet), &wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if wallet["gems"] < int64(req.Gems) {
+		return "", errors.ErrInsufficientGems
+	}
+
+	treats := req.Gems * rate
+
+	pending := NewPendingWrites()
+	pending.AddWalletDeduction(userID, "gems", int64(req.Gems))
+	pending.AddWalletUpdate(userID, map[string]int64{"treats": int64(treats)})
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return "", err
+		}
+		logger.Error("Treat purchase commit failed for user %s: %v", userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	resp := BuyTreatsResponse{
+		TreatsGained: treats,
+		Treats:       int(wallet["treats"]) + treats,
+		Gems:         int(wallet["gems"]) - req.Gems,
+	}
+	if account, err := nk.AccountGetId(ctx, userID); err == nil {
+		var post map[string]int64
+		if err := json.Unmarshal([]byte(account.Wallet), &post); err == nil {
+			resp.Treats = int(post["treats"])
+			resp.Gems = int(post["gems"])
+		}
+	}
+
+	logger.Info("User %s bought %d treats for %d gems", userID, treats, req.Gems)
+
+	telemetryData, _ := json.Marshal(map[string]interface{}{
+		"action":       "purchase",
+		"item_id":      "pet_treats",
+		"gems_spent":   req.Gems,
+		"gold_spent":   0,
+		"treats_added": treats,
+	})
+	processTelemetryEvent(context.Background(), logger, db, nk, userID, TelemetryEvent{
+		EventType: "economy_transaction",
+		Timestamp: float64(time.Now().Unix()),
+		Data:      string(telemetryData),
+	})
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
 // Validates Apple IAP (JWS) via Nakama.
 // Idempotent via Nakama's seen_before flag. Server controls gem payout.
 func RpcValidateIAPReceipt(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
@@ -615,26 +847,11 @@ func RpcValidateIAPReceipt(ctx context.Context, logger runtime.Logger, db *sql.D
 		pending.AddWalletUpdate(userID, map[string]int64{"gems": int64(product.Gems)})
 	}
 
-	mutator := NewInventoryMutator()
-	for _, reward := range product.Rewards {
-		if reward.Type == "currency" {
-			pending.AddWalletUpdate(userID, map[string]int64{reward.ID: int64(reward.Amount)})
-		} else if reward.Type == "lootbox" {
-			for i := 0; i < reward.Amount; i++ {
-				_, boxWrite, err := PrepareCreateLootbox(userID, reward.ID)
-				if err == nil && boxWrite != nil {
-					pending.AddStorageWrite(boxWrite)
-				}
-			}
-		} else if reward.Type == "pet" || reward.Type == "class" || reward.Type == "piece_style" || reward.Type == "background" {
-			mutator.AddItem(reward.Type, reward.ItemID)
-		}
-	}
-
-	invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
-	if err == nil && invPending != nil {
-		pending.Merge(invPending)
+	bundlePending, err := prepareBundleRewards(ctx, nk, logger, userID, product.Rewards)
+	if err != nil {
+		logger.Error("%s Bundle item grants failed: %v", logPrefix, err)
 	}
+	pending.Merge(bundlePending)
 
 	grant := IAPPurchaseGrant{
 		OriginalTransactionId: verifiedOrigTxId,
@@ -683,10 +900,8 @@ func RpcValidateIAPReceipt(ctx context.Context, logger runtime.Logger, db *sql.D
 		rewardPayload.Wallet.Gems += product.Gems
 	}
 
-	if err := notify.SendReward(ctx, nk, userID, rewardPayload); err != nil {
-		logger.Error("%s Failed to send reward notification: %v", logPrefix, err)
-		// Non-fatal — items already granted
-	}
+	// Non-fatal — items already granted; a failed send lands in the pending_rewards inbox.
+	SendRewardOrStore(ctx, nk, logger, userID, rewardPayload)
 
 	logger.Info("%s Validated bundle %s txn=%s", logPrefix, product.ProductID, purchase.TransactionId)
 	return `{"success":true}`, nil
@@ -694,29 +909,133 @@ func RpcValidateIAPReceipt(ctx context.Context, logger runtime.Logger, db *sql.D
 
 // Helper functions
 
-func getUserOwnedItems(ctx context.Context, nk runtime.NakamaModule, userID string) map[string][]uint32 {
-	owned := make(map[string][]uint32)
+// prepareBundleRewards stages the grants for a bundle's reward list (IAP products, starter pack).
+// Currency is staged as wallet updates only; item grants carry their own payload via the mutator.
+// On an inventory error the currency/lootbox writes are still returned alongside the error.
+func prepareBundleRewards(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, rewards []IAPBundleReward) (*PendingWrites, error) {
+	pending := NewPendingWrites()
+	mutator := NewInventoryMutator()
+	for _, reward := range rewards {
+		if reward.Type == "currency" {
+			pending.AddWalletUpdate(userID, map[string]int64{reward.ID: int64(reward.Amount)})
+		} else if reward.Type == "lootbox" {
+			for i := 0; i < reward.Amount; i++ {
+				_, boxWrites, err := PrepareCreateLootbox(userID, reward.ID)
+				if err == nil {
+					pending.AddStorageWrites(boxWrites...)
+				}
+			}
+		} else if reward.Type == "pet" || reward.Type == "class" || reward.Type == "piece_style" || reward.Type == "background" {
+			mutator.AddItem(reward.Type, reward.ItemID)
+		}
+	}
+
+	invPending, err := mutator.CompileWrites(ctx, nk, logger, userID)
+	if err != nil {
+		return pending, err
+	}
+	pending.Merge(invPending)
+	return pending, nil
+}
+
+// resolveLootboxTiers flattens inherits_from chains into complete tier definitions.
+// Merging is presence-based on the raw JSON, so a child can explicitly zero a parent field
+// (e.g. "gems": {"min": 0, "max": 0}) and still differ from "not specified".
+func resolveLootboxTiers(data []byte) (map[string]LootboxTierDef, error) {
+	var raw struct {
+		LootboxTiers map[string]map[string]json.RawMessage `json:"lootbox_tiers"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse lootbox tiers: %w", err)
+	}
+
+	resolved := make(map[string]map[string]json.RawMessage, len(raw.LootboxTiers))
+	visiting := make(map[string]bool)
 
-	// Read inventory for each type
-	types := []string{storageKeyBackground, storageKeyPieceStyle}
-	for _, t := range types {
-		objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
-			Collection: storageCollectionInventory,
-			Key:        t,
-			UserID:     userID,
-		}})
-		if err != nil || len(objects) == 0 {
-			continue
+	var resolve func(name string) (map[string]json.RawMessage, error)
+	resolve = func(name string) (map[string]json.RawMessage, error) {
+		if tier, ok := resolved[name]; ok {
+			return tier, nil
+		}
+		tier, ok := raw.LootboxTiers[name]
+		if !ok {
+			return nil, fmt.Errorf("lootbox tier %q not defined", name)
 		}
+		if visiting[name] {
+			return nil, fmt.Errorf("lootbox tier inheritance cycle at %q", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
 
-		var inv InventoryData
-		if err := json.Unmarshal([]byte(objects[0].Value), &inv); err != nil {
-			continue
+		var parentName string
+		if rawParent, ok := tier["inherits_from"]; ok {
+			if err := json.Unmarshal(rawParent, &parentName); err != nil {
+				return nil, fmt.Errorf("lootbox tier %q has invalid inherits_from: %w", name, err)
+			}
+		}
+		if parentName == "" {
+			resolved[name] = tier
+			return tier, nil
 		}
-		owned[t] = inv.Items
+
+		parent, err := resolve(parentName)
+		if err != nil {
+			return nil, fmt.Errorf("lootbox tier %q: %w", name, err)
+		}
+
+		var parentDrops, childDrops map[string]json.RawMessage
+		if rawDrops, ok := parent["drop_table"]; ok {
+			if err := json.Unmarshal(rawDrops, &parentDrops); err != nil {
+				return nil, fmt.Errorf("lootbox tier %q has invalid drop_table: %w", parentName, err)
+			}
+		}
+		if rawDrops, ok := tier["drop_table"]; ok {
+			if err := json.Unmarshal(rawDrops, &childDrops); err != nil {
+				return nil, fmt.Errorf("lootbox tier %q has invalid drop_table: %w", name, err)
+			}
+		}
+		merged := make(map[string]json.RawMessage, len(parentDrops)+len(childDrops))
+		for k, v := range parentDrops {
+			merged[k] = v
+		}
+		for k, v := range childDrops {
+			merged[k] = v
+		}
+		mergedDrops, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+
+		flat := make(map[string]json.RawMessage, len(tier))
+		for k, v := range tier {
+			flat[k] = v
+		}
+		flat["drop_table"] = mergedDrops
+		resolved[name] = flat
+		return flat, nil
 	}
 
-	return owned
+	tiers := make(map[string]LootboxTierDef, len(raw.LootboxTiers))
+	for name := range raw.LootboxTiers {
+		flat, err := resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		flatBytes, err := json.Marshal(flat)
+		if err != nil {
+			return nil, err
+		}
+		var def LootboxTierDef
+		if err := json.Unmarshal(flatBytes, &def); err != nil {
+			return nil, fmt.Errorf("lootbox tier %q: %w", name, err)
+		}
+		tiers[name] = def
+	}
+	return tiers, nil
+}
+
+func getUserOwnedItems(ctx context.Context, nk runtime.NakamaModule, userID string) map[string][]uint32 {
+	return readOwnedItems(ctx, nk, userID, []string{storageKeyBackground, storageKeyPieceStyle})
 }
 
 func isItemOwned(owned map[string][]uint32, itemType string, itemID uint32) bool {
@@ -826,7 +1145,7 @@ func getNextRotationTime() int64 {
 // checkPurchaseLog reads a previously processed purchase from storage.
 // Returns the cached PurchaseResponse if found, nil if not.
 func checkPurchaseLog(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, requestId string) (*PurchaseResponse, error) {
-	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
 		Collection: storageCollectionShopHistory,
 		Key:        requestId,
 		UserID:     userID,
@@ -886,6 +1205,32 @@ func purchaseFail(requestId, userID string, nk runtime.NakamaModule, logger runt
 	return "", err
 }
 
+// purchasePreview builds the dry-run response: the price that would be charged, what would be
+// granted, and the wallet as it would stand afterwards.
+func purchasePreview(wallet map[string]int64, price Price, grants []IAPBundleReward) (string, error) {
+	projected := map[string]int{
+		"gold": int(wallet["gold"]),
+		"gems": int(wallet["gems"]),
+	}
+	if price.Gems > 0 {
+		projected["gems"] -= price.Gems
+	} else if price.Gold > 0 {
+		projected["gold"] -= price.Gold
+	}
+	for _, grant := range grants {
+		if _, tracked := projected[grant.ID]; tracked && grant.Type == "currency" {
+			projected[grant.ID] += grant.Amount
+		}
+	}
+
+	resp := PurchaseResponse{Success: true, Wallet: projected, DryRun: true, Charged: &price, Grants: grants}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
 // ── Revocation RPC ───────────────────────────────────────────────────────────
 
 // Handles server-side IAP revocation from Apple App Store Server Notifications or admin action.
@@ -903,7 +1248,7 @@ func RpcRevokeIAPPurchase(ctx context.Context, logger runtime.Logger, db *sql.DB
 	if !ok || userID == "" {
 		// S2S Execution: Webhook proxy must provide user_id (extracted from appAccountToken)
 		if req.UserId == "" {
-			return "", fmt.Errorf("missing user_id for server-to-server invocation")
+			return "", errors.ErrNoUserIdFound
 		}
 		userID = req.UserId
 	}
@@ -923,7 +1268,8 @@ func RpcRevokeIAPPurchase(ctx context.Context, logger runtime.Logger, db *sql.DB
 
 	var grant IAPPurchaseGrant
 	if err := json.Unmarshal([]byte(objects[0].Value), &grant); err != nil {
-		return "", fmt.Errorf("corrupt grant record: %w", err)
+		logger.Error("%s Corrupt grant record: %v", logPrefix, err)
+		return "", errors.ErrCouldNotUnmarshal
 	}
 
 	if grant.Status == "revoked" {
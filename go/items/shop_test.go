@@ -0,0 +1,53 @@
+package items
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLootboxTierInheritsUnspecifiedFields(t *testing.T) {
+	tiers, err := resolveLootboxTiers([]byte(`{"lootbox_tiers": {
+		"standard": {"price_gems": 10, "open_cooldown_seconds": 60, "drop_table": {
+			"gold": {"min": 10, "max": 20},
+			"gems": {"min": 1, "max": 2},
+			"item_pools": [{"pool": "backgrounds", "chance": 0.5}]
+		}},
+		"premium": {"inherits_from": "standard", "price_gems": 50, "drop_table": {
+			"gold": {"min": 100, "max": 200},
+			"gems": {"min": 0, "max": 0}
+		}}
+	}}`))
+	if err != nil {
+		t.Fatalf("resolveLootboxTiers: %v", err)
+	}
+
+	premium := tiers["premium"]
+	dt := premium.DropTable
+	if dt.Gold.Min != 100 || dt.Gold.Max != 200 {
+		t.Errorf("gold = %+v, want the child's own range", dt.Gold)
+	}
+	if dt.Gems.Max != 0 {
+		t.Errorf("gems = %+v, want the child's explicit zero", dt.Gems)
+	}
+	if len(dt.ItemPools) != 1 || dt.ItemPools[0].Pool != "backgrounds" {
+		t.Errorf("item_pools = %+v, want the parent's pools", dt.ItemPools)
+	}
+	if premium.PriceGems != 50 || premium.OpenCooldownSeconds != 0 {
+		t.Errorf("price %d cooldown %d, want 50 and no inherited cooldown", premium.PriceGems, premium.OpenCooldownSeconds)
+	}
+}
+
+func TestLootboxTierInheritanceErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"cycle":          `{"lootbox_tiers": {"a": {"inherits_from": "b"}, "b": {"inherits_from": "a"}}}`,
+		"unknown parent": `{"lootbox_tiers": {"a": {"inherits_from": "missing"}}}`,
+	} {
+		if _, err := resolveLootboxTiers([]byte(data)); err == nil {
+			t.Errorf("%s: resolved without error", name)
+		}
+	}
+	_, err := resolveLootboxTiers([]byte(`{"lootbox_tiers": {"a": {"inherits_from": "a"}}}`))
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("self inheritance err = %v, want a cycle error", err)
+	}
+}
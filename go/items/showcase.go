@@ -0,0 +1,186 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionProfile holds publicly readable profile data; "showcase" is the pinned cosmetics.
+	storageCollectionProfile = "profile"
+	storageKeyShowcase       = "showcase"
+	maxShowcaseItems         = 6
+)
+
+// ShowcaseItem is one pinned item. Type is pet, class, background or piece_style.
+type ShowcaseItem struct {
+	Type string `json:"type"`
+	ID   uint32 `json:"id"`
+}
+
+type Showcase struct {
+	Items     []ShowcaseItem `json:"items"`
+	UpdatedAt int64          `json:"updated_at"`
+}
+
+type SetShowcaseRequest struct {
+	Items []ShowcaseItem `json:"items"`
+}
+
+type GetShowcaseRequest struct {
+	UserID string `json:"user_id,omitempty"` // Defaults to the caller
+}
+
+// readOwnedForShowcase loads the inventory keys the items need in one StorageRead.
+func readOwnedForShowcase(ctx context.Context, nk runtime.NakamaModule, userID string, items []ShowcaseItem) (map[string]InventoryData, error) {
+	keys := map[string]bool{}
+	var reads []*runtime.StorageRead
+	for _, item := range items {
+		key := lootboxTypeToStorageKey[item.Type]
+		if key == "" || keys[key] {
+			continue
+		}
+		keys[key] = true
+		reads = append(reads, &runtime.StorageRead{Collection: storageCollectionInventory, Key: key, UserID: userID})
+	}
+	owned := make(map[string]InventoryData, len(reads))
+	if len(reads) == 0 {
+		return owned, nil
+	}
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		data, err := UnmarshalJSON[InventoryData](obj.Value)
+		if err != nil {
+			return nil, err
+		}
+		owned[obj.Key] = *data
+	}
+	return owned, nil
+}
+
+// RpcSetShowcase pins up to maxShowcaseItems owned items to the caller's public profile.
+// An empty list clears the showcase.
+func RpcSetShowcase(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req SetShowcaseRequest
+	if err := json.Unmarshal([]byte(payload), &req); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if len(req.Items) > maxShowcaseItems {
+		return "", errors.ErrInvalidInput
+	}
+
+	seen := map[ShowcaseItem]bool{}
+	for _, item := range req.Items {
+		key, ok := lootboxTypeToStorageKey[item.Type]
+		if !ok {
+			return "", errors.ErrWrongItemType
+		}
+		if !ValidateItemExists(key, item.ID) {
+			return "", errors.ErrInvalidItemID
+		}
+		if seen[item] {
+			return "", errors.ErrInvalidInput
+		}
+		seen[item] = true
+	}
+
+	owned, err := readOwnedForShowcase(ctx, nk, userID, req.Items)
+	if err != nil {
+		return "", errors.ErrFailedCheckOwnership
+	}
+	for _, item := range req.Items {
+		data := owned[lootboxTypeToStorageKey[item.Type]]
+		if !data.Has(item.ID) {
+			return "", errors.ErrNotOwned
+		}
+	}
+
+	showcase := Showcase{Items: req.Items, UpdatedAt: time.Now().Unix()}
+	if showcase.Items == nil {
+		showcase.Items = []ShowcaseItem{}
+	}
+	value, err := json.Marshal(showcase)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionProfile,
+		Key:             storageKeyShowcase,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  2, // Public read — other players view it on the profile card
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.Error("Failed to write showcase for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotWriteStorage
+	}
+
+	return string(value), nil
+}
+
+// RpcGetShowcase returns any player's showcase. Items no longer owned (e.g. sold) are omitted.
+func RpcGetShowcase(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	callerID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req GetShowcaseRequest
+	if payload != "" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+	}
+	userID := req.UserID
+	if userID == "" {
+		userID = callerID
+	}
+
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProfile,
+		Key:        storageKeyShowcase,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	showcase := Showcase{Items: []ShowcaseItem{}}
+	if len(objects) > 0 {
+		if err := json.Unmarshal([]byte(objects[0].Value), &showcase); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+		owned, err := readOwnedForShowcase(ctx, nk, userID, showcase.Items)
+		if err != nil {
+			return "", errors.ErrFailedCheckOwnership
+		}
+		kept := make([]ShowcaseItem, 0, len(showcase.Items))
+		for _, item := range showcase.Items {
+			data := owned[lootboxTypeToStorageKey[item.Type]]
+			if data.Has(item.ID) {
+				kept = append(kept, item)
+			}
+		}
+		showcase.Items = kept
+	}
+
+	respBytes, err := json.Marshal(showcase)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
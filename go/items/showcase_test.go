@@ -0,0 +1,46 @@
+package items
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"block-server/errors"
+)
+
+func TestShowcaseRequiresOwnershipAndIsPublic(t *testing.T) {
+	nk := newFakeNakama()
+	owner, viewer := "00000000-0000-0000-0000-000000000061", "00000000-0000-0000-0000-000000000062"
+	petID := firstPetID(t)
+	payload := `{"items":[{"type":"pet","id":` + strconv.FormatUint(uint64(petID), 10) + `}]}`
+
+	if _, err := RpcSetShowcase(testContext(owner), testLogger{}, nil, nk, payload); err != errors.ErrNotOwned {
+		t.Fatalf("unowned pet: err = %v, want ErrNotOwned", err)
+	}
+	if nk.count(storageCollectionProfile, owner) != 0 {
+		t.Fatal("rejected showcase was written")
+	}
+
+	if err := GivePet(testContext(owner), nk, testLogger{}, owner, petID); err != nil {
+		t.Fatalf("GivePet: %v", err)
+	}
+	if _, err := RpcSetShowcase(testContext(owner), testLogger{}, nil, nk, payload); err != nil {
+		t.Fatalf("RpcSetShowcase: %v", err)
+	}
+
+	if stored := nk.storage[storageID{storageCollectionProfile, storageKeyShowcase, owner}]; stored == nil || stored.PermissionRead != 2 {
+		t.Errorf("stored showcase = %v, want public read permission 2", stored)
+	}
+
+	resp, err := RpcGetShowcase(testContext(viewer), testLogger{}, nil, nk, `{"user_id":"`+owner+`"}`)
+	if err != nil {
+		t.Fatalf("RpcGetShowcase: %v", err)
+	}
+	var showcase Showcase
+	if err := json.Unmarshal([]byte(resp), &showcase); err != nil {
+		t.Fatalf("unmarshal showcase: %v", err)
+	}
+	if len(showcase.Items) != 1 || showcase.Items[0] != (ShowcaseItem{Type: "pet", ID: petID}) {
+		t.Errorf("viewer sees %+v, want the owner's pinned pet", showcase.Items)
+	}
+}
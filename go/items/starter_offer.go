@@ -0,0 +1,259 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// StarterOffer is the one-time discounted starter pack sold through RpcPurchaseShopItem.
+// Not to be confused with StarterPack, the free items granted at signup.
+type StarterOffer struct {
+	ID               string            `json:"id"`
+	Price            Price             `json:"price"`
+	Rewards          []IAPBundleReward `json:"rewards"`
+	LifetimeLimit    int               `json:"lifetime_limit"`     // Defaults to 1
+	OfferWindowHours int               `json:"offer_window_hours"` // 0 = offered forever; otherwise hours since signup
+}
+
+// PurchaseLimitRecord counts lifetime purchases of a limited shop offer. Lives in shop_history
+// next to the purchase log and is written in the same commit as the grant (OCC-guarded).
+type PurchaseLimitRecord struct {
+	Count           int   `json:"count"`
+	LastPurchasedAt int64 `json:"last_purchased_at"`
+}
+
+type StarterPackStatusResponse struct {
+	Available   bool              `json:"available"`
+	Purchased   bool              `json:"purchased"`
+	Expired     bool              `json:"expired"`
+	ExpiresAt   int64             `json:"expires_at,omitempty"`
+	PurchasedAt int64             `json:"purchased_at,omitempty"`
+	ShopItemID  string            `json:"shop_item_id,omitempty"`
+	Price       Price             `json:"price"`
+	Rewards     []IAPBundleReward `json:"rewards,omitempty"`
+}
+
+func purchaseLimitKey(offerID string) string {
+	return "limit_" + offerID
+}
+
+func (o *StarterOffer) lifetimeLimit() int {
+	if o.LifetimeLimit <= 0 {
+		return 1
+	}
+	return o.LifetimeLimit
+}
+
+// offerExpiresAt returns the unix time the offer closes for an account created at createdAt, or 0 if it never does.
+func (o *StarterOffer) offerExpiresAt(createdAt time.Time) int64 {
+	if o.OfferWindowHours <= 0 || createdAt.IsZero() {
+		return 0
+	}
+	return createdAt.Add(time.Duration(o.OfferWindowHours) * time.Hour).Unix()
+}
+
+// readPurchaseLimit returns the limit record and its version ("" if never purchased).
+func readPurchaseLimit(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, offerID string) (*PurchaseLimitRecord, string, error) {
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
+		Collection: storageCollectionShopHistory,
+		Key:        purchaseLimitKey(offerID),
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	record := &PurchaseLimitRecord{}
+	if len(objects) == 0 {
+		return record, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), record); err != nil {
+		return nil, "", err
+	}
+	return record, objects[0].Version, nil
+}
+
+// starterOfferStatus evaluates the offer against the account's signup time and purchase record.
+func starterOfferStatus(offer *StarterOffer, createdAt time.Time, record *PurchaseLimitRecord, now time.Time) StarterPackStatusResponse {
+	status := StarterPackStatusResponse{
+		ShopItemID: offer.ID,
+		Price:      offer.Price,
+		Rewards:    offer.Rewards,
+		ExpiresAt:  offer.offerExpiresAt(createdAt),
+	}
+	if record != nil && record.Count >= offer.lifetimeLimit() {
+		status.Purchased = true
+		status.PurchasedAt = record.LastPurchasedAt
+	}
+	if status.ExpiresAt > 0 && now.Unix() >= status.ExpiresAt {
+		status.Expired = true
+	}
+	status.Available = !status.Purchased && !status.Expired
+	return status
+}
+
+// RpcGetStarterPackStatus reports whether the starter pack can still be bought.
+func RpcGetStarterPackStatus(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+	if shopConfig == nil {
+		return "", errors.ErrShopNotConfigured
+	}
+
+	offer := shopConfig.StarterOffer
+	if offer == nil {
+		return `{"available":false}`, nil
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	record, _, err := readPurchaseLimit(ctx, nk, logger, userID, offer.ID)
+	if err != nil {
+		logger.WithField("err", err).Error("starter pack limit read failed")
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	status := starterOfferStatus(offer, account.GetUser().GetCreateTime().AsTime(), record, time.Now())
+	respBytes, err := json.Marshal(status)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
+
+// purchaseStarterOffer is RpcPurchaseShopItem's bundle path for the starter pack.
+// The lifetime limit record is written in the same commit as the grant, so a concurrent
+// second purchase loses the OCC race instead of double-granting.
+func purchaseStarterOffer(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, userID string, req PurchaseRequest, offer *StarterOffer) (string, error) {
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	var wallet map[string]int64
+	if err := json.Unmarshal([]byte(account.Wallet), &wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+
+	record, version, err := readPurchaseLimit(ctx, nk, logger, userID, offer.ID)
+	if err != nil {
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	now := time.Now()
+	status := starterOfferStatus(offer, account.GetUser().GetCreateTime().AsTime(), record, now)
+	if status.Purchased {
+		return purchaseFail(req.RequestId, userID, nk, logger, errors.ErrOfferAlreadyPurchased)
+	}
+	if status.Expired {
+		return purchaseFail(req.RequestId, userID, nk, logger, errors.ErrOfferExpired)
+	}
+
+	pending := NewPendingWrites()
+	if offer.Price.Gems > 0 {
+		if wallet["gems"] < int64(offer.Price.Gems) {
+			return purchaseFail(req.RequestId, userID, nk, logger, errors.ErrInsufficientGems)
+		}
+		pending.AddWalletDeduction(userID, "gems", int64(offer.Price.Gems))
+	} else if offer.Price.Gold > 0 {
+		if wallet["gold"] < int64(offer.Price.Gold) {
+			return purchaseFail(req.RequestId, userID, nk, logger, errors.ErrInsufficientGold)
+		}
+		pending.AddWalletDeduction(userID, "gold", int64(offer.Price.Gold))
+	}
+
+	bundlePending, err := prepareBundleRewards(ctx, nk, logger, userID, offer.Rewards)
+	if err != nil {
+		logger.WithField("err", err).Error("starter pack grant prepare failed")
+		return purchaseFail(req.RequestId, userID, nk, logger, errors.ErrInternalError)
+	}
+	pending.Merge(bundlePending)
+
+	if req.DryRun {
+		return purchasePreview(wallet, offer.Price, offer.Rewards)
+	}
+
+	record.Count++
+	record.LastPurchasedAt = now.Unix()
+	recordBytes, _ := json.Marshal(record)
+	if version == "" {
+		version = "*" // First purchase: create-only
+	}
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionShopHistory,
+		Key:             purchaseLimitKey(offer.ID),
+		UserID:          userID,
+		Value:           string(recordBytes),
+		Version:         version,
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	})
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return purchaseFail(req.RequestId, userID, nk, logger, err)
+		}
+		logger.Error("Starter pack commit failed for user %s: %v", userID, err)
+		return "", errors.ErrInternalError
+	}
+
+	updatedWallet := map[string]int{
+		"gold": int(wallet["gold"]),
+		"gems": int(wallet["gems"]),
+	}
+	if offer.Price.Gems > 0 {
+		updatedWallet["gems"] -= offer.Price.Gems
+	} else if offer.Price.Gold > 0 {
+		updatedWallet["gold"] -= offer.Price.Gold
+	}
+
+	// Currency rewards aren't in the mutator payload; add them so the client ceremony shows them.
+	rewardPayload := pending.Payload
+	if rewardPayload == nil {
+		rewardPayload = notify.NewRewardPayload("starter_pack")
+	}
+	for _, reward := range offer.Rewards {
+		if reward.Type != "currency" {
+			continue
+		}
+		if rewardPayload.Wallet == nil {
+			rewardPayload.Wallet = &notify.WalletDelta{}
+		}
+		switch reward.ID {
+		case "gold":
+			rewardPayload.Wallet.Gold += reward.Amount
+		case "gems":
+			rewardPayload.Wallet.Gems += reward.Amount
+		case "treats":
+			rewardPayload.Wallet.Treats += reward.Amount
+		}
+		if _, tracked := updatedWallet[reward.ID]; tracked {
+			updatedWallet[reward.ID] += reward.Amount
+		}
+	}
+	SendRewardOrStore(ctx, nk, logger, userID, rewardPayload)
+
+	if req.RequestId != "" {
+		writePurchaseLog(ctx, nk, userID, req.RequestId, offer.ID, offer.Price.Gems, offer.Price.Gold, true, updatedWallet)
+	}
+
+	logger.Info("User %s purchased starter pack %s", userID, offer.ID)
+	EmitServerTelemetry(logger, userID, "starter_pack_purchase", map[string]interface{}{
+		"item_id":    offer.ID,
+		"gems_spent": offer.Price.Gems,
+		"gold_spent": offer.Price.Gold,
+	})
+
+	resp := PurchaseResponse{Success: true, Wallet: updatedWallet}
+	respBytes, _ := json.Marshal(resp)
+	return string(respBytes), nil
+}
@@ -0,0 +1,78 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"block-server/errors"
+)
+
+const starterTestUser = "00000000-0000-0000-0000-000000000002"
+
+func starterPackStatus(t *testing.T, nk *fakeNakama) StarterPackStatusResponse {
+	t.Helper()
+	resp, err := RpcGetStarterPackStatus(testContext(starterTestUser), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetStarterPackStatus: %v", err)
+	}
+	var status StarterPackStatusResponse
+	if err := json.Unmarshal([]byte(resp), &status); err != nil {
+		t.Fatalf("unmarshal status: %v", err)
+	}
+	return status
+}
+
+func buyStarterPack(nk *fakeNakama) error {
+	offer := GetShopConfig().StarterOffer
+	_, err := RpcPurchaseShopItem(testContext(starterTestUser), testLogger{}, nil, nk, `{"shop_item_id":"`+offer.ID+`"}`)
+	return err
+}
+
+func TestStarterPackAlreadyPurchased(t *testing.T) {
+	offer := GetShopConfig().StarterOffer
+	if offer == nil {
+		t.Skip("no starter offer configured")
+	}
+	nk := newFakeNakama()
+	nk.setAccountCreated(starterTestUser, time.Now())
+	nk.setWallet(starterTestUser, map[string]int64{"gems": int64(offer.Price.Gems) * 2, "gold": int64(offer.Price.Gold) * 2})
+
+	if status := starterPackStatus(t, nk); !status.Available {
+		t.Fatalf("status = %+v, want available for a new account", status)
+	}
+	if err := buyStarterPack(nk); err != nil {
+		t.Fatalf("first purchase: %v", err)
+	}
+	walletAfterFirst := nk.wallet(starterTestUser)
+
+	if err := buyStarterPack(nk); err != errors.ErrOfferAlreadyPurchased {
+		t.Fatalf("second purchase err = %v, want ErrOfferAlreadyPurchased", err)
+	}
+	if got := nk.wallet(starterTestUser); got["gems"] != walletAfterFirst["gems"] || got["gold"] != walletAfterFirst["gold"] {
+		t.Errorf("wallet changed on a rejected purchase: %v -> %v", walletAfterFirst, got)
+	}
+	if status := starterPackStatus(t, nk); status.Available || !status.Purchased {
+		t.Errorf("status = %+v, want purchased and unavailable", status)
+	}
+}
+
+func TestStarterPackWindowExpired(t *testing.T) {
+	offer := GetShopConfig().StarterOffer
+	if offer == nil || offer.OfferWindowHours <= 0 {
+		t.Skip("no time-limited starter offer configured")
+	}
+	nk := newFakeNakama()
+	nk.setAccountCreated(starterTestUser, time.Now().Add(-time.Duration(offer.OfferWindowHours+1)*time.Hour))
+	nk.setWallet(starterTestUser, map[string]int64{"gems": int64(offer.Price.Gems), "gold": int64(offer.Price.Gold)})
+
+	if status := starterPackStatus(t, nk); status.Available || !status.Expired {
+		t.Errorf("status = %+v, want expired and unavailable", status)
+	}
+	if err := buyStarterPack(nk); err != errors.ErrOfferExpired {
+		t.Fatalf("purchase err = %v, want ErrOfferExpired", err)
+	}
+	if gems := nk.wallet(starterTestUser)["gems"]; gems != int64(offer.Price.Gems) {
+		t.Errorf("gems = %d after an expired purchase, want unchanged %d", gems, offer.Price.Gems)
+	}
+}
@@ -9,29 +9,45 @@ import (
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
+// listAllStorageMaxPages caps one listStorageFrom call, bounding a client-facing response.
 const listAllStorageMaxPages = 10
 
-// listAllStorage fetches all records from a storage collection using cursor pagination
+// listAllStorage fetches all records from a storage collection using cursor pagination,
+// following the cursor until it is exhausted.
 func listAllStorage(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger,
 	userID string, collection string) ([]*api.StorageObject, error) {
 	var all []*api.StorageObject
 	cursor := ""
-	for i := 0; i < listAllStorageMaxPages; i++ {
-		objects, nextCursor, err := nk.StorageList(ctx, "", userID, collection, 100, cursor)
+	for {
+		objects, nextCursor, err := listStorageFrom(ctx, nk, logger, userID, collection, cursor)
 		if err != nil {
 			return nil, err
 		}
 		all = append(all, objects...)
 		if nextCursor == "" {
-			break
+			return all, nil
 		}
 		cursor = nextCursor
-		if i == listAllStorageMaxPages-1 {
-			logger.Warn("listAllStorage hit page cap for collection %s, user %s (%d items so far)",
-				collection, userID, len(all))
+	}
+}
+
+// listStorageFrom lists up to listAllStorageMaxPages pages starting at cursor. The returned cursor
+// is non-empty when the page cap was hit with records still left; pass it back to continue.
+func listStorageFrom(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger,
+	userID string, collection string, cursor string) ([]*api.StorageObject, string, error) {
+	var all []*api.StorageObject
+	for i := 0; i < listAllStorageMaxPages; i++ {
+		objects, nextCursor, err := nk.StorageList(ctx, "", userID, collection, 100, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		all = append(all, objects...)
+		cursor = nextCursor
+		if nextCursor == "" {
+			break
 		}
 	}
-	return all, nil
+	return all, cursor, nil
 }
 
 func GetUserInventory(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) (*InventoryResponse, error) {
@@ -49,38 +65,76 @@ func GetUserInventory(ctx context.Context, nk runtime.NakamaModule, logger runti
 		{Collection: storageCollectionInventory, Key: storageKeyPieceStyle, UserID: userID},
 	}
 
-	objs, err := nk.StorageRead(ctx, reads)
+	objs, err := storageReadWithRetry(ctx, nk, logger, reads)
 	if err != nil {
 		logger.WithField("error", err.Error()).Error("Failed to read user inventory")
 		return nil, err
 	}
 
 	for _, obj := range objs {
+		applyInventoryObject(logger, inventory, obj)
+	}
+
+	return inventory, nil
+}
+
+// applyInventoryObject decodes one inventory storage object into the matching response field.
+func applyInventoryObject(logger runtime.Logger, inventory *InventoryResponse, obj *api.StorageObject) {
+	if obj == nil {
+		return
+	}
+
+	data, err := UnmarshalJSON[InventoryData](obj.Value)
+	if err != nil {
+		logger.WithField("error", err.Error()).WithField("obj_key", obj.Key).Warn("Failed to unmarshal inventory data")
+		return
+	}
+
+	switch obj.Key {
+	case storageKeyPet:
+		inventory.Pets = data.Items
+	case storageKeyClass:
+		inventory.Classes = data.Items
+	case storageKeyBackground:
+		inventory.Backgrounds = data.Items
+	case storageKeyPieceStyle:
+		inventory.PieceStyles = data.Items
+	default:
+		logger.WithField("obj_key", obj.Key).Warn("Unexpected inventory storage key")
+	}
+}
+
+// readOwnedItems loads the given inventory keys in a single StorageRead.
+// A missing or unparseable object leaves that key absent (treated as an empty list).
+func readOwnedItems(ctx context.Context, nk runtime.NakamaModule, userID string, keys []string) map[string][]uint32 {
+	owned := make(map[string][]uint32, len(keys))
+
+	reads := make([]*runtime.StorageRead, 0, len(keys))
+	for _, key := range keys {
+		reads = append(reads, &runtime.StorageRead{
+			Collection: storageCollectionInventory,
+			Key:        key,
+			UserID:     userID,
+		})
+	}
+
+	objects, err := nk.StorageRead(ctx, reads)
+	if err != nil {
+		return owned
+	}
+
+	for _, obj := range objects {
 		if obj == nil {
 			continue
 		}
-
 		data, err := UnmarshalJSON[InventoryData](obj.Value)
 		if err != nil {
-			logger.WithField("error", err.Error()).WithField("obj_key", obj.Key).Warn("Failed to unmarshal inventory data")
 			continue
 		}
-
-		switch obj.Key {
-		case storageKeyPet:
-			inventory.Pets = data.Items
-		case storageKeyClass:
-			inventory.Classes = data.Items
-		case storageKeyBackground:
-			inventory.Backgrounds = data.Items
-		case storageKeyPieceStyle:
-			inventory.PieceStyles = data.Items
-		default:
-			logger.WithField("obj_key", obj.Key).Warn("Unexpected inventory storage key")
-		}
+		owned[obj.Key] = data.Items
 	}
 
-	return inventory, nil
+	return owned
 }
 
 func GetUserProgression(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) (*ProgressionResponse, error) {
@@ -0,0 +1,30 @@
+package items
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestListAllStorageFollowsEveryPage(t *testing.T) {
+	nk := newFakeNakama()
+	total := listAllStorageMaxPages*100 + 50
+	for i := 0; i < total; i++ {
+		nk.put(t, storageCollectionPendingRewards, fmt.Sprintf("r-%05d", i), "u1", map[string]int{"n": i})
+	}
+	nk.put(t, storageCollectionPendingRewards, "r-other", "u2", map[string]int{"n": 0})
+
+	objects, err := listAllStorage(testContext("u1"), nk, testLogger{}, "u1", storageCollectionPendingRewards)
+	if err != nil {
+		t.Fatalf("listAllStorage: %v", err)
+	}
+	if len(objects) != total {
+		t.Fatalf("listed %d objects, want all %d", len(objects), total)
+	}
+	seen := make(map[string]bool, total)
+	for _, obj := range objects {
+		if obj.UserId != "u1" || seen[obj.Key] {
+			t.Fatalf("unexpected object %s/%s", obj.UserId, obj.Key)
+		}
+		seen[obj.Key] = true
+	}
+}
@@ -0,0 +1,137 @@
+package items
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	storageRetryAttempts  = 3
+	storageRetryBaseDelay = 50 * time.Millisecond
+)
+
+// transientStorageMarkers are substrings of driver/DB errors worth retrying.
+// Postgres/Cockroach surface contention as serialization failures or restarts.
+var transientStorageMarkers = []string{
+	"timeout",
+	"deadline exceeded",
+	"connection reset",
+	"connection refused",
+	"bad connection",
+	"could not serialize",
+	"restart transaction",
+	"deadlock",
+	"too many connections",
+}
+
+// isTransientStorageError reports whether err is worth retrying. OCC version conflicts and
+// permission rejections are permanent here — callers own their re-read/retry semantics.
+func isTransientStorageError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if stderrors.Is(err, runtime.ErrStorageRejectedVersion) || stderrors.Is(err, runtime.ErrStorageRejectedPermission) {
+		return false
+	}
+	if stderrors.Is(err, context.Canceled) {
+		return false
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "version check") {
+		return false
+	}
+	for _, marker := range transientStorageMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// PartialCompletionError reports a storage loop that stopped early because its context was done.
+// Done of Total steps had already been applied when it stopped.
+type PartialCompletionError struct {
+	Op    string
+	Done  int
+	Total int
+	Err   error
+}
+
+func (e *PartialCompletionError) Error() string {
+	return fmt.Sprintf("%s stopped after %d of %d: %v", e.Op, e.Done, e.Total, e.Err)
+}
+
+func (e *PartialCompletionError) Unwrap() error {
+	return e.Err
+}
+
+// checkContext returns a PartialCompletionError once ctx is cancelled or past its deadline.
+// Call it between storage batches so a dropped client stops the loop instead of finishing it.
+func checkContext(ctx context.Context, op string, done, total int) error {
+	if err := ctx.Err(); err != nil {
+		return &PartialCompletionError{Op: op, Done: done, Total: total, Err: err}
+	}
+	return nil
+}
+
+// isPartialCompletion reports whether err came from checkContext.
+func isPartialCompletion(err error) bool {
+	var partial *PartialCompletionError
+	return stderrors.As(err, &partial)
+}
+
+// withStorageRetry runs fn, retrying transient failures with bounded exponential backoff
+// (50ms, 100ms). Stops early if ctx is done. Returns the last error.
+func withStorageRetry(ctx context.Context, logger runtime.Logger, op string, fn func() error) error {
+	var err error
+	delay := storageRetryBaseDelay
+	for attempt := 1; attempt <= storageRetryAttempts; attempt++ {
+		if err = fn(); err == nil || !isTransientStorageError(err) {
+			return err
+		}
+		if attempt == storageRetryAttempts {
+			break
+		}
+		logger.Warn("Transient storage error on %s (attempt %d/%d): %v", op, attempt, storageRetryAttempts, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// storageReadWithRetry is nk.StorageRead routed through withStorageRetry.
+func storageReadWithRetry(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	var objects []*api.StorageObject
+	err := withStorageRetry(ctx, logger, "StorageRead", func() error {
+		var readErr error
+		objects, readErr = nk.StorageRead(ctx, reads)
+		return readErr
+	})
+	return objects, err
+}
+
+// storageWriteWithRetry is nk.StorageWrite routed through withStorageRetry. Only use it for
+// idempotent blind writes or version-guarded writes (a write that landed before a timeout
+// fails the retry's OCC check).
+func storageWriteWithRetry(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	var acks []*api.StorageObjectAck
+	err := withStorageRetry(ctx, logger, "StorageWrite", func() error {
+		var writeErr error
+		acks, writeErr = nk.StorageWrite(ctx, writes)
+		return writeErr
+	})
+	return acks, err
+}
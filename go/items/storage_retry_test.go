@@ -0,0 +1,65 @@
+package items
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// flakyNakama fails the next `failures` storage reads with err, then defers to the fake.
+type flakyNakama struct {
+	*fakeNakama
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyNakama) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return nil, f.err
+	}
+	return f.fakeNakama.StorageRead(ctx, reads)
+}
+
+func TestStorageReadRetriesTransientErrors(t *testing.T) {
+	fake := newFakeNakama()
+	fake.put(t, storageCollectionProgression, ProgressionKeyDailyJourney, "u1", DailyJourney{DailyMatches: 3})
+	nk := &flakyNakama{fakeNakama: fake, failures: 2, err: fmt.Errorf("dial tcp: i/o timeout")}
+
+	objects, err := storageReadWithRetry(context.Background(), nk, testLogger{}, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression, Key: ProgressionKeyDailyJourney, UserID: "u1",
+	}})
+	if err != nil {
+		t.Fatalf("storageReadWithRetry: %v", err)
+	}
+	if nk.calls != 3 || len(objects) != 1 {
+		t.Errorf("calls = %d, objects = %d; want 3 calls returning the object", nk.calls, len(objects))
+	}
+}
+
+func TestStorageRetryLeavesPermanentErrors(t *testing.T) {
+	for _, err := range []error{runtime.ErrStorageRejectedVersion, fmt.Errorf("invalid collection")} {
+		nk := &flakyNakama{fakeNakama: newFakeNakama(), failures: 2, err: err}
+		if _, got := storageReadWithRetry(context.Background(), nk, testLogger{}, nil); got != err {
+			t.Errorf("err = %v, want %v", got, err)
+		}
+		if nk.calls != 1 {
+			t.Errorf("%v: %d calls, want 1", err, nk.calls)
+		}
+	}
+}
+
+func TestStorageRetryGivesUp(t *testing.T) {
+	nk := &flakyNakama{fakeNakama: newFakeNakama(), failures: storageRetryAttempts + 1, err: fmt.Errorf("could not serialize access")}
+	if _, err := storageReadWithRetry(context.Background(), nk, testLogger{}, nil); err != nk.err {
+		t.Errorf("err = %v, want the last transient error", err)
+	}
+	if nk.calls != storageRetryAttempts {
+		t.Errorf("%d calls, want %d", nk.calls, storageRetryAttempts)
+	}
+}
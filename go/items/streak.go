@@ -0,0 +1,290 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// ProgressionKeyMatchStreak holds the consecutive-win counter (progression collection).
+const ProgressionKeyMatchStreak = "match_streak"
+
+// streakOutcome is the consensus-resolved result of a 1v1 match as far as the streak is concerned.
+// Only resolved outcomes move the streak; pending, conflicting and drawn matches leave it alone.
+type streakOutcome int
+
+const (
+	streakUnresolved streakOutcome = iota
+	streakWin
+	streakLoss
+	streakConcede // Forfeit: breaks the win streak but never earns loss protection
+)
+
+// streakOutcomeFor maps a consensus state to a streak outcome.
+// The first submitter ("pending") doesn't know the result yet; its streak is moved later by the
+// second submitter via applyDeferredStreak.
+func streakOutcomeFor(consensus string, won bool, isSolo bool) streakOutcome {
+	if isSolo {
+		return streakUnresolved // Solo outcomes are self-reported; they don't count.
+	}
+	switch consensus {
+	case "ok", "forfeit_win":
+		if won {
+			return streakWin
+		}
+		return streakLoss
+	}
+	return streakUnresolved
+}
+
+// StreakMilestone is one entry of economy.streak_milestones in items.json.
+// Paid each time the streak reaches Wins.
+type StreakMilestone struct {
+	Wins        int    `json:"wins"`
+	Gold        int    `json:"gold,omitempty"`
+	Gems        int    `json:"gems,omitempty"`
+	Treats      int    `json:"treats,omitempty"`
+	LootboxTier string `json:"lootbox_tier,omitempty"`
+}
+
+// LossProtectionConfig is economy.loss_protection in items.json.
+// After Losses consecutive resolved losses the player gets the consolation and the counter resets.
+type LossProtectionConfig struct {
+	Losses int `json:"losses"` // 0 disables
+	Tokens int `json:"tokens"` // Half-units, added to the daily journey's round tokens
+	Treats int `json:"treats"`
+}
+
+// MatchStreak is the per-user win streak document. OCC-protected via the storage version.
+type MatchStreak struct {
+	Current           int    `json:"current"`
+	Best              int    `json:"best"`
+	ConsecutiveLosses int    `json:"consecutive_losses"`
+	LastMatchID       string `json:"last_match_id,omitempty"` // Guards against applying one match twice
+	UpdatedAt         int64  `json:"updated_at"`
+
+	consoled bool // Set by advanceStreak when this outcome hit the loss-protection threshold
+}
+
+type MatchStreakResponse struct {
+	Current       int              `json:"current"`
+	Best          int              `json:"best"`
+	NextMilestone *StreakMilestone `json:"next_milestone,omitempty"`
+}
+
+func readMatchStreak(ctx context.Context, nk runtime.NakamaModule, userID string) (*MatchStreak, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyMatchStreak,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return nil, "", err
+	}
+	streak := &MatchStreak{}
+	if len(objects) == 0 {
+		return streak, "", nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), streak); err != nil {
+		return nil, "", err
+	}
+	return streak, objects[0].Version, nil
+}
+
+// nextStreakMilestone returns the first milestone above current, or nil past the last one.
+func nextStreakMilestone(milestones []StreakMilestone, current int) *StreakMilestone {
+	var next *StreakMilestone
+	for i := range milestones {
+		m := &milestones[i]
+		if m.Wins > current && (next == nil || m.Wins < next.Wins) {
+			next = m
+		}
+	}
+	return next
+}
+
+// advanceStreak applies one resolved outcome and returns the milestone reached, if any.
+// A loss that reaches the loss-protection threshold sets streak.consoled and resets the counter.
+func advanceStreak(streak *MatchStreak, outcome streakOutcome, milestones []StreakMilestone, protection LossProtectionConfig) *StreakMilestone {
+	switch outcome {
+	case streakWin:
+		streak.ConsecutiveLosses = 0
+		streak.Current++
+		if streak.Current > streak.Best {
+			streak.Best = streak.Current
+		}
+		for i := range milestones {
+			if milestones[i].Wins == streak.Current {
+				return &milestones[i]
+			}
+		}
+	case streakLoss:
+		streak.Current = 0
+		streak.ConsecutiveLosses++
+		if protection.Losses > 0 && streak.ConsecutiveLosses >= protection.Losses {
+			streak.ConsecutiveLosses = 0
+			streak.consoled = true
+		}
+	case streakConcede:
+		streak.Current = 0
+	}
+	return nil
+}
+
+// prepareStreakUpdate stages the streak write and any milestone reward for one resolved match.
+// Returns nil pending when the outcome is unresolved or this match was already applied.
+func prepareStreakUpdate(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, matchID string, outcome streakOutcome) (*MatchStreak, *PendingWrites, error) {
+	if outcome == streakUnresolved {
+		return nil, nil, nil
+	}
+
+	streak, version, err := readMatchStreak(ctx, nk, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if matchID != "" && streak.LastMatchID == matchID {
+		return streak, nil, nil
+	}
+
+	cfg := GetEconomyConfig()
+	milestone := advanceStreak(streak, outcome, cfg.StreakMilestones, cfg.LossProtection)
+	streak.LastMatchID = matchID
+	streak.UpdatedAt = time.Now().Unix()
+
+	pending := NewPendingWrites()
+	streakBytes, err := json.Marshal(streak)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version == "" {
+		version = "*"
+	}
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyMatchStreak,
+		UserID:          userID,
+		Value:           string(streakBytes),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	})
+
+	if streak.consoled && cfg.LossProtection.Treats > 0 {
+		treats := cfg.LossProtection.Treats
+		pending.AddWalletUpdate(userID, map[string]int64{"treats": int64(treats)})
+		consolation := notify.NewRewardPayload("loss_protection")
+		consolation.ReasonKey = "reward.streak.consolation"
+		consolation.Wallet = &notify.WalletDelta{Treats: treats}
+		consolation.SetWalletReasonArgs()
+		pending.Payload = consolation
+	}
+
+	if milestone == nil {
+		return streak, pending, nil
+	}
+
+	payload := notify.NewRewardPayload("win_streak")
+	payload.ReasonKey = "reward.streak.milestone"
+	payload.SetReasonArgInt("streak", milestone.Wins)
+	changeset := map[string]int64{}
+	if milestone.Gold > 0 {
+		changeset["gold"] = int64(milestone.Gold)
+	}
+	if milestone.Gems > 0 {
+		changeset["gems"] = int64(milestone.Gems)
+	}
+	if milestone.Treats > 0 {
+		changeset["treats"] = int64(milestone.Treats)
+	}
+	if len(changeset) > 0 {
+		pending.AddWalletUpdate(userID, changeset)
+		payload.Wallet = &notify.WalletDelta{Gold: milestone.Gold, Gems: milestone.Gems, Treats: milestone.Treats}
+		payload.SetWalletReasonArgs()
+	}
+	if milestone.LootboxTier != "" {
+		if lootbox, lootboxWrites, lboxErr := PrepareCreateLootbox(userID, milestone.LootboxTier); lboxErr == nil {
+			pending.AddStorageWrites(lootboxWrites...)
+			payload.Lootboxes = append(payload.Lootboxes, notify.LootboxGrant{
+				ID:     lootbox.ID,
+				Tier:   lootbox.Tier,
+				Source: "win_streak",
+			})
+		} else {
+			logger.Error("Failed to prepare streak lootbox for user %s: %v", userID, lboxErr)
+		}
+	}
+	pending.Payload = payload
+
+	logger.WithFields(map[string]interface{}{
+		"user":   userID,
+		"streak": milestone.Wins,
+	}).Info("Win streak milestone reached")
+	return streak, pending, nil
+}
+
+// applyDeferredStreak moves the first submitter's streak once the second submitter resolves the match.
+// Committed on its own (the opponent's rewards were committed at their submit). Any milestone reward
+// or consolation is applied here and returned for the caller to notify alongside the rest of the
+// deferred grants. Consolation tokens are banked on the daily journey, like the deferred win bonus.
+func applyDeferredStreak(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, matchID string, won bool) (*notify.RewardPayload, error) {
+	outcome := streakLoss
+	if won {
+		outcome = streakWin
+	}
+	streak, pending, err := prepareStreakUpdate(ctx, nk, logger, userID, matchID, outcome)
+	if err != nil || pending == nil {
+		return nil, err
+	}
+	if tokens := GetEconomyConfig().LossProtection.Tokens; streak.consoled && tokens > 0 {
+		dj, djVersion, err := readDailyJourneyForBanking(ctx, nk, logger, userID, utcMidnight(time.Now()))
+		if err != nil {
+			return nil, err
+		}
+		dj.RoundTokens += tokens
+		pending.AddStorageWrite(dailyJourneyWrite(userID, dj, djVersion))
+		if pending.Payload == nil {
+			pending.Payload = notify.NewRewardPayload("loss_protection")
+			pending.Payload.ReasonKey = "reward.streak.consolation"
+		}
+		pending.Payload.Economy = &notify.EconomyState{
+			RoundTokens:  notify.IntPtr(dj.RoundTokens),
+			TokensEarned: notify.IntPtr(tokens),
+		}
+	}
+	pending.CapDailyEarnings()
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		return nil, err
+	}
+	return pending.Payload, nil
+}
+
+// RpcGetMatchStreak returns the caller's current and best win streak.
+func RpcGetMatchStreak(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	streak, _, err := readMatchStreak(ctx, nk, userID)
+	if err != nil {
+		logger.WithField("err", err).Error("match streak read failed")
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := MatchStreakResponse{
+		Current:       streak.Current,
+		Best:          streak.Best,
+		NextMilestone: nextStreakMilestone(GetEconomyConfig().StreakMilestones, streak.Current),
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -0,0 +1,147 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func withStreakMilestones(t *testing.T, milestones []StreakMilestone, protection LossProtectionConfig) {
+	withEconomyConfig(t, func(cfg *EconomyConfig) {
+		cfg.StreakMilestones = milestones
+		cfg.LossProtection = protection
+	})
+}
+
+// playStreakMatch resolves one match for userID and commits the streak update.
+func playStreakMatch(t *testing.T, nk *fakeNakama, userID, matchID string, outcome streakOutcome) *PendingWrites {
+	t.Helper()
+	_, pending, err := prepareStreakUpdate(testContext(userID), nk, testLogger{}, userID, matchID, outcome)
+	if err != nil {
+		t.Fatalf("prepareStreakUpdate(%s): %v", matchID, err)
+	}
+	if pending != nil {
+		if err := CommitPendingWrites(testContext(userID), nk, testLogger{}, pending); err != nil {
+			t.Fatalf("commit %s: %v", matchID, err)
+		}
+	}
+	return pending
+}
+
+func matchStreak(t *testing.T, nk *fakeNakama, userID string) MatchStreakResponse {
+	t.Helper()
+	resp, err := RpcGetMatchStreak(testContext(userID), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetMatchStreak: %v", err)
+	}
+	var streak MatchStreakResponse
+	if err := json.Unmarshal([]byte(resp), &streak); err != nil {
+		t.Fatalf("unmarshal streak: %v", err)
+	}
+	return streak
+}
+
+func TestStreakIncrementsAndResetsOnLoss(t *testing.T) {
+	withStreakMilestones(t, nil, LossProtectionConfig{})
+	nk := newFakeNakama()
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		playStreakMatch(t, nk, "u1", id, streakWin)
+	}
+	// Replaying the same match must not count twice.
+	playStreakMatch(t, nk, "u1", "m3", streakWin)
+	if s := matchStreak(t, nk, "u1"); s.Current != 3 || s.Best != 3 {
+		t.Fatalf("streak = %+v, want current 3 best 3", s)
+	}
+
+	playStreakMatch(t, nk, "u1", "m4", streakLoss)
+	if s := matchStreak(t, nk, "u1"); s.Current != 0 || s.Best != 3 {
+		t.Errorf("after loss streak = %+v, want current 0 best 3", s)
+	}
+}
+
+func TestStreakIgnoresUnresolvedOutcomes(t *testing.T) {
+	for _, consensus := range []string{"pending", "resolved", "conflict", "draw"} {
+		if got := streakOutcomeFor(consensus, true, false); got != streakUnresolved {
+			t.Errorf("consensus %q moved the streak: %v", consensus, got)
+		}
+	}
+	if got := streakOutcomeFor("ok", true, true); got != streakUnresolved {
+		t.Errorf("solo win moved the streak: %v", got)
+	}
+	if got := streakOutcomeFor("ok", false, false); got != streakLoss {
+		t.Errorf("resolved 1v1 loss = %v, want streakLoss", got)
+	}
+
+	nk := newFakeNakama()
+	if pending := playStreakMatch(t, nk, "u1", "m1", streakUnresolved); pending != nil {
+		t.Error("unresolved outcome staged a streak write")
+	}
+	if nk.count(storageCollectionProgression, "u1") != 0 {
+		t.Error("unresolved outcome wrote the streak")
+	}
+}
+
+func TestStreakMilestoneRewards(t *testing.T) {
+	withStreakMilestones(t, []StreakMilestone{{Wins: 3, Gold: 100}, {Wins: 5, Gems: 5}}, LossProtectionConfig{})
+	nk := newFakeNakama()
+
+	for i, id := range []string{"m1", "m2", "m3", "m4", "m5"} {
+		pending := playStreakMatch(t, nk, "u1", id, streakWin)
+		wins := i + 1
+		paid := pending.Payload != nil && pending.Payload.Wallet != nil
+		if paid != (wins == 3 || wins == 5) {
+			t.Errorf("win %d: milestone payload = %+v", wins, pending.Payload)
+		}
+	}
+	wallet := nk.wallet("u1")
+	if wallet["gold"] != 100 || wallet["gems"] != 5 {
+		t.Errorf("wallet = %v, want gold 100 gems 5 from the 3 and 5 milestones", wallet)
+	}
+	if s := matchStreak(t, nk, "u1"); s.NextMilestone != nil {
+		t.Errorf("next milestone = %+v past the last one, want none", s.NextMilestone)
+	}
+}
+
+func TestLossProtectionFiresAtConfiguredLossCount(t *testing.T) {
+	withStreakMilestones(t, nil, LossProtectionConfig{Losses: 2, Tokens: 3, Treats: 4})
+	nk := newFakeNakama()
+
+	if pending := playStreakMatch(t, nk, "u1", "m1", streakLoss); pending.Payload != nil {
+		t.Errorf("first loss paid a consolation: %+v", pending.Payload)
+	}
+	pending := playStreakMatch(t, nk, "u1", "m2", streakLoss)
+	if pending.Payload == nil || pending.Payload.Wallet == nil || pending.Payload.Wallet.Treats != 4 {
+		t.Fatalf("second loss payload = %+v, want the 4 treat consolation", pending.Payload)
+	}
+	if pending := playStreakMatch(t, nk, "u1", "m3", streakLoss); pending.Payload != nil {
+		t.Errorf("loss after the consolation paid again: %+v", pending.Payload)
+	}
+}
+
+func TestDeferredLossProtectionBanksTokens(t *testing.T) {
+	withStreakMilestones(t, nil, LossProtectionConfig{Losses: 2, Tokens: 3, Treats: 4})
+	nk := newFakeNakama()
+
+	if payload, err := applyDeferredStreak(testContext("u2"), nk, testLogger{}, "u1", "m1", false); err != nil || payload != nil {
+		t.Fatalf("first deferred loss = %+v, %v; want no consolation", payload, err)
+	}
+	payload, err := applyDeferredStreak(testContext("u2"), nk, testLogger{}, "u1", "m2", false)
+	if err != nil {
+		t.Fatalf("applyDeferredStreak: %v", err)
+	}
+	if payload == nil || payload.Economy == nil || payload.Economy.TokensEarned == nil || *payload.Economy.TokensEarned != 3 {
+		t.Fatalf("deferred consolation payload = %+v, want 3 tokens earned", payload)
+	}
+	if payload.Wallet == nil || payload.Wallet.Treats != 4 {
+		t.Errorf("deferred consolation wallet = %+v, want 4 treats", payload.Wallet)
+	}
+
+	var dj DailyJourney
+	nk.get(t, storageCollectionProgression, ProgressionKeyDailyJourney, "u1", &dj)
+	if dj.RoundTokens != 3 {
+		t.Errorf("daily journey round tokens = %d, want the 3 consolation tokens", dj.RoundTokens)
+	}
+	if got := nk.wallet("u1")["treats"]; got != 4 {
+		t.Errorf("wallet treats = %d, want 4", got)
+	}
+}
@@ -0,0 +1,371 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"block-server/errors"
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+// TournamentConfig is the "tournament" block of items.json. An empty ID disables tournaments.
+// The tournament is created once at module init; changing it on hot reload takes effect at restart.
+type TournamentConfig struct {
+	ID           string                 `json:"id"`
+	Title        string                 `json:"title"`
+	Description  string                 `json:"description"`
+	Category     int                    `json:"category"`
+	Reset        string                 `json:"reset"`    // cron expression for each session
+	Duration     int                    `json:"duration"` // seconds a session stays open after each reset
+	MaxSize      int                    `json:"max_size"` // 0 = unlimited
+	JoinRequired bool                   `json:"join_required"`
+	ClaimRewards bool                   `json:"claim_rewards"` // true: players claim via RPC; false: paid at session end
+	Rewards      []TournamentRankReward `json:"rewards"`
+}
+
+// TournamentRankReward pays every rank up to MaxRank not covered by an earlier (better) bracket.
+type TournamentRankReward struct {
+	MaxRank     int64  `json:"max_rank"`
+	Gold        int    `json:"gold,omitempty"`
+	Gems        int    `json:"gems,omitempty"`
+	LootboxTier string `json:"lootbox_tier,omitempty"`
+}
+
+var tournamentConfig *TournamentConfig
+
+// GetTournamentConfig returns the configured tournament (zero value when none).
+func GetTournamentConfig() *TournamentConfig {
+	cfg := TournamentConfig{}
+	gameDataMu.RLock()
+	if tournamentConfig != nil {
+		cfg = *tournamentConfig
+	}
+	gameDataMu.RUnlock()
+	return &cfg
+}
+
+// rewardForRank returns the bracket paying rank, or nil if rank is outside every bracket.
+func (c *TournamentConfig) rewardForRank(rank int64) *TournamentRankReward {
+	var best *TournamentRankReward
+	for i := range c.Rewards {
+		r := &c.Rewards[i]
+		if rank <= r.MaxRank && (best == nil || r.MaxRank < best.MaxRank) {
+			best = r
+		}
+	}
+	return best
+}
+
+func (c *TournamentConfig) maxRewardedRank() int64 {
+	var max int64
+	for _, r := range c.Rewards {
+		if r.MaxRank > max {
+			max = r.MaxRank
+		}
+	}
+	return max
+}
+
+// BootstrapTournament creates the configured tournament. Non-fatal like BootstrapLeaderboards:
+// the tournament usually exists from a previous startup.
+func BootstrapTournament(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger) string {
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" {
+		return ""
+	}
+	// Authoritative: only the server writes scores, from resolved match wins.
+	if err := nk.TournamentCreate(ctx, cfg.ID, true, "desc", "incr", cfg.Reset, nil,
+		cfg.Title, cfg.Description, cfg.Category, 0, 0, cfg.Duration, cfg.MaxSize, 0, cfg.JoinRequired, true); err != nil {
+		logger.Error("Failed to create tournament %s: %v", cfg.ID, err)
+		return ""
+	}
+	return cfg.ID
+}
+
+// submitTournamentWin adds one win to the caller's tournament score. Called only for
+// consensus-resolved wins of players who aren't shadow-banned.
+// Non-fatal: a player who hasn't joined, or a closed session, just skips.
+func submitTournamentWin(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string) {
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" {
+		return
+	}
+	username := ""
+	if users, err := nk.UsersGetId(ctx, []string{userID}, nil); err == nil && len(users) > 0 {
+		username = users[0].Username
+	}
+	if _, err := nk.TournamentRecordWrite(ctx, cfg.ID, userID, username, 1, 0, nil, nil); err != nil {
+		switch err {
+		case runtime.ErrTournamentWriteJoinRequired, runtime.ErrTournamentOutsideDuration:
+			logger.Debug("[tournament] Skipped win for user %s: %v", userID, err)
+		default:
+			logger.Warn("[tournament] Failed to write win for user %s: %v", userID, err)
+		}
+	}
+}
+
+// TournamentResponse is the get_tournament payload.
+type TournamentResponse struct {
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	StartActive uint32                 `json:"start_active"`
+	EndActive   uint32                 `json:"end_active"`
+	NextReset   uint32                 `json:"next_reset"`
+	Size        uint32                 `json:"size"`
+	MaxSize     uint32                 `json:"max_size"`
+	CanEnter    bool                   `json:"can_enter"`
+	Joined      bool                   `json:"joined"`
+	MyEntry     *LeaderboardEntry      `json:"my_entry,omitempty"`
+	Rewards     []TournamentRankReward `json:"rewards,omitempty"`
+}
+
+// RpcGetTournament returns the active tournament's details and the caller's record.
+func RpcGetTournament(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" {
+		return "", errors.ErrTournamentNotConfigured
+	}
+
+	tournaments, err := nk.TournamentsGetId(ctx, []string{cfg.ID})
+	if err != nil || len(tournaments) == 0 {
+		logger.Error("[tournament] Failed to get %s: %v", cfg.ID, err)
+		return "", errors.ErrTournamentNotConfigured
+	}
+	t := tournaments[0]
+
+	resp := TournamentResponse{
+		ID:          t.Id,
+		Title:       t.Title,
+		Description: t.Description,
+		StartActive: t.StartActive,
+		EndActive:   t.EndActive,
+		NextReset:   t.NextReset,
+		Size:        t.Size,
+		MaxSize:     t.MaxSize,
+		CanEnter:    t.CanEnter,
+		Rewards:     cfg.Rewards,
+	}
+
+	// Joining writes a zero-score record, so an owner record means the caller has joined.
+	_, ownerRecords, _, _, err := nk.TournamentRecordsList(ctx, cfg.ID, []string{userID}, 1, "", 0)
+	if err != nil {
+		logger.Warn("[tournament] Failed to read record for user %s: %v", userID, err)
+	}
+	for _, r := range ownerRecords {
+		if r.OwnerId == userID {
+			entry := leaderboardEntryFromRecord(r)
+			resp.MyEntry = &entry
+			resp.Joined = true
+			break
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(b), nil
+}
+
+// RpcJoinTournament enters the caller into the active tournament. Joining twice is a no-op.
+func RpcJoinTournament(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" {
+		return "", errors.ErrTournamentNotConfigured
+	}
+
+	username, _ := ctx.Value(runtime.RUNTIME_CTX_USERNAME).(string)
+	if err := nk.TournamentJoin(ctx, cfg.ID, userID, username); err != nil {
+		switch err {
+		case runtime.ErrTournamentMaxSizeReached:
+			return "", errors.ErrTournamentFull
+		case runtime.ErrTournamentOutsideDuration:
+			return "", errors.ErrTournamentClosed
+		}
+		logger.Error("[tournament] Failed to join %s for user %s: %v", cfg.ID, userID, err)
+		return "", errors.ErrInternalError
+	}
+	return `{"success": true}`, nil
+}
+
+// tournamentRewardID keys a session payout by the reset that closed it. Shared by the end-of-session
+// payout and the claim RPC, so a session is never paid twice whichever path runs.
+func tournamentRewardID(tournamentID string, reset int64) string {
+	return fmt.Sprintf("tournament_%s_%d", tournamentID, reset)
+}
+
+// TournamentEnd pays the rank brackets when a tournament session ends. Registered with
+// RegisterTournamentEnd, so Nakama schedules it; each payout is keyed by tournament and session
+// so a re-run after a crash doesn't double-grant. Skipped when rewards are claim-based.
+func TournamentEnd(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, tournament *api.Tournament, end, reset int64) error {
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" || tournament.Id != cfg.ID || cfg.ClaimRewards {
+		return nil
+	}
+	maxRank := cfg.maxRewardedRank()
+	if maxRank == 0 {
+		return nil
+	}
+
+	paid := 0
+	cursor := ""
+	for {
+		records, _, _, nextCursor, err := nk.TournamentRecordsList(ctx, tournament.Id, nil, 100, cursor, reset)
+		if err != nil {
+			logger.Error("[tournament] Failed to list records for %s: %v", tournament.Id, err)
+			return err
+		}
+		for _, r := range records {
+			if r.Rank > maxRank {
+				logger.Info("[tournament] %s ended: paid %d players", tournament.Id, paid)
+				return nil
+			}
+			bracket := cfg.rewardForRank(r.Rank)
+			if bracket == nil {
+				continue
+			}
+			rewardID := tournamentRewardID(tournament.Id, reset)
+			applied, err := grantTournamentReward(ctx, nk, logger, r.OwnerId, rewardID, r.Rank, bracket)
+			if err != nil {
+				logger.Error("[tournament] Failed to pay rank %d (%s): %v", r.Rank, r.OwnerId, err)
+				continue
+			}
+			if applied {
+				paid++
+			}
+		}
+		if nextCursor == "" || len(records) == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+	logger.Info("[tournament] %s ended: paid %d players", tournament.Id, paid)
+	return nil
+}
+
+// grantTournamentReward commits one bracket payout once and notifies the player.
+// Returns false when rewardID was already applied.
+func grantTournamentReward(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, rewardID string, rank int64, bracket *TournamentRankReward) (bool, error) {
+	pending := NewPendingWrites()
+	payload := notify.NewRewardPayload("tournament")
+	payload.RewardID = rewardID
+	payload.ReasonKey = "reward.tournament.rank"
+	payload.SetReasonArgInt("rank", int(rank))
+
+	changeset := map[string]int64{}
+	if bracket.Gold > 0 {
+		changeset["gold"] = int64(bracket.Gold)
+	}
+	if bracket.Gems > 0 {
+		changeset["gems"] = int64(bracket.Gems)
+	}
+	if len(changeset) > 0 {
+		pending.AddWalletUpdate(userID, changeset)
+		payload.Wallet = &notify.WalletDelta{Gold: bracket.Gold, Gems: bracket.Gems}
+		payload.SetWalletReasonArgs()
+	}
+	if bracket.LootboxTier != "" {
+		lootbox, writes, err := PrepareCreateLootbox(userID, bracket.LootboxTier)
+		if err != nil {
+			return false, err
+		}
+		pending.AddStorageWrites(writes...)
+		payload.Lootboxes = append(payload.Lootboxes, notify.LootboxGrant{
+			ID:     lootbox.ID,
+			Tier:   lootbox.Tier,
+			Source: "tournament",
+		})
+	}
+	pending.Payload = payload
+
+	applied, err := CommitRewardOnce(ctx, nk, logger, userID, pending)
+	if err != nil || !applied {
+		return false, err
+	}
+	SendRewardOrStore(ctx, nk, logger, userID, payload)
+	return true, nil
+}
+
+// ClaimTournamentRewardResponse is the claim_tournament_reward payload. Ranked is false when the
+// caller has no record in the last completed session; Reward is nil when their rank pays nothing.
+type ClaimTournamentRewardResponse struct {
+	Ranked bool                  `json:"ranked"`
+	Rank   int64                 `json:"rank,omitempty"`
+	Reward *TournamentRankReward `json:"reward,omitempty"`
+}
+
+// RpcClaimTournamentReward pays the caller's bracket for the last completed tournament session.
+// Claims share the session payout key with TournamentEnd, so a second claim returns ErrRewardAlreadyClaimed.
+func RpcClaimTournamentReward(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, err := GetUserIDFromContext(ctx, logger)
+	if err != nil {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	cfg := GetTournamentConfig()
+	if cfg.ID == "" {
+		return "", errors.ErrTournamentNotConfigured
+	}
+
+	tournaments, err := nk.TournamentsGetId(ctx, []string{cfg.ID})
+	if err != nil || len(tournaments) == 0 {
+		logger.Error("[tournament] Failed to get %s: %v", cfg.ID, err)
+		return "", errors.ErrTournamentNotConfigured
+	}
+	// Records of the last completed session expire at the most recent reset.
+	prevReset := int64(tournaments[0].PrevReset)
+	if prevReset == 0 {
+		return "", errors.ErrTournamentNoCompletedSession
+	}
+
+	_, ownerRecords, _, _, err := nk.TournamentRecordsList(ctx, cfg.ID, []string{userID}, 1, "", prevReset)
+	if err != nil {
+		logger.Error("[tournament] Failed to read final record for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := ClaimTournamentRewardResponse{}
+	for _, r := range ownerRecords {
+		if r.OwnerId == userID && r.Rank > 0 {
+			resp.Ranked = true
+			resp.Rank = r.Rank
+			break
+		}
+	}
+	if resp.Ranked {
+		resp.Reward = cfg.rewardForRank(resp.Rank)
+	}
+
+	if resp.Reward != nil {
+		applied, err := grantTournamentReward(ctx, nk, logger, userID, tournamentRewardID(cfg.ID, prevReset), resp.Rank, resp.Reward)
+		if err != nil {
+			logger.Error("[tournament] Failed to grant rank %d reward for user %s: %v", resp.Rank, userID, err)
+			return "", errors.ErrTransactionFailed
+		}
+		if !applied {
+			return "", errors.ErrRewardAlreadyClaimed
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(b), nil
+}
@@ -0,0 +1,166 @@
+package items
+
+import (
+	"encoding/json"
+	"testing"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/api"
+)
+
+const tournamentUser = "00000000-0000-0000-0000-000000000004"
+
+// withTournament configures cfg as the tournament for the test and registers it with the fake.
+func withTournament(t *testing.T, nk *fakeNakama, cfg TournamentConfig) *api.Tournament {
+	t.Helper()
+	cfg.ID, cfg.Title = "weekly_test", "Weekly"
+	gameDataMu.Lock()
+	original := tournamentConfig
+	tournamentConfig = &cfg
+	gameDataMu.Unlock()
+	t.Cleanup(func() {
+		gameDataMu.Lock()
+		tournamentConfig = original
+		gameDataMu.Unlock()
+	})
+	tournament := &api.Tournament{Id: cfg.ID, Title: cfg.Title, JoinRequired: cfg.JoinRequired, CanEnter: true}
+	nk.tournaments[cfg.ID] = tournament
+	return tournament
+}
+
+func getTournament(t *testing.T, nk *fakeNakama, userID string) TournamentResponse {
+	t.Helper()
+	out, err := RpcGetTournament(testContext(userID), testLogger{}, nil, nk, "")
+	if err != nil {
+		t.Fatalf("RpcGetTournament: %v", err)
+	}
+	var resp TournamentResponse
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return resp
+}
+
+func TestTournamentSubmitsOnlyResolvedWins(t *testing.T) {
+	nk := newFakeNakama()
+	withTournament(t, nk, TournamentConfig{})
+	ctx := testContext(tournamentUser)
+
+	if _, err := processMatchRewards(ctx, nk, testLogger{}, tournamentUser, soloWin(t, "m1"), true, nil, streakUnresolved); err != nil {
+		t.Fatalf("solo win: %v", err)
+	}
+	if nk.tournamentWrites != 0 {
+		t.Fatalf("unresolved win wrote %d tournament records", nk.tournamentWrites)
+	}
+	if _, err := processMatchRewards(ctx, nk, testLogger{}, tournamentUser, soloWin(t, "m2"), false, nil, streakWin); err != nil {
+		t.Fatalf("resolved win: %v", err)
+	}
+	if resp := getTournament(t, nk, tournamentUser); resp.MyEntry == nil || resp.MyEntry.Score != 1 {
+		t.Errorf("my entry = %+v after one resolved win, want score 1", resp.MyEntry)
+	}
+}
+
+func TestTournamentJoin(t *testing.T) {
+	nk := newFakeNakama()
+	withTournament(t, nk, TournamentConfig{JoinRequired: true})
+
+	// Before joining, a win is skipped rather than failing the match.
+	submitTournamentWin(testContext(tournamentUser), nk, testLogger{}, tournamentUser)
+	if resp := getTournament(t, nk, tournamentUser); resp.Joined {
+		t.Fatalf("resp = %+v, want not joined", resp)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := RpcJoinTournament(testContext(tournamentUser), testLogger{}, nil, nk, ""); err != nil {
+			t.Fatalf("join %d: %v", i+1, err)
+		}
+	}
+	resp := getTournament(t, nk, tournamentUser)
+	if !resp.Joined || resp.MyEntry == nil || resp.MyEntry.Score != 0 {
+		t.Fatalf("resp = %+v, want joined with score 0", resp)
+	}
+
+	submitTournamentWin(testContext(tournamentUser), nk, testLogger{}, tournamentUser)
+	if resp := getTournament(t, nk, tournamentUser); resp.MyEntry == nil || resp.MyEntry.Score != 1 {
+		t.Errorf("my entry = %+v after joining and winning, want score 1", resp.MyEntry)
+	}
+}
+
+func TestTournamentNotConfigured(t *testing.T) {
+	nk := newFakeNakama()
+	gameDataMu.Lock()
+	original := tournamentConfig
+	tournamentConfig = nil
+	gameDataMu.Unlock()
+	t.Cleanup(func() {
+		gameDataMu.Lock()
+		tournamentConfig = original
+		gameDataMu.Unlock()
+	})
+	if _, err := RpcJoinTournament(testContext(tournamentUser), testLogger{}, nil, nk, ""); err == nil {
+		t.Error("join succeeded with no tournament configured")
+	}
+}
+
+func claimTournamentReward(nk *fakeNakama, userID string) (ClaimTournamentRewardResponse, error) {
+	var resp ClaimTournamentRewardResponse
+	out, err := RpcClaimTournamentReward(testContext(userID), testLogger{}, nil, nk, "")
+	if err == nil {
+		err = json.Unmarshal([]byte(out), &resp)
+	}
+	return resp, err
+}
+
+func TestClaimTournamentRewardTopRankOnce(t *testing.T) {
+	nk := newFakeNakama()
+	tournament := withTournament(t, nk, TournamentConfig{
+		ClaimRewards: true,
+		Rewards:      []TournamentRankReward{{MaxRank: 10, Gold: 50}, {MaxRank: 1, Gold: 500, Gems: 20}},
+	})
+	if _, err := claimTournamentReward(nk, tournamentUser); err == nil {
+		t.Fatal("claim succeeded before any session completed")
+	}
+	tournament.PrevReset = 1_700_000_000
+	nk.writeRecordLocked(tournament.Id, tournamentUser, "", 9, 0)
+	nk.writeRecordLocked(tournament.Id, "runner-up", "", 4, 0)
+
+	resp, err := claimTournamentReward(nk, tournamentUser)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if !resp.Ranked || resp.Rank != 1 || resp.Reward == nil || resp.Reward.Gold != 500 {
+		t.Fatalf("claim = %+v, want rank 1 with the top bracket", resp)
+	}
+	if w := nk.wallet(tournamentUser); w["gold"] != 500 || w["gems"] != 20 {
+		t.Errorf("wallet = %v, want gold 500 gems 20", w)
+	}
+
+	if _, err := claimTournamentReward(nk, tournamentUser); err != errors.ErrRewardAlreadyClaimed {
+		t.Errorf("second claim err = %v, want ErrRewardAlreadyClaimed", err)
+	}
+	if w := nk.wallet(tournamentUser); w["gold"] != 500 {
+		t.Errorf("gold = %d after a second claim, want 500", w["gold"])
+	}
+}
+
+func TestClaimTournamentRewardUnranked(t *testing.T) {
+	nk := newFakeNakama()
+	tournament := withTournament(t, nk, TournamentConfig{
+		ClaimRewards: true,
+		Rewards:      []TournamentRankReward{{MaxRank: 1, Gold: 500}},
+	})
+	tournament.PrevReset = 1_700_000_000
+	nk.writeRecordLocked(tournament.Id, "someone-else", "", 3, 0)
+
+	resp, err := claimTournamentReward(nk, tournamentUser)
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if resp.Ranked || resp.Reward != nil {
+		t.Errorf("claim = %+v, want unranked with no reward", resp)
+	}
+	if w := nk.wallet(tournamentUser); len(w) != 0 && w["gold"] != 0 {
+		t.Errorf("unranked player was paid: %v", w)
+	}
+}
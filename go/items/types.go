@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"block-server/errors"
@@ -126,6 +127,8 @@ type ItemProgression struct {
 	UnclaimedRewards []int                `json:"ur,omitempty"`
 	TierStates       map[string]TierState `json:"ts,omitempty"`
 
+	PrestigeLevel int `json:"pl,omitempty"` // Times reset from max level via prestige_item
+
 	Version string `json:"-"`
 }
 
@@ -203,6 +206,21 @@ func (p *ItemProgression) HasAbility(index int) bool {
 	return false
 }
 
+// HasSprite checks if the player has unlocked the sprite at the given index
+func (p *ItemProgression) HasSprite(index int) bool {
+	for _, idx := range p.UnlockedSpriteIndices {
+		if int(idx) == index {
+			return true
+		}
+	}
+	return false
+}
+
+type SpriteEquipRequest struct {
+	ItemID      uint32 `json:"id"`
+	SpriteIndex int    `json:"sprite_index"`
+}
+
 type AbilityEquipRequest struct {
 	ItemID    uint32 `json:"id"`
 	AbilityID uint32 `json:"ability_id"`
@@ -213,6 +231,12 @@ type EquipmentResponse struct {
 	Class      uint32 `json:"class"`
 	Background uint32 `json:"background"`
 	PieceStyle uint32 `json:"piece_style"`
+
+	// Ability and sprite indices equipped on the active pet and class (0 without progression).
+	PetAbility   int `json:"pet_ability"`
+	PetSprite    int `json:"pet_sprite"`
+	ClassAbility int `json:"class_ability"`
+	ClassSprite  int `json:"class_sprite"`
 }
 
 type InventoryResponse struct {
@@ -235,6 +259,7 @@ type DailyJourney struct {
 	ExchangesLeft      int   `json:"exchangesLeft"`
 	RoundTokens        int   `json:"roundTokens"`
 	ResetUnix          int64 `json:"reset_unix"`
+	LastWinBonusDay    int64 `json:"last_win_bonus_day,omitempty"` // UTC midnight of the last first-win bonus
 }
 
 // getDailyJourneyState reads state from storage; returns initialized struct for new users.
@@ -259,7 +284,7 @@ func getDailyJourneyState(ctx context.Context, logger runtime.Logger, nk runtime
 	if len(objects) == 0 {
 		// New user case
 		nowUTC := time.Now().UTC()
-		data.ResetUnix = time.Date(nowUTC.Year(), nowUTC.Month(), nowUTC.Day(), 0, 0, 0, 0, time.UTC).Unix()
+		data.ResetUnix = utcMidnight(nowUTC).Unix()
 		data.ExchangesLeft = DailyExchangeCap
 		data.RoundTokens = 0
 		return data, nil, nil
@@ -278,12 +303,75 @@ type ProgressionResponse struct {
 	Pets         map[uint32]ItemProgression `json:"pets"`
 	Classes      map[uint32]ItemProgression `json:"classes"`
 	DailyJourney *DailyJourneyResponse      `json:"dailyJourney"`
+	HasMore      bool                       `json:"has_more"`              // More records remain; call again with NextCursor
+	NextCursor   string                     `json:"next_cursor,omitempty"` // Storage list cursor for the next call
 }
 
+// ProgressionRequest continues a truncated get_progression listing. An empty payload starts over.
+type ProgressionRequest struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// InventoryData is one inventory key's owned item IDs, kept sorted ascending with no duplicates
+// so ownership checks can binary search and clients see a stable order.
 type InventoryData struct {
 	Items []uint32 `json:"items"`
 }
 
+// UnmarshalJSON normalizes on read, so inventories written before the sorted invariant still search correctly.
+func (d *InventoryData) UnmarshalJSON(data []byte) error {
+	type raw InventoryData
+	var r raw
+	if err := json.Unmarshal(data, &r); err != nil {
+		return err
+	}
+	d.Items = normalizeItemIDs(r.Items)
+	return nil
+}
+
+// Has reports whether id is owned.
+func (d *InventoryData) Has(id uint32) bool {
+	i := sort.Search(len(d.Items), func(i int) bool { return d.Items[i] >= id })
+	return i < len(d.Items) && d.Items[i] == id
+}
+
+// Add inserts id in order. Returns false if it was already owned.
+func (d *InventoryData) Add(id uint32) bool {
+	i := sort.Search(len(d.Items), func(i int) bool { return d.Items[i] >= id })
+	if i < len(d.Items) && d.Items[i] == id {
+		return false
+	}
+	d.Items = append(d.Items, 0)
+	copy(d.Items[i+1:], d.Items[i:])
+	d.Items[i] = id
+	return true
+}
+
+// Remove deletes id. Returns false if it wasn't owned.
+func (d *InventoryData) Remove(id uint32) bool {
+	i := sort.Search(len(d.Items), func(i int) bool { return d.Items[i] >= id })
+	if i >= len(d.Items) || d.Items[i] != id {
+		return false
+	}
+	d.Items = append(d.Items[:i], d.Items[i+1:]...)
+	return true
+}
+
+// normalizeItemIDs returns a sorted, deduplicated copy of ids (never nil).
+func normalizeItemIDs(ids []uint32) []uint32 {
+	out := make([]uint32, 0, len(ids))
+	out = append(out, ids...)
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	n := 0
+	for i, id := range out {
+		if i == 0 || id != out[n-1] {
+			out[n] = id
+			n++
+		}
+	}
+	return out[:n]
+}
+
 type EquipmentData struct {
 	ID uint32 `json:"id"`
 }
@@ -301,6 +389,7 @@ type RoundResult struct {
 	PlayerWon   bool  `json:"player_won"`
 	Survived    bool  `json:"survived"`    // true if player health > 0 at round end
 	DurationMs  int64 `json:"duration_ms"` // milliseconds; matches RoundRecord.DurationMs for direct comparison
+	Score       int   `json:"score,omitempty"`  // points scored this round; summed against FinalScore under strict validation
 }
 
 // Match Result Types
@@ -318,11 +407,16 @@ type MatchResultRequest struct {
 	RoundsLost        int           `json:"rounds_lost"`
 	Rounds            []RoundResult `json:"rounds"`             // Per-round history; server validates plausibility
 	OpponentForfeited bool          `json:"opponent_forfeited"` // Whether the opponent forfeited the match
+	Draw              bool          `json:"draw"`               // Match ended level; mutually exclusive with Won
 	AbilitiesCast     int           `json:"abilities_cast"`
 	APM               int           `json:"apm"`
 	PiecesPlaced      int           `json:"pieces_placed"`
 	TowerHeight       int           `json:"tower_height"`
 	OpponentName      string        `json:"opponent_name,omitempty"`
+	IdempotencyKey    string        `json:"idempotency_key,omitempty"` // Client-generated; a retry with the same key gets the cached result
+
+	// ServerDurationMs is stamped from the active match lock, never read from the client.
+	ServerDurationMs int64 `json:"-"`
 }
 
 // â”€â”€â”€ Leaderboard & Competitive System â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€
@@ -340,6 +434,23 @@ const (
 	Leaderboard1v1Weekly  = "1v1_weekly"
 )
 
+// LeaderboardDefinition configures one Nakama leaderboard created at init.
+type LeaderboardDefinition struct {
+	ID        string `json:"id"`
+	SortOrder string `json:"sort_order"`      // "desc" | "asc"
+	Operator  string `json:"operator"`        // "best" | "set" | "incr" | "decr"
+	Reset     string `json:"reset,omitempty"` // cron expression; empty = never auto-reset
+}
+
+// LeaderboardsConfig is the "leaderboards" block of items.json.
+// Empty fields fall back to the Leaderboard* constants above.
+type LeaderboardsConfig struct {
+	SoloSeason LeaderboardDefinition `json:"solo_season"`
+	SoloWeekly LeaderboardDefinition `json:"solo_weekly"`
+	WinsSeason LeaderboardDefinition `json:"wins_season"`
+	WinsWeekly LeaderboardDefinition `json:"wins_weekly"`
+}
+
 const (
 	storageCollectionCompetitiveStats = "competitive_stats"
 	storageKeyStats                   = "stats"
@@ -361,6 +472,7 @@ type PlayerStats struct {
 	PeakRating    int    `json:"peak_rating"`
 	Wins          int    `json:"wins"`
 	Losses        int    `json:"losses"`
+	Draws         int    `json:"draws"`
 	MatchesPlayed int    `json:"matches_played"`
 	BestSoloScore int    `json:"best_solo_score"`
 	SeasonID      string `json:"season_id,omitempty"` // set when seasons are introduced
@@ -376,8 +488,16 @@ type MatchHistoryDocument struct {
 
 // MatchResultCacheEntry stores the latest match result payload for idempotency.
 type MatchResultCacheEntry struct {
-	MatchID string          `json:"match_id"`
-	Payload json.RawMessage `json:"payload"`
+	MatchID  string          `json:"match_id"`
+	Payload  json.RawMessage `json:"payload"`
+	CachedAt int64           `json:"cached_at,omitempty"` // Unix millis
+}
+
+// MatchResultIdempotencyDocument holds recent submit_match_result payloads by idempotency key.
+// Entries older than matchResultIdempotencyWindow are pruned on each write.
+type MatchResultIdempotencyDocument struct {
+	Entries map[string]MatchResultCacheEntry `json:"entries"`
+	Version string                           `json:"-"` // OCC version read from storage; "*" when absent
 }
 
 // MatchHistoryEntry is a single match record, written after each completed match.
@@ -390,6 +510,7 @@ type MatchHistoryEntry struct {
 	OpponentID      string `json:"opponent_id,omitempty"`
 	OpponentName    string `json:"opponent_name,omitempty"`
 	Won             bool   `json:"won"`
+	Draw            bool   `json:"draw,omitempty"`
 	MyPetID         uint32 `json:"my_pet_id,omitempty"`
 	MyClassID       uint32 `json:"my_class_id,omitempty"`
 	OpponentPetID   uint32 `json:"opponent_pet_id"`
@@ -400,12 +521,15 @@ type MatchHistoryEntry struct {
 
 	RoundsWon    int   `json:"rounds_won"`
 	RoundsLost   int   `json:"rounds_lost"`
-	DurationSec  int   `json:"duration_sec"`
+	DurationSec  int   `json:"duration_sec"` // Client-reported
 	PiecesPlaced int   `json:"pieces_placed"`
 	TowerHeight  int   `json:"tower_height"`
 	Rating       *int  `json:"rating,omitempty"`       // player rating at match time
 	RatingDelta  *int  `json:"rating_delta,omitempty"` // ELO delta applied
 	PlayedAt     int64 `json:"played_at"`
+
+	// ServerDurationMs is submit time minus the server's match start stamp. 0 on older entries.
+	ServerDurationMs int64 `json:"server_duration_ms,omitempty"`
 }
 
 // â”€â”€â”€ RPC request/response types â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€â”€
@@ -442,6 +566,20 @@ type FriendsLeaderboardRequest struct {
 	Limit   int    `json:"limit,omitempty"` // default 50, max 50
 }
 
+// MyRankRequest fetches the caller's position on one board. BoardID defaults to the season wins board.
+type MyRankRequest struct {
+	BoardID string `json:"board_id,omitempty"`
+	Around  int    `json:"around,omitempty"` // records returned around the caller; default 5, max 25
+}
+
+// MyRankResponse is the "your position" widget. Ranked is false when the caller has no record yet.
+type MyRankResponse struct {
+	BoardID string             `json:"board_id"`
+	Ranked  bool               `json:"ranked"`
+	MyEntry *LeaderboardEntry  `json:"my_entry,omitempty"`
+	Around  []LeaderboardEntry `json:"around"`
+}
+
 // PlayerStatsRequest fetches competitive stats for a user.
 // Omitting UserID returns the calling user's own stats.
 type PlayerStatsRequest struct {
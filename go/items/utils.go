@@ -8,8 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
 	"block-server/errors"
+	"block-server/notify"
 
 	"github.com/heroiclabs/nakama-common/runtime"
 )
@@ -28,6 +30,28 @@ func GetUserIDFromContext(ctx context.Context, logger runtime.Logger) (string, e
 	return userID, nil
 }
 
+// utcMidnight returns the daily reset boundary (00:00 UTC) for t's day.
+// Shared by daily journey, daily drops and daily quests so they roll over together.
+func utcMidnight(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// utcWeekStart returns the weekly reset boundary: Monday 00:00 UTC, matching the weekly boards.
+func utcWeekStart(t time.Time) time.Time {
+	day := utcMidnight(t)
+	offset := (int(day.Weekday()) + 6) % 7 // Monday = 0
+	return day.AddDate(0, 0, -offset)
+}
+
+// notifyRateLimited sends a warning toast explaining a throttled request. Best effort:
+// the RPC error stays the authoritative signal, the toast only gives it warning styling.
+func notifyRateLimited(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, message string) {
+	if err := notify.SendToast(ctx, nk, userID, message, notify.ToastWarning, 0); err != nil {
+		logger.Warn("Failed to send rate-limit toast to %s: %v", userID, err)
+	}
+}
+
 func ParseUint32Safely(value string, logger runtime.Logger) (uint32, error) {
 	result, err := strconv.ParseUint(value, 10, 32)
 	if err != nil {
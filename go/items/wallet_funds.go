@@ -0,0 +1,40 @@
+package items
+
+import (
+	stderrors "errors"
+
+	"block-server/errors"
+)
+
+// insufficientFundsError maps a wallet key to the client-facing insufficient-funds error.
+func insufficientFundsError(currency string) error {
+	switch currency {
+	case "gems":
+		return errors.ErrInsufficientGems
+	case "gold":
+		return errors.ErrInsufficientGold
+	case "treats":
+		return errors.ErrInsufficientPetTreats
+	case walletKeyXpBoost:
+		return errors.ErrNoXpBoost
+	default:
+		return errors.ErrInsufficientFunds
+	}
+}
+
+// isInsufficientFunds reports whether a CommitPendingWrites error is an insufficient-funds
+// rejection, which callers should return as-is instead of a generic transaction failure.
+func isInsufficientFunds(err error) bool {
+	for _, target := range []error{
+		errors.ErrInsufficientGems,
+		errors.ErrInsufficientGold,
+		errors.ErrInsufficientPetTreats,
+		errors.ErrNoXpBoost,
+		errors.ErrInsufficientFunds,
+	} {
+		if stderrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
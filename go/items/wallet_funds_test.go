@@ -0,0 +1,65 @@
+package items
+
+import (
+	"sync"
+	"testing"
+
+	"block-server/errors"
+)
+
+func TestConcurrentPurchasesCannotOverdraw(t *testing.T) {
+	const userID = "00000000-0000-0000-0000-000000000101"
+	price := GetShopConfig().LootboxTiers["standard"].PriceGems
+	if price <= 0 {
+		t.Skip("standard tier is not purchasable")
+	}
+	nk := newFakeNakama()
+	nk.setWallet(userID, map[string]int64{"gems": int64(price)})
+
+	// Hold both purchases at the commit until each has passed its balance pre-check.
+	var mu sync.Mutex
+	arrived, bothArrived := 0, make(chan struct{})
+	nk.multiUpdateHook = func() {
+		mu.Lock()
+		arrived++
+		if arrived == 2 {
+			close(bothArrived)
+		}
+		waiting := arrived <= 2
+		mu.Unlock()
+		if waiting {
+			<-bothArrived
+		}
+	}
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = RpcPurchaseLootbox(testContext(userID), testLogger{}, nil, nk, `{"tier":"standard"}`)
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case errors.ErrInsufficientGems:
+		default:
+			t.Fatalf("purchase failed with %v, want ErrInsufficientGems", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d purchases succeeded, want exactly 1", succeeded)
+	}
+	if gems := nk.wallet(userID)["gems"]; gems != 0 {
+		t.Errorf("gems = %d, want 0", gems)
+	}
+	if boxes := nk.count(storageCollectionLootboxes, userID); boxes != 1 {
+		t.Errorf("%d lootboxes granted, want 1", boxes)
+	}
+}
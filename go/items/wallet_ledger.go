@@ -0,0 +1,209 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// storageCollectionWalletLedger holds one bounded, newest-first ledger document per user.
+	storageCollectionWalletLedger = "wallet_ledger"
+	storageKeyWalletLedger        = "ledger"
+	maxWalletLedgerEntries        = 200
+	walletLedgerWriteAttempts     = 3
+)
+
+// WalletLedgerEntry is one applied wallet change. Delta is what actually landed after the daily
+// earn cap; Withheld is what the cap clamped off, so disputes over "missing" currency can be answered.
+type WalletLedgerEntry struct {
+	Delta     map[string]int64 `json:"delta"`
+	Withheld  map[string]int64 `json:"withheld,omitempty"`
+	Reason    string           `json:"reason"`           // Reward reason key, or a fixed reason for direct updates
+	Source    string           `json:"source,omitempty"` // match, lootbox, level_up, daily...
+	CreatedAt int64            `json:"created_at"`       // Unix millis
+}
+
+// WalletLedgerDocument is the bounded ledger buffer (maxWalletLedgerEntries, newest first).
+type WalletLedgerDocument struct {
+	Entries []WalletLedgerEntry `json:"entries"`
+}
+
+// WalletLedgerRequest pages through the caller's ledger.
+type WalletLedgerRequest struct {
+	Limit  int    `json:"limit,omitempty"` // default 20, max maxWalletLedgerEntries
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// WalletLedgerResponse is returned by get_wallet_ledger.
+type WalletLedgerResponse struct {
+	Entries    []WalletLedgerEntry `json:"entries"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// recordWalletDelta appends an entry to the user's wallet ledger. It runs after the wallet change
+// has committed, so it is best-effort: failures are logged and never undo the change itself.
+func recordWalletDelta(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, entry WalletLedgerEntry) {
+	if userID == "" || len(entry.Delta) == 0 {
+		return
+	}
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().UnixMilli()
+	}
+
+	var err error
+	for attempt := 0; attempt < walletLedgerWriteAttempts; attempt++ {
+		if err = appendWalletLedgerEntry(ctx, nk, userID, entry); err == nil {
+			return
+		}
+	}
+	logger.Warn("Failed to record wallet ledger entry for user %s (%s): %v", userID, entry.Reason, err)
+}
+
+// appendWalletLedgerEntry does one OCC read-modify-write of the ledger document.
+func appendWalletLedgerEntry(ctx context.Context, nk runtime.NakamaModule, userID string, entry WalletLedgerEntry) error {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionWalletLedger,
+		Key:        storageKeyWalletLedger,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return err
+	}
+
+	var doc WalletLedgerDocument
+	version := "*"
+	if len(objects) > 0 {
+		if err := json.Unmarshal([]byte(objects[0].Value), &doc); err != nil {
+			return err
+		}
+		version = objects[0].Version
+	}
+
+	doc.Entries = append([]WalletLedgerEntry{entry}, doc.Entries...)
+	if len(doc.Entries) > maxWalletLedgerEntries {
+		doc.Entries = doc.Entries[:maxWalletLedgerEntries]
+	}
+
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionWalletLedger,
+		Key:             storageKeyWalletLedger,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1, // Owner-only
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// recordPendingWalletDeltas writes one ledger entry per user touched by a committed batch.
+func recordPendingWalletDeltas(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, pending *PendingWrites) {
+	if len(pending.WalletUpdates) == 0 {
+		return
+	}
+
+	byUser := map[string]map[string]int64{}
+	order := make([]string, 0, 1)
+	for _, u := range pending.WalletUpdates {
+		delta, ok := byUser[u.UserID]
+		if !ok {
+			delta = map[string]int64{}
+			byUser[u.UserID] = delta
+			order = append(order, u.UserID)
+		}
+		for currency, amount := range u.Changeset {
+			if amount != 0 {
+				delta[currency] += amount
+			}
+		}
+	}
+
+	reason, source := "system", ""
+	if pending.Payload != nil {
+		if pending.Payload.ReasonKey != "" {
+			reason = pending.Payload.ReasonKey
+		}
+		source = pending.Payload.Source
+	}
+
+	for _, userID := range order {
+		entry := WalletLedgerEntry{
+			Delta:  byUser[userID],
+			Reason: reason,
+			Source: source,
+		}
+		// Withheld is tracked per batch; only attribute it when the batch has a single wallet.
+		if len(order) == 1 && len(pending.Withheld) > 0 {
+			entry.Withheld = pending.Withheld
+		}
+		recordWalletDelta(ctx, nk, logger, userID, entry)
+	}
+}
+
+// RpcGetWalletLedger pages through the caller's wallet ledger, newest first.
+func RpcGetWalletLedger(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	var req WalletLedgerRequest
+	if payload != "" && payload != "{}" && payload != "null" {
+		if err := json.Unmarshal([]byte(payload), &req); err != nil {
+			return "", errors.ErrUnmarshal
+		}
+	}
+
+	limit := req.Limit
+	if limit < 1 || limit > maxWalletLedgerEntries {
+		limit = 20
+	}
+	offset := 0
+	if req.Cursor != "" {
+		fmt.Sscanf(req.Cursor, "%d", &offset)
+	}
+
+	objects, err := storageReadWithRetry(ctx, nk, logger, []*runtime.StorageRead{{
+		Collection: storageCollectionWalletLedger,
+		Key:        storageKeyWalletLedger,
+		UserID:     userID,
+	}})
+	if err != nil {
+		logger.Error("Failed to read wallet ledger for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	resp := WalletLedgerResponse{Entries: []WalletLedgerEntry{}}
+	if len(objects) > 0 {
+		doc, err := UnmarshalJSON[WalletLedgerDocument](objects[0].Value)
+		if err != nil {
+			return "", errors.ErrUnmarshal
+		}
+		if offset >= 0 && offset < len(doc.Entries) {
+			end := offset + limit
+			if end < len(doc.Entries) {
+				resp.NextCursor = fmt.Sprintf("%d", end)
+			} else {
+				end = len(doc.Entries)
+			}
+			resp.Entries = doc.Entries[offset:end]
+		}
+	}
+
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"strings"
 
+	"block-server/errors"
+
 	"github.com/heroiclabs/nakama-common/runtime"
 )
 
@@ -18,21 +20,21 @@ func HandleAppleS2SWebhook(ctx context.Context, logger runtime.Logger, db *sql.D
 	}
 	if err := json.Unmarshal([]byte(payload), &req); err != nil {
 		logger.Error("Failed to unmarshal Apple webhook payload: %v", err)
-		return "", err
+		return "", errors.ErrUnmarshal
 	}
 
 	if req.SignedPayload == "" {
-		return "", runtime.NewError("missing signedPayload", 400)
+		return "", errors.ErrInvalidInput
 	}
 
 	parts := strings.Split(req.SignedPayload, ".")
 	if len(parts) != 3 {
-		return "", runtime.NewError("invalid JWS", 400)
+		return "", errors.ErrInvalidInput
 	}
 
 	decodedBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return "", err
+		return "", errors.ErrInvalidInput
 	}
 
 	var notification struct {
@@ -42,7 +44,7 @@ func HandleAppleS2SWebhook(ctx context.Context, logger runtime.Logger, db *sql.D
 		} `json:"data"`
 	}
 	if err := json.Unmarshal(decodedBytes, &notification); err != nil {
-		return "", err
+		return "", errors.ErrUnmarshal
 	}
 
 	if notification.NotificationType != "REFUND" {
@@ -51,12 +53,12 @@ func HandleAppleS2SWebhook(ctx context.Context, logger runtime.Logger, db *sql.D
 
 	txParts := strings.Split(notification.Data.SignedTransactionInfo, ".")
 	if len(txParts) != 3 {
-		return "", runtime.NewError("invalid transaction JWS", 400)
+		return "", errors.ErrInvalidInput
 	}
 
 	txBytes, err := base64.RawURLEncoding.DecodeString(txParts[1])
 	if err != nil {
-		return "", err
+		return "", errors.ErrInvalidInput
 	}
 
 	var txInfo struct {
@@ -64,7 +66,7 @@ func HandleAppleS2SWebhook(ctx context.Context, logger runtime.Logger, db *sql.D
 		OriginalTransactionId string `json:"originalTransactionId"`
 	}
 	if err := json.Unmarshal(txBytes, &txInfo); err != nil {
-		return "", err
+		return "", errors.ErrUnmarshal
 	}
 
 	if txInfo.AppAccountToken == "" || txInfo.OriginalTransactionId == "" {
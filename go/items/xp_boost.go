@@ -0,0 +1,178 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"block-server/errors"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	// ProgressionKeyXpBoost holds the active XP boost expiry (progression collection).
+	ProgressionKeyXpBoost = "xp_boost"
+	// walletKeyXpBoost is the consumable wallet currency spent by activate_xp_boost.
+	walletKeyXpBoost = "xp_boost"
+)
+
+// XpBoostConfig is economy.xp_boost in items.json. Factor <= 1 disables the multiplier.
+type XpBoostConfig struct {
+	Factor          float64 `json:"factor"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
+
+func (c XpBoostConfig) duration() time.Duration {
+	if c.DurationMinutes <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(c.DurationMinutes) * time.Minute
+}
+
+// XpBoostState is the per-user boost record. ExpiresAt is a unix timestamp; zero means none.
+type XpBoostState struct {
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// remaining returns the time left on the boost at now, or zero once it has lapsed.
+func (s XpBoostState) remaining(now time.Time) time.Duration {
+	left := time.Unix(s.ExpiresAt, 0).Sub(now)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// factor returns the XP multiplier at now: the configured factor while active, otherwise 1.
+func (s XpBoostState) factor(now time.Time) float64 {
+	f := GetEconomyConfig().XpBoost.Factor
+	if f <= 1 || s.remaining(now) == 0 {
+		return 1
+	}
+	return f
+}
+
+// xpBoostRead is the storage read for a user's boost state, for folding into a larger batch.
+func xpBoostRead(userID string) *runtime.StorageRead {
+	return &runtime.StorageRead{
+		Collection: storageCollectionProgression,
+		Key:        ProgressionKeyXpBoost,
+		UserID:     userID,
+	}
+}
+
+// parseXpBoost decodes a boost object; a missing or corrupt record reads as no boost.
+func parseXpBoost(obj *api.StorageObject) XpBoostState {
+	var state XpBoostState
+	if obj != nil {
+		_ = json.Unmarshal([]byte(obj.Value), &state)
+	}
+	return state
+}
+
+// readXpBoost returns the user's boost state and its storage version ("*" when none exists yet).
+func readXpBoost(ctx context.Context, nk runtime.NakamaModule, userID string) (XpBoostState, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{xpBoostRead(userID)})
+	if err != nil {
+		return XpBoostState{}, "", err
+	}
+	if len(objects) == 0 {
+		return XpBoostState{}, "*", nil
+	}
+	return parseXpBoost(objects[0]), objects[0].Version, nil
+}
+
+// applyXpBoost scales xp by the boost factor at now.
+func applyXpBoost(xp int, state XpBoostState, now time.Time) int {
+	if f := state.factor(now); f > 1 {
+		return int(float64(xp) * f)
+	}
+	return xp
+}
+
+type XpBoostResponse struct {
+	Success      bool  `json:"success"`
+	ExpiresAt    int64 `json:"expires_at"`
+	RemainingSec int64 `json:"remaining_sec"`
+	BoostsLeft   int64 `json:"boosts_left"`
+}
+
+// RpcActivateXpBoost consumes one xp_boost from the wallet and starts (or extends) the boost.
+// The wallet deduction and the expiry write commit in one MultiUpdate.
+func RpcActivateXpBoost(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runtime.NakamaModule, payload string) (string, error) {
+	userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
+	if !ok {
+		return "", errors.ErrNoUserIdFound
+	}
+
+	account, err := nk.AccountGetId(ctx, userID)
+	if err != nil {
+		return "", errors.ErrCouldNotGetAccount
+	}
+	var wallet map[string]int64
+	if err := json.Unmarshal([]byte(account.Wallet), &wallet); err != nil {
+		return "", errors.ErrUnmarshal
+	}
+	if wallet[walletKeyXpBoost] < 1 {
+		return "", errors.ErrNoXpBoost
+	}
+
+	state, version, err := readXpBoost(ctx, nk, userID)
+	if err != nil {
+		logger.Error("Failed to read xp boost for user %s: %v", userID, err)
+		return "", errors.ErrCouldNotReadStorage
+	}
+
+	// Stacking extends from the current expiry so an early activation never wastes time.
+	now := time.Now()
+	start := now
+	if state.remaining(now) > 0 {
+		start = time.Unix(state.ExpiresAt, 0)
+	}
+	state.ExpiresAt = start.Add(GetEconomyConfig().XpBoost.duration()).Unix()
+
+	value, err := json.Marshal(state)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	pending := NewPendingWrites()
+	pending.AddStorageWrite(&runtime.StorageWrite{
+		Collection:      storageCollectionProgression,
+		Key:             ProgressionKeyXpBoost,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	})
+	pending.AddWalletDeduction(userID, walletKeyXpBoost, 1)
+
+	if err := CommitPendingWrites(ctx, nk, logger, pending); err != nil {
+		if isInsufficientFunds(err) {
+			return "", err
+		}
+		logger.Error("Failed to commit xp boost for user %s: %v", userID, err)
+		return "", errors.ErrTransactionFailed
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"user":      userID,
+		"expiresAt": state.ExpiresAt,
+		"action":    "activate_xp_boost",
+	}).Info("XP boost activated")
+
+	resp := XpBoostResponse{
+		Success:      true,
+		ExpiresAt:    state.ExpiresAt,
+		RemainingSec: int64(state.remaining(now).Seconds()),
+		BoostsLeft:   wallet[walletKeyXpBoost] - 1,
+	}
+	respBytes, err := json.Marshal(resp)
+	if err != nil {
+		return "", errors.ErrMarshal
+	}
+	return string(respBytes), nil
+}
@@ -24,23 +24,19 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		len(items.GameData.Backgrounds),
 		len(items.GameData.PieceStyles),
 		len(items.GameData.LevelTrees))
+	for _, warning := range items.LevelTreeWarnings() {
+		logger.Warn("Level tree validation: %s", warning)
+	}
 
-	for _, lb := range []struct {
-		id, sortOrder, operator, reset string
-	}{
-		{items.LeaderboardSoloSeason, "desc", "best", ""},
-		{items.LeaderboardSoloWeekly, "desc", "best", "0 0 * * 1"},
-		{items.Leaderboard1v1Season, "desc", "incr", ""},
-		{items.Leaderboard1v1Weekly, "desc", "incr", "0 0 * * 1"},
-	} {
-		if err := nk.LeaderboardCreate(ctx, lb.id, true, lb.sortOrder, lb.operator, lb.reset, nil, true); err != nil {
-			logger.Error("Failed to create leaderboard %s: %v", lb.id, err)
-			// Non-fatal: boards may already exist from a previous startup.
-		}
+	boards := items.BootstrapLeaderboards(ctx, nk, logger)
+	logger.Info("Leaderboards bootstrapped: %v", boards)
+	if tournamentID := items.BootstrapTournament(ctx, nk, logger); tournamentID != "" {
+		logger.Info("Tournament bootstrapped: %s", tournamentID)
+	}
+	if err := initializer.RegisterTournamentEnd(items.TournamentEnd); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
 	}
-	logger.Info("Leaderboards bootstrapped: %s, %s, %s, %s",
-		items.LeaderboardSoloSeason, items.LeaderboardSoloWeekly,
-		items.Leaderboard1v1Season, items.Leaderboard1v1Weekly)
 
 	if err := initializer.RegisterAfterAuthenticateDevice(items.AfterAuthorizeUserDevice); err != nil {
 		logger.Error("Unable to register: %v", err)
@@ -100,14 +96,26 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("get_item_details", items.RpcGetItemDetails); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("get_equipment", items.RpcGetEquipment); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("get_player_state", items.RpcGetPlayerState); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("get_progression", requireClientVersion(items.RpcGetProgression)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("verify_progression", requireClientVersion(items.RpcVerifyProgression)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("use_pet_treat", requireClientVersion(items.RpcUsePetTreat)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -120,6 +128,46 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("prestige_item", requireClientVersion(items.RpcPrestigeItem)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("activate_xp_boost", requireClientVersion(items.RpcActivateXpBoost)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_onboarding_state", items.RpcGetOnboardingState); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("complete_onboarding_step", requireClientVersion(items.RpcCompleteOnboardingStep)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_ability_loadout_rules", items.RpcGetAbilityLoadoutRules); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("validate_loadout_for_match", items.RpcValidateLoadoutForMatch); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("export_user_data", items.RpcExportUserData); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("delete_user_data", items.RpcDeleteUserData); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_referral_code", items.RpcGetReferralCode); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("redeem_referral", requireClientVersion(items.RpcRedeemReferral)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("claim_all_progression_rewards", requireClientVersion(items.RpcClaimAllProgressionRewards)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -140,6 +188,14 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("equip_class_sprite", requireClientVersion(items.RpcEquipClassSprite)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("equip_pet_sprite", requireClientVersion(items.RpcEquipPetSprite)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("equip_background", requireClientVersion(items.RpcEquipBackground)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -156,10 +212,38 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("get_token_status", items.RpcGetTokenStatus); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_daily_stats", items.RpcGetDailyStats); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_daily_earnings", items.RpcGetDailyEarnings); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("submit_match_result", requireClientVersion(items.RpcSubmitMatchResult)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("forfeit_match", requireClientVersion(items.RpcForfeitMatch)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_active_match", requireClientVersion(items.RpcGetActiveMatch)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterMatch(items.AuthoritativeMatchModule, items.NewAuthoritativeMatch); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("create_authoritative_match", requireClientVersion(items.RpcCreateAuthoritativeMatch)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("get_lootboxes", requireClientVersion(items.RpcGetLootboxes)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -168,6 +252,10 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("get_open_proof", items.RpcGetOpenProof); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 
 	if err := items.LoadShopData(); err != nil {
 		logger.Warn("Failed to load shop data (shop disabled): %v", err)
@@ -184,10 +272,50 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("set_showcase", requireClientVersion(items.RpcSetShowcase)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_showcase", items.RpcGetShowcase); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("toggle_favorite", requireClientVersion(items.RpcToggleFavorite)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_favorites", items.RpcGetFavorites); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("save_loadout", requireClientVersion(items.RpcSaveLoadout)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("apply_loadout", requireClientVersion(items.RpcApplyLoadout)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("list_loadouts", items.RpcListLoadouts); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("sell_item", requireClientVersion(items.RpcSellItem)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_starter_pack_status", items.RpcGetStarterPackStatus); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("purchase_lootbox", requireClientVersion(items.RpcPurchaseLootbox)); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("buy_treats", requireClientVersion(items.RpcBuyTreats)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("validate_iap_receipt", items.RpcValidateIAPReceipt); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -216,6 +344,22 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		logger.Error("Unable to register: %v", err)
 		return err
 	}
+	if err := initializer.RegisterRpc("get_my_rank", items.RpcGetMyRank); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_tournament", items.RpcGetTournament); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("join_tournament", requireClientVersion(items.RpcJoinTournament)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("claim_tournament_reward", requireClientVersion(items.RpcClaimTournamentReward)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
 	if err := initializer.RegisterRpc("get_player_stats", items.RpcGetPlayerStats); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -225,6 +369,11 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	if err := initializer.RegisterRpc("get_wallet_ledger", items.RpcGetWalletLedger); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+
 	if err := initializer.RegisterRpc("get_users_loadouts", items.RpcGetUsersLoadouts); err != nil {
 		logger.Error("Unable to register: %v", err)
 		return err
@@ -235,6 +384,71 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	if err := initializer.RegisterRpc("get_achievements", items.RpcGetAchievements); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_match_streak", items.RpcGetMatchStreak); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("acknowledge_reward", items.RpcAcknowledgeReward); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("ack_notifications", items.RpcAckNotifications); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("reload_game_data", items.RpcReloadGameData); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_economy_snapshot", items.RpcGetEconomySnapshot); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("admin_grant", items.RpcAdminGrant); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_reports", items.RpcGetReports); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("set_shadow_ban", items.RpcSetShadowBan); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("report_player", requireClientVersion(items.RpcReportPlayer)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_pending_rewards", items.RpcGetPendingRewards); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("claim_pending_reward", requireClientVersion(items.RpcClaimPendingReward)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_quests", items.RpcGetQuests); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("claim_quest_reward", requireClientVersion(items.RpcClaimQuestReward)); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("get_notification_settings", items.RpcGetNotificationSettings); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+	if err := initializer.RegisterRpc("set_notification_settings", items.RpcSetNotificationSettings); err != nil {
+		logger.Error("Unable to register: %v", err)
+		return err
+	}
+
 	// Social RPCs
 	if err := initializer.RegisterRpc("send_game_invite", items.RpcSendGameInvite); err != nil {
 		logger.Error("Unable to register: %v", err)
@@ -254,6 +468,8 @@ func InitModule(ctx context.Context, logger runtime.Logger, db *sql.DB, nk runti
 		return err
 	}
 
+	items.StartEconomySnapshots(logger, nk)
+
 	// Background cleanup of obsolete storage records
 	go func() {
 		time.Sleep(30 * time.Second)
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	StorageCollectionDeliveries = "reward_deliveries"
+
+	// DeliveryResendAfter is how long a reward may stay unacknowledged before it is re-sent.
+	DeliveryResendAfter = 2 * time.Minute
+	// maxDeliveryAttempts bounds re-sends so a client that never acks can't grow the queue forever.
+	maxDeliveryAttempts = 5
+	// maxDeliveriesScanned caps a single resend pass.
+	maxDeliveriesScanned = 100
+)
+
+// RewardDelivery tracks a reward notification awaiting client acknowledgement.
+// Collection: reward_deliveries, Key: RewardID. Deleted on ack.
+type RewardDelivery struct {
+	Payload  *RewardPayload `json:"payload"`
+	SentAt   int64          `json:"sent_at"`
+	Attempts int            `json:"attempts"`
+}
+
+func writeDelivery(ctx context.Context, nk runtime.NakamaModule, userID string, delivery *RewardDelivery, version string) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("delivery marshal: %w", err)
+	}
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      StorageCollectionDeliveries,
+		Key:             delivery.Payload.RewardID,
+		UserID:          userID,
+		Value:           string(value),
+		Version:         version,
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}})
+	return err
+}
+
+// AcknowledgeReward marks a reward as processed by the client. Unknown IDs are a no-op,
+// so acking twice (or acking a reward that was never tracked) is safe.
+func AcknowledgeReward(ctx context.Context, nk runtime.NakamaModule, userID, rewardID string) error {
+	return nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+		Collection: StorageCollectionDeliveries,
+		Key:        rewardID,
+		UserID:     userID,
+	}})
+}
+
+// ResendUnacknowledged re-sends rewards older than DeliveryResendAfter that the client never acked.
+// The client dedups on RewardID, so delivery is at-least-once. Returns the number re-sent.
+func ResendUnacknowledged(ctx context.Context, nk runtime.NakamaModule, userID string) (int, error) {
+	objects, _, err := nk.StorageList(ctx, "", userID, StorageCollectionDeliveries, maxDeliveriesScanned, "")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-DeliveryResendAfter).Unix()
+	resent := 0
+	for _, obj := range objects {
+		var delivery RewardDelivery
+		if err := json.Unmarshal([]byte(obj.Value), &delivery); err != nil || delivery.Payload == nil {
+			continue
+		}
+		if delivery.SentAt > cutoff {
+			continue
+		}
+		if delivery.Attempts >= maxDeliveryAttempts {
+			AcknowledgeReward(ctx, nk, userID, obj.Key)
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.SentAt = time.Now().Unix()
+		// Version-guarded: a concurrent ack or resend wins and we skip this one.
+		if err := writeDelivery(ctx, nk, userID, &delivery, obj.Version); err != nil {
+			continue
+		}
+		if err := sendRewardNotification(ctx, nk, userID, delivery.Payload); err != nil {
+			return resent, err
+		}
+		resent++
+	}
+	return resent, nil
+}
+
+// AcknowledgeNotifications deletes the caller's persistent notifications by ID and acks any
+// reward they carry, so the same reward isn't re-sent on the next session. IDs that don't
+// belong to userID are ignored. Returns the number of notifications deleted.
+func AcknowledgeNotifications(ctx context.Context, nk runtime.NakamaModule, userID string, ids []string) (int, error) {
+	// NotificationsGetId scopes the lookup to userID, which doubles as the ownership check.
+	owned, err := nk.NotificationsGetId(ctx, userID, ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(owned) == 0 {
+		return 0, nil
+	}
+
+	deletes := make([]*runtime.NotificationDelete, 0, len(owned))
+	for _, n := range owned {
+		if n.UserID != userID {
+			continue
+		}
+		if n.Code == CodeReward {
+			if rewardID, ok := n.Content["reward_id"].(string); ok && rewardID != "" {
+				if err := AcknowledgeReward(ctx, nk, userID, rewardID); err != nil {
+					return 0, err
+				}
+			}
+		}
+		deletes = append(deletes, &runtime.NotificationDelete{UserID: userID, NotificationID: n.Id})
+	}
+	if len(deletes) == 0 {
+		return 0, nil
+	}
+	if err := nk.NotificationsDelete(ctx, deletes); err != nil {
+		return 0, err
+	}
+	return len(deletes), nil
+}
@@ -0,0 +1,71 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUnacknowledgedRewardIsResent(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.Background()
+
+	acked := NewRewardPayload("match")
+	acked.Wallet = &WalletDelta{Gold: 10}
+	pending := NewRewardPayload("match")
+	pending.Wallet = &WalletDelta{Gold: 20}
+	for _, p := range []*RewardPayload{acked, pending} {
+		if err := SendReward(ctx, nk, "u1", p); err != nil {
+			t.Fatalf("SendReward: %v", err)
+		}
+	}
+	if err := AcknowledgeReward(ctx, nk, "u1", acked.RewardID); err != nil {
+		t.Fatalf("AcknowledgeReward: %v", err)
+	}
+
+	// Nothing is due before DeliveryResendAfter has elapsed.
+	if n, err := ResendUnacknowledged(ctx, nk, "u1"); err != nil || n != 0 {
+		t.Fatalf("early resend = %d, %v; want 0", n, err)
+	}
+
+	// Age the outstanding record past the resend window.
+	nk.put(t, StorageCollectionDeliveries, pending.RewardID, "u1", RewardDelivery{
+		Payload:  pending,
+		SentAt:   time.Now().Add(-DeliveryResendAfter - time.Second).Unix(),
+		Attempts: 1,
+	})
+	n, err := ResendUnacknowledged(ctx, nk, "u1")
+	if err != nil {
+		t.Fatalf("ResendUnacknowledged: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("resent %d rewards, want 1", n)
+	}
+	rewards := nk.sent(CodeReward)
+	if len(rewards) != 3 {
+		t.Fatalf("sent %d reward notifications, want 3", len(rewards))
+	}
+	if got := rewards[2].Content["reward_id"]; got != pending.RewardID {
+		t.Errorf("re-sent reward_id = %v, want %s", got, pending.RewardID)
+	}
+	if nk.has(StorageCollectionDeliveries, acked.RewardID, "u1") {
+		t.Error("acknowledged reward still has a delivery record")
+	}
+}
+
+func TestMetaOnlyNoteIsNotTracked(t *testing.T) {
+	nk := newFakeNakama()
+	p := NewRewardPayload("match")
+	p.Meta = &RewardMeta{}
+	p.ReasonKey = "reward.match.opponent_submitted"
+
+	if err := SendReward(context.Background(), nk, "u1", p); err != nil {
+		t.Fatalf("SendReward: %v", err)
+	}
+	if len(nk.sent(CodeReward)) != 1 {
+		t.Fatal("meta-only note was not sent")
+	}
+	if nk.has(StorageCollectionDeliveries, p.RewardID, "u1") {
+		t.Error("meta-only note wrote a delivery record")
+	}
+}
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+type storageID struct {
+	collection, key, userID string
+}
+
+// fakeNakama is an in-memory NakamaModule covering storage and notification sends.
+// Anything else panics through the nil embedded interface.
+type fakeNakama struct {
+	runtime.NakamaModule
+
+	mu            sync.Mutex
+	seq           int
+	storage       map[storageID]*api.StorageObject
+	notifications []*runtime.NotificationSend
+}
+
+func newFakeNakama() *fakeNakama {
+	return &fakeNakama{storage: make(map[storageID]*api.StorageObject)}
+}
+
+func (f *fakeNakama) put(t testing.TB, collection, key, userID string, v interface{}) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal %s/%s: %v", collection, key, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	f.storage[storageID{collection, key, userID}] = &api.StorageObject{
+		Collection: collection, Key: key, UserId: userID, Value: string(data), Version: strconv.Itoa(f.seq),
+	}
+}
+
+func (f *fakeNakama) has(collection, key, userID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.storage[storageID{collection, key, userID}]
+	return ok
+}
+
+func (f *fakeNakama) sent(code int) []*runtime.NotificationSend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*runtime.NotificationSend
+	for _, n := range f.notifications {
+		if n.Code == code {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (f *fakeNakama) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*api.StorageObject
+	for _, r := range reads {
+		if obj, ok := f.storage[storageID{r.Collection, r.Key, r.UserID}]; ok {
+			out = append(out, proto.Clone(obj).(*api.StorageObject))
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNakama) StorageWrite(ctx context.Context, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, w := range writes {
+		existing, ok := f.storage[storageID{w.Collection, w.Key, w.UserID}]
+		switch {
+		case w.Version == "":
+		case w.Version == "*" && ok, w.Version != "*" && (!ok || existing.Version != w.Version):
+			return nil, runtime.ErrStorageRejectedVersion
+		}
+	}
+	var acks []*api.StorageObjectAck
+	for _, w := range writes {
+		f.seq++
+		obj := &api.StorageObject{Collection: w.Collection, Key: w.Key, UserId: w.UserID, Value: w.Value, Version: strconv.Itoa(f.seq)}
+		f.storage[storageID{w.Collection, w.Key, w.UserID}] = obj
+		acks = append(acks, &api.StorageObjectAck{Collection: w.Collection, Key: w.Key, UserId: w.UserID, Version: obj.Version})
+	}
+	return acks, nil
+}
+
+func (f *fakeNakama) StorageDelete(ctx context.Context, deletes []*runtime.StorageDelete) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range deletes {
+		delete(f.storage, storageID{d.Collection, d.Key, d.UserID})
+	}
+	return nil
+}
+
+func (f *fakeNakama) StorageList(ctx context.Context, callerID, userID, collection string, limit int, cursor string) ([]*api.StorageObject, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*api.StorageObject
+	for id, obj := range f.storage {
+		if id.collection == collection && id.userID == userID {
+			out = append(out, proto.Clone(obj).(*api.StorageObject))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, "", nil
+}
+
+func (f *fakeNakama) NotificationSend(ctx context.Context, userID, subject string, content map[string]interface{}, code int, sender string, persistent bool) error {
+	return f.NotificationsSend(ctx, []*runtime.NotificationSend{{UserID: userID, Subject: subject, Content: content, Code: code, Sender: sender, Persistent: persistent}})
+}
+
+func (f *fakeNakama) NotificationsSend(ctx context.Context, notifications []*runtime.NotificationSend) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = append(f.notifications, notifications...)
+	return nil
+}
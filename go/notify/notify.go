@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/heroiclabs/nakama-common/runtime"
@@ -28,6 +29,20 @@ const (
 	CodeDevice        = 100 // Single-device enforcement
 )
 
+// ToastLevel selects the client's toast styling.
+// MUST remain aligned with the client's ToastLevel enum.
+type ToastLevel string
+
+const (
+	ToastInfo    ToastLevel = "info"
+	ToastSuccess ToastLevel = "success"
+	ToastWarning ToastLevel = "warning"
+	ToastError   ToastLevel = "error"
+)
+
+// DefaultToastDuration is used when a caller passes a non-positive duration (seconds).
+const DefaultToastDuration = 3.0
+
 // RewardPayload is the unified reward schema for all delivery channels.
 // Domains are MECE - each maps to a player state bucket.
 type RewardPayload struct {
@@ -63,6 +78,7 @@ type RewardPayload struct {
 	Progression      *ProgressionDelta `json:"progression,omitempty"`
 	Lootboxes        []LootboxGrant    `json:"lootboxes,omitempty"`
 	DuplicateGrants  []DuplicateGrant  `json:"duplicate_grants,omitempty"`
+	Achievements     []string          `json:"achievements,omitempty"` // IDs completed by this grant
 
 	// Meta (non-reward feedback)
 	Meta        *RewardMeta `json:"meta,omitempty"`
@@ -81,6 +97,7 @@ type EconomyState struct {
 	CarryOverTokens *int `json:"carry_over_tokens,omitempty"`
 	ExchangesMade   int  `json:"exchanges_made,omitempty"`
 	ExchangesLeft   *int `json:"exchanges_left,omitempty"`
+	FirstWinBonus   bool `json:"first_win_bonus,omitempty"` // This win paid the daily first-win bonus
 }
 
 // CompetitiveBoardState encapsulates rank data for a single leaderboard.
@@ -129,6 +146,23 @@ type ItemGrant struct {
 	Type string `json:"type"` // pet, class, background, piece_style
 }
 
+// ReasonArgs keys for wallet amounts.
+// Convention: any payload carrying a WalletDelta also exposes each non-zero currency
+// in ReasonArgs under these keys as a base-10 string (e.g. {"gold": "50"}), so
+// localized strings like "+{gold} Gold" render without the client reading Wallet.
+const (
+	ReasonArgGold   = "gold"
+	ReasonArgGems   = "gems"
+	ReasonArgTreats = "treats"
+)
+
+// ReasonArgs keys for progression and lootbox templates, e.g. "You reached level {level}!".
+const (
+	ReasonArgXP    = "xp"
+	ReasonArgLevel = "level"
+	ReasonArgTier  = "tier"
+)
+
 // Discrete currency changes rather than absolute totals.
 // Allows multiple parallel matches to claim rewards without race conditions.
 type WalletDelta struct {
@@ -154,6 +188,8 @@ type ProgressionDelta struct {
 	NewUnclaimedRewards []int                `json:"new_unclaimed_rewards,omitempty"`
 	UpdatedTierStates   map[string]TierState `json:"updated_tier_states,omitempty"`
 	Unlocks             []ProgressionUnlock  `json:"unlocks,omitempty"`
+	ItemLevel           *int                 `json:"item_level,omitempty"` // Pet/class level after an item XP grant
+	ItemExp             *int                 `json:"item_exp,omitempty"`   // Pet/class total XP after an item XP grant
 }
 
 // ProgressionUnlock represents an ability/sprite unlock from level-up.
@@ -190,6 +226,16 @@ type RewardMeta struct {
 	// > 0 means the player earned at least one lootbox from token exchange.
 	// The client uses this to trigger the exchange animation sequence.
 	ExchangesMade int `json:"exchanges_made,omitempty"`
+	// WinStreak is the player's consecutive 1v1 wins after this match. Nil when the outcome
+	// wasn't resolved yet (first submitter) and the streak was left untouched.
+	WinStreak *int `json:"win_streak,omitempty"`
+	// DailyCapReached lists currencies the daily earn cap clamped in this grant.
+	DailyCapReached []string `json:"daily_cap_reached,omitempty"`
+	// XpBoostRemainingSec is the time left on an active XP boost. Nil when no boost is active.
+	XpBoostRemainingSec *int64 `json:"xp_boost_remaining_sec,omitempty"`
+	// WalletBalances is the post-commit balance of each currency the action spent or granted,
+	// so the client can update counters without a separate wallet read.
+	WalletBalances map[string]int64 `json:"wallet_balances,omitempty"`
 	// ErrorCode is set when the match result was rejected by a server validation gate.
 	// Non-empty means no rewards were processed. Known values: MATCH_TOO_SHORT.
 	// The client routes to distinct UI messages based on this code.
@@ -205,6 +251,116 @@ func NewRewardPayload(source string) *RewardPayload {
 	}
 }
 
+// SetWalletReasonArgs fills ReasonArgs from Wallet per the ReasonArgGold/Gems/Treats convention.
+// Safe to call on payloads without a wallet delta.
+func (p *RewardPayload) SetWalletReasonArgs() {
+	if p == nil || p.Wallet == nil {
+		return
+	}
+	if p.Wallet.Gold != 0 {
+		p.SetReasonArgInt(ReasonArgGold, p.Wallet.Gold)
+	}
+	if p.Wallet.Gems != 0 {
+		p.SetReasonArgInt(ReasonArgGems, p.Wallet.Gems)
+	}
+	if p.Wallet.Treats != 0 {
+		p.SetReasonArgInt(ReasonArgTreats, p.Wallet.Treats)
+	}
+}
+
+// HasContent reports whether the payload carries anything beyond Meta and reason text:
+// a grant, progression, or end-screen state. Meta-only notes (e.g. "opponent submitted")
+// have nothing the client could lose, so they skip delivery tracking.
+func (p *RewardPayload) HasContent() bool {
+	if p == nil {
+		return false
+	}
+	if p.Wallet != nil && (p.Wallet.Gold != 0 || p.Wallet.Gems != 0 || p.Wallet.Treats != 0) {
+		return true
+	}
+	if p.Inventory != nil && len(p.Inventory.Items) > 0 {
+		return true
+	}
+	return p.Progression != nil || len(p.Lootboxes) > 0 || len(p.DuplicateGrants) > 0 ||
+		len(p.Achievements) > 0 || p.Economy != nil || len(p.Competitive) > 0 ||
+		len(p.Performance) > 0 || p.LeaderboardRank > 0
+}
+
+// SetReasonArg sets a localization arg, allocating ReasonArgs on first use.
+func (p *RewardPayload) SetReasonArg(key, value string) {
+	if p == nil {
+		return
+	}
+	if p.ReasonArgs == nil {
+		p.ReasonArgs = make(map[string]string)
+	}
+	p.ReasonArgs[key] = value
+}
+
+// SetReasonArgInt sets a numeric localization arg as a base-10 string.
+func (p *RewardPayload) SetReasonArgInt(key string, value int) {
+	p.SetReasonArg(key, strconv.Itoa(value))
+}
+
+// Merge additively combines other into p: wallet deltas are summed; inventory, unlocks,
+// lootboxes, duplicate grants and achievements are appended. Identity, context, levels/XP
+// and end-screen fields stay those of p.
+func (p *RewardPayload) Merge(other *RewardPayload) {
+	if p == nil || other == nil {
+		return
+	}
+
+	if other.Wallet != nil {
+		if p.Wallet == nil {
+			p.Wallet = &WalletDelta{}
+		}
+		p.Wallet.Gold += other.Wallet.Gold
+		p.Wallet.Gems += other.Wallet.Gems
+		p.Wallet.Treats += other.Wallet.Treats
+		p.SetWalletReasonArgs()
+	}
+
+	if other.Inventory != nil {
+		if p.Inventory == nil {
+			p.Inventory = &InventoryDelta{Items: []ItemGrant{}}
+		}
+		p.Inventory.Items = append(p.Inventory.Items, other.Inventory.Items...)
+	}
+
+	if len(other.Achievements) > 0 {
+		p.Achievements = append(p.Achievements, other.Achievements...)
+	}
+	if len(other.Lootboxes) > 0 {
+		p.Lootboxes = append(p.Lootboxes, other.Lootboxes...)
+	}
+	if len(other.DuplicateGrants) > 0 {
+		p.DuplicateGrants = append(p.DuplicateGrants, other.DuplicateGrants...)
+	}
+
+	if other.Progression != nil && len(other.Progression.Unlocks) > 0 {
+		if p.Progression == nil {
+			p.Progression = &ProgressionDelta{}
+		}
+		p.Progression.Unlocks = append(p.Progression.Unlocks, other.Progression.Unlocks...)
+	}
+}
+
+// Mergeable reports whether Merge carries all of p into another payload: grants, achievements
+// and unlocks only. Payloads with XP or levels, Meta, end-screen state or a deep link must be
+// sent on their own.
+func (p *RewardPayload) Mergeable() bool {
+	if p == nil || p.Action != "" || p.Meta != nil || p.DisplayTier != "" || p.Economy != nil ||
+		len(p.Competitive) > 0 || len(p.Performance) > 0 || p.LeaderboardRank != 0 || p.BoardId != "" {
+		return false
+	}
+	if pr := p.Progression; pr != nil {
+		return pr.XpGranted == nil && pr.XpBase == nil && pr.NewPlayerLevel == nil && pr.NewPetLevel == nil &&
+			pr.NewClassLevel == nil && len(pr.NewUnclaimedRewards) == 0 && len(pr.UpdatedTierStates) == 0 &&
+			pr.ItemLevel == nil && pr.ItemExp == nil
+	}
+	return true
+}
+
 // generateID creates a random 12-character hex string.
 func generateID() string {
 	b := make([]byte, 6)
@@ -223,7 +379,29 @@ func Int64Ptr(v int64) *int64 {
 }
 
 // Helper to marshal and ship a RewardPayload down to the client.
+// A delivery record is written first so the reward is re-sent until the client acknowledges it.
+// The record is best-effort: failing to write it must not block the notification itself.
+// Meta-only payloads are sent untracked; re-sending them would only repeat the note.
 func SendReward(ctx context.Context, nk runtime.NakamaModule, userID string, payload *RewardPayload) error {
+	payload.SetWalletReasonArgs()
+	_ = TrackReward(ctx, nk, userID, payload)
+	return sendRewardNotification(ctx, nk, userID, payload)
+}
+
+// TrackReward writes the delivery record SendReward would, without sending. A reward whose
+// notification is held back (e.g. bundled) is then re-sent even if it never goes out.
+func TrackReward(ctx context.Context, nk runtime.NakamaModule, userID string, payload *RewardPayload) error {
+	if payload.RewardID == "" || !payload.HasContent() {
+		return nil
+	}
+	return writeDelivery(ctx, nk, userID, &RewardDelivery{
+		Payload:  payload,
+		SentAt:   time.Now().Unix(),
+		Attempts: 1,
+	}, "")
+}
+
+func sendRewardNotification(ctx context.Context, nk runtime.NakamaModule, userID string, payload *RewardPayload) error {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("reward marshal: %w", err)
@@ -235,18 +413,37 @@ func SendReward(ctx context.Context, nk runtime.NakamaModule, userID string, pay
 	return nk.NotificationSend(ctx, userID, "Reward!", content, CodeReward, "", true)
 }
 
-// SendToast sends a simple toast notification.
-func SendToast(ctx context.Context, nk runtime.NakamaModule, userID, message string) error {
+// SendToast sends a toast notification with the given severity and duration (seconds).
+// Skipped if the user muted toasts.
+func SendToast(ctx context.Context, nk runtime.NakamaModule, userID, message string, level ToastLevel, duration float64) error {
+	if !isCategoryEnabled(ctx, nk, userID, CodeToast) {
+		return nil
+	}
+	if level == "" {
+		level = ToastInfo
+	}
+	if duration <= 0 {
+		duration = DefaultToastDuration
+	}
 	content := map[string]interface{}{
-		"message": message,
+		"message":  message,
+		"severity": string(level),
+		"duration": duration,
 	}
 	return nk.NotificationSend(ctx, userID, message, content, CodeToast, "", false)
 }
 
-// SendCenterMessage sends a center flyout message.
-func SendCenterMessage(ctx context.Context, nk runtime.NakamaModule, userID, message string, duration float64) error {
+// SendCenterMessage sends a center flyout message with the given severity and duration (seconds).
+func SendCenterMessage(ctx context.Context, nk runtime.NakamaModule, userID, message string, level ToastLevel, duration float64) error {
+	if level == "" {
+		level = ToastInfo
+	}
+	if duration <= 0 {
+		duration = DefaultToastDuration
+	}
 	content := map[string]interface{}{
 		"message":  message,
+		"severity": string(level),
 		"duration": duration,
 	}
 	return nk.NotificationSend(ctx, userID, message, content, CodeCenterMessage, "", false)
@@ -260,3 +457,19 @@ func SendAnnouncement(ctx context.Context, nk runtime.NakamaModule, userID, titl
 	}
 	return nk.NotificationSend(ctx, userID, title, content, CodeAnnouncement, "", true)
 }
+
+// SendSocial sends a friend/invite notification. Skipped if the user muted social.
+func SendSocial(ctx context.Context, nk runtime.NakamaModule, userID, subject string, content map[string]interface{}, senderID string, persistent bool) error {
+	if !isCategoryEnabled(ctx, nk, userID, CodeSocial) {
+		return nil
+	}
+	return nk.NotificationSend(ctx, userID, subject, content, CodeSocial, senderID, persistent)
+}
+
+// SendDailyRefresh sends a daily/weekly refresh event. Skipped if the user muted daily refreshes.
+func SendDailyRefresh(ctx context.Context, nk runtime.NakamaModule, userID, subject string, content map[string]interface{}) error {
+	if !isCategoryEnabled(ctx, nk, userID, CodeDailyRefresh) {
+		return nil
+	}
+	return nk.NotificationSend(ctx, userID, subject, content, CodeDailyRefresh, "", false)
+}
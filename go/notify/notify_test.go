@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetWalletReasonArgs(t *testing.T) {
+	p := NewRewardPayload("match")
+	p.Wallet = &WalletDelta{Gold: 50, Gems: 3}
+	p.SetWalletReasonArgs()
+
+	if got := p.ReasonArgs[ReasonArgGold]; got != "50" {
+		t.Errorf("gold arg = %q, want 50", got)
+	}
+	if got := p.ReasonArgs[ReasonArgGems]; got != "3" {
+		t.Errorf("gems arg = %q, want 3", got)
+	}
+	if _, ok := p.ReasonArgs[ReasonArgTreats]; ok {
+		t.Errorf("zero treats should not produce an arg, got %v", p.ReasonArgs)
+	}
+}
+
+func TestSetWalletReasonArgsWithoutWallet(t *testing.T) {
+	p := NewRewardPayload("level_up")
+	p.SetWalletReasonArgs()
+	if p.ReasonArgs != nil {
+		t.Errorf("payload without wallet got reason args %v", p.ReasonArgs)
+	}
+}
+
+func TestSendRewardFillsWalletReasonArgs(t *testing.T) {
+	nk := newFakeNakama()
+	p := NewRewardPayload("lootbox")
+	p.Wallet = &WalletDelta{Treats: 7}
+
+	if err := SendReward(context.Background(), nk, "u1", p); err != nil {
+		t.Fatalf("SendReward: %v", err)
+	}
+	sent := nk.sent(CodeReward)
+	if len(sent) != 1 {
+		t.Fatalf("sent %d reward notifications, want 1", len(sent))
+	}
+	args, _ := sent[0].Content["reason_args"].(map[string]interface{})
+	if args[ReasonArgTreats] != "7" {
+		t.Errorf("notification reason_args = %v, want treats=7", args)
+	}
+}
+
+func TestMergeRefreshesWalletReasonArgs(t *testing.T) {
+	a := NewRewardPayload("match")
+	a.Wallet = &WalletDelta{Gold: 10}
+	a.SetWalletReasonArgs()
+	b := NewRewardPayload("match")
+	b.Wallet = &WalletDelta{Gold: 15, Gems: 1}
+
+	a.Merge(b)
+	if a.ReasonArgs[ReasonArgGold] != "25" || a.ReasonArgs[ReasonArgGems] != "1" {
+		t.Errorf("merged reason args = %v, want gold=25 gems=1", a.ReasonArgs)
+	}
+}
+
+func TestToastCarriesSeverityAndDuration(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.Background()
+
+	if err := SendToast(ctx, nk, "u1", "slow down", ToastWarning, 5); err != nil {
+		t.Fatalf("SendToast: %v", err)
+	}
+	if err := SendToast(ctx, nk, "u1", "defaults", "", 0); err != nil {
+		t.Fatalf("SendToast: %v", err)
+	}
+	if err := SendCenterMessage(ctx, nk, "u1", "center", ToastError, 0); err != nil {
+		t.Fatalf("SendCenterMessage: %v", err)
+	}
+
+	toasts := nk.sent(CodeToast)
+	if len(toasts) != 2 {
+		t.Fatalf("sent %d toasts, want 2", len(toasts))
+	}
+	if toasts[0].Content["severity"] != "warning" || toasts[0].Content["duration"] != 5.0 {
+		t.Errorf("toast content = %v, want warning for 5s", toasts[0].Content)
+	}
+	if toasts[1].Content["severity"] != "info" || toasts[1].Content["duration"] != DefaultToastDuration {
+		t.Errorf("default toast content = %v, want info for %vs", toasts[1].Content, DefaultToastDuration)
+	}
+	center := nk.sent(CodeCenterMessage)
+	if len(center) != 1 || center[0].Content["severity"] != "error" || center[0].Content["duration"] != DefaultToastDuration {
+		t.Errorf("center message = %v, want error with default duration", center)
+	}
+}
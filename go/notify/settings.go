@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+const (
+	StorageCollectionSettings = "notification_settings"
+	StorageKeySettings        = "settings"
+)
+
+// Settings holds per-user opt-outs for non-critical notification categories.
+// Critical categories (CodeDevice, CodeReward, CodeWallet, CodeAnnouncement, etc.)
+// are never gated — they carry state the client must act on.
+type Settings struct {
+	Toasts       bool   `json:"toasts"`
+	Social       bool   `json:"social"`
+	DailyRefresh bool   `json:"daily_refresh"`
+	Version      string `json:"-"`
+}
+
+// DefaultSettings returns the all-enabled settings used when no object is stored.
+func DefaultSettings() *Settings {
+	return &Settings{
+		Toasts:       true,
+		Social:       true,
+		DailyRefresh: true,
+	}
+}
+
+// GetSettings reads the user's notification settings, falling back to defaults.
+// Fields absent from the stored object keep their default (enabled) value.
+func GetSettings(ctx context.Context, nk runtime.NakamaModule, userID string) (*Settings, error) {
+	settings := DefaultSettings()
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: StorageCollectionSettings,
+		Key:        StorageKeySettings,
+		UserID:     userID,
+	}})
+	if err != nil {
+		return settings, err
+	}
+	if len(objects) == 0 {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(objects[0].Value), settings); err != nil {
+		return DefaultSettings(), err
+	}
+	settings.Version = objects[0].Version
+	return settings, nil
+}
+
+// Allows reports whether the given notification code may be delivered under these settings.
+func (s *Settings) Allows(code int) bool {
+	switch code {
+	case CodeToast:
+		return s.Toasts
+	case CodeSocial:
+		return s.Social
+	case CodeDailyRefresh:
+		return s.DailyRefresh
+	default:
+		return true
+	}
+}
+
+// isCategoryEnabled checks the stored settings for non-critical codes.
+// Fails open: a storage error must not swallow notifications.
+func isCategoryEnabled(ctx context.Context, nk runtime.NakamaModule, userID string, code int) bool {
+	if !isGatedCode(code) {
+		return true
+	}
+	settings, err := GetSettings(ctx, nk, userID)
+	if err != nil {
+		return true
+	}
+	return settings.Allows(code)
+}
+
+func isGatedCode(code int) bool {
+	switch code {
+	case CodeToast, CodeSocial, CodeDailyRefresh:
+		return true
+	default:
+		return false
+	}
+}
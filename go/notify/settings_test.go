@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMutedCategoryIsSuppressed(t *testing.T) {
+	nk := newFakeNakama()
+	nk.put(t, StorageCollectionSettings, StorageKeySettings, "u1", Settings{Toasts: false, Social: true, DailyRefresh: false})
+	ctx := context.Background()
+
+	if err := SendToast(ctx, nk, "u1", "hello", ToastInfo, 0); err != nil {
+		t.Fatalf("SendToast: %v", err)
+	}
+	if err := SendDailyRefresh(ctx, nk, "u1", "refresh", nil); err != nil {
+		t.Fatalf("SendDailyRefresh: %v", err)
+	}
+	if n := len(nk.sent(CodeToast)); n != 0 {
+		t.Errorf("muted toasts sent %d notifications", n)
+	}
+	if n := len(nk.sent(CodeDailyRefresh)); n != 0 {
+		t.Errorf("muted daily refresh sent %d notifications", n)
+	}
+
+	if err := SendSocial(ctx, nk, "u1", "friend", nil, "u2", false); err != nil {
+		t.Fatalf("SendSocial: %v", err)
+	}
+	if n := len(nk.sent(CodeSocial)); n != 1 {
+		t.Errorf("enabled social sent %d notifications, want 1", n)
+	}
+}
+
+func TestCriticalCategoryIgnoresSettings(t *testing.T) {
+	nk := newFakeNakama()
+	nk.put(t, StorageCollectionSettings, StorageKeySettings, "u1", Settings{})
+	ctx := context.Background()
+
+	p := NewRewardPayload("match")
+	p.Wallet = &WalletDelta{Gold: 5}
+	if err := SendReward(ctx, nk, "u1", p); err != nil {
+		t.Fatalf("SendReward: %v", err)
+	}
+	if err := SendAnnouncement(ctx, nk, "u1", "maintenance", "soon"); err != nil {
+		t.Fatalf("SendAnnouncement: %v", err)
+	}
+	if n := len(nk.sent(CodeReward)); n != 1 {
+		t.Errorf("reward sent %d notifications with everything muted, want 1", n)
+	}
+	if n := len(nk.sent(CodeAnnouncement)); n != 1 {
+		t.Errorf("announcement sent %d notifications with everything muted, want 1", n)
+	}
+}
+
+func TestMissingSettingsDefaultToEnabled(t *testing.T) {
+	nk := newFakeNakama()
+	// Stored object predates the daily_refresh field: it must stay enabled.
+	nk.put(t, StorageCollectionSettings, StorageKeySettings, "u1", map[string]bool{"toasts": false})
+
+	settings, err := GetSettings(context.Background(), nk, "u1")
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.Toasts || !settings.Social || !settings.DailyRefresh {
+		t.Errorf("settings = %+v, want only toasts muted", settings)
+	}
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/heroiclabs/nakama-common/api"
+	"github.com/heroiclabs/nakama-common/runtime"
+	"google.golang.org/protobuf/proto"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(string, ...interface{})                       {}
+func (testLogger) Info(string, ...interface{})                        {}
+func (testLogger) Warn(string, ...interface{})                        {}
+func (testLogger) Error(string, ...interface{})                       {}
+func (l testLogger) WithField(string, interface{}) runtime.Logger     { return l }
+func (l testLogger) WithFields(map[string]interface{}) runtime.Logger { return l }
+func (testLogger) Fields() map[string]interface{}                     { return nil }
+
+type storageID struct {
+	collection, key, userID string
+}
+
+type testPresence struct {
+	runtime.PresenceMeta
+	userID, sessionID string
+}
+
+func (p testPresence) GetUserId() string    { return p.userID }
+func (p testPresence) GetSessionId() string { return p.sessionID }
+func (p testPresence) GetNodeId() string    { return "node" }
+
+// fakeNakama covers the storage, stream, notification and session calls made by this package.
+type fakeNakama struct {
+	runtime.NakamaModule
+
+	mu            sync.Mutex
+	seq           int
+	storage       map[storageID]*api.StorageObject
+	presences     []runtime.Presence
+	notifications []*runtime.NotificationSend
+	disconnected  []string
+}
+
+func newFakeNakama() *fakeNakama {
+	return &fakeNakama{storage: make(map[storageID]*api.StorageObject)}
+}
+
+func (f *fakeNakama) StorageRead(ctx context.Context, reads []*runtime.StorageRead) ([]*api.StorageObject, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*api.StorageObject
+	for _, r := range reads {
+		if obj, ok := f.storage[storageID{r.Collection, r.Key, r.UserID}]; ok {
+			out = append(out, proto.Clone(obj).(*api.StorageObject))
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeNakama) StorageWrite(ctx context.Context, writes []*runtime.StorageWrite) ([]*api.StorageObjectAck, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var acks []*api.StorageObjectAck
+	for _, w := range writes {
+		f.seq++
+		obj := &api.StorageObject{Collection: w.Collection, Key: w.Key, UserId: w.UserID, Value: w.Value, Version: strconv.Itoa(f.seq)}
+		f.storage[storageID{w.Collection, w.Key, w.UserID}] = obj
+		acks = append(acks, &api.StorageObjectAck{Collection: w.Collection, Key: w.Key, UserId: w.UserID, Version: obj.Version})
+	}
+	return acks, nil
+}
+
+func (f *fakeNakama) StorageDelete(ctx context.Context, deletes []*runtime.StorageDelete) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, d := range deletes {
+		id := storageID{d.Collection, d.Key, d.UserID}
+		if obj, ok := f.storage[id]; ok && d.Version != "" && obj.Version != d.Version {
+			return runtime.ErrStorageRejectedVersion
+		}
+		delete(f.storage, id)
+	}
+	return nil
+}
+
+func (f *fakeNakama) StreamUserList(mode uint8, subject, subcontext, label string, includeHidden, includeNotHidden bool) ([]runtime.Presence, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.presences, nil
+}
+
+func (f *fakeNakama) NotificationsSend(ctx context.Context, notifications []*runtime.NotificationSend) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.notifications = append(f.notifications, notifications...)
+	return nil
+}
+
+func (f *fakeNakama) NotificationSend(ctx context.Context, userID, subject string, content map[string]interface{}, code int, sender string, persistent bool) error {
+	return f.NotificationsSend(ctx, []*runtime.NotificationSend{{UserID: userID, Subject: subject, Content: content, Code: code, Sender: sender, Persistent: persistent}})
+}
+
+func (f *fakeNakama) SessionDisconnect(ctx context.Context, sessionID string, reason ...runtime.PresenceReason) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.disconnected = append(f.disconnected, sessionID)
+	return nil
+}
+
+func (f *fakeNakama) sent(code int) []*runtime.NotificationSend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []*runtime.NotificationSend
+	for _, n := range f.notifications {
+		if n.Code == code {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -1,9 +1,19 @@
-// Package session handles session-start grants and single-device enforcement.
+// Package session handles session lifecycle events.
+//
+// Registered events:
+//   - SessionStart: stamps the last-active marker, sends a daily refresh notice on the
+//     first session of a UTC day, re-sends unacknowledged rewards, records the active
+//     device and kicks any older session (CodeDevice). Progression is verified at login
+//     instead, once per UTC day (items.InitializeUser).
+//   - SessionEnd: stamps last_online_time_unix, updates the last-active marker,
+//     releases the active device record and clears an active_match lock that is
+//     stale or has no result submitted yet.
 package session
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"block-server/items"
@@ -15,13 +25,31 @@ import (
 
 const (
 	streamModeNotification = 0
+
+	storageCollectionSession = "session"
+	storageKeyActiveDevice   = "active_device"
+	storageKeyLastActive     = "last_active"
 )
 
+// LastActive is the per-user last-active marker, readable by the owner.
+type LastActive struct {
+	SessionStartedAt int64 `json:"session_started_at"`
+	SessionEndedAt   int64 `json:"session_ended_at,omitempty"`
+}
+
+// ActiveDevice records the session that currently owns the account.
+// Written on every session start; the previous owner is kicked with CodeDevice.
+type ActiveDevice struct {
+	SessionID  string `json:"session_id"`
+	InstanceID string `json:"instance_id,omitempty"`
+	StartedAt  int64  `json:"started_at"`
+}
+
 func RegisterSessionEvents(db *sql.DB, nk runtime.NakamaModule, initializer runtime.Initializer) error {
 	if err := initializer.RegisterEventSessionStart(eventSessionStartFunc(nk)); err != nil {
 		return err
 	}
-	if err := initializer.RegisterEventSessionEnd(eventSessionEndFunc(db)); err != nil {
+	if err := initializer.RegisterEventSessionEnd(eventSessionEndFunc(db, nk)); err != nil {
 		return err
 	}
 
@@ -29,7 +57,8 @@ func RegisterSessionEvents(db *sql.DB, nk runtime.NakamaModule, initializer runt
 }
 
 // eventSessionEndFunc stamps last_online_time_unix on disconnect. 1s deadline guards against reconnect storms.
-func eventSessionEndFunc(db *sql.DB) func(context.Context, runtime.Logger, *api.Event) {
+// Also releases the active_device record if this session still owns it.
+func eventSessionEndFunc(db *sql.DB, nk runtime.NakamaModule) func(context.Context, runtime.Logger, *api.Event) {
 	return func(ctx context.Context, logger runtime.Logger, evt *api.Event) {
 		userID, ok := ctx.Value(runtime.RUNTIME_CTX_USER_ID).(string)
 		if !ok {
@@ -53,12 +82,136 @@ WHERE
 		_, err := db.ExecContext(ctx2, query, userID)
 		if err != nil && err != context.DeadlineExceeded {
 			logger.WithField("err", err).Error("db.ExecContext last online update error.")
-			return
 		}
+
+		// Storage cleanup gets its own deadline so a slow DB update can't starve it.
+		ctx3, cancel3 := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel3()
+
+		stampLastActive(ctx3, nk, logger, userID, false)
+
+		if sessionID, ok := ctx.Value(runtime.RUNTIME_CTX_SESSION_ID).(string); ok {
+			releaseActiveDevice(ctx3, nk, logger, userID, sessionID)
+		}
+
+		items.PruneAppliedRewards(ctx3, nk, logger, userID)
+
+		if cleared, err := items.ClearAbandonedActiveMatch(ctx3, nk, logger, userID); err != nil {
+			logger.WithField("err", err).Warn("abandoned active match cleanup error.")
+		} else if cleared {
+			logger.WithField("user", userID).Info("cleared abandoned active match on session end.")
+		}
+	}
+}
+
+// stampLastActive records session start/end times and returns the marker as it was before.
+// Blind write: last writer wins.
+func stampLastActive(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, start bool) LastActive {
+	var marker LastActive
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionSession,
+		Key:        storageKeyLastActive,
+		UserID:     userID,
+	}})
+	if err == nil && len(objects) > 0 {
+		json.Unmarshal([]byte(objects[0].Value), &marker)
+	}
+	previous := marker
+	now := time.Now().Unix()
+	if start {
+		marker.SessionStartedAt = now
+		marker.SessionEndedAt = 0
+	} else {
+		marker.SessionEndedAt = now
+	}
+	value, _ := json.Marshal(marker)
+	if _, err := nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionSession,
+		Key:             storageKeyLastActive,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  1,
+		PermissionWrite: 0,
+	}}); err != nil {
+		logger.WithField("err", err).Warn("last active marker write error.")
+	}
+	return previous
+}
+
+// sendDailyRefresh tells the client its daily quests, exchanges and first-win bonus reset
+// when this is the user's first session of the UTC day. Muted users are skipped by notify.
+func sendDailyRefresh(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID string, previous LastActive, now time.Time) {
+	midnight := now.UTC().Truncate(24 * time.Hour).Unix()
+	if previous.SessionStartedAt == 0 || previous.SessionStartedAt >= midnight {
+		return
+	}
+	content := map[string]interface{}{"reset_unix": midnight}
+	if err := notify.SendDailyRefresh(ctx, nk, userID, "Daily rewards refreshed", content); err != nil {
+		logger.WithField("err", err).Warn("daily refresh notification error.")
 	}
 }
 
-// eventSessionStartFunc verifies progression and kicks duplicate sessions.
+// readActiveDevice returns the stored device record and its storage version, or nil if none.
+func readActiveDevice(ctx context.Context, nk runtime.NakamaModule, userID string) (*ActiveDevice, string, error) {
+	objects, err := nk.StorageRead(ctx, []*runtime.StorageRead{{
+		Collection: storageCollectionSession,
+		Key:        storageKeyActiveDevice,
+		UserID:     userID,
+	}})
+	if err != nil || len(objects) == 0 {
+		return nil, "", err
+	}
+	var device ActiveDevice
+	if err := json.Unmarshal([]byte(objects[0].Value), &device); err != nil {
+		return nil, "", err
+	}
+	return &device, objects[0].Version, nil
+}
+
+// recordActiveDevice claims the account for this session, returning the previous owner (if any).
+func recordActiveDevice(ctx context.Context, nk runtime.NakamaModule, userID, sessionID, instanceID string) (*ActiveDevice, error) {
+	previous, _, err := readActiveDevice(ctx, nk, userID)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(ActiveDevice{
+		SessionID:  sessionID,
+		InstanceID: instanceID,
+		StartedAt:  time.Now().Unix(),
+	})
+	if err != nil {
+		return previous, err
+	}
+	// Last writer wins: the newest session always owns the account.
+	_, err = nk.StorageWrite(ctx, []*runtime.StorageWrite{{
+		Collection:      storageCollectionSession,
+		Key:             storageKeyActiveDevice,
+		UserID:          userID,
+		Value:           string(value),
+		PermissionRead:  0,
+		PermissionWrite: 0,
+	}})
+	return previous, err
+}
+
+// releaseActiveDevice deletes the record only if sessionID still owns it (OCC-guarded),
+// so a kicked session ending late can't erase the new owner.
+func releaseActiveDevice(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, sessionID string) {
+	device, version, err := readActiveDevice(ctx, nk, userID)
+	if err != nil || device == nil || device.SessionID != sessionID {
+		return
+	}
+	if err := nk.StorageDelete(ctx, []*runtime.StorageDelete{{
+		Collection: storageCollectionSession,
+		Key:        storageKeyActiveDevice,
+		UserID:     userID,
+		Version:    version,
+	}}); err != nil {
+		logger.WithField("err", err).Debug("active device release skipped.")
+	}
+}
+
+// eventSessionStartFunc stamps activity and kicks duplicate sessions.
 func eventSessionStartFunc(nk runtime.NakamaModule) func(context.Context, runtime.Logger, *api.Event) {
 	return func(ctx context.Context, logger runtime.Logger, evt *api.Event) {
 
@@ -71,11 +224,14 @@ func eventSessionStartFunc(nk runtime.NakamaModule) func(context.Context, runtim
 		// DO NOT add GiveAllItemsToUser here. Dev convenience only.
 		// New-user item grants belong in initialize_user.go, guarded by out.Created.
 
-		report, err := items.VerifyAndFixUserProgression(ctx, nk, logger, userID)
-		if err != nil {
-			logger.WithField("err", err).Error("progression verification failed")
-		} else if report.TotalFixed > 0 {
-			logger.WithField("report", report).Info("progression verification completed with repairs")
+		lastActive := stampLastActive(ctx, nk, logger, userID, true)
+		sendDailyRefresh(ctx, nk, logger, userID, lastActive, time.Now())
+
+		// Re-send rewards the client never acknowledged (e.g. it crashed mid-ceremony).
+		if resent, err := notify.ResendUnacknowledged(ctx, nk, userID); err != nil {
+			logger.WithField("err", err).Warn("unacknowledged reward resend error.")
+		} else if resent > 0 {
+			logger.WithField("count", resent).Info("re-sent unacknowledged rewards.")
 		}
 
 		sessionID, ok := ctx.Value(runtime.RUNTIME_CTX_SESSION_ID).(string)
@@ -92,47 +248,75 @@ func eventSessionStartFunc(nk runtime.NakamaModule) func(context.Context, runtim
 			instanceID = evt.Properties["instance_id"]
 		}
 
-		// Stream user's private notification channel so we can kick duplicate logins.
-		presences, err := nk.StreamUserList(streamModeNotification, userID, "", "", true, true)
-		if err != nil {
-			logger.WithField("err", err).Error("nk.StreamUserList error.")
-			return
-		}
+		enforceSingleDevice(ctx, nk, logger, userID, sessionID, instanceID)
+	}
+}
 
-		notifications := []*runtime.NotificationSend{
-			{
-				Code: notify.CodeDevice,
-				Content: map[string]interface{}{
-					"kicked_by":          sessionID,
-					"kicked_by_instance": instanceID,
-				},
-				Persistent: false,
-				Sender:     userID,
-				Subject:    "Another device is already in use.",
-				UserID:     userID,
+// enforceSingleDevice claims the account for sessionID and kicks every older session with a
+// CodeDevice notice: the recorded owner plus anything else on the user's notification stream.
+func enforceSingleDevice(ctx context.Context, nk runtime.NakamaModule, logger runtime.Logger, userID, sessionID, instanceID string) {
+	previous, err := recordActiveDevice(ctx, nk, userID, sessionID, instanceID)
+	if err != nil {
+		logger.WithField("err", err).Error("active device record error.")
+	}
+
+	// Stream user's private notification channel so we can kick duplicate logins.
+	presences, err := nk.StreamUserList(streamModeNotification, userID, "", "", true, true)
+	if err != nil {
+		logger.WithField("err", err).Error("nk.StreamUserList error.")
+		return
+	}
+
+	// The recorded owner may not be joined to the notification stream (e.g. mid-reconnect);
+	// kick it explicitly as well.
+	staleSessions := make(map[string]struct{}, len(presences)+1)
+	if previous != nil && previous.SessionID != "" && previous.SessionID != sessionID {
+		staleSessions[previous.SessionID] = struct{}{}
+	}
+
+	notifications := []*runtime.NotificationSend{
+		{
+			Code: notify.CodeDevice,
+			Content: map[string]interface{}{
+				"kicked_by":          sessionID,
+				"kicked_by_instance": instanceID,
 			},
+			Persistent: false,
+			Sender:     userID,
+			Subject:    "Another device is already in use.",
+			UserID:     userID,
+		},
+	}
+	for _, presence := range presences {
+		if presence.GetUserId() == userID && presence.GetSessionId() == sessionID {
+			// Skip our own connection.
+			continue
 		}
-		for _, presence := range presences {
-			if presence.GetUserId() == userID && presence.GetSessionId() == sessionID {
-				// Skip our own connection.
-				continue
-			}
+		staleSessions[presence.GetSessionId()] = struct{}{}
+	}
+	if len(staleSessions) == 0 {
+		return
+	}
 
-			// Wrap in a closure so the context cancel doesn't leak across the loop.
-			func() {
-				ctx2, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-				defer cancel()
-
-				if err := nk.NotificationsSend(ctx2, notifications); err != nil {
-					logger.WithField("err", err).Error("nk.NotificationsSend error.")
-					return
-				}
-
-				// Boot their older active session.
-				if err := nk.SessionDisconnect(ctx2, presence.GetSessionId()); err != nil {
-					logger.WithField("err", err).Error("nk.SessionDisconnect error.")
-				}
-			}()
+	// Notify once; the message reaches every session on the user's stream.
+	func() {
+		ctx2, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if err := nk.NotificationsSend(ctx2, notifications); err != nil {
+			logger.WithField("err", err).Error("nk.NotificationsSend error.")
 		}
+	}()
+
+	for staleSessionID := range staleSessions {
+		// Wrap in a closure so the context cancel doesn't leak across the loop.
+		func() {
+			ctx2, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			// Boot their older active session.
+			if err := nk.SessionDisconnect(ctx2, staleSessionID); err != nil {
+				logger.WithField("err", err).Warn("nk.SessionDisconnect error.")
+			}
+		}()
 	}
 }
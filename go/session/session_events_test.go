@@ -0,0 +1,65 @@
+package session
+
+import (
+	"context"
+	"testing"
+
+	"block-server/notify"
+
+	"github.com/heroiclabs/nakama-common/runtime"
+)
+
+func TestSecondDeviceTriggersEnforcement(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.Background()
+
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-a", "phone")
+	if n := len(nk.sent(notify.CodeDevice)); n != 0 {
+		t.Fatalf("first device sent %d enforcement notifications, want 0", n)
+	}
+
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-b", "tablet")
+	sent := nk.sent(notify.CodeDevice)
+	if len(sent) != 1 {
+		t.Fatalf("second device sent %d enforcement notifications, want 1", len(sent))
+	}
+	if got := sent[0].Content["kicked_by"]; got != "session-b" {
+		t.Errorf("kicked_by = %v, want session-b", got)
+	}
+	if len(nk.disconnected) != 1 || nk.disconnected[0] != "session-a" {
+		t.Errorf("disconnected = %v, want [session-a]", nk.disconnected)
+	}
+
+	device, _, err := readActiveDevice(ctx, nk, "u1")
+	if err != nil || device == nil || device.SessionID != "session-b" {
+		t.Errorf("active device = %+v (err %v), want session-b", device, err)
+	}
+}
+
+func TestReconnectOfSameSessionIsNotKicked(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.Background()
+	nk.presences = []runtime.Presence{testPresence{userID: "u1", sessionID: "session-a"}}
+
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-a", "phone")
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-a", "phone")
+	if n := len(nk.sent(notify.CodeDevice)); n != 0 {
+		t.Errorf("same session sent %d enforcement notifications, want 0", n)
+	}
+	if len(nk.disconnected) != 0 {
+		t.Errorf("same session disconnected %v", nk.disconnected)
+	}
+}
+
+func TestStaleSessionReleaseKeepsNewOwner(t *testing.T) {
+	nk := newFakeNakama()
+	ctx := context.Background()
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-a", "")
+	enforceSingleDevice(ctx, nk, testLogger{}, "u1", "session-b", "")
+
+	releaseActiveDevice(ctx, nk, testLogger{}, "u1", "session-a")
+	device, _, _ := readActiveDevice(ctx, nk, "u1")
+	if device == nil || device.SessionID != "session-b" {
+		t.Errorf("kicked session's release removed the new owner: %+v", device)
+	}
+}